@@ -0,0 +1,139 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"go.aporeto.io/bahamut"
+	"go.uber.org/zap"
+)
+
+// An Authenticator is a bahamut.RequestAuthenticator and
+// bahamut.SessionAuthenticator verifying API keys against a Store.
+//
+// The API key is read from the configured header, then from the configured
+// query parameter. If there is no API key, the Authenticator returns
+// bahamut.AuthActionContinue. If the API key is known, not expired and not
+// revoked, it sets the claims and returns bahamut.AuthActionOK. Otherwise,
+// it returns bahamut.AuthActionKO.
+//
+// The claims are @auth:realm=apikey, @auth:keyid=<id>
+// and the claims configured for the key.
+type Authenticator struct {
+	store Store
+	cfg   config
+}
+
+// NewAuthenticator returns a new *Authenticator using the given Store.
+func NewAuthenticator(store Store, options ...Option) *Authenticator {
+
+	cfg := newConfig()
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	return &Authenticator{
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// AuthenticateRequest authenticates the request from the given bahamut.Context.
+func (a *Authenticator) AuthenticateRequest(ctx bahamut.Context) (bahamut.AuthAction, error) {
+
+	req := ctx.Request()
+
+	var key string
+
+	if a.cfg.header != "" {
+		key = req.Headers.Get(a.cfg.header)
+	}
+
+	if key == "" && a.cfg.queryParameter != "" {
+		key = req.Parameters.Get(a.cfg.queryParameter).StringValue()
+	}
+
+	return a.authenticate(ctx.Context(), key, ctx.SetClaims)
+}
+
+// AuthenticateSession authenticates the given session.
+func (a *Authenticator) AuthenticateSession(session bahamut.Session) (bahamut.AuthAction, error) {
+
+	var key string
+
+	if a.cfg.header != "" {
+		key = session.Header(a.cfg.header)
+	}
+
+	if key == "" && a.cfg.queryParameter != "" {
+		key = session.Parameter(a.cfg.queryParameter)
+	}
+
+	return a.authenticate(session.Context(), key, session.SetClaims)
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, key string, claimSetter func([]string)) (bahamut.AuthAction, error) {
+
+	if key == "" {
+		return bahamut.AuthActionContinue, nil
+	}
+
+	id, _, ok := strings.Cut(key, ".")
+	if !ok || id == "" {
+		zap.L().Debug("Invalid API key format")
+		return bahamut.AuthActionKO, nil
+	}
+
+	k, err := a.store.Key(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			zap.L().Debug("Unknown API key", zap.String("id", id))
+			return bahamut.AuthActionKO, nil
+		}
+		return bahamut.AuthActionKO, err
+	}
+
+	expected, err := hex.DecodeString(k.Hash)
+	if err != nil {
+		return bahamut.AuthActionKO, err
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	if subtle.ConstantTimeCompare(hash[:], expected) != 1 {
+		zap.L().Debug("Invalid API key", zap.String("id", id))
+		return bahamut.AuthActionKO, nil
+	}
+
+	if k.Revoked {
+		zap.L().Debug("Revoked API key", zap.String("id", id))
+		return bahamut.AuthActionKO, nil
+	}
+
+	if !k.ExpiresAt.IsZero() && a.cfg.now().After(k.ExpiresAt) {
+		zap.L().Debug("Expired API key", zap.String("id", id))
+		return bahamut.AuthActionKO, nil
+	}
+
+	claims := make([]string, 0, len(k.Claims)+2)
+	claims = append(claims, "@auth:realm=apikey", "@auth:keyid="+id)
+	claims = append(claims, k.Claims...)
+
+	claimSetter(claims)
+
+	return bahamut.AuthActionOK, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+)
+
+type failingStore struct{}
+
+func (failingStore) Key(context.Context, string) (*Key, error) {
+	return nil, errors.New("boom")
+}
+
+func makeRequestContext(header string, parameter string) bahamut.Context {
+
+	req := elemental.NewRequest()
+	req.Headers = http.Header{}
+	req.Parameters = elemental.Parameters{}
+
+	if header != "" {
+		req.Headers.Set("X-API-Key", header)
+	}
+
+	if parameter != "" {
+		req.Parameters["api_key"] = elemental.NewParameter(elemental.ParameterTypeString, parameter)
+	}
+
+	return bahamut.NewContext(context.Background(), req)
+}
+
+func TestAuthenticator(t *testing.T) {
+
+	Convey("Given I have an authenticator with some keys", t, func() {
+
+		now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		validKey, validHash, _ := GenerateKey("valid")
+		expiredKey, expiredHash, _ := GenerateKey("expired")
+		revokedKey, revokedHash, _ := GenerateKey("revoked")
+
+		store, err := NewMemoryStore(
+			&Key{ID: "valid", Hash: validHash, Claims: []string{"@auth:role=ci"}, ExpiresAt: now.Add(time.Hour)},
+			&Key{ID: "expired", Hash: expiredHash, ExpiresAt: now.Add(-time.Hour)},
+			&Key{ID: "revoked", Hash: revokedHash, Revoked: true},
+		)
+		So(err, ShouldBeNil)
+
+		auth := NewAuthenticator(store, OptionQueryParameter("api_key"))
+		auth.cfg.now = func() time.Time { return now }
+
+		Convey("When I authenticate a request with a valid key in the header", func() {
+
+			ctx := makeRequestContext(validKey, "")
+			action, err := auth.AuthenticateRequest(ctx)
+
+			Convey("Then it should be authenticated", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+				So(ctx.Claims(), ShouldResemble, []string{"@auth:realm=apikey", "@auth:keyid=valid", "@auth:role=ci"})
+			})
+		})
+
+		Convey("When I authenticate a request with a valid key in the query parameter", func() {
+
+			ctx := makeRequestContext("", validKey)
+			action, err := auth.AuthenticateRequest(ctx)
+
+			Convey("Then it should be authenticated", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+			})
+		})
+
+		Convey("When I authenticate a request without key", func() {
+
+			action, err := auth.AuthenticateRequest(makeRequestContext("", ""))
+
+			Convey("Then it should continue", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionContinue)
+			})
+		})
+
+		Convey("When I disable the query parameter", func() {
+
+			auth := NewAuthenticator(store)
+			action, err := auth.AuthenticateRequest(makeRequestContext("", validKey))
+
+			Convey("Then it should continue", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionContinue)
+			})
+		})
+
+		Convey("When I authenticate requests with bad keys", func() {
+
+			for _, key := range []string{
+				"nodot",
+				".nope",
+				"unknown.secret",
+				"valid.wrongsecret",
+				validKey + "x",
+				expiredKey,
+				revokedKey,
+			} {
+				ctx := makeRequestContext(key, "")
+				action, err := auth.AuthenticateRequest(ctx)
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+				So(ctx.Claims(), ShouldBeEmpty)
+			}
+		})
+
+		Convey("When I revoke a key", func() {
+
+			So(store.Revoke("valid"), ShouldBeNil)
+			action, err := auth.AuthenticateRequest(makeRequestContext(validKey, ""))
+
+			Convey("Then it should not be authenticated", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+
+		Convey("When I authenticate a session with a key in the parameter", func() {
+
+			session := bahamut.NewMockSession()
+			session.MockParameters["api_key"] = validKey
+
+			action, err := auth.AuthenticateSession(session)
+
+			Convey("Then it should be authenticated", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+				So(session.Claims(), ShouldContain, "@auth:keyid=valid")
+			})
+		})
+
+		Convey("When I authenticate a session with a key in the header", func() {
+
+			session := bahamut.NewMockSession()
+			session.MockHeaders["X-API-Key"] = expiredKey
+
+			action, err := auth.AuthenticateSession(session)
+
+			Convey("Then it should not be authenticated", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+
+		Convey("When I authenticate a session without key", func() {
+
+			action, err := auth.AuthenticateSession(bahamut.NewMockSession())
+
+			Convey("Then it should continue", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionContinue)
+			})
+		})
+	})
+
+	Convey("Given I have an authenticator with a failing store", t, func() {
+
+		auth := NewAuthenticator(failingStore{})
+
+		Convey("When I authenticate a request", func() {
+
+			action, err := auth.AuthenticateRequest(makeRequestContext("id.secret", ""))
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+	})
+}
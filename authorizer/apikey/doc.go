@@ -0,0 +1,34 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apikey provides implementations of bahamut.RequestAuthenticator
+// and bahamut.SessionAuthenticator that verify static API keys against a
+// store of hashed keys.
+//
+// An API key has the form <id>.<secret>. The id is used to retrieve the
+// key from the Store, and the SHA-256 hash of the whole API key is compared
+// in constant time with the hash stored for that id. The store never holds
+// the API keys themselves. Use GenerateKey to create a new API key and
+// its hash.
+//
+// A file store reads the keys from a YAML or JSON file like:
+//
+//	keys:
+//	  - id: ci
+//	    hash: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	    claims:
+//	      - "@auth:role=ci"
+//	    expiresAt: 2030-01-01T00:00:00Z
+//
+//	  - id: legacy
+//	    hash: 60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752
+//	    revoked: true
+package apikey // import "go.aporeto.io/bahamut/authorizer/apikey"
@@ -0,0 +1,67 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"time"
+)
+
+type config struct {
+	header         string
+	queryParameter string
+	now            func() time.Time
+}
+
+func newConfig() config {
+	return config{
+		header: "X-API-Key",
+		now:    time.Now,
+	}
+}
+
+// An Option represents an option to the Authenticator.
+type Option func(*config)
+
+// OptionHeader sets the name of the header the API key is read from,
+// for requests and sessions. The default is X-API-Key. An empty name
+// disables reading the API key from a header.
+func OptionHeader(name string) Option {
+	return func(c *config) {
+		c.header = name
+	}
+}
+
+// OptionQueryParameter sets the name of the query parameter the API key
+// is read from when it is not found in the header, for requests and
+// sessions. If not set, query parameters are not used.
+//
+// Note that query parameters are often logged by proxies: only use
+// this when the clients cannot set headers.
+func OptionQueryParameter(name string) Option {
+	return func(c *config) {
+		c.queryParameter = name
+	}
+}
+
+// A FileStoreOption represents an option to the FileStore.
+type FileStoreOption func(*FileStore)
+
+// OptionFileStoreReloadInterval sets how often the key file
+// is checked for changes by Watch. The default is 10s.
+func OptionFileStoreReloadInterval(interval time.Duration) FileStoreOption {
+	return func(s *FileStore) {
+		s.interval = interval
+		if s.interval <= 0 {
+			panic("interval cannot be <= 0")
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"go.aporeto.io/bahamut/internal/filewatch"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrKeyNotFound is returned by a Store when no key has the given id.
+var ErrKeyNotFound = errors.New("key not found")
+
+// A Key is an API key as held by a Store.
+type Key struct {
+
+	// ID is the public identifier of the key.
+	// It cannot contain a dot.
+	ID string `yaml:"id" json:"id"`
+
+	// Hash is the hex encoded SHA-256 hash
+	// of the API key, as returned by HashKey.
+	Hash string `yaml:"hash" json:"hash"`
+
+	// Claims are the claims set when the key is used.
+	Claims []string `yaml:"claims,omitempty" json:"claims,omitempty"`
+
+	// ExpiresAt is the time after which the key is not
+	// valid anymore. A zero value means the key never expires.
+	ExpiresAt time.Time `yaml:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+
+	// Revoked indicates the key is not valid anymore.
+	Revoked bool `yaml:"revoked,omitempty" json:"revoked,omitempty"`
+}
+
+// Validate returns an error if the key is not valid.
+func (k *Key) Validate() error {
+
+	if k.ID == "" {
+		return errors.New("missing id")
+	}
+
+	if strings.Contains(k.ID, ".") {
+		return fmt.Errorf("invalid id '%s': must not contain a dot", k.ID)
+	}
+
+	if h, err := hex.DecodeString(k.Hash); err != nil || len(h) != sha256.Size {
+		return fmt.Errorf("invalid hash for key '%s': must be a hex encoded sha256 hash", k.ID)
+	}
+
+	return nil
+}
+
+func (k *Key) copy() *Key {
+
+	c := *k
+	c.Claims = append([]string(nil), k.Claims...)
+
+	return &c
+}
+
+// A Store holds the hashed API keys.
+type Store interface {
+
+	// Key returns the key with the given id,
+	// or ErrKeyNotFound if there is none.
+	Key(ctx context.Context, id string) (*Key, error)
+}
+
+// HashKey returns the hex encoded SHA-256 hash of the given API key.
+func HashKey(key string) string {
+
+	h := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(h[:])
+}
+
+// GenerateKey generates a new random API key with the given id.
+// It returns the API key, to give to the client, and its hash,
+// to put in the Store.
+func GenerateKey(id string) (key string, hash string, err error) {
+
+	if id == "" || strings.Contains(id, ".") {
+		return "", "", fmt.Errorf("invalid id '%s': must not be empty nor contain a dot", id)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("unable to generate secret: %w", err)
+	}
+
+	key = id + "." + base64.RawURLEncoding.EncodeToString(secret)
+
+	return key, HashKey(key), nil
+}
+
+// A MemoryStore is a Store holding the keys in memory.
+type MemoryStore struct {
+	keys map[string]*Key
+	lock sync.RWMutex
+}
+
+// NewMemoryStore returns a new *MemoryStore holding the given keys.
+func NewMemoryStore(keys ...*Key) (*MemoryStore, error) {
+
+	m, err := makeKeyMap(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MemoryStore{keys: m}, nil
+}
+
+// Key is part of the Store interface.
+func (s *MemoryStore) Key(_ context.Context, id string) (*Key, error) {
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return k.copy(), nil
+}
+
+// Set adds the given key, or replaces the key with the same id.
+func (s *MemoryStore) Set(key *Key) error {
+
+	if err := key.Validate(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	s.keys[key.ID] = key.copy()
+	s.lock.Unlock()
+
+	return nil
+}
+
+// Revoke revokes the key with the given id.
+func (s *MemoryStore) Revoke(id string) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	k = k.copy()
+	k.Revoked = true
+	s.keys[id] = k
+
+	return nil
+}
+
+// Delete removes the key with the given id.
+func (s *MemoryStore) Delete(id string) {
+
+	s.lock.Lock()
+	delete(s.keys, id)
+	s.lock.Unlock()
+}
+
+// A FileStore is a Store holding the keys defined in a YAML or
+// JSON file. See the package documentation for the format.
+type FileStore struct {
+	watcher  *filewatch.Watcher
+	path     string
+	keys     map[string]*Key
+	interval time.Duration
+	lock     sync.RWMutex
+}
+
+// NewFileStore returns a new *FileStore holding the keys defined in the
+// given file. The file is loaded immediately and an error is returned if
+// it is not valid. Call Watch to reload the keys when the file changes.
+func NewFileStore(path string, options ...FileStoreOption) (*FileStore, error) {
+
+	s := &FileStore{
+		path:     path,
+		interval: 10 * time.Second,
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	s.watcher = filewatch.New(s.load, path)
+
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Key is part of the Store interface.
+func (s *FileStore) Key(_ context.Context, id string) (*Key, error) {
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return k.copy(), nil
+}
+
+// Reload replaces the current keys with the ones of the file if it
+// changed, and returns true if it did. A file containing an invalid
+// or duplicated key, or no key at all, like an empty or truncated file
+// read while it is being written, is rejected as a whole and the
+// current keys are kept.
+func (s *FileStore) Reload() (bool, error) {
+
+	return s.watcher.Reload()
+}
+
+// Watch calls Reload at the interval set by OptionFileStoreReloadInterval
+// until the given context is canceled.
+func (s *FileStore) Watch(ctx context.Context) {
+
+	filewatch.Watch(ctx, s.interval, s.Reload, func(err error) {
+		zap.L().Error("Unable to reload API keys", zap.String("path", s.path), zap.Error(err))
+	})
+}
+
+func (s *FileStore) load(data [][]byte) error {
+
+	keys, err := parseKeyFile(data[0])
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return errors.New("key file has no keys")
+	}
+
+	s.lock.Lock()
+	s.keys = keys
+	s.lock.Unlock()
+
+	zap.L().Debug("API keys loaded", zap.String("path", s.path), zap.Int("keys", len(keys)))
+
+	return nil
+}
+
+func parseKeyFile(data []byte) (map[string]*Key, error) {
+
+	file := struct {
+		Keys []*Key `yaml:"keys"`
+	}{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to decode key file: %w", err)
+	}
+
+	return makeKeyMap(file.Keys)
+}
+
+func makeKeyMap(keys []*Key) (map[string]*Key, error) {
+
+	m := make(map[string]*Key, len(keys))
+
+	for i, k := range keys {
+
+		if k == nil {
+			return nil, fmt.Errorf("key %d is empty", i)
+		}
+
+		if err := k.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid key %d: %w", i, err)
+		}
+
+		if _, ok := m[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id '%s'", k.ID)
+		}
+
+		m[k.ID] = k.copy()
+	}
+
+	return m, nil
+}
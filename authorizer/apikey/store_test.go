@@ -0,0 +1,233 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apikey
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateKey(t *testing.T) {
+
+	Convey("When I generate a key", t, func() {
+
+		key, hash, err := GenerateKey("ci")
+
+		Convey("Then it should be correct", func() {
+			So(err, ShouldBeNil)
+			So(strings.HasPrefix(key, "ci."), ShouldBeTrue)
+			So(len(key), ShouldEqual, 46)
+			So(hash, ShouldEqual, HashKey(key))
+			So((&Key{ID: "ci", Hash: hash}).Validate(), ShouldBeNil)
+		})
+	})
+
+	Convey("When I generate a key with an invalid id", t, func() {
+
+		_, _, err1 := GenerateKey("")
+		_, _, err2 := GenerateKey("a.b")
+
+		Convey("Then err should not be nil", func() {
+			So(err1, ShouldNotBeNil)
+			So(err2, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+
+	Convey("Given I have a memory store", t, func() {
+
+		hash := HashKey("a.secret")
+
+		store, err := NewMemoryStore(&Key{ID: "a", Hash: hash, Claims: []string{"x=y"}})
+		So(err, ShouldBeNil)
+
+		Convey("When I retrieve a key and modify it", func() {
+
+			k, err := store.Key(context.Background(), "a")
+			So(err, ShouldBeNil)
+			k.Claims[0] = "changed"
+			k.Revoked = true
+
+			Convey("Then the stored key should not change", func() {
+				k, _ := store.Key(context.Background(), "a")
+				So(k.Claims, ShouldResemble, []string{"x=y"})
+				So(k.Revoked, ShouldBeFalse)
+			})
+		})
+
+		Convey("When I set, revoke and delete keys", func() {
+
+			So(store.Set(&Key{ID: "b", Hash: hash}), ShouldBeNil)
+			So(store.Set(&Key{ID: "c", Hash: "nothex"}), ShouldNotBeNil)
+			So(store.Revoke("b"), ShouldBeNil)
+			So(store.Revoke("nope"), ShouldEqual, ErrKeyNotFound)
+			store.Delete("a")
+
+			Convey("Then the store should be updated", func() {
+				k, err := store.Key(context.Background(), "b")
+				So(err, ShouldBeNil)
+				So(k.Revoked, ShouldBeTrue)
+				_, err = store.Key(context.Background(), "a")
+				So(err, ShouldEqual, ErrKeyNotFound)
+			})
+		})
+	})
+
+	Convey("When I create a memory store with invalid keys", t, func() {
+
+		hash := HashKey("a.secret")
+
+		for _, tc := range []struct {
+			keys []*Key
+			msg  string
+		}{
+			{[]*Key{nil}, "key 0 is empty"},
+			{[]*Key{{Hash: hash}}, "invalid key 0: missing id"},
+			{[]*Key{{ID: "a.b", Hash: hash}}, "invalid key 0: invalid id 'a.b': must not contain a dot"},
+			{[]*Key{{ID: "a", Hash: hash[:10]}}, "invalid key 0: invalid hash for key 'a': must be a hex encoded sha256 hash"},
+			{[]*Key{{ID: "a", Hash: hash}, {ID: "a", Hash: hash}}, "duplicate key id 'a'"},
+		} {
+			_, err := NewMemoryStore(tc.keys...)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, tc.msg)
+		}
+	})
+}
+
+func TestFileStore(t *testing.T) {
+
+	Convey("Given I have a file store", t, func() {
+
+		hash := HashKey("ci.secret")
+
+		path := filepath.Join(t.TempDir(), "keys.yaml")
+		So(os.WriteFile(path, []byte(`
+keys:
+  - id: ci
+    hash: `+hash+`
+    claims: ["@auth:role=ci"]
+    expiresAt: 2030-01-01T00:00:00Z
+`), 0600), ShouldBeNil)
+
+		store, err := NewFileStore(path, OptionFileStoreReloadInterval(10*time.Millisecond))
+		So(err, ShouldBeNil)
+
+		Convey("Then the keys should be loaded", func() {
+			k, err := store.Key(context.Background(), "ci")
+			So(err, ShouldBeNil)
+			So(k.Hash, ShouldEqual, hash)
+			So(k.Claims, ShouldResemble, []string{"@auth:role=ci"})
+			So(k.ExpiresAt, ShouldEqual, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+			_, err = store.Key(context.Background(), "nope")
+			So(err, ShouldEqual, ErrKeyNotFound)
+		})
+
+		Convey("When the file does not change", func() {
+
+			changed, err := store.Reload()
+
+			Convey("Then the keys should not be replaced", func() {
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the file is updated with invalid keys", func() {
+
+			So(os.WriteFile(path, []byte(`keys: [{id: ci, hash: nope}]`), 0600), ShouldBeNil)
+			changed, err := store.Reload()
+
+			Convey("Then the current keys should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(changed, ShouldBeFalse)
+				_, err := store.Key(context.Background(), "ci")
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When the file is emptied", func() {
+
+			So(os.WriteFile(path, nil, 0600), ShouldBeNil)
+			changed, err := store.Reload()
+
+			Convey("Then the current keys should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "key file has no keys")
+				So(changed, ShouldBeFalse)
+				_, err := store.Key(context.Background(), "ci")
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When the key is revoked in the file while watching", func() {
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				store.Watch(ctx)
+				close(done)
+			}()
+
+			So(os.WriteFile(path, []byte(`{"keys": [{"id": "ci", "hash": "`+hash+`", "revoked": true}]}`), 0600), ShouldBeNil)
+
+			var revoked bool
+			for i := 0; i < 100 && !revoked; i++ {
+				k, _ := store.Key(context.Background(), "ci")
+				if revoked = k.Revoked; !revoked {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+
+			cancel()
+			<-done
+
+			Convey("Then the key should be revoked", func() {
+				So(revoked, ShouldBeTrue)
+			})
+		})
+
+		Convey("When I create a file store with an unknown field", func() {
+
+			So(os.WriteFile(path, []byte(`keys: [{id: ci, hash: `+hash+`, nope: 1}]`), 0600), ShouldBeNil)
+			_, err := NewFileStore(path)
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When I create a file store with a missing file", func() {
+
+			_, err := NewFileStore(path + ".nope")
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When I create a file store with an invalid reload interval", func() {
+
+			Convey("Then it should panic", func() {
+				So(func() { _, _ = NewFileStore(path, OptionFileStoreReloadInterval(0)) }, ShouldPanicWith, "interval cannot be <= 0")
+			})
+		})
+	})
+}
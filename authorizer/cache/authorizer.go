@@ -0,0 +1,255 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/bahamut/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// An Invalidation is the payload of the publications
+// sent by PublishInvalidation.
+type Invalidation struct {
+
+	// Namespace restricts the invalidation to the decisions made in
+	// this namespace and its children. If empty, all the decisions
+	// are invalidated.
+	Namespace string `msgpack:"namespace,omitempty" json:"namespace,omitempty"`
+}
+
+// PublishInvalidation publishes an Invalidation on the given topic. All
+// the Authorizers listening on that topic will invalidate the decisions
+// made in the given namespace and its children, or all their decisions
+// if namespace is empty.
+func PublishInvalidation(pubsub bahamut.PubSubClient, topic string, namespace string) error {
+
+	pub := bahamut.NewPublication(topic)
+	if err := pub.Encode(&Invalidation{Namespace: namespace}); err != nil {
+		return fmt.Errorf("unable to encode invalidation: %w", err)
+	}
+
+	return pubsub.Publish(pub)
+}
+
+type entry struct {
+	action    bahamut.AuthAction
+	namespace string
+	expires   time.Time
+}
+
+// An Authorizer is a bahamut.Authorizer caching the decisions
+// of another bahamut.Authorizer.
+//
+// Errors returned by the wrapped Authorizer are never cached.
+type Authorizer struct {
+	authorizer  bahamut.Authorizer
+	registerer  prometheus.Registerer
+	cacheMetric *prometheus.CounterVec
+	cache       map[[sha256.Size]byte]entry
+	now         func() time.Time
+	ttl         time.Duration
+	maxSize     int
+	generation  uint64
+	lock        sync.Mutex
+}
+
+// NewAuthorizer returns a new *Authorizer caching the decisions
+// of the given bahamut.Authorizer. The given bahamut.Authorizer
+// must not look at the headers, the parameters or the data of the
+// requests, as they are not part of the cache key: a decision would
+// be reused for requests it would have decided differently.
+func NewAuthorizer(authorizer bahamut.Authorizer, options ...Option) (*Authorizer, error) {
+
+	a := &Authorizer{
+		authorizer: authorizer,
+		registerer: prometheus.DefaultRegisterer,
+		now:        time.Now,
+		ttl:        time.Minute,
+		maxSize:    10000,
+	}
+
+	for _, opt := range options {
+		opt(a)
+	}
+
+	if a.registerer != nil {
+
+		var err error
+
+		if a.cacheMetric, err = metrics.RegisterCollector(a.registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "authorizer_cache_requests_total",
+				Help: "The total number of authorization decisions looked up in the cache.",
+			},
+			[]string{"result"},
+		)); err != nil {
+			return nil, fmt.Errorf("unable to register authorizer cache metric: %w", err)
+		}
+	}
+
+	return a, nil
+}
+
+// IsAuthorized is part of the bahamut.Authorizer interface.
+func (a *Authorizer) IsAuthorized(ctx bahamut.Context) (bahamut.AuthAction, error) {
+
+	req := ctx.Request()
+	key := cacheKey(ctx)
+	now := a.now()
+
+	a.lock.Lock()
+	e, ok := a.cache[key]
+	generation := a.generation
+	a.lock.Unlock()
+
+	if ok && now.Before(e.expires) {
+		a.observe("hit")
+		return e.action, nil
+	}
+
+	a.observe("miss")
+
+	action, err := a.authorizer.IsAuthorized(ctx)
+	if err != nil {
+		return action, err
+	}
+
+	// The decision is not cached if the cache
+	// has been invalidated in the meantime.
+	a.lock.Lock()
+	if a.maxSize > 0 && generation == a.generation {
+		if a.cache == nil || len(a.cache) >= a.maxSize {
+			a.cache = make(map[[sha256.Size]byte]entry, a.maxSize)
+		}
+		a.cache[key] = entry{
+			action:    action,
+			namespace: req.Namespace,
+			expires:   now.Add(a.ttl),
+		}
+	}
+	a.lock.Unlock()
+
+	return action, nil
+}
+
+// Invalidate removes all the cached decisions.
+func (a *Authorizer) Invalidate() {
+
+	a.lock.Lock()
+	a.cache = nil
+	a.generation++
+	a.lock.Unlock()
+}
+
+// InvalidateNamespace removes the decisions cached
+// for the given namespace and its children.
+func (a *Authorizer) InvalidateNamespace(namespace string) {
+
+	prefix := strings.TrimSuffix(namespace, "/")
+	if prefix == "" {
+		a.Invalidate()
+		return
+	}
+
+	a.lock.Lock()
+	for key, e := range a.cache {
+		if e.namespace == prefix || strings.HasPrefix(e.namespace, prefix+"/") {
+			delete(a.cache, key)
+		}
+	}
+	a.generation++
+	a.lock.Unlock()
+}
+
+// Listen invalidates the cache when an Invalidation is published on
+// the given topic, until the given context is canceled. Publications
+// that cannot be decoded invalidate the whole cache.
+func (a *Authorizer) Listen(ctx context.Context, pubsub bahamut.PubSubClient, topic string) {
+
+	pubs := make(chan *bahamut.Publication)
+	errs := make(chan error)
+
+	unsubscribe := pubsub.Subscribe(pubs, errs, topic)
+	defer unsubscribe()
+
+	for {
+		select {
+
+		case pub := <-pubs:
+			inv := &Invalidation{}
+			if err := pub.Decode(inv); err != nil {
+				zap.L().Warn("Unable to decode authorizer cache invalidation", zap.String("topic", topic), zap.Error(err))
+				a.Invalidate()
+				continue
+			}
+			a.InvalidateNamespace(inv.Namespace)
+
+		case err := <-errs:
+			zap.L().Error("Error while listening for authorizer cache invalidations", zap.String("topic", topic), zap.Error(err))
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *Authorizer) observe(result string) {
+
+	if a.cacheMetric != nil {
+		a.cacheMetric.WithLabelValues(result).Inc()
+	}
+}
+
+// cacheKey returns the key of the decision for the given context.
+// The claims are sorted so their order does not matter. See
+// NewAuthorizer for the parts of the request that are ignored.
+func cacheKey(ctx bahamut.Context) [sha256.Size]byte {
+
+	req := ctx.Request()
+
+	claims := append([]string(nil), ctx.Claims()...)
+	sort.Strings(claims)
+
+	h := sha256.New()
+	for _, c := range claims {
+		h.Write([]byte(c))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{0})
+
+	for _, part := range []string{
+		req.Identity.Name,
+		req.ObjectID,
+		req.ParentIdentity.Name,
+		req.ParentID,
+		string(req.Operation),
+		req.Namespace,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	return key
+}
@@ -0,0 +1,242 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+)
+
+type countingAuthorizer struct {
+	action bahamut.AuthAction
+	err    error
+	calls  int
+}
+
+func (a *countingAuthorizer) IsAuthorized(bahamut.Context) (bahamut.AuthAction, error) {
+	a.calls++
+	return a.action, a.err
+}
+
+func makeContext(ns string, op elemental.Operation, claims ...string) bahamut.Context {
+
+	req := elemental.NewRequest()
+	req.Identity = elemental.Identity{Name: "list", Category: "lists"}
+	req.Operation = op
+	req.Namespace = ns
+
+	ctx := bahamut.NewContext(context.Background(), req)
+	ctx.SetClaims(claims)
+
+	return ctx
+}
+
+func TestAuthorizer(t *testing.T) {
+
+	Convey("Given I have a cached authorizer", t, func() {
+
+		now := time.Now()
+		wrapped := &countingAuthorizer{action: bahamut.AuthActionOK}
+		registry := prometheus.NewRegistry()
+
+		a, err := NewAuthorizer(wrapped, OptionTTL(time.Minute), OptionMetricsRegisterer(registry))
+		So(err, ShouldBeNil)
+		a.now = func() time.Time { return now }
+
+		Convey("When I authorize the same request twice", func() {
+
+			action1, err1 := a.IsAuthorized(makeContext("/a", elemental.OperationCreate, "a=a", "b=b"))
+			action2, err2 := a.IsAuthorized(makeContext("/a", elemental.OperationCreate, "b=b", "a=a"))
+
+			Convey("Then the second decision should come from the cache", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(action1, ShouldEqual, bahamut.AuthActionOK)
+				So(action2, ShouldEqual, bahamut.AuthActionOK)
+				So(wrapped.calls, ShouldEqual, 1)
+				So(testutil.ToFloat64(a.cacheMetric.WithLabelValues("hit")), ShouldEqual, 1)
+				So(testutil.ToFloat64(a.cacheMetric.WithLabelValues("miss")), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I authorize different requests", func() {
+
+			_, _ = a.IsAuthorized(makeContext("/a", elemental.OperationCreate, "a=a"))
+			_, _ = a.IsAuthorized(makeContext("/a", elemental.OperationCreate, "a=b"))
+			_, _ = a.IsAuthorized(makeContext("/a", elemental.OperationDelete, "a=a"))
+			_, _ = a.IsAuthorized(makeContext("/b", elemental.OperationCreate, "a=a"))
+
+			Convey("Then no decision should come from the cache", func() {
+				So(wrapped.calls, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When I authorize requests on different objects", func() {
+
+			for _, id := range []string{"x", "y"} {
+				ctx := makeContext("/a", elemental.OperationUpdate, "a=a")
+				ctx.Request().ObjectID = id
+				_, _ = a.IsAuthorized(ctx)
+			}
+
+			for _, id := range []string{"x", "y"} {
+				ctx := makeContext("/a", elemental.OperationRetrieveMany, "a=a")
+				ctx.Request().ParentID = id
+				_, _ = a.IsAuthorized(ctx)
+			}
+
+			Convey("Then no decision should come from the cache", func() {
+				So(wrapped.calls, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When the decision expires", func() {
+
+			_, _ = a.IsAuthorized(makeContext("/a", elemental.OperationCreate))
+			now = now.Add(time.Minute)
+			_, _ = a.IsAuthorized(makeContext("/a", elemental.OperationCreate))
+
+			Convey("Then the wrapped authorizer should be called again", func() {
+				So(wrapped.calls, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the wrapped authorizer returns an error", func() {
+
+			wrapped.err = errors.New("boom")
+			_, err1 := a.IsAuthorized(makeContext("/a", elemental.OperationCreate))
+			_, err2 := a.IsAuthorized(makeContext("/a", elemental.OperationCreate))
+
+			Convey("Then the error should not be cached", func() {
+				So(err1, ShouldNotBeNil)
+				So(err2, ShouldNotBeNil)
+				So(wrapped.calls, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I invalidate the cache", func() {
+
+			_, _ = a.IsAuthorized(makeContext("/a", elemental.OperationCreate))
+			a.Invalidate()
+			_, _ = a.IsAuthorized(makeContext("/a", elemental.OperationCreate))
+
+			Convey("Then the wrapped authorizer should be called again", func() {
+				So(wrapped.calls, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I invalidate a namespace", func() {
+
+			for _, ns := range []string{"/a", "/a/b", "/ab", "/c"} {
+				_, _ = a.IsAuthorized(makeContext(ns, elemental.OperationCreate))
+			}
+
+			a.InvalidateNamespace("/a")
+
+			for _, ns := range []string{"/a", "/a/b", "/ab", "/c"} {
+				_, _ = a.IsAuthorized(makeContext(ns, elemental.OperationCreate))
+			}
+
+			Convey("Then only the decisions of the namespace and its children should be invalidated", func() {
+				So(wrapped.calls, ShouldEqual, 6)
+			})
+		})
+
+		Convey("When the cache is full", func() {
+
+			a.maxSize = 2
+			for _, ns := range []string{"/a", "/b", "/c", "/a"} {
+				_, _ = a.IsAuthorized(makeContext(ns, elemental.OperationCreate))
+			}
+
+			Convey("Then the cache should be emptied", func() {
+				So(wrapped.calls, ShouldEqual, 4)
+				So(len(a.cache), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I create another cached authorizer with the same registerer", func() {
+
+			other, err := NewAuthorizer(wrapped, OptionMetricsRegisterer(registry))
+
+			Convey("Then they should share the metric", func() {
+				So(err, ShouldBeNil)
+				So(other.cacheMetric, ShouldEqual, a.cacheMetric)
+			})
+		})
+
+		Convey("When I listen for invalidations", func() {
+
+			pubsub := bahamut.NewLocalPubSubClient()
+			So(pubsub.Connect(context.Background()), ShouldBeNil)
+			defer pubsub.Disconnect() // nolint: errcheck
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				a.Listen(ctx, pubsub, "authz")
+				close(done)
+			}()
+
+			_, _ = a.IsAuthorized(makeContext("/a", elemental.OperationCreate))
+			_, _ = a.IsAuthorized(makeContext("/b", elemental.OperationCreate))
+
+			// Give time to the subscription to be registered.
+			time.Sleep(50 * time.Millisecond)
+			So(PublishInvalidation(pubsub, "authz", "/a"), ShouldBeNil)
+
+			invalidated := false
+			for i := 0; i < 100 && !invalidated; i++ {
+				a.lock.Lock()
+				invalidated = len(a.cache) == 1
+				a.lock.Unlock()
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			cancel()
+			<-done
+
+			Convey("Then the namespace should be invalidated", func() {
+				So(invalidated, ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given I have a cached authorizer without cache nor metric", t, func() {
+
+		wrapped := &countingAuthorizer{action: bahamut.AuthActionKO}
+
+		a, err := NewAuthorizer(wrapped, OptionMaxSize(0), OptionMetricsRegisterer(nil))
+		So(err, ShouldBeNil)
+
+		Convey("When I authorize the same request twice", func() {
+
+			_, _ = a.IsAuthorized(makeContext("/a", elemental.OperationCreate))
+			action, err := a.IsAuthorized(makeContext("/a", elemental.OperationCreate))
+
+			Convey("Then no decision should be cached", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+				So(wrapped.calls, ShouldEqual, 2)
+			})
+		})
+	})
+}
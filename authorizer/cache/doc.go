@@ -0,0 +1,23 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache provides a bahamut.Authorizer decorator memoizing the
+// decisions of an expensive bahamut.Authorizer for a limited time.
+//
+// The decisions are cached per claims, identity and object ID, parent identity
+// and parent ID, operation and namespace. The headers, the parameters and the
+// data of the requests are not part of the cache key, so the decorator is only
+// safe for the authorizers that do not look at them.
+//
+// The cache can be invalidated explicitly, or by publishing on a pubsub
+// topic with PublishInvalidation so all the instances of a service drop
+// their cached decisions at once.
+package cache // import "go.aporeto.io/bahamut/authorizer/cache"
@@ -0,0 +1,45 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// An Option represents an option to the Authorizer.
+type Option func(*Authorizer)
+
+// OptionTTL sets how long a decision is cached. The default is 1m.
+func OptionTTL(ttl time.Duration) Option {
+	return func(a *Authorizer) {
+		a.ttl = ttl
+	}
+}
+
+// OptionMaxSize sets the maximum number of decisions kept in the
+// cache. When the cache is full, it is emptied. The default is 10000.
+func OptionMaxSize(size int) Option {
+	return func(a *Authorizer) {
+		a.maxSize = size
+	}
+}
+
+// OptionMetricsRegisterer sets the prometheus.Registerer used to register
+// the hit and miss counter. The default is prometheus.DefaultRegisterer.
+// Passing nil disables the metric.
+func OptionMetricsRegisterer(registerer prometheus.Registerer) Option {
+	return func(a *Authorizer) {
+		a.registerer = registerer
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"go.aporeto.io/bahamut"
+	"go.uber.org/zap"
+)
+
+// An Authenticator is a bahamut.RequestAuthenticator and
+// bahamut.SessionAuthenticator verifying JSON Web Tokens.
+//
+// The token of a request is read from the bearer token of the
+// Authorization header, then from the configured cookie. The token of a
+// session is read from the session token, then from the configured cookie.
+// If there is no token, the Authenticator returns bahamut.AuthActionContinue.
+// If the token is valid, it sets the claims and returns bahamut.AuthActionOK.
+// Otherwise, it returns bahamut.AuthActionKO.
+type Authenticator struct {
+	keys   KeyProvider
+	parser *gojwt.Parser
+	cfg    config
+}
+
+// NewAuthenticator returns a new *Authenticator using the
+// given KeyProvider to verify the signature of the tokens.
+func NewAuthenticator(keys KeyProvider, options ...Option) *Authenticator {
+
+	cfg := newConfig()
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	parserOptions := []gojwt.ParserOption{
+		gojwt.WithValidMethods(cfg.validMethods),
+		gojwt.WithLeeway(cfg.leeway),
+		gojwt.WithExpirationRequired(),
+		gojwt.WithIssuedAt(),
+		gojwt.WithJSONNumber(),
+	}
+
+	if cfg.issuer != "" {
+		parserOptions = append(parserOptions, gojwt.WithIssuer(cfg.issuer))
+	}
+
+	if cfg.audience != "" {
+		parserOptions = append(parserOptions, gojwt.WithAudience(cfg.audience))
+	}
+
+	return &Authenticator{
+		keys:   keys,
+		parser: gojwt.NewParser(parserOptions...),
+		cfg:    cfg,
+	}
+}
+
+// AuthenticateRequest authenticates the request from the given bahamut.Context.
+func (a *Authenticator) AuthenticateRequest(ctx bahamut.Context) (bahamut.AuthAction, error) {
+
+	req := ctx.Request()
+
+	token := bearerToken(req.Headers.Get("Authorization"))
+	if token == "" && a.cfg.cookieName != "" {
+		for _, c := range req.Cookies {
+			if c.Name == a.cfg.cookieName {
+				token = c.Value
+				break
+			}
+		}
+	}
+
+	return a.authenticate(ctx.Context(), token, ctx.SetClaims)
+}
+
+// AuthenticateSession authenticates the given session.
+func (a *Authenticator) AuthenticateSession(session bahamut.Session) (bahamut.AuthAction, error) {
+
+	token := session.Token()
+	if token == "" && a.cfg.cookieName != "" {
+		if c, err := session.Cookie(a.cfg.cookieName); err == nil {
+			token = c.Value
+		}
+	}
+
+	return a.authenticate(session.Context(), token, session.SetClaims)
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, token string, claimSetter func([]string)) (bahamut.AuthAction, error) {
+
+	if token == "" {
+		return bahamut.AuthActionContinue, nil
+	}
+
+	fields := gojwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, fields, a.keyFunc(ctx)); err != nil {
+		zap.L().Debug("Invalid jwt", zap.Error(err))
+		return bahamut.AuthActionKO, nil
+	}
+
+	claimSetter(a.makeClaims(fields))
+
+	return bahamut.AuthActionOK, nil
+}
+
+func (a *Authenticator) keyFunc(ctx context.Context) gojwt.Keyfunc {
+
+	return func(token *gojwt.Token) (any, error) {
+
+		kid, _ := token.Header["kid"].(string)
+
+		keys, err := a.keys.Keys(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no key found for kid '%s'", kid)
+		}
+
+		set := gojwt.VerificationKeySet{Keys: make([]gojwt.VerificationKey, len(keys))}
+		for i, k := range keys {
+			set.Keys[i] = k
+		}
+
+		return set, nil
+	}
+}
+
+func (a *Authenticator) makeClaims(fields gojwt.MapClaims) []string {
+
+	claims := []string{"@auth:realm=jwt"}
+
+	paths := make([]string, 0, len(a.cfg.claimsMapping))
+	for path := range a.cfg.claimsMapping {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+
+		value, ok := lookupField(fields, path)
+		if !ok {
+			continue
+		}
+
+		key := a.cfg.claimsMapping[path]
+
+		values, isList := value.([]any)
+		if !isList {
+			values = []any{value}
+		}
+
+		for _, v := range values {
+			if s, err := formatField(v); err == nil {
+				claims = append(claims, key+"="+s)
+			}
+		}
+	}
+
+	return claims
+}
+
+func lookupField(fields map[string]any, path string) (any, bool) {
+
+	var current any = fields
+
+	for _, part := range strings.Split(path, ".") {
+
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func formatField(v any) (string, error) {
+
+	switch tv := v.(type) {
+	case string:
+		return tv, nil
+	case json.Number:
+		return tv.String(), nil
+	case bool:
+		return strconv.FormatBool(tv), nil
+	default:
+		return "", errors.New("unsupported field type")
+	}
+}
+
+func bearerToken(header string) string {
+
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,346 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+)
+
+func makeToken(method gojwt.SigningMethod, key any, kid string, fields gojwt.MapClaims) string {
+
+	t := gojwt.NewWithClaims(method, fields)
+	if kid != "" {
+		t.Header["kid"] = kid
+	}
+
+	s, err := t.SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+func makeRequestContext(header string, cookies ...*http.Cookie) bahamut.Context {
+
+	req := elemental.NewRequest()
+	req.Headers = http.Header{}
+	if header != "" {
+		req.Headers.Set("Authorization", header)
+	}
+	req.Cookies = cookies
+
+	return bahamut.NewContext(context.Background(), req)
+}
+
+func validFields() gojwt.MapClaims {
+	return gojwt.MapClaims{
+		"sub": "alice",
+		"iss": "https://issuer",
+		"aud": "api",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+}
+
+func TestAuthenticator_Algorithms(t *testing.T) {
+
+	Convey("Given I have keys for every supported algorithm", t, func() {
+
+		hmacKey := []byte("secret")
+		rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+		auth := NewAuthenticator(NewStaticKeyProvider(map[string]any{
+			"hs": hmacKey,
+			"rs": &rsaKey.PublicKey,
+			"es": &ecKey.PublicKey,
+			"ed": edPub,
+		}))
+
+		for _, tc := range []struct {
+			method gojwt.SigningMethod
+			key    any
+			kid    string
+		}{
+			{gojwt.SigningMethodHS256, hmacKey, "hs"},
+			{gojwt.SigningMethodRS256, rsaKey, "rs"},
+			{gojwt.SigningMethodPS384, rsaKey, "rs"},
+			{gojwt.SigningMethodES256, ecKey, "es"},
+			{gojwt.SigningMethodEdDSA, edKey, "ed"},
+			{gojwt.SigningMethodEdDSA, edKey, ""},
+		} {
+
+			ctx := makeRequestContext("Bearer " + makeToken(tc.method, tc.key, tc.kid, validFields()))
+			action, err := auth.AuthenticateRequest(ctx)
+
+			So(err, ShouldBeNil)
+			So(action, ShouldEqual, bahamut.AuthActionOK)
+			So(ctx.Claims(), ShouldResemble, []string{"@auth:realm=jwt", "@auth:issuer=https://issuer", "@auth:subject=alice"})
+		}
+
+		Convey("When I use a token signed with a key of the wrong kid", func() {
+
+			ctx := makeRequestContext("Bearer " + makeToken(gojwt.SigningMethodES256, ecKey, "rs", validFields()))
+			action, err := auth.AuthenticateRequest(ctx)
+
+			Convey("Then action should be KO", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+				So(ctx.Claims(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I use an HMAC token referencing an asymmetric kid", func() {
+
+			ctx := makeRequestContext("Bearer " + makeToken(gojwt.SigningMethodHS256, []byte("not-a-key"), "rs", validFields()))
+			action, _ := auth.AuthenticateRequest(ctx)
+
+			Convey("Then action should be KO", func() {
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+
+		Convey("When I use a method that is not allowed", func() {
+
+			auth := NewAuthenticator(NewStaticKeyProvider(map[string]any{"hs": hmacKey}), OptionValidMethods("RS256"))
+			ctx := makeRequestContext("Bearer " + makeToken(gojwt.SigningMethodHS256, hmacKey, "hs", validFields()))
+			action, _ := auth.AuthenticateRequest(ctx)
+
+			Convey("Then action should be KO", func() {
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+	})
+}
+
+func TestAuthenticator_Validation(t *testing.T) {
+
+	Convey("Given I have an authenticator checking issuer and audience", t, func() {
+
+		key := []byte("secret")
+		auth := NewAuthenticator(
+			NewStaticKeyProvider(map[string]any{"k": key}),
+			OptionIssuer("https://issuer"),
+			OptionAudience("api"),
+			OptionLeeway(time.Minute),
+		)
+
+		check := func(fields gojwt.MapClaims) bahamut.AuthAction {
+			action, err := auth.AuthenticateRequest(makeRequestContext("Bearer " + makeToken(gojwt.SigningMethodHS256, key, "k", fields)))
+			So(err, ShouldBeNil)
+			return action
+		}
+
+		Convey("Then a valid token should be accepted", func() {
+			So(check(validFields()), ShouldEqual, bahamut.AuthActionOK)
+		})
+
+		Convey("Then a token expired within the leeway should be accepted", func() {
+			f := validFields()
+			f["exp"] = time.Now().Add(-30 * time.Second).Unix()
+			So(check(f), ShouldEqual, bahamut.AuthActionOK)
+		})
+
+		Convey("Then an expired token should be rejected", func() {
+			f := validFields()
+			f["exp"] = time.Now().Add(-2 * time.Minute).Unix()
+			So(check(f), ShouldEqual, bahamut.AuthActionKO)
+		})
+
+		Convey("Then a token without expiration should be rejected", func() {
+			f := validFields()
+			delete(f, "exp")
+			So(check(f), ShouldEqual, bahamut.AuthActionKO)
+		})
+
+		Convey("Then a token not yet valid should be rejected", func() {
+			f := validFields()
+			f["nbf"] = time.Now().Add(2 * time.Minute).Unix()
+			So(check(f), ShouldEqual, bahamut.AuthActionKO)
+		})
+
+		Convey("Then a token not yet valid within the leeway should be accepted", func() {
+			f := validFields()
+			f["nbf"] = time.Now().Add(30 * time.Second).Unix()
+			So(check(f), ShouldEqual, bahamut.AuthActionOK)
+		})
+
+		Convey("Then a token with another issuer should be rejected", func() {
+			f := validFields()
+			f["iss"] = "https://other"
+			So(check(f), ShouldEqual, bahamut.AuthActionKO)
+		})
+
+		Convey("Then a token with another audience should be rejected", func() {
+			f := validFields()
+			f["aud"] = []string{"other"}
+			So(check(f), ShouldEqual, bahamut.AuthActionKO)
+		})
+
+		Convey("Then a token with the audience in a list should be accepted", func() {
+			f := validFields()
+			f["aud"] = []string{"other", "api"}
+			So(check(f), ShouldEqual, bahamut.AuthActionOK)
+		})
+
+		Convey("Then a malformed token should be rejected", func() {
+			action, err := auth.AuthenticateRequest(makeRequestContext("Bearer nope"))
+			So(err, ShouldBeNil)
+			So(action, ShouldEqual, bahamut.AuthActionKO)
+		})
+	})
+}
+
+func TestAuthenticator_TokenSources(t *testing.T) {
+
+	Convey("Given I have an authenticator reading cookies", t, func() {
+
+		key := []byte("secret")
+		token := makeToken(gojwt.SigningMethodHS256, key, "", validFields())
+		auth := NewAuthenticator(NewStaticKeyProvider(map[string]any{"k": key}), OptionCookie("x-token"))
+
+		Convey("When I authenticate a request without token", func() {
+
+			action, err := auth.AuthenticateRequest(makeRequestContext(""))
+
+			Convey("Then action should be Continue", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionContinue)
+			})
+		})
+
+		Convey("When I authenticate a request with a basic authorization", func() {
+
+			action, err := auth.AuthenticateRequest(makeRequestContext("Basic " + token))
+
+			Convey("Then action should be Continue", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionContinue)
+			})
+		})
+
+		Convey("When I authenticate a request with a lower case bearer", func() {
+
+			action, err := auth.AuthenticateRequest(makeRequestContext("bearer " + token))
+
+			Convey("Then action should be OK", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+			})
+		})
+
+		Convey("When I authenticate a request with the token in a cookie", func() {
+
+			action, err := auth.AuthenticateRequest(makeRequestContext("", &http.Cookie{Name: "x-token", Value: token}))
+
+			Convey("Then action should be OK", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+			})
+		})
+
+		Convey("When I authenticate a session with a token", func() {
+
+			session := bahamut.NewMockSession()
+			session.MockToken = token
+
+			action, err := auth.AuthenticateSession(session)
+
+			Convey("Then action should be OK", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+				So(session.Claims(), ShouldContain, "@auth:subject=alice")
+			})
+		})
+
+		Convey("When I authenticate a session with the token in a cookie", func() {
+
+			session := bahamut.NewMockSession()
+			session.MockCookies["x-token"] = &http.Cookie{Name: "x-token", Value: token}
+
+			action, err := auth.AuthenticateSession(session)
+
+			Convey("Then action should be OK", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+			})
+		})
+
+		Convey("When I authenticate a session without token", func() {
+
+			action, err := auth.AuthenticateSession(bahamut.NewMockSession())
+
+			Convey("Then action should be Continue", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionContinue)
+			})
+		})
+	})
+}
+
+func TestAuthenticator_ClaimsMapping(t *testing.T) {
+
+	Convey("Given I have an authenticator with a claims mapping", t, func() {
+
+		key := []byte("secret")
+		auth := NewAuthenticator(
+			NewStaticKeyProvider(map[string]any{"k": key}),
+			OptionClaimsMapping(map[string]string{
+				"email":              "@auth:email",
+				"realm_access.roles": "@auth:role",
+				"admin":              "@auth:admin",
+				"level":              "@auth:level",
+				"missing":            "@auth:missing",
+				"nested":             "@auth:nested",
+			}),
+		)
+
+		f := validFields()
+		f["email"] = "alice@example.com"
+		f["realm_access"] = map[string]any{"roles": []string{"reader", "writer"}}
+		f["admin"] = true
+		f["level"] = 12345678901
+		f["nested"] = map[string]any{"a": "b"}
+
+		ctx := makeRequestContext("Bearer " + makeToken(gojwt.SigningMethodHS256, key, "", f))
+		action, err := auth.AuthenticateRequest(ctx)
+
+		Convey("Then the claims should be mapped", func() {
+			So(err, ShouldBeNil)
+			So(action, ShouldEqual, bahamut.AuthActionOK)
+			So(ctx.Claims(), ShouldResemble, []string{
+				"@auth:realm=jwt",
+				"@auth:admin=true",
+				"@auth:email=alice@example.com",
+				"@auth:level=12345678901",
+				"@auth:role=reader",
+				"@auth:role=writer",
+			})
+		})
+	})
+}
@@ -0,0 +1,16 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jwt provides implementations of bahamut.RequestAuthenticator and
+// bahamut.SessionAuthenticator that verify JSON Web Tokens given as bearer
+// tokens, cookies or session tokens, using keys from a static set or from
+// a JSON Web Key Set.
+package jwt // import "go.aporeto.io/bahamut/authorizer/jwt"
@@ -0,0 +1,406 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const maxJWKSSize = 1 << 20
+
+// A KeyProvider provides the keys used to verify the signature of the tokens.
+//
+// Keys returns the candidate keys for the given key ID, which is empty if
+// the token does not have a kid header. The keys can be []byte for HMAC,
+// *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+type KeyProvider interface {
+	Keys(ctx context.Context, kid string) ([]any, error)
+}
+
+type staticKeyProvider struct {
+	keys map[string]any
+}
+
+// NewStaticKeyProvider returns a KeyProvider using the given keys, indexed
+// by key ID. Tokens with a kid header are verified with the key with the same
+// ID. Tokens without a kid header are verified with any of the keys.
+func NewStaticKeyProvider(keys map[string]any) KeyProvider {
+
+	return &staticKeyProvider{
+		keys: keys,
+	}
+}
+
+func (p *staticKeyProvider) Keys(_ context.Context, kid string) ([]any, error) {
+
+	return selectKeys(p.keys, kid), nil
+}
+
+// A JWKSOption represents an option to the JWKS KeyProvider.
+type JWKSOption func(*jwksKeyProvider)
+
+// JWKSOptHTTPClient sets the *http.Client used to download the key set
+// when the source is an URL. The default is a client with a 30s timeout.
+// The download is not bound to the requests waiting for it, so the given
+// client must have a timeout.
+func JWKSOptHTTPClient(client *http.Client) JWKSOption {
+	return func(p *jwksKeyProvider) {
+		p.client = client
+	}
+}
+
+// JWKSOptRefreshInterval sets how long the key set is cached before
+// being retrieved again. The default is 1h.
+func JWKSOptRefreshInterval(interval time.Duration) JWKSOption {
+	return func(p *jwksKeyProvider) {
+		p.refreshInterval = interval
+	}
+}
+
+// JWKSOptMinRefreshInterval sets the minimum time between two retrievals of
+// the key set. When a token references an unknown key ID, the key set is
+// retrieved again to handle key rotations, but never more often than this
+// interval. The default is 1m.
+func JWKSOptMinRefreshInterval(interval time.Duration) JWKSOption {
+	return func(p *jwksKeyProvider) {
+		p.minRefreshInterval = interval
+	}
+}
+
+type jwksKeyProvider struct {
+	keys               map[string]any
+	lastErr            error
+	client             *http.Client
+	refreshing         chan struct{}
+	lastAttempt        time.Time
+	lastRefresh        time.Time
+	source             string
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	lock               sync.Mutex
+}
+
+// NewJWKSKeyProvider returns a KeyProvider using the JSON Web Key Set
+// found at the given source, which can either be a file path or an http(s) URL.
+//
+// The key set is retrieved lazily and cached. If it cannot be refreshed,
+// the previous keys are kept. The key set is retrieved in the background,
+// once for all the concurrent callers, which only wait for it if they do
+// not have a key to use yet. The key set is never retrieved more often
+// than the minimum refresh interval, even after a failure. Keys that are
+// not signature keys or that are not supported are ignored.
+func NewJWKSKeyProvider(source string, options ...JWKSOption) KeyProvider {
+
+	p := &jwksKeyProvider{
+		source:             source,
+		client:             &http.Client{Timeout: 30 * time.Second},
+		refreshInterval:    time.Hour,
+		minRefreshInterval: time.Minute,
+	}
+
+	for _, opt := range options {
+		opt(p)
+	}
+
+	return p
+}
+
+func (p *jwksKeyProvider) Keys(ctx context.Context, kid string) ([]any, error) {
+
+	p.lock.Lock()
+
+	now := time.Now()
+
+	_, known := p.keys[kid]
+	expired := p.keys == nil || now.Sub(p.lastRefresh) >= p.refreshInterval
+	rotated := kid != "" && !known
+
+	var done chan struct{}
+
+	if (expired || rotated) && now.Sub(p.lastAttempt) >= p.minRefreshInterval {
+
+		if p.refreshing == nil {
+			p.refreshing = make(chan struct{})
+			go p.refresh(p.refreshing)
+		}
+
+		// We only wait for the refresh if we cannot
+		// verify the token with the current keys.
+		if p.keys == nil || rotated {
+			done = p.refreshing
+		}
+	}
+
+	p.lock.Unlock()
+
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.keys == nil {
+		if p.lastErr == nil {
+			return nil, ctx.Err()
+		}
+		return nil, p.lastErr
+	}
+
+	return selectKeys(p.keys, kid), nil
+}
+
+// refresh retrieves the key set and closes the given
+// channel once the cached keys have been updated.
+func (p *jwksKeyProvider) refresh(done chan struct{}) {
+
+	defer close(done)
+
+	keys, err := p.fetch(context.Background())
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+
+	p.lastAttempt = now
+	p.refreshing = nil
+
+	switch {
+	case err != nil && p.keys == nil:
+		p.lastErr = err
+	case err != nil:
+		zap.L().Warn("Unable to refresh JWKS, using cached keys", zap.String("source", p.source), zap.Error(err))
+	default:
+		p.keys = keys
+		p.lastRefresh = now
+		p.lastErr = nil
+	}
+}
+
+func (p *jwksKeyProvider) fetch(ctx context.Context) (map[string]any, error) {
+
+	var data []byte
+	var err error
+
+	if strings.HasPrefix(p.source, "http://") || strings.HasPrefix(p.source, "https://") {
+		data, err = p.download(ctx)
+	} else {
+		data, err = os.ReadFile(p.source)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve jwks: %w", err)
+	}
+
+	return parseJWKS(data)
+}
+
+func (p *jwksKeyProvider) download(ctx context.Context) ([]byte, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxJWKSSize {
+		return nil, fmt.Errorf("jwks is larger than %d bytes", maxJWKSSize)
+	}
+
+	return data, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func parseJWKS(data []byte) (map[string]any, error) {
+
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for i, jwk := range set.Keys {
+
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			zap.L().Debug("Ignoring unsupported jwk", zap.String("kid", jwk.Kid), zap.Error(err))
+			continue
+		}
+
+		// Keys without ID still need a unique
+		// entry to be usable by tokens without kid.
+		kid := jwk.Kid
+		if kid == "" {
+			kid = fmt.Sprintf("#%d", i)
+		}
+
+		keys[kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no usable key in jwks")
+	}
+
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+
+	switch k.Kty {
+
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+
+	case "oct":
+		key, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, err
+		}
+		return key, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, errors.New("empty integer")
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
+
+func selectKeys(keys map[string]any, kid string) []any {
+
+	if kid != "" {
+		if key, ok := keys[kid]; ok {
+			return []any{key}
+		}
+		return nil
+	}
+
+	out := make([]any, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, key)
+	}
+
+	return out
+}
@@ -0,0 +1,287 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func makeJWKS(keys ...map[string]string) []byte {
+
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32)))}
+}
+
+func TestParseJWKS(t *testing.T) {
+
+	Convey("Given I have a JWKS with various keys", t, func() {
+
+		rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+		data := makeJWKS(
+			rsaJWK("rsa", &rsaKey.PublicKey),
+			ecJWK("ec", &ecKey.PublicKey),
+			map[string]string{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPub)},
+			map[string]string{"kty": "oct", "kid": "hmac", "k": b64([]byte("secret"))},
+			map[string]string{"kty": "oct", "k": b64([]byte("nokid"))},
+			map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+			map[string]string{"kty": "EC", "kid": "bad-curve", "crv": "P-192", "x": "AA", "y": "AA"},
+			map[string]string{"kty": "EC", "kid": "off-curve", "crv": "P-256", "x": b64([]byte{1}), "y": b64([]byte{2})},
+			map[string]string{"kty": "unknown", "kid": "unknown"},
+		)
+
+		keys, err := parseJWKS(data)
+
+		Convey("Then the supported signature keys should be parsed", func() {
+			So(err, ShouldBeNil)
+			So(len(keys), ShouldEqual, 5)
+			So(keys["rsa"], ShouldResemble, &rsaKey.PublicKey)
+			So(keys["ec"].(*ecdsa.PublicKey).Equal(&ecKey.PublicKey), ShouldBeTrue)
+			So(keys["ed"], ShouldResemble, edPub)
+			So(keys["hmac"], ShouldResemble, []byte("secret"))
+			So(keys["#4"], ShouldResemble, []byte("nokid"))
+		})
+	})
+
+	Convey("Given I have invalid JWKS", t, func() {
+
+		_, err := parseJWKS([]byte("nope"))
+		So(err, ShouldNotBeNil)
+
+		_, err = parseJWKS(makeJWKS(map[string]string{"kty": "unknown"}))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "no usable key in jwks")
+	})
+}
+
+func TestJWKSKeyProvider(t *testing.T) {
+
+	Convey("Given I have a JWKS server", t, func() {
+
+		key1, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		key2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+		var hits int64
+		var fail atomic.Bool
+		jwks := atomic.Value{}
+		jwks.Store(makeJWKS(ecJWK("k1", &key1.PublicKey)))
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt64(&hits, 1)
+			if fail.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write(jwks.Load().([]byte))
+		}))
+		defer ts.Close()
+
+		Convey("When I retrieve keys several times", func() {
+
+			p := NewJWKSKeyProvider(ts.URL, JWKSOptHTTPClient(ts.Client()))
+
+			keys1, err1 := p.Keys(context.Background(), "k1")
+			keys2, err2 := p.Keys(context.Background(), "")
+
+			Convey("Then the key set should be cached", func() {
+				So(err1, ShouldBeNil)
+				So(err2, ShouldBeNil)
+				So(len(keys1), ShouldEqual, 1)
+				So(len(keys2), ShouldEqual, 1)
+				So(atomic.LoadInt64(&hits), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the keys are rotated", func() {
+
+			p := NewJWKSKeyProvider(ts.URL, JWKSOptMinRefreshInterval(0))
+			auth := NewAuthenticator(p)
+
+			_, err := p.Keys(context.Background(), "k1")
+			So(err, ShouldBeNil)
+
+			jwks.Store(makeJWKS(ecJWK("k1", &key1.PublicKey), ecJWK("k2", &key2.PublicKey)))
+
+			ctx := makeRequestContext("Bearer " + makeToken(gojwt.SigningMethodES256, key2, "k2", validFields()))
+			action, err := auth.AuthenticateRequest(ctx)
+
+			Convey("Then the new key should be retrieved", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+				So(atomic.LoadInt64(&hits), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When unknown keys are requested too often", func() {
+
+			p := NewJWKSKeyProvider(ts.URL, JWKSOptMinRefreshInterval(time.Hour))
+
+			_, _ = p.Keys(context.Background(), "k1")
+			keys, err := p.Keys(context.Background(), "nope")
+			_, _ = p.Keys(context.Background(), "nope")
+
+			Convey("Then the key set should not be retrieved again", func() {
+				So(err, ShouldBeNil)
+				So(keys, ShouldBeEmpty)
+				So(atomic.LoadInt64(&hits), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the key set expires and cannot be refreshed", func() {
+
+			p := NewJWKSKeyProvider(ts.URL, JWKSOptRefreshInterval(0), JWKSOptMinRefreshInterval(0))
+
+			_, err := p.Keys(context.Background(), "k1")
+			So(err, ShouldBeNil)
+
+			fail.Store(true)
+			keys, err := p.Keys(context.Background(), "k1")
+
+			// The expired key set is refreshed in the background.
+			for i := 0; i < 100 && atomic.LoadInt64(&hits) < 2; i++ {
+				time.Sleep(time.Millisecond)
+			}
+			keys2, err2 := p.Keys(context.Background(), "k1")
+
+			Convey("Then the cached keys should be used", func() {
+				So(err, ShouldBeNil)
+				So(len(keys), ShouldEqual, 1)
+				So(err2, ShouldBeNil)
+				So(len(keys2), ShouldEqual, 1)
+				So(atomic.LoadInt64(&hits), ShouldBeGreaterThanOrEqualTo, 2)
+			})
+		})
+
+		Convey("When the first caller cancels its request during the retrieval", func() {
+
+			release := make(chan struct{})
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt64(&hits, 1)
+				<-release
+				_, _ = w.Write(jwks.Load().([]byte))
+			}))
+			defer slow.Close()
+
+			p := NewJWKSKeyProvider(slow.URL, JWKSOptMinRefreshInterval(time.Hour))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err1 := p.Keys(ctx, "k1")
+
+			results := make(chan error, 5)
+			for i := 0; i < 5; i++ {
+				go func() {
+					_, err := p.Keys(context.Background(), "k1")
+					results <- err
+				}()
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+
+			var errs []error
+			for i := 0; i < 5; i++ {
+				errs = append(errs, <-results)
+			}
+
+			Convey("Then the retrieval should complete once for all the callers", func() {
+				So(err1, ShouldEqual, context.Canceled)
+				for _, err := range errs {
+					So(err, ShouldBeNil)
+				}
+				So(atomic.LoadInt64(&hits), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the key set cannot be retrieved at all", func() {
+
+			fail.Store(true)
+			p := NewJWKSKeyProvider(ts.URL, JWKSOptMinRefreshInterval(time.Hour))
+
+			_, err1 := p.Keys(context.Background(), "k1")
+			_, err2 := p.Keys(context.Background(), "k1")
+
+			Convey("Then I should get an error and the set should not be retrieved again", func() {
+				So(err1, ShouldNotBeNil)
+				So(err1.Error(), ShouldEqual, "unable to retrieve jwks: unexpected status code 500")
+				So(err2, ShouldResemble, err1)
+				So(atomic.LoadInt64(&hits), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given I have a JWKS file", t, func() {
+
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		So(os.WriteFile(path, makeJWKS(ecJWK("k1", &key.PublicKey)), 0600), ShouldBeNil)
+
+		auth := NewAuthenticator(NewJWKSKeyProvider(path))
+
+		Convey("When I authenticate a token signed with the key", func() {
+
+			action, err := auth.AuthenticateRequest(makeRequestContext("Bearer " + makeToken(gojwt.SigningMethodES256, key, "k1", validFields())))
+
+			Convey("Then action should be OK", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+			})
+		})
+
+		Convey("When the file does not exist", func() {
+
+			_, err := NewJWKSKeyProvider(path+".nope").Keys(context.Background(), "")
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"time"
+)
+
+type config struct {
+	claimsMapping map[string]string
+	issuer        string
+	audience      string
+	cookieName    string
+	validMethods  []string
+	leeway        time.Duration
+}
+
+func newConfig() config {
+	return config{
+		validMethods: []string{
+			"HS256", "HS384", "HS512",
+			"RS256", "RS384", "RS512",
+			"PS256", "PS384", "PS512",
+			"ES256", "ES384", "ES512",
+			"EdDSA",
+		},
+		claimsMapping: map[string]string{
+			"sub": "@auth:subject",
+			"iss": "@auth:issuer",
+		},
+	}
+}
+
+// An Option represents an option to the Authenticator.
+type Option func(*config)
+
+// OptionIssuer sets the issuer the tokens must have in their iss field.
+// If not set, the issuer is not checked.
+func OptionIssuer(issuer string) Option {
+	return func(c *config) {
+		c.issuer = issuer
+	}
+}
+
+// OptionAudience sets the audience the tokens must contain in their aud field.
+// If not set, the audience is not checked.
+func OptionAudience(audience string) Option {
+	return func(c *config) {
+		c.audience = audience
+	}
+}
+
+// OptionLeeway sets the clock skew tolerated when checking
+// the exp, nbf and iat fields of the tokens. The default is 0.
+func OptionLeeway(leeway time.Duration) Option {
+	return func(c *config) {
+		c.leeway = leeway
+	}
+}
+
+// OptionValidMethods sets the signing methods allowed for the tokens,
+// like HS256, RS256, ES256 or EdDSA. The default is all the HMAC, RSA,
+// RSA-PSS, ECDSA and EdDSA methods. Note that a method can only be used
+// with a key of the matching type.
+func OptionValidMethods(methods ...string) Option {
+	return func(c *config) {
+		c.validMethods = methods
+	}
+}
+
+// OptionCookie sets the name of the cookie the token is read from when
+// there is no bearer token in the Authorization header for requests,
+// or no token for sessions. If not set, cookies are not used.
+func OptionCookie(name string) Option {
+	return func(c *config) {
+		c.cookieName = name
+	}
+}
+
+// OptionClaimsMapping sets how the fields of the tokens are converted
+// to bahamut claims. The keys of the mapping are the token fields, which
+// can be nested using dots, like `realm_access.roles`, and the values are
+// the keys of the bahamut claims. A field holding a list creates one claim
+// per element. The default maps sub to @auth:subject and iss to @auth:issuer.
+//
+// The claim @auth:realm=jwt is always added.
+func OptionClaimsMapping(mapping map[string]string) Option {
+	return func(c *config) {
+		c.claimsMapping = mapping
+	}
+}
@@ -12,18 +12,27 @@
 package mtls
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"go.aporeto.io/bahamut"
 	"go.aporeto.io/elemental"
 )
 
-const tlsHeaderKey = "X-TLS-Client-Certificate"
+const (
+	tlsHeaderKey      = "X-TLS-Client-Certificate"
+	tlsHeaderVersion  = "2"
+	tlsHeaderChainKey = "Chain"
+	legacyPEMBegin    = "-----BEGIN CERTIFICATE-----"
+	legacyPEMEnd      = "-----END CERTIFICATE-----"
+)
 
 // CertificateCheckMode represents the mode to use to
 // check the certificate.
@@ -88,6 +97,8 @@ func CertificatesFromTLSState(state *tls.ConnectionState) (certs []*x509.Certifi
 }
 
 // CertificatesFromHeader retrieves the certificates from the http header `X-TLS-Client-Certificate`.
+// The header can either use the encoding produced by EncodeCertificatesHeader, or the legacy
+// encoding made of PEM certificates with their new lines replaced by spaces.
 func CertificatesFromHeader(headerData string) (certs []*x509.Certificate, err error) {
 
 	if headerData == "" {
@@ -98,10 +109,9 @@ func CertificatesFromHeader(headerData string) (certs []*x509.Certificate, err e
 }
 
 // VerifierFunc is the type of function you can pass to do custom
-// verification on the certificates, like checking against a certificate
-// revocation list. Note that CRL checking is not done by
-// Go when using x509.VerifyOptions. If you need need advanced CRL check
-// you need to implement it in a VerifierFunc.
+// verification on the certificates. Note that CRL checking is not done by
+// Go when using x509.VerifyOptions. Use OptionRevocationChecker to check
+// the verified chains against certificate revocation lists.
 type VerifierFunc func(*x509.Certificate) bool
 
 // DeciderFunc is the type of function to pass to decide
@@ -117,6 +127,8 @@ type mtlsVerifier struct {
 	ignoredIdentities    []elemental.Identity
 	verifyOptions        x509.VerifyOptions
 	certificateCheckMode CertificateCheckMode
+	revocationChecker    *RevocationChecker
+	claimsExtractor      ClaimsExtractor
 }
 
 func newMTLSVerifier(
@@ -125,14 +137,24 @@ func newMTLSVerifier(
 	ignoredIdentities []elemental.Identity,
 	verifier VerifierFunc,
 	certificateCheckMode CertificateCheckMode,
+	options ...Option,
 ) *mtlsVerifier {
 
+	cfg := config{
+		claimsExtractor: DefaultClaimsExtractor,
+	}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
 	return &mtlsVerifier{
 		verifyOptions:        verifyOptions,
 		ignoredIdentities:    ignoredIdentities,
 		deciderFunc:          deciderFunc,
 		verifier:             verifier,
 		certificateCheckMode: certificateCheckMode,
+		revocationChecker:    cfg.revocationChecker,
+		claimsExtractor:      cfg.claimsExtractor,
 	}
 }
 
@@ -148,9 +170,10 @@ func NewMTLSAuthorizer(
 	ignoredIdentities []elemental.Identity,
 	certVerifier VerifierFunc,
 	certificateCheckMode CertificateCheckMode,
+	options ...Option,
 ) bahamut.Authorizer {
 
-	return newMTLSVerifier(verifyOptions, deciderFunc, ignoredIdentities, certVerifier, certificateCheckMode)
+	return newMTLSVerifier(verifyOptions, deciderFunc, ignoredIdentities, certVerifier, certificateCheckMode, options...)
 }
 
 // NewMTLSRequestAuthenticator returns a new Authenticator that ensures the client certificate
@@ -164,9 +187,10 @@ func NewMTLSRequestAuthenticator(
 	deciderFunc DeciderFunc,
 	certVerifier VerifierFunc,
 	certificateCheckMode CertificateCheckMode,
+	options ...Option,
 ) bahamut.RequestAuthenticator {
 
-	return newMTLSVerifier(verifyOptions, deciderFunc, nil, certVerifier, certificateCheckMode)
+	return newMTLSVerifier(verifyOptions, deciderFunc, nil, certVerifier, certificateCheckMode, options...)
 }
 
 // NewMTLSSessionAuthenticator returns a new Authenticator that ensures the client certificate are
@@ -180,9 +204,10 @@ func NewMTLSSessionAuthenticator(
 	deciderFunc DeciderFunc,
 	certVerifier VerifierFunc,
 	certificateCheckMode CertificateCheckMode,
+	options ...Option,
 ) bahamut.SessionAuthenticator {
 
-	return newMTLSVerifier(verifyOptions, deciderFunc, nil, certVerifier, certificateCheckMode)
+	return newMTLSVerifier(verifyOptions, deciderFunc, nil, certVerifier, certificateCheckMode, options...)
 }
 
 func (a *mtlsVerifier) IsAuthorized(ctx bahamut.Context) (bahamut.AuthAction, error) {
@@ -218,12 +243,8 @@ func (a *mtlsVerifier) IsAuthorized(ctx bahamut.Context) (bahamut.AuthAction, er
 	}
 
 	// If we can verify, we return the success auth action.
-	for _, cert := range certs {
-		if _, err := cert.Verify(a.verifyOptions); err == nil {
-			if a.verifier == nil || a.verifier(cert) {
-				return a.deciderFunc(bahamut.AuthActionOK, ctx, nil), nil
-			}
-		}
+	if a.verify(certs) {
+		return a.deciderFunc(bahamut.AuthActionOK, ctx, nil), nil
 	}
 
 	// If we can't verify, we return the failure auth action.
@@ -275,44 +296,181 @@ func (a *mtlsVerifier) checkAction(tlsState *tls.ConnectionState, headerCert str
 	}
 
 	// If we can verify, we return the success auth action
-	for _, cert := range certs {
-		if _, err := cert.Verify(a.verifyOptions); err == nil {
-			if a.verifier == nil || a.verifier(cert) {
-				claimSetter(makeClaims(cert))
-				return bahamut.AuthActionOK, nil
-			}
-		}
+	if a.verify(certs) {
+		claimSetter(a.claimsExtractor(certs[0]))
+		return bahamut.AuthActionOK, nil
 	}
 
 	// If we can't verify, we return the failure auth action.
 	return bahamut.AuthActionKO, nil
 }
 
+// verify verifies the given chain of certificates, ordered from the
+// leaf to the last intermediate. Only the leaf is verified: the other
+// certificates are only used as intermediates to build the chains, so
+// presenting a CA certificate along with a revoked leaf does not work.
+func (a *mtlsVerifier) verify(certs []*x509.Certificate) bool {
+
+	if len(certs) == 0 {
+		return false
+	}
+
+	opts := a.verifyOptions
+	if len(certs) > 1 {
+
+		if opts.Intermediates != nil {
+			opts.Intermediates = opts.Intermediates.Clone()
+		} else {
+			opts.Intermediates = x509.NewCertPool()
+		}
+
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+
+	chains, err := certs[0].Verify(opts)
+	if err != nil {
+		return false
+	}
+
+	if a.revocationChecker != nil && !a.revocationChecker.Check(chains) {
+		return false
+	}
+
+	return a.verifier == nil || a.verifier(certs[0])
+}
+
+// EncodeCertificatesHeader encodes the given certificates, ordered from the
+// leaf to the last intermediate, to be used as the value of the header
+// `X-TLS-Client-Certificate`.
+//
+// The value uses the version 2 of the encoding, which has the form
+// `v=2;Chain=<url encoded PEM chain>`. The legacy encoding, which is a single
+// PEM certificate with its new lines replaced by spaces, is still supported
+// by the functions retrieving the certificates from the header.
+func EncodeCertificatesHeader(certs []*x509.Certificate) (string, error) {
+
+	if len(certs) == 0 {
+		return "", errors.New("no certificate provided")
+	}
+
+	var buf bytes.Buffer
+	for _, cert := range certs {
+
+		if cert == nil {
+			return "", errors.New("nil certificate provided")
+		}
+
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("v=%s;%s=%s", tlsHeaderVersion, tlsHeaderChainKey, url.QueryEscape(buf.String())), nil
+}
+
 func decodeCertHeader(header string) ([]*x509.Certificate, error) {
 
-	if len(header) < 54 {
-		return nil, errors.New("invalid certificate in header")
+	if strings.HasPrefix(header, "v=") {
+		return decodeVersionedCertHeader(header)
+	}
+
+	return decodeLegacyCertHeader(header)
+}
+
+func decodeVersionedCertHeader(header string) ([]*x509.Certificate, error) {
+
+	fields := map[string]string{}
+	for _, part := range strings.Split(header, ";") {
+
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid field '%s' in certificate header", part)
+		}
+
+		fields[k] = v
+	}
+
+	if v := fields["v"]; v != tlsHeaderVersion {
+		return nil, fmt.Errorf("unsupported certificate header version '%s'", v)
+	}
+
+	chain, ok := fields[tlsHeaderChainKey]
+	if !ok {
+		return nil, errors.New("no certificate chain in certificate header")
+	}
+
+	data, err := url.QueryUnescape(chain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate chain encoding in header: %w", err)
 	}
-	// TODO: support multiple of them.
-	header = fmt.Sprintf("-----BEGIN CERTIFICATE-----\n%s\n-----END CERTIFICATE-----", strings.Replace(header[28:len(header)-26], " ", "\n", -1))
 
 	var certs []*x509.Certificate
-	var pemBlock *pem.Block
-	rest := []byte(header)
+	var block *pem.Block
+	rest := []byte(data)
 
 	for {
-		pemBlock, rest = pem.Decode(rest)
-		if pemBlock == nil {
-			return nil, fmt.Errorf("no valid cert in '%s'", header)
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
 		}
-		cert, err := x509.ParseCertificate(pemBlock.Bytes)
+
+		cert, err := x509.ParseCertificate(block.Bytes)
 		if err != nil {
 			return nil, err
 		}
+
 		certs = append(certs, cert)
-		if len(rest) == 0 {
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no valid certificate in certificate header")
+	}
+
+	return certs, nil
+}
+
+// decodeLegacyCertHeader decodes PEM certificates whose
+// new lines have been replaced by spaces.
+func decodeLegacyCertHeader(header string) ([]*x509.Certificate, error) {
+
+	var certs []*x509.Certificate
+	rest := header
+
+	for {
+
+		_, after, found := strings.Cut(rest, legacyPEMBegin)
+		if !found {
 			break
 		}
+
+		body, after, found := strings.Cut(after, legacyPEMEnd)
+		if !found {
+			return nil, errors.New("invalid certificate in header: missing end marker")
+		}
+
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in header: %w", err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, cert)
+		rest = after
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no valid cert in '%s'", header)
 	}
 
 	return certs, nil
@@ -17,8 +17,10 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"net/http"
+	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	// nolint:revive // Allow dot imports for readability in tests
@@ -1525,6 +1527,17 @@ func Test_decodeCertHeader(t *testing.T) {
 	cblock, _ := pem.Decode(cdata)
 	cert, _ := x509.ParseCertificate(cblock.Bytes)
 
+	chainData, _ := os.ReadFile("./fixtures/ca-chain-a.pem")
+	var chain []*x509.Certificate
+	for rest := chainData; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		c, _ := x509.ParseCertificate(block.Bytes)
+		chain = append(chain, c)
+	}
+
 	type args struct {
 		header string
 	}
@@ -1542,6 +1555,70 @@ func Test_decodeCertHeader(t *testing.T) {
 			[]*x509.Certificate{cert},
 			false,
 		},
+		{
+			"valid legacy chain",
+			args{
+				strings.ReplaceAll(string(cdata)+string(chainData), "\n", " "),
+			},
+			append([]*x509.Certificate{cert}, chain...),
+			false,
+		},
+		{
+			"valid v2",
+			args{
+				"v=2;Chain=" + url.QueryEscape(string(cdata)),
+			},
+			[]*x509.Certificate{cert},
+			false,
+		},
+		{
+			"valid v2 chain",
+			args{
+				"v=2; Chain=" + url.QueryEscape(string(cdata)+string(chainData)),
+			},
+			append([]*x509.Certificate{cert}, chain...),
+			false,
+		},
+		{
+			"unsupported version",
+			args{
+				"v=3;Chain=" + url.QueryEscape(string(cdata)),
+			},
+			nil,
+			true,
+		},
+		{
+			"v2 without chain",
+			args{
+				"v=2;Cert=" + url.QueryEscape(string(cdata)),
+			},
+			nil,
+			true,
+		},
+		{
+			"v2 with invalid field",
+			args{
+				"v=2;nope",
+			},
+			nil,
+			true,
+		},
+		{
+			"v2 with invalid encoding",
+			args{
+				"v=2;Chain=%zz",
+			},
+			nil,
+			true,
+		},
+		{
+			"v2 with empty chain",
+			args{
+				"v=2;Chain=",
+			},
+			nil,
+			true,
+		},
 		{
 			"too small",
 			args{
@@ -1580,3 +1657,53 @@ func Test_decodeCertHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeCertificatesHeader(t *testing.T) {
+
+	Convey("Given I have a certificate chain", t, func() {
+
+		chainData, _ := os.ReadFile("./fixtures/ca-chain-a.pem")
+		userCertAData, _ := os.ReadFile("./fixtures/user-a-cert.pem")
+		certs, err := decodeCertHeader(strings.ReplaceAll(string(userCertAData)+string(chainData), "\n", " "))
+		So(err, ShouldBeNil)
+
+		Convey("When I encode it", func() {
+
+			header, err := EncodeCertificatesHeader(certs)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then the header should be versioned", func() {
+				So(header, ShouldStartWith, "v=2;Chain=-----BEGIN+CERTIFICATE-----%0A")
+			})
+
+			Convey("Then I should be able to decode it", func() {
+				decoded, err := CertificatesFromHeader(header)
+				So(err, ShouldBeNil)
+				So(decoded, ShouldResemble, certs)
+			})
+		})
+
+		Convey("When I encode no certificate", func() {
+
+			_, err := EncodeCertificatesHeader(nil)
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "no certificate provided")
+			})
+		})
+
+		Convey("When I encode a nil certificate", func() {
+
+			_, err := EncodeCertificatesHeader([]*x509.Certificate{certs[0], nil})
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "nil certificate provided")
+			})
+		})
+	})
+}
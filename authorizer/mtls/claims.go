@@ -0,0 +1,193 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// A ClaimsExtractor returns the claims to set in the bahamut.Context
+// or bahamut.Session for the given verified client certificate.
+type ClaimsExtractor func(cert *x509.Certificate) []string
+
+// DefaultClaimsExtractor is the ClaimsExtractor used by default.
+// It returns the realm, the mode, the serial number, the common name,
+// the organizations and the organizational units of the certificate.
+func DefaultClaimsExtractor(cert *x509.Certificate) []string {
+
+	return makeClaims(cert)
+}
+
+// CombineClaimsExtractors returns a ClaimsExtractor returning
+// the claims of all the given extractors, in order.
+func CombineClaimsExtractors(extractors ...ClaimsExtractor) ClaimsExtractor {
+
+	return func(cert *x509.Certificate) []string {
+
+		var claims []string
+		for _, e := range extractors {
+			claims = append(claims, e(cert)...)
+		}
+
+		return claims
+	}
+}
+
+// URIClaimsExtractor returns a claim @auth:uri for
+// each URI subject alternative name of the certificate.
+func URIClaimsExtractor(cert *x509.Certificate) []string {
+
+	claims := make([]string, 0, len(cert.URIs))
+	for _, u := range cert.URIs {
+		claims = append(claims, "@auth:uri="+u.String())
+	}
+
+	return claims
+}
+
+// DNSNamesClaimsExtractor returns a claim @auth:dnsname for
+// each DNS subject alternative name of the certificate.
+func DNSNamesClaimsExtractor(cert *x509.Certificate) []string {
+
+	claims := make([]string, 0, len(cert.DNSNames))
+	for _, n := range cert.DNSNames {
+		claims = append(claims, "@auth:dnsname="+n)
+	}
+
+	return claims
+}
+
+// EmailClaimsExtractor returns a claim @auth:email for
+// each email subject alternative name of the certificate.
+func EmailClaimsExtractor(cert *x509.Certificate) []string {
+
+	claims := make([]string, 0, len(cert.EmailAddresses))
+	for _, e := range cert.EmailAddresses {
+		claims = append(claims, "@auth:email="+e)
+	}
+
+	return claims
+}
+
+// IssuerClaimsExtractor returns the claims @auth:issuer, containing
+// the distinguished name of the issuer of the certificate, and
+// @auth:issuercommonname, containing its common name.
+func IssuerClaimsExtractor(cert *x509.Certificate) []string {
+
+	return []string{
+		"@auth:issuer=" + cert.Issuer.String(),
+		"@auth:issuercommonname=" + cert.Issuer.CommonName,
+	}
+}
+
+// FingerprintClaimsExtractor returns the claim @auth:fingerprint,
+// containing the hex encoded SHA-256 fingerprint of the certificate.
+func FingerprintClaimsExtractor(cert *x509.Certificate) []string {
+
+	sum := sha256.Sum256(cert.Raw)
+
+	return []string{"@auth:fingerprint=" + hex.EncodeToString(sum[:])}
+}
+
+// SPIFFEClaimsExtractor returns the claims @auth:spiffeid, @auth:spiffetrustdomain
+// and @auth:spiffepath if the certificate is a SPIFFE X509-SVID. As required by
+// the SPIFFE specification, the certificate must contain exactly one URI subject
+// alternative name, which must be a valid SPIFFE ID. Otherwise, no claim is returned.
+func SPIFFEClaimsExtractor(cert *x509.Certificate) []string {
+
+	if len(cert.URIs) != 1 {
+		return nil
+	}
+
+	trustDomain, path, err := parseSPIFFEID(cert.URIs[0])
+	if err != nil {
+		return nil
+	}
+
+	return []string{
+		"@auth:spiffeid=" + cert.URIs[0].String(),
+		"@auth:spiffetrustdomain=" + trustDomain,
+		"@auth:spiffepath=" + path,
+	}
+}
+
+// ExtensionClaimsExtractor returns a ClaimsExtractor returning the claim
+// @auth:<key> containing the value of the certificate extension with the given
+// OID. If the value is an ASN.1 string, it is used as is. Otherwise, the
+// hex encoded raw value is used. No claim is returned if the extension
+// is not present in the certificate.
+func ExtensionClaimsExtractor(key string, oid asn1.ObjectIdentifier) ClaimsExtractor {
+
+	return func(cert *x509.Certificate) []string {
+
+		for _, ext := range cert.Extensions {
+
+			if !ext.Id.Equal(oid) {
+				continue
+			}
+
+			var value string
+			if rest, err := asn1.Unmarshal(ext.Value, &value); err != nil || len(rest) > 0 {
+				value = hex.EncodeToString(ext.Value)
+			}
+
+			return []string{fmt.Sprintf("@auth:%s=%s", key, value)}
+		}
+
+		return nil
+	}
+}
+
+// parseSPIFFEID validates the given SPIFFE ID and returns its
+// trust domain and path.
+func parseSPIFFEID(u *url.URL) (trustDomain string, path string, err error) {
+
+	if u.Scheme != "spiffe" {
+		return "", "", errors.New("scheme must be spiffe")
+	}
+
+	if u.Host == "" {
+		return "", "", errors.New("trust domain must not be empty")
+	}
+
+	if u.User != nil || u.Port() != "" || u.RawQuery != "" || u.Fragment != "" || u.Opaque != "" {
+		return "", "", errors.New("spiffe id must not contain user info, port, query or fragment")
+	}
+
+	for _, c := range u.Host {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return "", "", fmt.Errorf("invalid character '%c' in trust domain", c)
+		}
+	}
+
+	if u.Path != "" {
+		for _, segment := range strings.Split(strings.TrimPrefix(u.Path, "/"), "/") {
+			if segment == "" || segment == "." || segment == ".." {
+				return "", "", fmt.Errorf("invalid path segment '%s'", segment)
+			}
+			for _, c := range segment {
+				if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+					return "", "", fmt.Errorf("invalid character '%c' in path", c)
+				}
+			}
+		}
+	}
+
+	return u.Host, u.Path, nil
+}
@@ -0,0 +1,260 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+)
+
+var (
+	testStringExtensionOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+	testRawExtensionOID    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 2}
+)
+
+func makeClaimsTestCert(uris ...string) *x509.Certificate {
+
+	signerCert := loadFixtureCert("ca-signer-a")
+	signerKey := loadFixtureKey("ca-signer-a")
+
+	var parsedURIs []*url.URL
+	for _, u := range uris {
+		pu, err := url.Parse(u)
+		if err != nil {
+			panic(err)
+		}
+		parsedURIs = append(parsedURIs, pu)
+	}
+
+	stringExt, _ := asn1.Marshal("hello")
+
+	der, err := x509.CreateCertificate(
+		rand.Reader,
+		&x509.Certificate{
+			SerialNumber:   big.NewInt(42),
+			Subject:        pkix.Name{CommonName: "claims", Organization: []string{"A"}},
+			NotBefore:      time.Now().Add(-time.Hour),
+			NotAfter:       time.Now().Add(time.Hour),
+			KeyUsage:       x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			DNSNames:       []string{"a.example.com", "b.example.com"},
+			EmailAddresses: []string{"user@example.com"},
+			URIs:           parsedURIs,
+			ExtraExtensions: []pkix.Extension{
+				{Id: testStringExtensionOID, Value: stringExt},
+				{Id: testRawExtensionOID, Value: []byte{0x01, 0x02}},
+			},
+		},
+		signerCert,
+		signerKey.Public(),
+		signerKey,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+
+	return cert
+}
+
+func TestClaimsExtractors(t *testing.T) {
+
+	Convey("Given I have a certificate with various attributes", t, func() {
+
+		cert := makeClaimsTestCert("spiffe://example.org/ns/default/sa/api")
+
+		Convey("Then DefaultClaimsExtractor should return the default claims", func() {
+			So(DefaultClaimsExtractor(cert), ShouldResemble, []string{
+				"@auth:realm=certificate",
+				"@auth:mode=internal",
+				"@auth:serialnumber=42",
+				"@auth:commonname=claims",
+				"@auth:organization=A",
+			})
+		})
+
+		Convey("Then URIClaimsExtractor should return the uris", func() {
+			So(URIClaimsExtractor(cert), ShouldResemble, []string{"@auth:uri=spiffe://example.org/ns/default/sa/api"})
+		})
+
+		Convey("Then DNSNamesClaimsExtractor should return the dns names", func() {
+			So(DNSNamesClaimsExtractor(cert), ShouldResemble, []string{"@auth:dnsname=a.example.com", "@auth:dnsname=b.example.com"})
+		})
+
+		Convey("Then EmailClaimsExtractor should return the emails", func() {
+			So(EmailClaimsExtractor(cert), ShouldResemble, []string{"@auth:email=user@example.com"})
+		})
+
+		Convey("Then IssuerClaimsExtractor should return the issuer", func() {
+			So(IssuerClaimsExtractor(cert), ShouldResemble, []string{"@auth:issuer=CN=signer-a", "@auth:issuercommonname=signer-a"})
+		})
+
+		Convey("Then FingerprintClaimsExtractor should return the fingerprint", func() {
+			sum := sha256.Sum256(cert.Raw)
+			So(FingerprintClaimsExtractor(cert), ShouldResemble, []string{"@auth:fingerprint=" + hex.EncodeToString(sum[:])})
+		})
+
+		Convey("Then SPIFFEClaimsExtractor should return the spiffe id", func() {
+			So(SPIFFEClaimsExtractor(cert), ShouldResemble, []string{
+				"@auth:spiffeid=spiffe://example.org/ns/default/sa/api",
+				"@auth:spiffetrustdomain=example.org",
+				"@auth:spiffepath=/ns/default/sa/api",
+			})
+		})
+
+		Convey("Then ExtensionClaimsExtractor should return the string extension value", func() {
+			So(ExtensionClaimsExtractor("custom", testStringExtensionOID)(cert), ShouldResemble, []string{"@auth:custom=hello"})
+		})
+
+		Convey("Then ExtensionClaimsExtractor should return the hex encoded raw extension value", func() {
+			So(ExtensionClaimsExtractor("raw", testRawExtensionOID)(cert), ShouldResemble, []string{"@auth:raw=0102"})
+		})
+
+		Convey("Then ExtensionClaimsExtractor should return nothing for a missing extension", func() {
+			So(ExtensionClaimsExtractor("nope", asn1.ObjectIdentifier{1, 2, 3})(cert), ShouldBeNil)
+		})
+
+		Convey("Then CombineClaimsExtractors should return all the claims in order", func() {
+			So(CombineClaimsExtractors(EmailClaimsExtractor, DNSNamesClaimsExtractor)(cert), ShouldResemble, []string{
+				"@auth:email=user@example.com",
+				"@auth:dnsname=a.example.com",
+				"@auth:dnsname=b.example.com",
+			})
+		})
+	})
+
+	Convey("Given I have a certificate with several uris", t, func() {
+
+		cert := makeClaimsTestCert("spiffe://example.org/a", "spiffe://example.org/b")
+
+		Convey("Then SPIFFEClaimsExtractor should return nothing", func() {
+			So(SPIFFEClaimsExtractor(cert), ShouldBeNil)
+		})
+	})
+
+	Convey("Given I have a certificate without uri", t, func() {
+
+		cert := makeClaimsTestCert()
+
+		Convey("Then SPIFFEClaimsExtractor should return nothing", func() {
+			So(SPIFFEClaimsExtractor(cert), ShouldBeNil)
+		})
+
+		Convey("Then URIClaimsExtractor should return nothing", func() {
+			So(URIClaimsExtractor(cert), ShouldBeEmpty)
+		})
+	})
+}
+
+func Test_parseSPIFFEID(t *testing.T) {
+
+	Convey("Given I have some spiffe ids", t, func() {
+
+		parse := func(s string) (string, string, error) {
+			u, err := url.Parse(s)
+			So(err, ShouldBeNil)
+			return parseSPIFFEID(u)
+		}
+
+		td, path, err := parse("spiffe://example.org/ns/default")
+		So(err, ShouldBeNil)
+		So(td, ShouldEqual, "example.org")
+		So(path, ShouldEqual, "/ns/default")
+
+		td, path, err = parse("spiffe://example.org")
+		So(err, ShouldBeNil)
+		So(td, ShouldEqual, "example.org")
+		So(path, ShouldEqual, "")
+
+		for _, invalid := range []string{
+			"https://example.org/a",
+			"spiffe:///a",
+			"spiffe://Example.org/a",
+			"spiffe://user@example.org/a",
+			"spiffe://example.org:8443/a",
+			"spiffe://example.org/a?q=1",
+			"spiffe://example.org/a#f",
+			"spiffe://example.org/a/",
+			"spiffe://example.org/a//b",
+			"spiffe://example.org/a/../b",
+			"spiffe://example.org/a/%20b",
+		} {
+			_, _, err := parse(invalid)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestBahamut_MTLSAuthenticatorClaimsExtractor(t *testing.T) {
+
+	Convey("Given I have an authenticator with custom claims extractors", t, func() {
+
+		caChainAData, _ := os.ReadFile("./fixtures/ca-chain-a.pem")
+		certPoolA := x509.NewCertPool()
+		certPoolA.AppendCertsFromPEM(caChainAData)
+
+		cert := makeClaimsTestCert("spiffe://example.org/api")
+
+		opts := x509.VerifyOptions{
+			Roots:         certPoolA,
+			Intermediates: x509.NewCertPool(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+
+		auth := NewMTLSRequestAuthenticator(
+			opts,
+			func(a bahamut.AuthAction, c bahamut.Context, s bahamut.Session) bahamut.AuthAction { return a },
+			nil,
+			CertificateCheckModeTLSStateOnly,
+			OptionClaimsExtractor(SPIFFEClaimsExtractor, EmailClaimsExtractor),
+		)
+
+		ctx := bahamut.NewContext(context.TODO(), &elemental.Request{
+			TLSConnectionState: &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert},
+			},
+		})
+
+		action, err := auth.AuthenticateRequest(ctx)
+
+		Convey("Then the claims should come from the extractors", func() {
+			So(err, ShouldBeNil)
+			So(action, ShouldEqual, bahamut.AuthActionOK)
+			So(ctx.Claims(), ShouldResemble, []string{
+				"@auth:spiffeid=spiffe://example.org/api",
+				"@auth:spiffetrustdomain=example.org",
+				"@auth:spiffepath=/api",
+				"@auth:email=user@example.com",
+			})
+		})
+	})
+}
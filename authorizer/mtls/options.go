@@ -0,0 +1,41 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+type config struct {
+	revocationChecker *RevocationChecker
+	claimsExtractor   ClaimsExtractor
+}
+
+// An Option represents an optional configuration
+// of the mtls Authorizer and Authenticators.
+type Option func(*config)
+
+// OptionRevocationChecker sets the RevocationChecker used to check
+// every verified chain against certificate revocation lists.
+// A certificate is only accepted if at least one of its verified
+// chains does not contain any revoked certificate.
+func OptionRevocationChecker(checker *RevocationChecker) Option {
+	return func(c *config) {
+		c.revocationChecker = checker
+	}
+}
+
+// OptionClaimsExtractor sets the ClaimsExtractors used by the Authenticators
+// to compute the claims from the verified client certificate. If several
+// extractors are given, their claims are combined in order.
+// The default is DefaultClaimsExtractor.
+func OptionClaimsExtractor(extractors ...ClaimsExtractor) Option {
+	return func(c *config) {
+		c.claimsExtractor = CombineClaimsExtractors(extractors...)
+	}
+}
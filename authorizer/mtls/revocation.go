@@ -0,0 +1,400 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const maxRevocationListSize = 32 << 20
+
+// A RevocationListProvider provides certificate revocation lists
+// to a RevocationChecker. It is called every time the RevocationChecker
+// refreshes its lists.
+type RevocationListProvider interface {
+	RevocationLists(ctx context.Context) ([]*x509.RevocationList, error)
+}
+
+type fileRevocationListProvider struct {
+	path string
+}
+
+// NewFileRevocationListProvider returns a RevocationListProvider that reads
+// the revocation lists from the given file. The file can either contain a
+// single DER encoded list, or one or more PEM encoded lists.
+func NewFileRevocationListProvider(path string) RevocationListProvider {
+
+	return &fileRevocationListProvider{
+		path: path,
+	}
+}
+
+func (p *fileRevocationListProvider) RevocationLists(context.Context) ([]*x509.RevocationList, error) {
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read revocation list file: %w", err)
+	}
+
+	return parseRevocationLists(data)
+}
+
+type urlRevocationListProvider struct {
+	client *http.Client
+	url    string
+}
+
+// NewURLRevocationListProvider returns a RevocationListProvider that downloads
+// the revocation lists from the given URL, using the given *http.Client.
+// If client is nil, a client with a 30s timeout is used.
+func NewURLRevocationListProvider(url string, client *http.Client) RevocationListProvider {
+
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &urlRevocationListProvider{
+		url:    url,
+		client: client,
+	}
+}
+
+func (p *urlRevocationListProvider) RevocationLists(ctx context.Context) ([]*x509.RevocationList, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build revocation list request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download revocation list: %w", err)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download revocation list: unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRevocationListSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read revocation list: %w", err)
+	}
+
+	if len(data) > maxRevocationListSize {
+		return nil, fmt.Errorf("revocation list is larger than %d bytes", maxRevocationListSize)
+	}
+
+	return parseRevocationLists(data)
+}
+
+// A RevocationCheckerOption represents an option to the RevocationChecker.
+type RevocationCheckerOption func(*RevocationChecker)
+
+// RevocationCheckerOptRefreshInterval sets how often the revocation
+// lists are refreshed by Watch. The default is 1h.
+func RevocationCheckerOptRefreshInterval(interval time.Duration) RevocationCheckerOption {
+	return func(r *RevocationChecker) {
+		r.interval = interval
+	}
+}
+
+// RevocationCheckerOptCacheSize sets the maximum number of check
+// results kept in the cache. The default is 10000. Passing 0
+// disables the cache.
+func RevocationCheckerOptCacheSize(size int) RevocationCheckerOption {
+	return func(r *RevocationChecker) {
+		r.cacheSize = size
+	}
+}
+
+// RevocationCheckerOptRequireRevocationList makes the RevocationChecker
+// reject the certificates issued by a certificate authority for which it
+// does not have a valid and up to date revocation list.
+// By default, such certificates are considered as not revoked.
+func RevocationCheckerOptRequireRevocationList(require bool) RevocationCheckerOption {
+	return func(r *RevocationChecker) {
+		r.requireList = require
+	}
+}
+
+type revocationStatus int
+
+const (
+	revocationStatusGood revocationStatus = iota
+	revocationStatusRevoked
+	revocationStatusUnknown
+)
+
+type revocationList struct {
+	list    *x509.RevocationList
+	revoked map[string]struct{}
+}
+
+type revocationCacheEntry struct {
+	status     revocationStatus
+	expiration time.Time
+}
+
+// A RevocationChecker checks verified certificate chains against
+// certificate revocation lists given by a set of RevocationListProvider.
+//
+// The lists are loaded when the RevocationChecker is created, and then
+// refreshed periodically once Watch is called. A list is only used if it
+// is signed by the issuer of the certificate being checked and if it has
+// not expired. The results of the checks are cached until the next refresh.
+type RevocationChecker struct {
+	cache       map[[sha256.Size]byte]revocationCacheEntry
+	lists       map[string][]*revocationList
+	current     [][]*x509.RevocationList
+	providers   []RevocationListProvider
+	interval    time.Duration
+	cacheSize   int
+	generation  uint64
+	requireList bool
+	lock        sync.RWMutex
+	cacheLock   sync.Mutex
+}
+
+// NewRevocationChecker returns a new RevocationChecker using the given
+// providers. The lists are loaded immediately and an error is returned
+// if any of the providers fails.
+func NewRevocationChecker(ctx context.Context, providers []RevocationListProvider, options ...RevocationCheckerOption) (*RevocationChecker, error) {
+
+	r := &RevocationChecker{
+		providers: providers,
+		current:   make([][]*x509.RevocationList, len(providers)),
+		interval:  time.Hour,
+		cacheSize: 10000,
+	}
+
+	for _, opt := range options {
+		opt(r)
+	}
+
+	if err := r.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Watch refreshes the revocation lists until the given context is canceled.
+func (r *RevocationChecker) Watch(ctx context.Context) {
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				zap.L().Error("Unable to refresh revocation lists", zap.Error(err))
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Refresh retrieves the revocation lists from all the providers and
+// clears the cache. If a provider fails, the lists it previously returned
+// are kept and the error is returned once all providers have been called.
+func (r *RevocationChecker) Refresh(ctx context.Context) error {
+
+	var errs []error
+
+	r.lock.RLock()
+	current := append([][]*x509.RevocationList{}, r.current...)
+	r.lock.RUnlock()
+
+	for i, p := range r.providers {
+
+		lists, err := p.RevocationLists(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		current[i] = lists
+	}
+
+	index := map[string][]*revocationList{}
+	for _, lists := range current {
+		for _, l := range lists {
+
+			revoked := make(map[string]struct{}, len(l.RevokedCertificateEntries))
+			for _, entry := range l.RevokedCertificateEntries {
+				revoked[entry.SerialNumber.String()] = struct{}{}
+			}
+
+			issuer := string(l.RawIssuer)
+			index[issuer] = append(index[issuer], &revocationList{list: l, revoked: revoked})
+		}
+	}
+
+	r.lock.Lock()
+	r.cacheLock.Lock()
+	r.current = current
+	r.lists = index
+	r.cache = nil
+	r.generation++
+	r.cacheLock.Unlock()
+	r.lock.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// Check returns true if at least one of the given verified chains does
+// not contain any revoked certificate. The chains must be ordered from the
+// leaf to the root, like the ones returned by x509.Certificate.Verify.
+// The root of each chain is not checked.
+func (r *RevocationChecker) Check(chains [][]*x509.Certificate) bool {
+
+	now := time.Now()
+
+	for _, chain := range chains {
+		if r.checkChain(chain, now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *RevocationChecker) checkChain(chain []*x509.Certificate, now time.Time) bool {
+
+	for i := 0; i < len(chain)-1; i++ {
+
+		switch r.status(chain[i], chain[i+1], now) {
+		case revocationStatusRevoked:
+			return false
+		case revocationStatusUnknown:
+			if r.requireList {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (r *RevocationChecker) status(cert *x509.Certificate, issuer *x509.Certificate, now time.Time) revocationStatus {
+
+	key := sha256.Sum256(bytes.Join([][]byte{issuer.Raw, cert.SerialNumber.Bytes()}, []byte{0}))
+
+	r.cacheLock.Lock()
+	entry, ok := r.cache[key]
+	r.cacheLock.Unlock()
+
+	if ok && (entry.expiration.IsZero() || now.Before(entry.expiration)) {
+		return entry.status
+	}
+
+	r.lock.RLock()
+	lists := r.lists[string(issuer.RawSubject)]
+	generation := r.generation
+	r.lock.RUnlock()
+
+	entry = revocationCacheEntry{status: revocationStatusUnknown}
+	serial := cert.SerialNumber.String()
+
+	for _, l := range lists {
+
+		if !l.list.NextUpdate.IsZero() && now.After(l.list.NextUpdate) {
+			continue
+		}
+
+		if err := l.list.CheckSignatureFrom(issuer); err != nil {
+			continue
+		}
+
+		if _, revoked := l.revoked[serial]; revoked {
+			entry = revocationCacheEntry{status: revocationStatusRevoked}
+			break
+		}
+
+		entry.status = revocationStatusGood
+		if entry.expiration.IsZero() || l.list.NextUpdate.Before(entry.expiration) {
+			entry.expiration = l.list.NextUpdate
+		}
+	}
+
+	// The result is not cached if the lists
+	// have been refreshed in the meantime.
+	r.cacheLock.Lock()
+	if r.cacheSize > 0 && generation == r.generation {
+		if r.cache == nil || len(r.cache) >= r.cacheSize {
+			r.cache = make(map[[sha256.Size]byte]revocationCacheEntry, r.cacheSize)
+		}
+		r.cache[key] = entry
+	}
+	r.cacheLock.Unlock()
+
+	return entry.status
+}
+
+func parseRevocationLists(data []byte) ([]*x509.RevocationList, error) {
+
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+
+		l, err := x509.ParseRevocationList(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid revocation list: %w", err)
+		}
+
+		return []*x509.RevocationList{l}, nil
+	}
+
+	var lists []*x509.RevocationList
+	var block *pem.Block
+
+	for {
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "X509 CRL" {
+			continue
+		}
+
+		l, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid revocation list: %w", err)
+		}
+
+		lists = append(lists, l)
+	}
+
+	if len(lists) == 0 {
+		return nil, errors.New("no revocation list found in pem data")
+	}
+
+	return lists, nil
+}
@@ -0,0 +1,519 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+)
+
+type mockRevocationListProvider struct {
+	err   error
+	lists []*x509.RevocationList
+	calls int
+}
+
+func (p *mockRevocationListProvider) RevocationLists(context.Context) ([]*x509.RevocationList, error) {
+
+	p.calls++
+
+	return p.lists, p.err
+}
+
+func loadFixtureCert(name string) *x509.Certificate {
+
+	data, err := os.ReadFile("./fixtures/" + name + "-cert.pem")
+	if err != nil {
+		panic(err)
+	}
+
+	block, _ := pem.Decode(data)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		panic(err)
+	}
+
+	return cert
+}
+
+func loadFixtureKey(name string) crypto.Signer {
+
+	data, err := os.ReadFile("./fixtures/" + name + "-key.pem")
+	if err != nil {
+		panic(err)
+	}
+
+	block, _ := pem.Decode(data)
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+}
+
+func makeRevocationList(issuer *x509.Certificate, key crypto.Signer, nextUpdate time.Time, revoked ...*x509.Certificate) []byte {
+
+	entries := make([]x509.RevocationListEntry, len(revoked))
+	for i, c := range revoked {
+		entries[i] = x509.RevocationListEntry{
+			SerialNumber:   c.SerialNumber,
+			RevocationTime: time.Now().Add(-time.Minute),
+		}
+	}
+
+	// The fixtures CAs do not have a subject key identifier,
+	// which is required to create a revocation list.
+	signer := *issuer
+	if len(signer.SubjectKeyId) == 0 {
+		signer.SubjectKeyId = []byte{1, 2, 3, 4}
+	}
+
+	data, err := x509.CreateRevocationList(
+		rand.Reader,
+		&x509.RevocationList{
+			Number:                    big.NewInt(1),
+			ThisUpdate:                time.Now().Add(-time.Hour),
+			NextUpdate:                nextUpdate,
+			RevokedCertificateEntries: entries,
+		},
+		&signer,
+		key,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+func parseRevocationList(data []byte) *x509.RevocationList {
+
+	l, err := x509.ParseRevocationList(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return l
+}
+
+func TestRevocationChecker(t *testing.T) {
+
+	Convey("Given I have a CA hierarchy and some certificates", t, func() {
+
+		rootCert := loadFixtureCert("ca-root")
+		rootKey := loadFixtureKey("ca-root")
+		intermediateCert := loadFixtureCert("ca-intermediate")
+		signerACert := loadFixtureCert("ca-signer-a")
+		signerAKey := loadFixtureKey("ca-signer-a")
+		signerBKey := loadFixtureKey("ca-signer-b")
+		userA := loadFixtureCert("user-a")
+		serverA := loadFixtureCert("server-a")
+
+		chainUserA := [][]*x509.Certificate{{userA, signerACert, intermediateCert, rootCert}}
+		chainServerA := [][]*x509.Certificate{{serverA, signerACert, intermediateCert, rootCert}}
+		nextUpdate := time.Now().Add(time.Hour)
+
+		Convey("When I check chains against a list revoking user-a", func() {
+
+			p := &mockRevocationListProvider{
+				lists: []*x509.RevocationList{parseRevocationList(makeRevocationList(signerACert, signerAKey, nextUpdate, userA))},
+			}
+
+			r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p})
+			So(err, ShouldBeNil)
+
+			Convey("Then user-a should be rejected", func() {
+				So(r.Check(chainUserA), ShouldBeFalse)
+			})
+
+			Convey("Then server-a should be accepted", func() {
+				So(r.Check(chainServerA), ShouldBeTrue)
+			})
+
+			Convey("Then a certificate with a clean chain among others should be accepted", func() {
+				So(r.Check([][]*x509.Certificate{chainUserA[0], chainServerA[0]}), ShouldBeTrue)
+			})
+		})
+
+		Convey("When I check chains against a list revoking the intermediate", func() {
+
+			p := &mockRevocationListProvider{
+				lists: []*x509.RevocationList{parseRevocationList(makeRevocationList(rootCert, rootKey, nextUpdate, intermediateCert))},
+			}
+
+			r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p})
+			So(err, ShouldBeNil)
+
+			Convey("Then every chain going through it should be rejected", func() {
+				So(r.Check(chainUserA), ShouldBeFalse)
+				So(r.Check(chainServerA), ShouldBeFalse)
+			})
+		})
+
+		Convey("When I check chains against a list not signed by the issuer", func() {
+
+			p := &mockRevocationListProvider{
+				lists: []*x509.RevocationList{parseRevocationList(makeRevocationList(signerACert, signerBKey, nextUpdate, userA))},
+			}
+
+			r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p})
+			So(err, ShouldBeNil)
+
+			Convey("Then the list should be ignored", func() {
+				So(r.Check(chainUserA), ShouldBeTrue)
+			})
+		})
+
+		Convey("When I check chains against an expired list", func() {
+
+			p := &mockRevocationListProvider{
+				lists: []*x509.RevocationList{parseRevocationList(makeRevocationList(signerACert, signerAKey, time.Now().Add(-time.Minute), userA))},
+			}
+
+			Convey("Then the list should be ignored by default", func() {
+				r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p})
+				So(err, ShouldBeNil)
+				So(r.Check(chainUserA), ShouldBeTrue)
+			})
+
+			Convey("Then the certificate should be rejected if a list is required", func() {
+				r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p}, RevocationCheckerOptRequireRevocationList(true))
+				So(err, ShouldBeNil)
+				So(r.Check(chainServerA), ShouldBeFalse)
+			})
+		})
+
+		Convey("When I require lists for every issuer of the chain", func() {
+
+			p := &mockRevocationListProvider{
+				lists: []*x509.RevocationList{
+					parseRevocationList(makeRevocationList(signerACert, signerAKey, nextUpdate)),
+					parseRevocationList(makeRevocationList(rootCert, rootKey, nextUpdate)),
+				},
+			}
+
+			r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p}, RevocationCheckerOptRequireRevocationList(true))
+			So(err, ShouldBeNil)
+
+			Convey("Then a chain with a missing list should be rejected", func() {
+				So(r.Check(chainServerA), ShouldBeFalse)
+			})
+
+			Convey("Then a chain with all its lists should be accepted", func() {
+				p.lists = append(p.lists, parseRevocationList(makeRevocationList(intermediateCert, loadFixtureKey("ca-intermediate"), nextUpdate)))
+				So(r.Refresh(context.Background()), ShouldBeNil)
+				So(r.Check(chainServerA), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the lists change between two refreshes", func() {
+
+			p := &mockRevocationListProvider{}
+
+			r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p})
+			So(err, ShouldBeNil)
+			So(r.Check(chainUserA), ShouldBeTrue)
+			So(len(r.cache), ShouldEqual, 3)
+
+			p.lists = []*x509.RevocationList{parseRevocationList(makeRevocationList(signerACert, signerAKey, nextUpdate, userA))}
+
+			Convey("Then the cached result should be used until the next refresh", func() {
+				So(r.Check(chainUserA), ShouldBeTrue)
+				So(r.Refresh(context.Background()), ShouldBeNil)
+				So(r.cache, ShouldBeNil)
+				So(r.Check(chainUserA), ShouldBeFalse)
+			})
+		})
+
+		Convey("When the cache is disabled", func() {
+
+			p := &mockRevocationListProvider{}
+
+			r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p}, RevocationCheckerOptCacheSize(0))
+			So(err, ShouldBeNil)
+			So(r.Check(chainUserA), ShouldBeTrue)
+
+			Convey("Then nothing should be cached", func() {
+				So(r.cache, ShouldBeNil)
+			})
+		})
+
+		Convey("When a provider fails", func() {
+
+			p := &mockRevocationListProvider{err: fmt.Errorf("boom")}
+
+			Convey("Then creating the checker should fail", func() {
+				r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p})
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "boom")
+				So(r, ShouldBeNil)
+			})
+
+			Convey("Then refreshing should keep the previous lists", func() {
+				p.err = nil
+				p.lists = []*x509.RevocationList{parseRevocationList(makeRevocationList(signerACert, signerAKey, nextUpdate, userA))}
+
+				r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p})
+				So(err, ShouldBeNil)
+
+				p.err = fmt.Errorf("boom")
+				p.lists = nil
+
+				So(r.Refresh(context.Background()), ShouldNotBeNil)
+				So(r.Check(chainUserA), ShouldBeFalse)
+			})
+		})
+
+		Convey("When I watch the providers", func() {
+
+			p := &mockRevocationListProvider{}
+
+			r, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p}, RevocationCheckerOptRefreshInterval(10*time.Millisecond))
+			So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				r.Watch(ctx)
+				close(done)
+			}()
+
+			time.Sleep(55 * time.Millisecond)
+			cancel()
+			<-done
+
+			Convey("Then the lists should have been refreshed", func() {
+				So(p.calls, ShouldBeGreaterThan, 2)
+			})
+		})
+	})
+}
+
+func TestRevocationListProviders(t *testing.T) {
+
+	Convey("Given I have a revocation list", t, func() {
+
+		signerACert := loadFixtureCert("ca-signer-a")
+		signerAKey := loadFixtureKey("ca-signer-a")
+		userA := loadFixtureCert("user-a")
+
+		der := makeRevocationList(signerACert, signerAKey, time.Now().Add(time.Hour), userA)
+		pemData := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+
+		dir := t.TempDir()
+
+		Convey("When I use a file provider with a DER list", func() {
+
+			path := filepath.Join(dir, "crl.der")
+			So(os.WriteFile(path, der, 0600), ShouldBeNil)
+
+			lists, err := NewFileRevocationListProvider(path).RevocationLists(context.Background())
+
+			Convey("Then I should get the list", func() {
+				So(err, ShouldBeNil)
+				So(len(lists), ShouldEqual, 1)
+				So(lists[0].RevokedCertificateEntries[0].SerialNumber.Cmp(userA.SerialNumber), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When I use a file provider with several PEM lists", func() {
+
+			path := filepath.Join(dir, "crl.pem")
+			So(os.WriteFile(path, append(append([]byte{}, pemData...), pemData...), 0600), ShouldBeNil)
+
+			lists, err := NewFileRevocationListProvider(path).RevocationLists(context.Background())
+
+			Convey("Then I should get the lists", func() {
+				So(err, ShouldBeNil)
+				So(len(lists), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I use a file provider with a missing file", func() {
+
+			_, err := NewFileRevocationListProvider(filepath.Join(dir, "nope")).RevocationLists(context.Background())
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When I use a file provider with invalid data", func() {
+
+			path := filepath.Join(dir, "crl.pem")
+			So(os.WriteFile(path, []byte("-----BEGIN NOPE-----\n-----END NOPE-----\n"), 0600), ShouldBeNil)
+
+			_, err := NewFileRevocationListProvider(path).RevocationLists(context.Background())
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "no revocation list found in pem data")
+			})
+		})
+
+		Convey("When I use an url provider", func() {
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/crl" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write(der)
+			}))
+			defer ts.Close()
+
+			Convey("Then I should get the list", func() {
+				lists, err := NewURLRevocationListProvider(ts.URL+"/crl", nil).RevocationLists(context.Background())
+				So(err, ShouldBeNil)
+				So(len(lists), ShouldEqual, 1)
+			})
+
+			Convey("Then I should get an error if the server does not return the list", func() {
+				_, err := NewURLRevocationListProvider(ts.URL+"/nope", ts.Client()).RevocationLists(context.Background())
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "unable to download revocation list: unexpected status code 404")
+			})
+		})
+	})
+}
+
+func TestBahamut_MTLSAuthorizerRevocation(t *testing.T) {
+
+	Convey("Given I have an authorizer with a revocation checker", t, func() {
+
+		caChainAData, _ := os.ReadFile("./fixtures/ca-chain-a.pem")
+		certPoolA := x509.NewCertPool()
+		certPoolA.AppendCertsFromPEM(caChainAData)
+
+		signerACert := loadFixtureCert("ca-signer-a")
+		signerAKey := loadFixtureKey("ca-signer-a")
+		userA := loadFixtureCert("user-a")
+		serverA := loadFixtureCert("server-a")
+
+		p := &mockRevocationListProvider{
+			lists: []*x509.RevocationList{
+				parseRevocationList(makeRevocationList(signerACert, signerAKey, time.Now().Add(time.Hour), userA)),
+			},
+		}
+
+		checker, err := NewRevocationChecker(context.Background(), []RevocationListProvider{p})
+		So(err, ShouldBeNil)
+
+		otherProvider := &mockRevocationListProvider{
+			lists: []*x509.RevocationList{
+				parseRevocationList(makeRevocationList(signerACert, signerAKey, time.Now().Add(time.Hour), serverA)),
+			},
+		}
+
+		otherChecker, err := NewRevocationChecker(context.Background(), []RevocationListProvider{otherProvider})
+		So(err, ShouldBeNil)
+
+		opts := x509.VerifyOptions{
+			Roots:     certPoolA,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+
+		decider := func(a bahamut.AuthAction, c bahamut.Context, s bahamut.Session) bahamut.AuthAction { return a }
+
+		makeCtx := func(cert *x509.Certificate) bahamut.Context {
+			return bahamut.NewContext(context.TODO(), &elemental.Request{
+				TLSConnectionState: &tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{cert},
+				},
+			})
+		}
+
+		Convey("When I check a revoked certificate", func() {
+
+			auth := NewMTLSAuthorizer(opts, decider, nil, nil, CertificateCheckModeTLSStateOnly, OptionRevocationChecker(checker))
+			action, err := auth.IsAuthorized(makeCtx(userA))
+
+			Convey("Then action should be bahamut.AuthActionKO", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+
+		Convey("When I authenticate a revoked certificate", func() {
+
+			auth := NewMTLSRequestAuthenticator(opts, decider, nil, CertificateCheckModeTLSStateOnly, OptionRevocationChecker(checker))
+			action, err := auth.AuthenticateRequest(makeCtx(userA))
+
+			Convey("Then action should be bahamut.AuthActionKO", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+
+		Convey("When I check a revoked certificate presented with its CA chain", func() {
+
+			chain := []*x509.Certificate{userA, signerACert, loadFixtureCert("ca-intermediate")}
+
+			auth := NewMTLSAuthorizer(opts, decider, nil, nil, CertificateCheckModeTLSStateOnly, OptionRevocationChecker(checker))
+			action, err := auth.IsAuthorized(bahamut.NewContext(context.TODO(), &elemental.Request{
+				TLSConnectionState: &tls.ConnectionState{PeerCertificates: chain},
+			}))
+
+			Convey("Then action should be bahamut.AuthActionKO", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+
+		Convey("When I authenticate a revoked certificate forwarded with its CA chain", func() {
+
+			header, err := EncodeCertificatesHeader([]*x509.Certificate{userA, signerACert, loadFixtureCert("ca-intermediate")})
+			So(err, ShouldBeNil)
+
+			ctx := bahamut.NewContext(context.TODO(), &elemental.Request{Headers: http.Header{}})
+			ctx.Request().Headers.Set("X-TLS-Client-Certificate", header)
+
+			auth := NewMTLSRequestAuthenticator(opts, decider, nil, CertificateCheckModeHeaderOnly, OptionRevocationChecker(checker))
+			action, err := auth.AuthenticateRequest(ctx)
+
+			Convey("Then action should be bahamut.AuthActionKO", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+				So(ctx.Claims(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I authenticate a certificate that is not revoked", func() {
+
+			auth := NewMTLSRequestAuthenticator(opts, decider, nil, CertificateCheckModeTLSStateOnly, OptionRevocationChecker(otherChecker))
+			action, err := auth.AuthenticateRequest(makeCtx(userA))
+
+			Convey("Then action should be bahamut.AuthActionOK", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+			})
+		})
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/bahamut/internal/filewatch"
+	"go.uber.org/zap"
+)
+
+// An Option represents an option to the Authorizer.
+type Option func(*Authorizer)
+
+// OptionExplain makes the Authorizer log the explanation of
+// every decision at the info level. This is meant for debugging.
+func OptionExplain(explain bool) Option {
+	return func(a *Authorizer) {
+		a.explain = explain
+	}
+}
+
+// OptionReloadInterval sets how often the policy file is checked
+// for changes by Watch. The default is 10s.
+func OptionReloadInterval(interval time.Duration) Option {
+	return func(a *Authorizer) {
+		a.interval = interval
+	}
+}
+
+// An Authorizer is a bahamut.Authorizer evaluating a Policy.
+type Authorizer struct {
+	current  atomic.Pointer[Policy]
+	watcher  *filewatch.Watcher
+	path     string
+	interval time.Duration
+	explain  bool
+}
+
+// NewAuthorizer returns a new *Authorizer evaluating the given Policy.
+func NewAuthorizer(policy *Policy, options ...Option) *Authorizer {
+
+	a := newAuthorizer(options...)
+	a.SetPolicy(policy)
+
+	return a
+}
+
+// NewFileAuthorizer returns a new *Authorizer evaluating the policy
+// stored in the given YAML or JSON file. The file is loaded immediately
+// and an error is returned if it is not valid. Call Watch to reload the
+// policy when the file changes.
+func NewFileAuthorizer(path string, options ...Option) (*Authorizer, error) {
+
+	a := newAuthorizer(options...)
+	a.path = path
+	a.watcher = filewatch.New(a.load, path)
+
+	if _, err := a.Reload(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func newAuthorizer(options ...Option) *Authorizer {
+
+	a := &Authorizer{
+		interval: 10 * time.Second,
+	}
+
+	for _, opt := range options {
+		opt(a)
+	}
+
+	return a
+}
+
+// SetPolicy replaces the current policy.
+func (a *Authorizer) SetPolicy(policy *Policy) {
+
+	a.current.Store(policy)
+}
+
+// Policy returns the current policy.
+func (a *Authorizer) Policy() *Policy {
+
+	return a.current.Load()
+}
+
+// Reload replaces the current policy with the one of the policy file
+// if the file changed, and returns true if it did. A policy file that
+// cannot be parsed or has no rule, like an empty or truncated file
+// read while it is being written, is rejected and the current policy
+// is kept.
+func (a *Authorizer) Reload() (bool, error) {
+
+	if a.watcher == nil {
+		return false, errors.New("authorizer has no policy file")
+	}
+
+	return a.watcher.Reload()
+}
+
+// Watch calls Reload at the interval set by OptionReloadInterval
+// until the given context is canceled.
+func (a *Authorizer) Watch(ctx context.Context) {
+
+	filewatch.Watch(ctx, a.interval, a.Reload, func(err error) {
+		zap.L().Error("Unable to reload authorization policy", zap.String("path", a.path), zap.Error(err))
+	})
+}
+
+func (a *Authorizer) load(data [][]byte) error {
+
+	policy, err := ParsePolicy(data[0])
+	if err != nil {
+		return err
+	}
+
+	if len(policy.Rules) == 0 {
+		return errors.New("policy file has no rules")
+	}
+
+	a.current.Store(policy)
+
+	zap.L().Debug("Authorization policy loaded", zap.String("path", a.path), zap.Int("rules", len(policy.Rules)))
+
+	return nil
+}
+
+// IsAuthorized is part of the bahamut.Authorizer interface.
+func (a *Authorizer) IsAuthorized(ctx bahamut.Context) (bahamut.AuthAction, error) {
+
+	req := ctx.Request()
+
+	in := Input{
+		Identity:       req.Identity,
+		ParentIdentity: req.ParentIdentity,
+		Operation:      req.Operation,
+		Namespace:      req.Namespace,
+		Claims:         ctx.Claims(),
+	}
+
+	policy := a.Policy()
+
+	if !a.explain {
+		return Evaluate(policy, in).Action, nil
+	}
+
+	d := Explain(policy, in)
+
+	zap.L().Info("Authorization decision",
+		zap.String("identity", in.Identity.Name),
+		zap.String("operation", string(in.Operation)),
+		zap.String("namespace", in.Namespace),
+		zap.String("rule", d.Rule),
+		zap.Strings("explanation", d.Explanation),
+	)
+
+	return d.Action, nil
+}
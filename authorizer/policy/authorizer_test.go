@@ -0,0 +1,156 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+)
+
+func makeContext(identity elemental.Identity, op elemental.Operation, ns string, claims ...string) bahamut.Context {
+
+	req := elemental.NewRequest()
+	req.Identity = identity
+	req.Operation = op
+	req.Namespace = ns
+
+	ctx := bahamut.NewContext(context.Background(), req)
+	ctx.SetClaims(claims)
+
+	return ctx
+}
+
+func TestAuthorizer(t *testing.T) {
+
+	Convey("Given I have an authorizer with a policy", t, func() {
+
+		p, err := ParsePolicy([]byte(testPolicyYAML))
+		So(err, ShouldBeNil)
+
+		for _, explain := range []bool{false, true} {
+
+			auth := NewAuthorizer(p, OptionExplain(explain))
+
+			action, err := auth.IsAuthorized(makeContext(listIdentity, elemental.OperationDelete, "/acme", "@auth:role=admin"))
+			So(err, ShouldBeNil)
+			So(action, ShouldEqual, bahamut.AuthActionOK)
+
+			action, err = auth.IsAuthorized(makeContext(listIdentity, elemental.OperationDelete, "/other", "@auth:role=admin"))
+			So(err, ShouldBeNil)
+			So(action, ShouldEqual, bahamut.AuthActionKO)
+		}
+
+		Convey("When I reload an authorizer without file", func() {
+
+			_, err := NewAuthorizer(p).Reload()
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given I have an authorizer with a policy file", t, func() {
+
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		So(os.WriteFile(path, []byte("rules: [{action: ko}]"), 0600), ShouldBeNil)
+
+		auth, err := NewFileAuthorizer(path, OptionReloadInterval(10*time.Millisecond))
+		So(err, ShouldBeNil)
+
+		ctx := makeContext(taskIdentity, elemental.OperationCreate, "/")
+
+		action, _ := auth.IsAuthorized(ctx)
+		So(action, ShouldEqual, bahamut.AuthActionKO)
+
+		Convey("When the file does not change", func() {
+
+			changed, err := auth.Reload()
+
+			Convey("Then the policy should not be replaced", func() {
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the file is updated with an invalid policy", func() {
+
+			So(os.WriteFile(path, []byte("rules: [{action: nope}]"), 0600), ShouldBeNil)
+			changed, err := auth.Reload()
+
+			Convey("Then the current policy should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(changed, ShouldBeFalse)
+				action, _ := auth.IsAuthorized(ctx)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+
+		Convey("When the file is emptied", func() {
+
+			So(os.WriteFile(path, nil, 0600), ShouldBeNil)
+			changed, err := auth.Reload()
+
+			Convey("Then the current policy should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "policy file has no rules")
+				So(changed, ShouldBeFalse)
+				action, _ := auth.IsAuthorized(ctx)
+				So(action, ShouldEqual, bahamut.AuthActionKO)
+			})
+		})
+
+		Convey("When the file is updated while watching", func() {
+
+			wctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				auth.Watch(wctx)
+				close(done)
+			}()
+
+			So(os.WriteFile(path, []byte("rules: [{action: ok}]"), 0600), ShouldBeNil)
+
+			var action bahamut.AuthAction
+			for i := 0; i < 100; i++ {
+				if action, _ = auth.IsAuthorized(ctx); action == bahamut.AuthActionOK {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			cancel()
+			<-done
+
+			Convey("Then the new policy should be used", func() {
+				So(action, ShouldEqual, bahamut.AuthActionOK)
+			})
+		})
+
+		Convey("When I create an authorizer with a missing file", func() {
+
+			_, err := NewFileAuthorizer(path + ".nope")
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy provides a bahamut.Authorizer evaluating declarative
+// rules loaded from YAML or JSON documents.
+//
+// A policy is an ordered list of rules. Each rule matches requests on their
+// identity, operation, parent identity, namespace and claims, and gives the
+// bahamut.AuthAction to return. The first matching rule wins. If no rule
+// matches, the default action of the policy is returned.
+//
+// Example:
+//
+//	defaultAction: continue
+//	rules:
+//	  - name: admins can do anything in /acme
+//	    namespaces: [/acme]
+//	    claims:
+//	      any:
+//	        - "@auth:role=admin"
+//	        - all: ["@auth:realm=certificate", "@auth:organization=acme"]
+//	    action: ok
+//
+//	  - name: nobody deletes lists
+//	    identities: [list]
+//	    operations: [delete]
+//	    action: ko
+//
+// Policies can be evaluated without any bahamut.Context using Evaluate,
+// which makes them easy to unit test.
+package policy // import "go.aporeto.io/bahamut/authorizer/policy"
@@ -0,0 +1,224 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+)
+
+// An Input contains the information about
+// a request needed to evaluate a Policy.
+type Input struct {
+	Identity       elemental.Identity
+	ParentIdentity elemental.Identity
+	Operation      elemental.Operation
+	Namespace      string
+	Claims         []string
+}
+
+// A Decision is the result of the evaluation of a Policy.
+type Decision struct {
+
+	// Action is the resulting bahamut.AuthAction.
+	Action bahamut.AuthAction
+
+	// Rule is the name of the rule that matched, or
+	// an empty string if the default action has been used.
+	Rule string
+
+	// Explanation describes why each evaluated rule matched or not.
+	// It is only set by Explain.
+	Explanation []string
+}
+
+// Evaluate evaluates the given policy against the given input.
+// It has no side effects.
+func Evaluate(p *Policy, in Input) Decision {
+
+	return evaluate(p, in, false)
+}
+
+// Explain evaluates the given policy against the given input, like
+// Evaluate, and explains why each evaluated rule matched or not.
+func Explain(p *Policy, in Input) Decision {
+
+	return evaluate(p, in, true)
+}
+
+func evaluate(p *Policy, in Input, explain bool) Decision {
+
+	d := Decision{}
+
+	for i, r := range p.Rules {
+
+		name := ruleName(r, i)
+		reason := r.mismatch(in)
+
+		if explain {
+			if reason == "" {
+				d.Explanation = append(d.Explanation, fmt.Sprintf("rule '%s': matched, action %s", name, r.Action))
+			} else {
+				d.Explanation = append(d.Explanation, fmt.Sprintf("rule '%s': %s", name, reason))
+			}
+		}
+
+		if reason == "" {
+			d.Action = r.Action.AuthAction()
+			d.Rule = name
+			return d
+		}
+	}
+
+	d.Action = p.DefaultAction.AuthAction()
+
+	if explain {
+		d.Explanation = append(d.Explanation, fmt.Sprintf("no rule matched, default action %s", p.defaultAction()))
+	}
+
+	return d
+}
+
+func (p *Policy) defaultAction() Action {
+
+	if p.DefaultAction == "" {
+		return ActionContinue
+	}
+
+	return p.DefaultAction
+}
+
+// mismatch returns the reason why the rule does not
+// match the input, or an empty string if it matches.
+func (r *Rule) mismatch(in Input) string {
+
+	if len(r.Identities) > 0 && !matchIdentity(r.Identities, in.Identity) {
+		return fmt.Sprintf("identity '%s' not in %v", in.Identity.Name, r.Identities)
+	}
+
+	if len(r.Operations) > 0 && !matchOperation(r.Operations, in.Operation) {
+		return fmt.Sprintf("operation '%s' not in %v", in.Operation, r.Operations)
+	}
+
+	if len(r.ParentIdentities) > 0 && !matchIdentity(r.ParentIdentities, in.ParentIdentity) {
+		return fmt.Sprintf("parent identity '%s' not in %v", in.ParentIdentity.Name, r.ParentIdentities)
+	}
+
+	if len(r.Namespaces) > 0 && !matchNamespace(r.Namespaces, in.Namespace) {
+		return fmt.Sprintf("namespace '%s' not under %v", in.Namespace, r.Namespaces)
+	}
+
+	if r.Claims != nil && !r.Claims.match(in.Claims) {
+		return fmt.Sprintf("claims do not match %s", r.Claims)
+	}
+
+	return ""
+}
+
+func (e *Expression) match(claims []string) bool {
+
+	switch {
+
+	case e.Claim != "":
+		for _, c := range claims {
+			if matchWildcard(e.Claim, c) {
+				return true
+			}
+		}
+		return false
+
+	case e.Not != nil:
+		return !e.Not.match(claims)
+
+	case len(e.All) > 0:
+		for _, sub := range e.All {
+			if !sub.match(claims) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		for _, sub := range e.Any {
+			if sub.match(claims) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func matchIdentity(candidates []string, identity elemental.Identity) bool {
+
+	for _, c := range candidates {
+		if c == "*" || c == identity.Name || c == identity.Category {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchOperation(candidates []elemental.Operation, op elemental.Operation) bool {
+
+	for _, c := range candidates {
+		if c == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchNamespace(prefixes []string, ns string) bool {
+
+	for _, prefix := range prefixes {
+
+		prefix = strings.TrimSuffix(prefix, "/")
+
+		if prefix == "" || ns == prefix || strings.HasPrefix(ns, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchWildcard returns true if s matches the pattern,
+// where * matches any sequence of characters.
+func matchWildcard(pattern string, s string) bool {
+
+	if !strings.Contains(pattern, "*") {
+		return pattern == s
+	}
+
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(s, part)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+)
+
+var (
+	listIdentity = elemental.Identity{Name: "list", Category: "lists"}
+	taskIdentity = elemental.Identity{Name: "task", Category: "tasks"}
+	rootIdentity = elemental.Identity{Name: "root", Category: "root"}
+)
+
+func TestEvaluate(t *testing.T) {
+
+	Convey("Given I have a policy", t, func() {
+
+		p, err := ParsePolicy([]byte(testPolicyYAML))
+		So(err, ShouldBeNil)
+
+		tests := []struct {
+			name   string
+			in     Input
+			action bahamut.AuthAction
+			rule   string
+		}{
+			{
+				"admin in namespace",
+				Input{Identity: listIdentity, Operation: elemental.OperationDelete, Namespace: "/acme/a", Claims: []string{"@auth:role=admin"}},
+				bahamut.AuthActionOK,
+				"admins",
+			},
+			{
+				"certificate from acme in namespace",
+				Input{Identity: listIdentity, Operation: elemental.OperationDelete, Namespace: "/acme", Claims: []string{"@auth:realm=certificate", "@auth:organization=acme"}},
+				bahamut.AuthActionOK,
+				"admins",
+			},
+			{
+				"certificate from another org in namespace",
+				Input{Identity: listIdentity, Operation: elemental.OperationDelete, Namespace: "/acme", Claims: []string{"@auth:realm=certificate", "@auth:organization=evil"}},
+				bahamut.AuthActionKO,
+				"no list deletion",
+			},
+			{
+				"admin in namespace with same prefix",
+				Input{Identity: listIdentity, Operation: elemental.OperationDelete, Namespace: "/acmecorp", Claims: []string{"@auth:role=admin"}},
+				bahamut.AuthActionKO,
+				"no list deletion",
+			},
+			{
+				"reader using category",
+				Input{Identity: taskIdentity, ParentIdentity: listIdentity, Operation: elemental.OperationRetrieveMany, Namespace: "/other"},
+				bahamut.AuthActionOK,
+				"readers",
+			},
+			{
+				"banned reader",
+				Input{Identity: taskIdentity, ParentIdentity: rootIdentity, Operation: elemental.OperationRetrieve, Namespace: "/other", Claims: []string{"@auth:role=banned"}},
+				bahamut.AuthActionKO,
+				"",
+			},
+			{
+				"writer",
+				Input{Identity: taskIdentity, Operation: elemental.OperationCreate, Namespace: "/other"},
+				bahamut.AuthActionKO,
+				"",
+			},
+		}
+
+		for _, tt := range tests {
+			Convey("Then "+tt.name+" should be correct", func() {
+				d := Evaluate(p, tt.in)
+				So(d.Action, ShouldEqual, tt.action)
+				So(d.Rule, ShouldEqual, tt.rule)
+				So(d.Explanation, ShouldBeNil)
+			})
+		}
+
+		Convey("When I explain a decision", func() {
+
+			d := Explain(p, Input{Identity: taskIdentity, Operation: elemental.OperationCreate, Namespace: "/other"})
+
+			Convey("Then the explanation should be correct", func() {
+				So(d.Action, ShouldEqual, bahamut.AuthActionKO)
+				So(d.Explanation, ShouldResemble, []string{
+					"rule 'admins': namespace '/other' not under [/acme]",
+					"rule 'no list deletion': identity 'task' not in [list]",
+					"rule 'readers': operation 'create' not in [retrieve retrieve-many info]",
+					"no rule matched, default action ko",
+				})
+			})
+		})
+
+		Convey("When I explain a matching decision", func() {
+
+			d := Explain(p, Input{Identity: listIdentity, Operation: elemental.OperationDelete, Namespace: "/acme", Claims: []string{"@auth:role=user"}})
+
+			Convey("Then the explanation should be correct", func() {
+				So(d.Action, ShouldEqual, bahamut.AuthActionKO)
+				So(d.Explanation, ShouldResemble, []string{
+					"rule 'admins': claims do not match any(@auth:role=admin, all(@auth:realm=certificate, @auth:organization=acme))",
+					"rule 'no list deletion': matched, action ko",
+				})
+			})
+		})
+	})
+
+	Convey("Given I have a policy with a continue rule and a root namespace", t, func() {
+
+		p := &Policy{
+			Rules: []*Rule{
+				{Namespaces: []string{"/"}, Claims: &Expression{Claim: "@auth:realm=*"}, Action: ActionContinue},
+				{Action: ActionOK},
+			},
+		}
+
+		Convey("Then the first rule should match any namespace", func() {
+			d := Evaluate(p, Input{Namespace: "/a/b", Claims: []string{"@auth:realm=jwt"}})
+			So(d.Action, ShouldEqual, bahamut.AuthActionContinue)
+			So(d.Rule, ShouldEqual, "#0")
+		})
+
+		Convey("Then the second rule should match without claims", func() {
+			d := Evaluate(p, Input{Namespace: "/a/b"})
+			So(d.Action, ShouldEqual, bahamut.AuthActionOK)
+			So(d.Rule, ShouldEqual, "#1")
+		})
+	})
+}
+
+func Test_matchWildcard(t *testing.T) {
+
+	Convey("Given I have some patterns", t, func() {
+
+		for _, tc := range []struct {
+			pattern string
+			s       string
+			match   bool
+		}{
+			{"a=b", "a=b", true},
+			{"a=b", "a=bc", false},
+			{"a=*", "a=", true},
+			{"a=*", "a=anything", true},
+			{"a=*", "b=anything", false},
+			{"*=b", "key=b", true},
+			{"a=*x*y", "a=1x2y", true},
+			{"a=*x*y", "a=1x2y3", false},
+			{"a=*xx", "a=x", false},
+			{"a=x*x", "a=x", false},
+			{"a=x*x", "a=xx", true},
+			{"*", "", true},
+		} {
+			So(matchWildcard(tc.pattern, tc.s), ShouldEqual, tc.match)
+		}
+	})
+}
@@ -0,0 +1,295 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+	"gopkg.in/yaml.v3"
+)
+
+// An Action is the action of a Rule.
+type Action string
+
+// Various values for Action.
+const (
+	ActionOK       Action = "ok"
+	ActionKO       Action = "ko"
+	ActionContinue Action = "continue"
+)
+
+// AuthAction returns the bahamut.AuthAction corresponding to the Action.
+func (a Action) AuthAction() bahamut.AuthAction {
+
+	switch a {
+	case ActionOK:
+		return bahamut.AuthActionOK
+	case ActionKO:
+		return bahamut.AuthActionKO
+	default:
+		return bahamut.AuthActionContinue
+	}
+}
+
+// An Expression is a logical expression on the claims of a request.
+// Exactly one of its fields must be set. In YAML or JSON, an expression
+// can also be given as a single string, which is equivalent to an expression
+// with only Claim set.
+type Expression struct {
+
+	// Claim matches if the request has a claim matching it.
+	// It can contain * wildcards, like `@auth:role=*`.
+	Claim string `yaml:"claim,omitempty" json:"claim,omitempty"`
+
+	// All matches if all of its expressions match.
+	All []*Expression `yaml:"all,omitempty" json:"all,omitempty"`
+
+	// Any matches if any of its expressions match.
+	Any []*Expression `yaml:"any,omitempty" json:"any,omitempty"`
+
+	// Not matches if its expression does not match.
+	Not *Expression `yaml:"not,omitempty" json:"not,omitempty"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (e *Expression) UnmarshalYAML(value *yaml.Node) error {
+
+	if value.Kind == yaml.ScalarNode {
+		e.Claim = value.Value
+		return nil
+	}
+
+	type expression Expression
+	return value.Decode((*expression)(e))
+}
+
+// String returns a compact representation of the expression.
+func (e *Expression) String() string {
+
+	switch {
+	case e.Claim != "":
+		return e.Claim
+	case e.Not != nil:
+		return "not(" + e.Not.String() + ")"
+	case len(e.All) > 0:
+		return "all(" + joinExpressions(e.All) + ")"
+	default:
+		return "any(" + joinExpressions(e.Any) + ")"
+	}
+}
+
+func (e *Expression) validate() error {
+
+	set := 0
+	if e.Claim != "" {
+		set++
+	}
+	if e.Not != nil {
+		set++
+	}
+	if len(e.All) > 0 {
+		set++
+	}
+	if len(e.Any) > 0 {
+		set++
+	}
+
+	if set != 1 {
+		return errors.New("an expression must have exactly one of claim, all, any or not")
+	}
+
+	if e.Claim != "" && !strings.Contains(e.Claim, "=") {
+		return fmt.Errorf("invalid claim '%s': must be in the form key=value", e.Claim)
+	}
+
+	subs := append(append([]*Expression{}, e.All...), e.Any...)
+	if e.Not != nil {
+		subs = append(subs, e.Not)
+	}
+
+	for _, sub := range subs {
+		if sub == nil {
+			return errors.New("an expression must not be empty")
+		}
+		if err := sub.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A Rule gives the Action to take for the requests it matches.
+// Empty fields match every request. List fields match if any
+// of their values match.
+type Rule struct {
+
+	// Name is the name of the rule, used in explanations.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Identities are the names or categories of the identities to match.
+	// The value * matches every identity.
+	Identities []string `yaml:"identities,omitempty" json:"identities,omitempty"`
+
+	// Operations are the operations to match.
+	Operations []elemental.Operation `yaml:"operations,omitempty" json:"operations,omitempty"`
+
+	// ParentIdentities are the names or categories of the parent identities to match.
+	// The value * matches every identity.
+	ParentIdentities []string `yaml:"parentIdentities,omitempty" json:"parentIdentities,omitempty"`
+
+	// Namespaces are the namespace prefixes to match. A prefix matches
+	// the namespace itself and all of its children.
+	Namespaces []string `yaml:"namespaces,omitempty" json:"namespaces,omitempty"`
+
+	// Claims is the expression the claims of the request must match.
+	Claims *Expression `yaml:"claims,omitempty" json:"claims,omitempty"`
+
+	// Action is the action to take if the rule matches.
+	Action Action `yaml:"action" json:"action"`
+}
+
+func (r *Rule) validate() error {
+
+	if err := validateAction(r.Action); err != nil {
+		return err
+	}
+
+	for _, op := range r.Operations {
+		switch op {
+		case elemental.OperationCreate,
+			elemental.OperationDelete,
+			elemental.OperationInfo,
+			elemental.OperationPatch,
+			elemental.OperationRetrieve,
+			elemental.OperationRetrieveMany,
+			elemental.OperationUpdate:
+		default:
+			return fmt.Errorf("invalid operation '%s'", op)
+		}
+	}
+
+	for _, ns := range r.Namespaces {
+		if !strings.HasPrefix(ns, "/") {
+			return fmt.Errorf("invalid namespace '%s': must start with /", ns)
+		}
+	}
+
+	if r.Claims != nil {
+		if err := r.Claims.validate(); err != nil {
+			return fmt.Errorf("invalid claims: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// A Policy is an ordered list of Rules.
+type Policy struct {
+
+	// DefaultAction is the action to take when no rule matches.
+	// The default is continue.
+	DefaultAction Action `yaml:"defaultAction,omitempty" json:"defaultAction,omitempty"`
+
+	// Rules are the rules of the policy. The first matching rule wins.
+	Rules []*Rule `yaml:"rules" json:"rules"`
+}
+
+// Validate returns an error if the policy is not valid.
+func (p *Policy) Validate() error {
+
+	if p.DefaultAction != "" {
+		if err := validateAction(p.DefaultAction); err != nil {
+			return fmt.Errorf("invalid default action: %w", err)
+		}
+	}
+
+	for i, r := range p.Rules {
+
+		if r == nil {
+			return fmt.Errorf("rule %d is empty", i)
+		}
+
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("invalid rule '%s': %w", ruleName(r, i), err)
+		}
+	}
+
+	return nil
+}
+
+// ParsePolicy parses and validates the given YAML or JSON document.
+// Unknown fields are rejected.
+func ParsePolicy(data []byte) (*Policy, error) {
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	p := &Policy{}
+	if err := decoder.Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to decode policy: %w", err)
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// LoadPolicy reads, parses and validates the YAML or JSON policy file
+// at the given path.
+func LoadPolicy(path string) (*Policy, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read policy file: %w", err)
+	}
+
+	return ParsePolicy(data)
+}
+
+func validateAction(a Action) error {
+
+	switch a {
+	case ActionOK, ActionKO, ActionContinue:
+		return nil
+	default:
+		return fmt.Errorf("invalid action '%s': must be ok, ko or continue", a)
+	}
+}
+
+func ruleName(r *Rule, i int) string {
+
+	if r.Name != "" {
+		return r.Name
+	}
+
+	return fmt.Sprintf("#%d", i)
+}
+
+func joinExpressions(exprs []*Expression) string {
+
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = e.String()
+	}
+
+	return strings.Join(parts, ", ")
+}
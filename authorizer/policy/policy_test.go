@@ -0,0 +1,115 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/elemental"
+)
+
+const testPolicyYAML = `
+defaultAction: ko
+rules:
+  - name: admins
+    namespaces: [/acme]
+    claims:
+      any:
+        - "@auth:role=admin"
+        - all: ["@auth:realm=certificate", "@auth:organization=acme"]
+    action: ok
+
+  - name: no list deletion
+    identities: [list]
+    operations: [delete]
+    action: ko
+
+  - name: readers
+    operations: [retrieve, retrieve-many, info]
+    parentIdentities: ["*"]
+    claims:
+      not: "@auth:role=banned"
+    action: ok
+`
+
+func TestParsePolicy(t *testing.T) {
+
+	Convey("Given I have a YAML policy", t, func() {
+
+		p, err := ParsePolicy([]byte(testPolicyYAML))
+
+		Convey("Then it should be parsed", func() {
+			So(err, ShouldBeNil)
+			So(p.DefaultAction, ShouldEqual, ActionKO)
+			So(len(p.Rules), ShouldEqual, 3)
+			So(p.Rules[0].Namespaces, ShouldResemble, []string{"/acme"})
+			So(p.Rules[0].Claims.String(), ShouldEqual, "any(@auth:role=admin, all(@auth:realm=certificate, @auth:organization=acme))")
+			So(p.Rules[1].Operations, ShouldResemble, []elemental.Operation{elemental.OperationDelete})
+			So(p.Rules[2].ParentIdentities, ShouldResemble, []string{"*"})
+			So(p.Rules[2].Claims.String(), ShouldEqual, "not(@auth:role=banned)")
+		})
+	})
+
+	Convey("Given I have a JSON policy", t, func() {
+
+		p, err := ParsePolicy([]byte(`{
+			"rules": [
+				{"identities": ["task"], "claims": {"claim": "@auth:subject=*"}, "action": "ok"}
+			]
+		}`))
+
+		Convey("Then it should be parsed", func() {
+			So(err, ShouldBeNil)
+			So(p.DefaultAction, ShouldEqual, Action(""))
+			So(p.Rules[0].Identities, ShouldResemble, []string{"task"})
+			So(p.Rules[0].Claims.Claim, ShouldEqual, "@auth:subject=*")
+		})
+	})
+
+	Convey("Given I have an empty policy", t, func() {
+
+		p, err := ParsePolicy(nil)
+
+		Convey("Then it should continue", func() {
+			So(err, ShouldBeNil)
+			So(Evaluate(p, Input{}).Action, ShouldEqual, bahamut.AuthActionContinue)
+		})
+	})
+
+	Convey("Given I have invalid policies", t, func() {
+
+		for doc, msg := range map[string]string{
+			`rules: [{action: maybe}]`:                                       "invalid rule '#0': invalid action 'maybe': must be ok, ko or continue",
+			`defaultAction: nope`:                                            "invalid default action: invalid action 'nope': must be ok, ko or continue",
+			`rules: [{name: r, operations: [burn], action: ok}]`:             "invalid rule 'r': invalid operation 'burn'",
+			`rules: [{name: r, namespaces: [acme], action: ok}]`:             "invalid rule 'r': invalid namespace 'acme': must start with /",
+			`rules: [{name: r, claims: {claim: a=b, not: c=d}, action: ok}]`: "invalid rule 'r': invalid claims: an expression must have exactly one of claim, all, any or not",
+			`rules: [{name: r, claims: {all: []}, action: ok}]`:              "invalid rule 'r': invalid claims: an expression must have exactly one of claim, all, any or not",
+			`rules: [{name: r, claims: {any: [~]}, action: ok}]`:             "invalid rule 'r': invalid claims: an expression must not be empty",
+			`rules: [{name: r, claims: nokey, action: ok}]`:                  "invalid rule 'r': invalid claims: invalid claim 'nokey': must be in the form key=value",
+			`rules: [~]`: "rule 0 is empty",
+		} {
+			_, err := ParsePolicy([]byte(doc))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, msg)
+		}
+
+		_, err := ParsePolicy([]byte(`rules: [{action: ok, unknown: true}]`))
+		So(err, ShouldNotBeNil)
+
+		_, err = ParsePolicy([]byte(`{{{`))
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -119,6 +119,11 @@ func NewServer(cfg config) Server {
 		cfg.model.unmarshallers = map[elemental.Identity]CustomUmarshaller{}
 	}
 
+	// The namespace enforcer runs before the configured authorizers.
+	if enforcer := cfg.security.namespaceEnforcer; enforcer != nil {
+		cfg.security.authorizers = append([]Authorizer{enforcer}, cfg.security.authorizers...)
+	}
+
 	mux := bone.New()
 	srv := &server{
 		multiplexer:          mux,
@@ -223,6 +228,20 @@ func (b *server) RoutesInfo() map[int][]RouteInfo {
 	return buildVersionedRoutes(b.cfg.model.modelManagers, b.ProcessorForIdentity)
 }
 
+func (b *server) OpenAPI() map[int]*OpenAPIDocument {
+
+	info := OpenAPIInfo{
+		Title:   b.cfg.meta.serviceName,
+		Version: b.cfg.meta.serviceVersion,
+	}
+
+	if info.Title == "" {
+		info.Title = "bahamut"
+	}
+
+	return buildVersionedOpenAPI(b.cfg.model.modelManagers, b.ProcessorForIdentity, info, b.cfg.restServer.apiPrefix)
+}
+
 func (b *server) VersionsInfo() map[string]any {
 
 	return b.cfg.meta.version
@@ -248,7 +267,7 @@ func (b *server) Run(ctx context.Context) {
 	}
 
 	if b.restServer != nil {
-		go b.restServer.start(ctx, b.RoutesInfo())
+		go b.restServer.start(ctx, b.RoutesInfo(), b.OpenAPI())
 	}
 
 	if b.pushServer != nil {
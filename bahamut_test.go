@@ -92,6 +92,11 @@ func TestBahamut_NewBahamut(t *testing.T) {
 		Convey("Then pushing an event should not panic", func() {
 			So(func() { b.Push(elemental.NewEvent(elemental.EventCreate, testmodel.NewList())) }, ShouldNotPanic)
 		})
+
+		Convey("Then it should provide its OpenAPI documents", func() {
+			_, ok := b.(OpenAPIProvider)
+			So(ok, ShouldBeTrue)
+		})
 	})
 
 	Convey("Given I create a new Bahamut with all servers", t, func() {
@@ -243,6 +248,24 @@ func TestBahamut_PushEndpoint(t *testing.T) {
 			})
 		})
 	})
+
+	Convey("Given I have a bahamut server with namespace enforcement", t, func() {
+
+		authorizers := []Authorizer{&mockAuth{}}
+
+		cfg := config{}
+		cfg.security.authorizers = authorizers
+		OptNamespaceEnforcement("@auth:namespace", true)(&cfg)
+
+		b := NewServer(cfg)
+
+		Convey("Then the namespace enforcer should run first", func() {
+			So(len(b.(*server).cfg.security.authorizers), ShouldEqual, 2)
+			So(b.(*server).cfg.security.authorizers[0], ShouldEqual, cfg.security.namespaceEnforcer)
+			So(b.(*server).cfg.security.authorizers[1], ShouldEqual, authorizers[0])
+			So(len(authorizers), ShouldEqual, 1)
+		})
+	})
 }
 
 func TestBahamut_ProcessorRegistration(t *testing.T) {
@@ -69,13 +69,16 @@ type config struct {
 		requestAuthenticators []RequestAuthenticator
 		sessionAuthenticators []SessionAuthenticator
 		authorizers           []Authorizer
+		namespaceEnforcer     *namespaceEnforcer
 	}
 	pushServer struct {
 		service                   PubSubClient
 		dispatchHandler           PushDispatchHandler
 		publishHandler            PushPublishHandler
+		outbox                    PushOutbox
 		topic                     string
 		endpoint                  string
+		outboxPollInterval        time.Duration
 		enabled                   bool
 		subjectHierarchiesEnabled bool
 		publishEnabled            bool
@@ -95,14 +98,17 @@ type config struct {
 		modelManagers              map[int]elemental.ModelManager
 		unmarshallers              map[elemental.Identity]CustomUmarshaller
 		marshallers                map[elemental.Identity]CustomMarshaller
+		validators                 map[elemental.Identity][]CustomValidator
 		retriever                  IdentifiableRetriever
 		readOnlyExcludedIdentities []elemental.Identity
 		readOnly                   bool
+		strictDecoding             bool
 	}
 	tls struct {
 		clientCAPool                    *x509.CertPool
 		serverCertificatesRetrieverFunc func(*tls.ClientHelloInfo) (*tls.Certificate, error)
 		peerCertificateVerifyFunc       func([][]byte, [][]*x509.Certificate) error
+		reloader                        *TLSReloader
 		serverCertificates              []tls.Certificate
 		nextProtos                      []string
 		authType                        tls.ClientAuthType
@@ -132,6 +138,11 @@ type config struct {
 		enabled               bool
 		disableKeepalive      bool
 		disableCompression    bool
+		maxRequestBodySize    int64
+		maxRequestBodySizes   map[elemental.Identity]int64
+		http3ServerMaker      HTTP3ServerMaker
+		http3ListenAddress    string
+		h2cEnabled            bool
 	}
 	general struct{ panicRecoveryDisabled bool }
 }
@@ -124,6 +124,8 @@ func dispatchCreateOperation(
 	processorFinder processorFinderFunc,
 	modelManager elemental.ModelManager,
 	unmarshaller CustomUmarshaller,
+	validators []CustomValidator,
+	strictDecoding bool,
 	authenticators []RequestAuthenticator,
 	authorizers []Authorizer,
 	pusher eventPusherFunc,
@@ -165,19 +167,18 @@ func dispatchCreateOperation(
 	} else {
 		obj = modelManager.Identifiable(ctx.request.Identity)
 		if len(ctx.Request().Data) > 0 {
-			if err := ctx.Request().Decode(obj); err != nil {
+			if err = decodeRequestData(ctx.Request(), obj, modelManager, strictDecoding); err != nil {
 				audit(auditer, ctx, err)
-				return elemental.NewError("Bad Request", err.Error(), "bahamut", http.StatusBadRequest)
+				logValidationError(ctx, err)
+				return err
 			}
 		}
 	}
 
-	if v, ok := obj.(elemental.Validatable); ok {
-		if err = v.Validate(); err != nil {
-			audit(auditer, ctx, err)
-			logValidationError(ctx, err)
-			return err
-		}
+	if err = validateIdentifiable(ctx.Request(), obj, validators); err != nil {
+		audit(auditer, ctx, err)
+		logValidationError(ctx, err)
+		return err
 	}
 
 	ctx.inputData = obj
@@ -206,6 +207,8 @@ func dispatchUpdateOperation(
 	processorFinder processorFinderFunc,
 	modelManager elemental.ModelManager,
 	unmarshaller CustomUmarshaller,
+	validators []CustomValidator,
+	strictDecoding bool,
 	authenticators []RequestAuthenticator,
 	authorizers []Authorizer,
 	pusher eventPusherFunc,
@@ -247,19 +250,18 @@ func dispatchUpdateOperation(
 	} else {
 		obj = modelManager.Identifiable(ctx.request.Identity)
 		if len(ctx.Request().Data) > 0 {
-			if err := ctx.Request().Decode(obj); err != nil {
+			if err = decodeRequestData(ctx.Request(), obj, modelManager, strictDecoding); err != nil {
 				audit(auditer, ctx, err)
-				return elemental.NewError("Bad Request", err.Error(), "bahamut", http.StatusBadRequest)
+				logValidationError(ctx, err)
+				return err
 			}
 		}
 	}
 
-	if v, ok := obj.(elemental.Validatable); ok {
-		if err = v.Validate(); err != nil {
-			audit(auditer, ctx, err)
-			logValidationError(ctx, err)
-			return err
-		}
+	if err = validateIdentifiable(ctx.Request(), obj, validators); err != nil {
+		audit(auditer, ctx, err)
+		logValidationError(ctx, err)
+		return err
 	}
 
 	ctx.inputData = obj
@@ -342,6 +344,8 @@ func dispatchPatchOperation(
 	processorFinder processorFinderFunc,
 	modelManager elemental.ModelManager,
 	unmarshaller CustomUmarshaller,
+	validators []CustomValidator,
+	strictDecoding bool,
 	authenticators []RequestAuthenticator,
 	authorizers []Authorizer,
 	pusher eventPusherFunc,
@@ -391,9 +395,10 @@ func dispatchPatchOperation(
 		}
 	} else {
 		sparse = modelManager.SparseIdentifiable(ctx.request.Identity)
-		if err := ctx.Request().Decode(sparse); err != nil {
+		if err = decodeRequestData(ctx.Request(), sparse, modelManager, strictDecoding); err != nil {
 			audit(auditer, ctx, err)
-			return elemental.NewError("Bad Request", err.Error(), "bahamut", http.StatusBadRequest)
+			logValidationError(ctx, err)
+			return err
 		}
 	}
 
@@ -418,12 +423,10 @@ func dispatchPatchOperation(
 		}
 		patchable.Patch(sparse.(elemental.SparseIdentifiable))
 
-		if v, ok := patchable.(elemental.Validatable); ok {
-			if err = v.Validate(); err != nil {
-				audit(auditer, ctx, err)
-				logValidationError(ctx, err)
-				return err
-			}
+		if err = validateIdentifiable(ctx.Request(), identifiable, validators); err != nil {
+			audit(auditer, ctx, err)
+			logValidationError(ctx, err)
+			return err
 		}
 
 		ctx.inputData = patchable
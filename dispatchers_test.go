@@ -346,7 +346,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil)
+		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil)
 
 		expectedNbCalls := 1
 
@@ -388,7 +388,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 
 			Convey("Then I should not panic no events should be pushed", func() {
 				So(func() {
-					err = dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil)
+					err = dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil)
 				}, ShouldNotPanic)
 				So(err, ShouldBeNil)
 				So(ctx.InputData, ShouldNotBeNil)
@@ -412,7 +412,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 
 			Convey("Then I should not panic no events should be pushed", func() {
 				So(func() {
-					err = dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil)
+					err = dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil)
 				}, ShouldNotPanic)
 				So(err, ShouldBeNil)
 				So(ctx.InputData, ShouldNotBeNil)
@@ -422,6 +422,31 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 
 		})
 
+		Convey("Given a processor that can handle ProcessCreate function with a push outbox configured", func() {
+
+			var err error
+			processorFinder := func(identity elemental.Identity) (Processor, error) {
+				return &mockProcessor{
+					output: testmodel.NewList(),
+					events: []*elemental.Event{elemental.NewEvent(elemental.EventUpdate, &testmodel.List{})},
+				}, nil
+			}
+
+			cfg := config{}
+			cfg.pushServer.outbox = NewMemoryPushOutbox()
+			disableOutputDataPushWithOutbox(ctx, cfg)
+
+			Convey("Then only the enqueued events should be pushed", func() {
+				So(func() {
+					err = dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil)
+				}, ShouldNotPanic)
+				So(err, ShouldBeNil)
+				So(auditer.GetCallCount(), ShouldEqual, 1)
+				So(len(pusher.events), ShouldEqual, 1)
+				So(pusher.events[0].Type, ShouldEqual, elemental.EventUpdate)
+			})
+		})
+
 		Convey("Given a processor that can handle ProcessCreate function with a context output that contains a nil elemental.Identifiable", func() {
 
 			// notice how this is a type that satisfies the elemental.Identifiable interface, but it is not a nil interface!
@@ -439,7 +464,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 
 			Convey("Then I should not panic and an event should be pushed", func() {
 				So(func() {
-					err = dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil)
+					err = dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil)
 				}, ShouldNotPanic)
 				So(err, ShouldBeNil)
 				So(ctx.InputData, ShouldNotBeNil)
@@ -462,7 +487,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, true, nil)
+		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, true, nil)
 
 		Convey("Then I should have a 423 error and context should be nil", func() {
 			So(err, ShouldNotBeNil)
@@ -484,7 +509,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		expectedError := "error 400 (bahamut-test): Error: Bad request."
 		expectedNbCalls := 1
@@ -506,7 +531,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		expectedError := "error 422 (elemental): Validation Error: Attribute 'name' is required"
 		expectedNbCalls := 1
@@ -532,7 +557,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		expectedError := "error 400 (bahamut): Bad Request: unable to decode application/json: json decode error"
 		expectedNbCalls := 1
@@ -555,7 +580,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		expectedError := "error 422 (elemental): Validation Error: Data 'not-good' of attribute 'status' is not in list '[DONE PROGRESS TODO]'"
 		expectedNbCalls := 1
@@ -585,7 +610,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 		expectedNbCalls := 1
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		Convey("Then I should get a bahamut error and no context", func() {
 			So(err.Error(), ShouldEqual, expectedError)
@@ -611,7 +636,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 			testmodel.Manager(),
 			func(*elemental.Request) (elemental.Identifiable, error) {
 				return nil, fmt.Errorf(expectedError) //nolint:staticcheck
-			},
+			}, nil, false,
 			nil,
 			nil,
 			nil,
@@ -648,7 +673,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 		expectedNbCalls := 1
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, authenticators, nil, nil, auditer, false, nil)
+		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, authenticators, nil, nil, auditer, false, nil)
 
 		Convey("Then I should get a bahamut error and no context", func() {
 			So(err.Error(), ShouldEqual, expectedError)
@@ -688,7 +713,7 @@ func TestDispatchers_dispatchCreateOperation(t *testing.T) {
 		expectedNbCalls := 1
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, authenticators, authorizers, nil, auditer, false, nil)
+		err := dispatchCreateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, authenticators, authorizers, nil, auditer, false, nil)
 
 		Convey("Then I should get a bahamut error and no context", func() {
 			So(err.Error(), ShouldEqual, expectedError)
@@ -719,7 +744,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil)
+		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil)
 
 		expectedNbCalls := 1
 
@@ -761,7 +786,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 			Convey("Then I should not panic no events should be pushed", func() {
 				var err error
 				So(func() {
-					err = dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil)
+					err = dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil)
 				}, ShouldNotPanic)
 				So(err, ShouldBeNil)
 				So(ctx.InputData, ShouldNotBeNil)
@@ -784,7 +809,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 			Convey("Then I should not panic no events should be pushed", func() {
 				var err error
 				So(func() {
-					err = dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil)
+					err = dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil)
 				}, ShouldNotPanic)
 				So(err, ShouldBeNil)
 				So(ctx.InputData, ShouldNotBeNil)
@@ -810,7 +835,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 			Convey("Then I should not panic and an event should be pushed", func() {
 				var err error
 				So(func() {
-					err = dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil)
+					err = dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil)
 				}, ShouldNotPanic)
 				So(err, ShouldBeNil)
 				So(ctx.InputData, ShouldNotBeNil)
@@ -833,7 +858,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, true, nil)
+		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, true, nil)
 
 		Convey("Then I should have a 423 error and context should be nil", func() {
 			So(err, ShouldNotBeNil)
@@ -855,7 +880,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		expectedError := "error 400 (bahamut-test): Error: Bad request."
 		expectedNbCalls := 1
@@ -877,7 +902,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		expectedError := "error 422 (elemental): Validation Error: Attribute 'name' is required"
 		expectedNbCalls := 1
@@ -904,7 +929,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		expectedError := "error 400 (bahamut): Bad Request: unable to decode application/json: json decode error [pos 1]: only encoded map or array can decode into struct"
 		expectedNbCalls := 1
@@ -927,7 +952,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		expectedError := "error 422 (elemental): Validation Error: Data 'not-good' of attribute 'status' is not in list '[DONE PROGRESS TODO]'"
 		expectedNbCalls := 1
@@ -957,7 +982,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 		expectedNbCalls := 1
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil)
+		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil)
 
 		Convey("Then I should get a bahamut error and no context", func() {
 			So(err.Error(), ShouldEqual, expectedError)
@@ -988,7 +1013,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 		expectedNbCalls := 1
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, authenticators, nil, nil, auditer, false, nil)
+		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, authenticators, nil, nil, auditer, false, nil)
 
 		Convey("Then I should get a bahamut error and no context", func() {
 			So(err.Error(), ShouldEqual, expectedError)
@@ -1028,7 +1053,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 		expectedNbCalls := 1
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, authenticators, authorizers, nil, auditer, false, nil)
+		err := dispatchUpdateOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, authenticators, authorizers, nil, auditer, false, nil)
 
 		Convey("Then I should get a bahamut error and no context", func() {
 			So(err.Error(), ShouldEqual, expectedError)
@@ -1054,7 +1079,7 @@ func TestDispatchers_dispatchUpdateOperation(t *testing.T) {
 			testmodel.Manager(),
 			func(*elemental.Request) (elemental.Identifiable, error) {
 				return nil, fmt.Errorf(expectedError) //nolint:staticcheck
-			},
+			}, nil, false,
 			nil,
 			nil,
 			nil,
@@ -1349,7 +1374,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, nil)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, nil)
 
 		expectedNbCalls := 1
 
@@ -1390,7 +1415,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 			Convey("Then I should not panic no events should be pushed", func() {
 				var err error
 				So(func() {
-					err = dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, nil)
+					err = dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, nil)
 				}, ShouldNotPanic)
 				So(err, ShouldBeNil)
 				So(auditer.GetCallCount(), ShouldEqual, 1)
@@ -1412,7 +1437,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 			Convey("Then I should not panic no events should be pushed", func() {
 				var err error
 				So(func() {
-					err = dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, nil)
+					err = dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, nil)
 				}, ShouldNotPanic)
 				So(err, ShouldBeNil)
 				So(ctx.InputData, ShouldNotBeNil)
@@ -1438,7 +1463,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 			Convey("Then I should not panic and an event should be pushed", func() {
 				var err error
 				So(func() {
-					err = dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, nil)
+					err = dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, nil)
 				}, ShouldNotPanic)
 				So(err, ShouldBeNil)
 				So(auditer.GetCallCount(), ShouldEqual, 1)
@@ -1460,7 +1485,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil, nil)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil, nil)
 
 		expectedError := "error 400 (bahamut): Bad Request: unable to decode application/json: json decode error [pos 1]: only encoded map or array can decode into struct"
 		expectedNbCalls := 1
@@ -1485,7 +1510,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil, nil)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil, nil)
 
 		expectedError := "error 400 (bahamut-test): Error: Bad request."
 		expectedNbCalls := 1
@@ -1512,7 +1537,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		expectedNbCalls := 1
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, false, nil, nil)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, false, nil, nil)
 
 		Convey("Then I should get a bahamut error and no context", func() {
 			So(err.Error(), ShouldEqual, expectedError)
@@ -1544,7 +1569,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		expectedNbCalls := 1
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, authenticators, nil, nil, auditer, false, nil, nil)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, authenticators, nil, nil, auditer, false, nil, nil)
 
 		Convey("Then I should get a bahamut error and no context", func() {
 			So(err.Error(), ShouldEqual, expectedError)
@@ -1585,7 +1610,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		expectedNbCalls := 1
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, authenticators, authorizers, nil, auditer, false, nil, nil)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, authenticators, authorizers, nil, auditer, false, nil, nil)
 
 		Convey("Then I should get a bahamut error and no context", func() {
 			So(err.Error(), ShouldEqual, expectedError)
@@ -1605,7 +1630,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		auditer := &mockAuditer{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, nil, auditer, true, nil, nil)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, nil, auditer, true, nil, nil)
 
 		Convey("Then I should have a 423 error and context should be nil", func() {
 			So(err, ShouldNotBeNil)
@@ -1631,7 +1656,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 			testmodel.Manager(),
 			func(*elemental.Request) (elemental.Identifiable, error) {
 				return nil, fmt.Errorf(expectedError) //nolint:staticcheck
-			},
+			}, nil, false,
 			nil,
 			nil,
 			nil,
@@ -1671,7 +1696,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, retriever)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, retriever)
 
 		expectedNbCalls := 1
 
@@ -1711,7 +1736,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, retriever)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, retriever)
 
 		expectedError := "error 422 (elemental): Validation Error: Data 'not-good' of attribute 'status' is not in list '[DONE PROGRESS TODO]'"
 		expectedNbCalls := 1
@@ -1755,7 +1780,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, retriever)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, retriever)
 
 		expectedNbCalls := 1
 
@@ -1794,7 +1819,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, retriever)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, retriever)
 
 		expectedNbCalls := 1
 
@@ -1833,7 +1858,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, retriever)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, retriever)
 
 		expectedNbCalls := 1
 
@@ -1870,7 +1895,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, retriever)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, retriever)
 
 		expectedNbCalls := 1
 
@@ -1903,7 +1928,7 @@ func TestDispatchers_dispatchPatchOperation(t *testing.T) {
 		pusher := &mockPusher{}
 
 		ctx := newContext(context.Background(), request)
-		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, nil, pusher.Push, auditer, false, nil, retriever)
+		err := dispatchPatchOperation(ctx, processorFinder, testmodel.Manager(), nil, nil, false, nil, nil, pusher.Push, auditer, false, nil, retriever)
 
 		expectedNbCalls := 1
 
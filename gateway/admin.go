@@ -0,0 +1,301 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// UpstreamerState describes the state of an InspectableUpstreamer.
+type UpstreamerState struct {
+	Services []ServiceState `json:"services"`
+	Peers    []PeerState    `json:"peers"`
+}
+
+// ServiceState describes a service known by an Upstreamer.
+type ServiceState struct {
+
+	// Name is the name of the service, prefixed by
+	// its prefix and a slash if it has one.
+	Name string `json:"name"`
+
+	// Routes are the keys the Upstreamer routes to the
+	// service, in the form <prefix>/<identity>.
+	Routes []string `json:"routes"`
+
+	// Endpoints are the endpoints of the service.
+	Endpoints []EndpointState `json:"endpoints"`
+}
+
+// EndpointState describes an endpoint of a service.
+type EndpointState struct {
+	LastSeen time.Time               `json:"lastSeen"`
+	Limiters map[string]LimiterState `json:"limiters,omitempty"`
+	Address  string                  `json:"address"`
+	Version  string                  `json:"version,omitempty"`
+
+	// Latency is the moving average of the response
+	// times of the endpoint, or 0 if it is not known yet.
+	Latency time.Duration `json:"latency"`
+	Load    float64       `json:"load"`
+	Ejected bool          `json:"ejected"`
+	Drained bool          `json:"drained"`
+}
+
+// LimiterState describes a rate limiter.
+type LimiterState struct {
+	Limit  rate.Limit `json:"limit"`
+	Burst  int        `json:"burst"`
+	Tokens float64    `json:"tokens"`
+}
+
+// PeerState describes a peer gateway.
+type PeerState struct {
+	LastSeen time.Time `json:"lastSeen"`
+	ID       string    `json:"id"`
+}
+
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+}
+
+type ejectionRequest struct {
+	Duration string `json:"duration"`
+}
+
+type sourceRateLimitState struct {
+	RPS        rate.Limit `json:"rps"`
+	Burst      int        `json:"burst"`
+	Enabled    bool       `json:"enabled"`
+	Dynamic    bool       `json:"dynamic"`
+	Overridden bool       `json:"overridden"`
+}
+
+// makeAdminHandler returns the handler of the admin server.
+func (s *gateway) makeAdminHandler() http.Handler {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /services", s.handleAdminServices)
+	mux.HandleFunc("GET /peers", s.handleAdminPeers)
+
+	mux.HandleFunc("GET /maintenance", s.handleAdminGetMaintenance)
+	mux.HandleFunc("PUT /maintenance", s.handleAdminSetMaintenance)
+
+	mux.HandleFunc("PUT /endpoints/{address}/drain", s.handleAdminDrain(true))
+	mux.HandleFunc("DELETE /endpoints/{address}/drain", s.handleAdminDrain(false))
+	mux.HandleFunc("PUT /endpoints/{address}/ejection", s.handleAdminEject)
+	mux.HandleFunc("DELETE /endpoints/{address}/ejection", s.handleAdminReinstate)
+
+	mux.HandleFunc("GET /ratelimit", s.handleAdminGetRateLimit)
+	mux.HandleFunc("PUT /ratelimit", s.handleAdminSetRateLimit)
+	mux.HandleFunc("DELETE /ratelimit", s.handleAdminResetRateLimit)
+
+	return mux
+}
+
+func (s *gateway) handleAdminServices(w http.ResponseWriter, r *http.Request) {
+
+	u, ok := s.upstreamer.(InspectableUpstreamer)
+	if !ok {
+		writeError(w, r, makeError(http.StatusNotImplemented, "Not Implemented", "The upstreamer cannot be inspected"))
+		return
+	}
+
+	writeAdminResponse(w, u.Inspect().Services)
+}
+
+func (s *gateway) handleAdminPeers(w http.ResponseWriter, r *http.Request) {
+
+	u, ok := s.upstreamer.(InspectableUpstreamer)
+	if !ok {
+		writeError(w, r, makeError(http.StatusNotImplemented, "Not Implemented", "The upstreamer cannot be inspected"))
+		return
+	}
+
+	writeAdminResponse(w, u.Inspect().Peers)
+}
+
+func (s *gateway) handleAdminGetMaintenance(w http.ResponseWriter, _ *http.Request) {
+
+	writeAdminResponse(w, maintenanceState{Enabled: s.maintenance.Load()})
+}
+
+func (s *gateway) handleAdminSetMaintenance(w http.ResponseWriter, r *http.Request) {
+
+	var state maintenanceState
+	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+		writeError(w, r, makeError(http.StatusBadRequest, "Bad Request", fmt.Sprintf("unable to decode maintenance state: %s", err)))
+		return
+	}
+
+	if s.maintenance.Swap(state.Enabled) != state.Enabled {
+		zap.L().Info("Maintenance mode changed from admin server", zap.Bool("enabled", state.Enabled))
+	}
+
+	writeAdminResponse(w, state)
+}
+
+func (s *gateway) handleAdminDrain(drained bool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		u, ok := s.upstreamer.(ControllableUpstreamer)
+		if !ok {
+			writeError(w, r, makeError(http.StatusNotImplemented, "Not Implemented", "The upstreamer cannot be controlled"))
+			return
+		}
+
+		address := r.PathValue("address")
+
+		if err := u.DrainEndpoint(address, drained); err != nil {
+			writeAdminControlError(w, r, err)
+			return
+		}
+
+		zap.L().Info("Endpoint drain changed from admin server",
+			zap.String("backend", address),
+			zap.Bool("drained", drained),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *gateway) handleAdminEject(w http.ResponseWriter, r *http.Request) {
+
+	var req ejectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, makeError(http.StatusBadRequest, "Bad Request", fmt.Sprintf("unable to decode ejection: %s", err)))
+		return
+	}
+
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		writeError(w, r, makeError(http.StatusBadRequest, "Bad Request", fmt.Sprintf("invalid duration '%s'", req.Duration)))
+		return
+	}
+
+	s.ejectEndpoint(w, r, duration)
+}
+
+func (s *gateway) handleAdminReinstate(w http.ResponseWriter, r *http.Request) {
+
+	s.ejectEndpoint(w, r, 0)
+}
+
+func (s *gateway) ejectEndpoint(w http.ResponseWriter, r *http.Request, duration time.Duration) {
+
+	u, ok := s.upstreamer.(ControllableUpstreamer)
+	if !ok {
+		writeError(w, r, makeError(http.StatusNotImplemented, "Not Implemented", "The upstreamer cannot be controlled"))
+		return
+	}
+
+	address := r.PathValue("address")
+
+	if err := u.EjectEndpoint(address, duration); err != nil {
+		writeAdminControlError(w, r, err)
+		return
+	}
+
+	zap.L().Info("Endpoint ejection changed from admin server",
+		zap.String("backend", address),
+		zap.Duration("duration", duration),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *gateway) handleAdminGetRateLimit(w http.ResponseWriter, _ *http.Request) {
+
+	writeAdminResponse(w, s.sourceRateLimitState())
+}
+
+func (s *gateway) handleAdminSetRateLimit(w http.ResponseWriter, r *http.Request) {
+
+	if s.sourceLimiter == nil {
+		writeError(w, r, makeError(http.StatusConflict, "Conflict", "Source rate limiting is not enabled"))
+		return
+	}
+
+	var state sourceRateLimitState
+	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+		writeError(w, r, makeError(http.StatusBadRequest, "Bad Request", fmt.Sprintf("unable to decode rate limit: %s", err)))
+		return
+	}
+
+	if state.RPS <= 0 || state.Burst <= 0 {
+		writeError(w, r, makeError(http.StatusBadRequest, "Bad Request", "rps and burst must be > 0"))
+		return
+	}
+
+	s.sourceLimiter.setOverride(state.RPS, state.Burst)
+
+	zap.L().Info("Source rate limit overridden from admin server",
+		zap.Float64("rps", float64(state.RPS)),
+		zap.Int("burst", state.Burst),
+	)
+
+	writeAdminResponse(w, s.sourceRateLimitState())
+}
+
+func (s *gateway) handleAdminResetRateLimit(w http.ResponseWriter, r *http.Request) {
+
+	if s.sourceLimiter == nil {
+		writeError(w, r, makeError(http.StatusConflict, "Conflict", "Source rate limiting is not enabled"))
+		return
+	}
+
+	s.sourceLimiter.setOverride(0, 0)
+
+	zap.L().Info("Source rate limit override removed from admin server")
+
+	writeAdminResponse(w, s.sourceRateLimitState())
+}
+
+func (s *gateway) sourceRateLimitState() sourceRateLimitState {
+
+	if s.sourceLimiter == nil {
+		return sourceRateLimitState{}
+	}
+
+	state := sourceRateLimitState{
+		Enabled: true,
+		Dynamic: s.sourceLimiter.rateExtractor != nil,
+		RPS:     s.sourceLimiter.defaultLimit,
+		Burst:   s.sourceLimiter.defaultBurst,
+	}
+
+	if o := s.sourceLimiter.override.Load(); o != nil {
+		state.Overridden = true
+		state.RPS = o.limit
+		state.Burst = o.burst
+	}
+
+	return state
+}
+
+func writeAdminControlError(w http.ResponseWriter, r *http.Request, err error) {
+
+	if errors.Is(err, ErrUpstreamerUnknownEndpoint) {
+		writeError(w, r, makeError(http.StatusNotFound, "Not Found", err.Error()))
+		return
+	}
+
+	writeError(w, r, makeError(http.StatusInternalServerError, "Internal Server Error", err.Error()))
+}
+
+func writeAdminResponse(w http.ResponseWriter, data any) {
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		zap.L().Error("Unable to encode admin response", zap.Error(err))
+	}
+}
@@ -0,0 +1,259 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/time/rate"
+)
+
+type fakeAdminUpstreamer struct {
+	drained map[string]bool
+	ejected map[string]time.Duration
+	state   UpstreamerState
+}
+
+func (u *fakeAdminUpstreamer) Upstream(*http.Request) (string, error) { return "", nil }
+
+func (u *fakeAdminUpstreamer) Inspect() UpstreamerState { return u.state }
+
+func (u *fakeAdminUpstreamer) DrainEndpoint(address string, drained bool) error {
+
+	if address != "1.1.1.1:1" {
+		return ErrUpstreamerUnknownEndpoint
+	}
+
+	u.drained[address] = drained
+
+	return nil
+}
+
+func (u *fakeAdminUpstreamer) EjectEndpoint(address string, duration time.Duration) error {
+
+	if address != "1.1.1.1:1" {
+		return ErrUpstreamerUnknownEndpoint
+	}
+
+	u.ejected[address] = duration
+
+	return nil
+}
+
+func TestAdminServer(t *testing.T) {
+
+	call := func(h http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+		return w
+	}
+
+	Convey("Given I have a gateway with an inspectable and controllable upstreamer", t, func() {
+
+		now := time.Now().UTC().Truncate(time.Second)
+
+		u := &fakeAdminUpstreamer{
+			drained: map[string]bool{},
+			ejected: map[string]time.Duration{},
+			state: UpstreamerState{
+				Services: []ServiceState{
+					{
+						Name:   "cats",
+						Routes: []string{"/cats"},
+						Endpoints: []EndpointState{
+							{Address: "1.1.1.1:1", LastSeen: now, Latency: time.Millisecond, Load: 0.2},
+						},
+					},
+				},
+				Peers: []PeerState{{ID: "peer1", LastSeen: now}},
+			},
+		}
+
+		s := &gateway{upstreamer: u}
+		h := s.makeAdminHandler()
+
+		Convey("When I list the services", func() {
+
+			w := call(h, http.MethodGet, "/services", "")
+
+			Convey("Then I should get them", func() {
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var services []ServiceState
+				So(json.Unmarshal(w.Body.Bytes(), &services), ShouldBeNil)
+				So(services, ShouldResemble, u.state.Services)
+			})
+		})
+
+		Convey("When I list the peers", func() {
+
+			w := call(h, http.MethodGet, "/peers", "")
+
+			Convey("Then I should get them", func() {
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var peers []PeerState
+				So(json.Unmarshal(w.Body.Bytes(), &peers), ShouldBeNil)
+				So(peers, ShouldResemble, u.state.Peers)
+			})
+		})
+
+		Convey("When I enable the maintenance", func() {
+
+			w := call(h, http.MethodPut, "/maintenance", `{"enabled": true}`)
+
+			Convey("Then the maintenance should be enabled", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(s.maintenance.Load(), ShouldBeTrue)
+				So(call(h, http.MethodGet, "/maintenance", "").Body.String(), ShouldEqual, "{\"enabled\":true}\n")
+			})
+
+			Convey("When I disable the maintenance", func() {
+
+				call(h, http.MethodPut, "/maintenance", `{"enabled": false}`)
+
+				Convey("Then the maintenance should be disabled", func() {
+					So(s.maintenance.Load(), ShouldBeFalse)
+				})
+			})
+		})
+
+		Convey("When I send an invalid maintenance state", func() {
+
+			w := call(h, http.MethodPut, "/maintenance", `nope`)
+
+			Convey("Then it should fail", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(s.maintenance.Load(), ShouldBeFalse)
+			})
+		})
+
+		Convey("When I drain and undrain an endpoint", func() {
+
+			So(call(h, http.MethodPut, "/endpoints/1.1.1.1:1/drain", "").Code, ShouldEqual, http.StatusNoContent)
+			So(u.drained["1.1.1.1:1"], ShouldBeTrue)
+
+			So(call(h, http.MethodDelete, "/endpoints/1.1.1.1:1/drain", "").Code, ShouldEqual, http.StatusNoContent)
+			So(u.drained["1.1.1.1:1"], ShouldBeFalse)
+		})
+
+		Convey("When I eject and reinstate an endpoint", func() {
+
+			So(call(h, http.MethodPut, "/endpoints/1.1.1.1:1/ejection", `{"duration": "1m"}`).Code, ShouldEqual, http.StatusNoContent)
+			So(u.ejected["1.1.1.1:1"], ShouldEqual, time.Minute)
+
+			So(call(h, http.MethodDelete, "/endpoints/1.1.1.1:1/ejection", "").Code, ShouldEqual, http.StatusNoContent)
+			So(u.ejected["1.1.1.1:1"], ShouldEqual, 0)
+		})
+
+		Convey("When I eject an endpoint with an invalid duration", func() {
+
+			Convey("Then it should fail", func() {
+				So(call(h, http.MethodPut, "/endpoints/1.1.1.1:1/ejection", `{"duration": "nope"}`).Code, ShouldEqual, http.StatusBadRequest)
+				So(call(h, http.MethodPut, "/endpoints/1.1.1.1:1/ejection", `{"duration": "-1s"}`).Code, ShouldEqual, http.StatusBadRequest)
+				So(u.ejected, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I act on an unknown endpoint", func() {
+
+			Convey("Then it should fail", func() {
+				So(call(h, http.MethodPut, "/endpoints/2.2.2.2:1/drain", "").Code, ShouldEqual, http.StatusNotFound)
+				So(call(h, http.MethodDelete, "/endpoints/2.2.2.2:1/ejection", "").Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When I set the rate limit without source rate limiting", func() {
+
+			w := call(h, http.MethodPut, "/ratelimit", `{"rps": 10, "burst": 20}`)
+
+			Convey("Then it should fail", func() {
+				So(w.Code, ShouldEqual, http.StatusConflict)
+				So(call(h, http.MethodGet, "/ratelimit", "").Body.String(), ShouldContainSubstring, `"enabled":false`)
+			})
+		})
+	})
+
+	Convey("Given I have a gateway with an upstreamer that cannot be inspected nor controlled", t, func() {
+
+		s := &gateway{upstreamer: &outcomeUpstreamer{}}
+		h := s.makeAdminHandler()
+
+		Convey("Then the related admin endpoints should not be implemented", func() {
+			So(call(h, http.MethodGet, "/services", "").Code, ShouldEqual, http.StatusNotImplemented)
+			So(call(h, http.MethodGet, "/peers", "").Code, ShouldEqual, http.StatusNotImplemented)
+			So(call(h, http.MethodPut, "/endpoints/1.1.1.1:1/drain", "").Code, ShouldEqual, http.StatusNotImplemented)
+			So(call(h, http.MethodDelete, "/endpoints/1.1.1.1:1/ejection", "").Code, ShouldEqual, http.StatusNotImplemented)
+		})
+	})
+
+	Convey("Given I have a gateway with source rate limiting", t, func() {
+
+		var served int
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served++ })
+
+		s := &gateway{
+			upstreamer: &outcomeUpstreamer{},
+			sourceLimiter: newSourceLimiter(
+				next,
+				next,
+				rate.Limit(1000),
+				1000,
+				&defaultSourceExtractor{},
+				nil,
+				&errorHandler{},
+				nil,
+			),
+		}
+		h := s.makeAdminHandler()
+
+		Convey("When I override the rate limit", func() {
+
+			w := call(h, http.MethodPut, "/ratelimit", `{"rps": 1, "burst": 2}`)
+
+			Convey("Then the override should be applied", func() {
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var state sourceRateLimitState
+				So(json.Unmarshal(w.Body.Bytes(), &state), ShouldBeNil)
+				So(state, ShouldResemble, sourceRateLimitState{RPS: 1, Burst: 2, Enabled: true, Overridden: true})
+
+				for i := 0; i < 5; i++ {
+					call(s.sourceLimiter, http.MethodGet, "/cats", "")
+				}
+				So(served, ShouldEqual, 2)
+			})
+
+			Convey("When I remove the override", func() {
+
+				w := call(h, http.MethodDelete, "/ratelimit", "")
+
+				Convey("Then the configured rate limit should be used", func() {
+
+					var state sourceRateLimitState
+					So(json.Unmarshal(w.Body.Bytes(), &state), ShouldBeNil)
+					So(state, ShouldResemble, sourceRateLimitState{RPS: 1000, Burst: 1000, Enabled: true})
+				})
+			})
+		})
+
+		Convey("When I set an invalid rate limit", func() {
+
+			Convey("Then it should fail", func() {
+				So(call(h, http.MethodPut, "/ratelimit", `{"rps": 0, "burst": 2}`).Code, ShouldEqual, http.StatusBadRequest)
+				So(call(h, http.MethodPut, "/ratelimit", `{`).Code, ShouldEqual, http.StatusBadRequest)
+				So(s.sourceLimiter.override.Load(), ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,496 @@
+package gateway
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karlseguin/ccache/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.aporeto.io/bahamut/internal/metrics"
+)
+
+const (
+	cacheStatusHeader = "X-Cache"
+
+	cacheStatusHit         = "HIT"
+	cacheStatusMiss        = "MISS"
+	cacheStatusRevalidated = "REVALIDATED"
+	cacheStatusBypass      = "BYPASS"
+
+	// cacheStaleRetention is for how long the stale responses
+	// having a validator are kept to be revalidated.
+	cacheStaleRetention = time.Hour
+)
+
+// cacheableStatusCodes are the status codes of the
+// responses that can be cached, as defined by RFC 9110.
+var cacheableStatusCodes = map[int]struct{}{
+	http.StatusOK:                   {},
+	http.StatusNonAuthoritativeInfo: {},
+	http.StatusNoContent:            {},
+	http.StatusMultipleChoices:      {},
+	http.StatusMovedPermanently:     {},
+	http.StatusPermanentRedirect:    {},
+	http.StatusNotFound:             {},
+	http.StatusMethodNotAllowed:     {},
+	http.StatusGone:                 {},
+	http.StatusRequestURITooLong:    {},
+	http.StatusNotImplemented:       {},
+}
+
+// A cachedResponse is a response stored in the cache.
+type cachedResponse struct {
+	stored  time.Time // the time the response was generated.
+	expires time.Time
+	header  http.Header
+	vary    map[string]string
+	body    []byte
+	code    int
+}
+
+// Size implements the ccache.Sized interface so the
+// cache is bounded by the size of the responses.
+func (e *cachedResponse) Size() int64 {
+
+	size := int64(len(e.body))
+	for k, v := range e.header {
+		size += int64(len(k))
+		for _, s := range v {
+			size += int64(len(s))
+		}
+	}
+
+	return size
+}
+
+func (e *cachedResponse) fresh(now time.Time) bool {
+	return now.Before(e.expires)
+}
+
+func (e *cachedResponse) hasValidator() bool {
+	return e.header.Get("ETag") != "" || e.header.Get("Last-Modified") != ""
+}
+
+// matches returns true if the given request has the same values
+// for the headers listed in the Vary header of the response.
+func (e *cachedResponse) matches(r *http.Request) bool {
+
+	for k, v := range e.vary {
+		if strings.Join(r.Header.Values(k), ",") != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// notModified returns true if the conditional headers
+// of the given request match the response.
+func (e *cachedResponse) notModified(r *http.Request) bool {
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+
+		etag := strings.TrimPrefix(e.header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+
+		modified, err := http.ParseTime(e.header.Get("Last-Modified"))
+		if err != nil {
+			return false
+		}
+
+		return !modified.After(since)
+	}
+
+	return false
+}
+
+// A responseCache is a http.Handler caching the responses to the
+// GET requests according to their Cache-Control, Expires and Vary
+// headers, and revalidating them with the upstreams using their
+// ETag and Last-Modified headers.
+//
+// The responses are cached per source, as returned by the
+// SourceExtractor, and per namespace, so they are never shared
+// between clients. The requests without a source, or using
+// the default one, are never cached.
+type responseCache struct {
+	next            http.Handler
+	sourceExtractor SourceExtractor
+	corsInjector    errorHeaderInjector
+	store           *ccache.Cache
+	requestsMetric  *prometheus.CounterVec
+	entriesMetric   prometheus.Gauge
+	maxEntrySize    int64
+}
+
+func newResponseCache(next http.Handler, corsInjector errorHeaderInjector, cfg *gwconfig) (*responseCache, error) {
+
+	c := &responseCache{
+		next:            next,
+		sourceExtractor: cfg.responseCacheSourceExtractor,
+		corsInjector:    corsInjector,
+		store:           ccache.New(ccache.Configure().MaxSize(cfg.responseCacheMaxSize)),
+		maxEntrySize:    cfg.responseCacheMaxEntrySize,
+	}
+
+	if registerer := cfg.responseCacheMetricsRegisterer; registerer != nil {
+
+		var err error
+
+		if c.requestsMetric, err = metrics.RegisterCollector(registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_response_cache_requests_total",
+				Help: "The total number of GET requests handled by the response cache, per result.",
+			},
+			[]string{"result"},
+		)); err != nil {
+			return nil, fmt.Errorf("unable to register response cache requests metric: %w", err)
+		}
+
+		if c.entriesMetric, err = metrics.RegisterCollector(registerer, prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "gateway_response_cache_entries",
+				Help: "The number of responses stored in the response cache.",
+			},
+		)); err != nil {
+			return nil, fmt.Errorf("unable to register response cache entries metric: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *responseCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet || r.Header.Get(internalWSMarkingHeader) != "" {
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	reqCC := parseCacheControl(r.Header.Values("Cache-Control"))
+
+	source, err := c.sourceExtractor.ExtractSource(r)
+	if _, noStore := reqCC["no-store"]; noStore || err != nil || source == "" || source == defaultSource {
+		c.observe(cacheStatusBypass)
+		w.Header().Set(cacheStatusHeader, cacheStatusBypass)
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	// The path may have been rewritten by the Upstreamer, like
+	// the push Upstreamer trimming the prefix of the services,
+	// so we use the original one to not mix different services.
+	key := strings.Join([]string{source, r.Header.Get("X-Namespace"), originalPath(r), r.URL.RawQuery}, "\x00")
+	now := time.Now()
+
+	var entry *cachedResponse
+	if item := c.store.Get(key); item != nil {
+		if e := item.Value().(*cachedResponse); e.matches(r) {
+			entry = e
+		}
+	}
+
+	_, noCache := reqCC["no-cache"]
+	if entry != nil && entry.fresh(now) && !noCache && reqCC["max-age"] != "0" {
+		c.observe(cacheStatusHit)
+		c.serve(w, r, entry, cacheStatusHit, now)
+		return
+	}
+
+	// If we have a stale response, we ask the upstream to revalidate
+	// it, unless the client sent its own conditional headers, in which
+	// case the response of the upstream is forwarded as is.
+	revalidating := entry != nil &&
+		entry.hasValidator() &&
+		r.Header.Get("If-None-Match") == "" &&
+		r.Header.Get("If-Modified-Since") == ""
+
+	upstreamReq := r
+	if revalidating {
+		upstreamReq = r.Clone(r.Context())
+		if etag := entry.header.Get("ETag"); etag != "" {
+			upstreamReq.Header.Set("If-None-Match", etag)
+		}
+		if lm := entry.header.Get("Last-Modified"); lm != "" {
+			upstreamReq.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	cw := &cacheWriter{
+		ResponseWriter: w,
+		maxSize:        c.maxEntrySize,
+		intercept304:   revalidating,
+	}
+
+	w.Header().Set(cacheStatusHeader, cacheStatusMiss)
+	c.next.ServeHTTP(cw, upstreamReq)
+
+	if cw.intercepted {
+
+		// The upstream confirmed the stale response is still valid.
+		// We update it with the headers of the 304 response.
+		updated := *entry
+		updated.header = entry.header.Clone()
+		for _, k := range []string{"Cache-Control", "Expires", "Date", "ETag", "Last-Modified"} {
+			if v := w.Header().Values(k); len(v) > 0 {
+				updated.header[http.CanonicalHeaderKey(k)] = v
+			}
+		}
+
+		if stored, ok := c.makeEntry(r, updated.code, updated.header, updated.body, now); ok {
+			c.set(key, stored)
+			entry = stored
+		}
+
+		c.observe(cacheStatusRevalidated)
+		c.serve(w, r, entry, cacheStatusRevalidated, now)
+		return
+	}
+
+	c.observe(cacheStatusMiss)
+
+	if cw.overflow {
+		return
+	}
+
+	if stored, ok := c.makeEntry(r, cw.code, w.Header(), cw.body.Bytes(), now); ok {
+		c.set(key, stored)
+	}
+}
+
+// makeEntry returns the cachedResponse to store for the given
+// response, or false if the response must not be cached.
+func (c *responseCache) makeEntry(r *http.Request, code int, header http.Header, body []byte, now time.Time) (*cachedResponse, bool) {
+
+	if _, ok := cacheableStatusCodes[code]; !ok {
+		return nil, false
+	}
+
+	if header.Get("Set-Cookie") != "" {
+		return nil, false
+	}
+
+	respCC := parseCacheControl(header.Values("Cache-Control"))
+	if _, ok := respCC["no-store"]; ok {
+		return nil, false
+	}
+
+	entry := &cachedResponse{
+		code:   code,
+		header: http.Header{},
+		body:   append([]byte(nil), body...),
+		stored: now,
+	}
+
+	for k, v := range header {
+		if k == cacheStatusHeader || strings.HasPrefix(k, "Access-Control-") {
+			continue
+		}
+		entry.header[k] = append([]string(nil), v...)
+	}
+
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil, false
+			}
+
+			if name != "" {
+				if entry.vary == nil {
+					entry.vary = map[string]string{}
+				}
+				entry.vary[name] = strings.Join(r.Header.Values(name), ",")
+			}
+		}
+	}
+
+	if age, err := strconv.Atoi(header.Get("Age")); err == nil && age > 0 {
+		entry.stored = now.Add(-time.Duration(age) * time.Second)
+	}
+
+	var lifetime time.Duration
+	_, noCache := respCC["no-cache"]
+
+	switch {
+
+	case noCache:
+
+	case respCC["max-age"] != "":
+		if maxAge, err := strconv.Atoi(respCC["max-age"]); err == nil && maxAge > 0 {
+			lifetime = time.Duration(maxAge) * time.Second
+		}
+
+	case header.Get("Expires") != "":
+		if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+			date, err := http.ParseTime(header.Get("Date"))
+			if err != nil {
+				date = now
+			}
+			lifetime = expires.Sub(date)
+		}
+	}
+
+	entry.expires = entry.stored.Add(lifetime)
+
+	ttl := entry.expires.Sub(now)
+	if entry.hasValidator() {
+		ttl += cacheStaleRetention
+	}
+
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func (c *responseCache) set(key string, entry *cachedResponse) {
+
+	ttl := entry.expires.Sub(time.Now())
+	if entry.hasValidator() {
+		ttl += cacheStaleRetention
+	}
+
+	c.store.Set(key, entry, ttl)
+
+	if c.entriesMetric != nil {
+		c.entriesMetric.Set(float64(c.store.ItemCount()))
+	}
+}
+
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request, entry *cachedResponse, status string, now time.Time) {
+
+	h := w.Header()
+	for k := range h {
+		delete(h, k)
+	}
+
+	for k, v := range entry.header {
+		h[k] = append([]string(nil), v...)
+	}
+
+	h.Set("Age", strconv.Itoa(int(now.Sub(entry.stored).Seconds())))
+	h.Set(cacheStatusHeader, status)
+	c.corsInjector(w, r)
+
+	if entry.notModified(r) {
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(entry.code)
+	w.Write(entry.body) // nolint: errcheck
+}
+
+func (c *responseCache) observe(result string) {
+
+	if c.requestsMetric != nil {
+		c.requestsMetric.WithLabelValues(strings.ToLower(result)).Inc()
+	}
+}
+
+// A cacheWriter is a http.ResponseWriter keeping a copy
+// of the body written, up to maxSize bytes.
+// If intercept304 is true, a 304 Not Modified response is
+// not written to the underlying http.ResponseWriter.
+type cacheWriter struct {
+	http.ResponseWriter
+	body         bytes.Buffer
+	maxSize      int64
+	code         int
+	intercept304 bool
+	intercepted  bool
+	overflow     bool
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+
+	if w.code != 0 {
+		return
+	}
+
+	w.code = code
+
+	if w.intercept304 && code == http.StatusNotModified {
+		w.intercepted = true
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheWriter) Write(data []byte) (int, error) {
+
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.intercepted {
+		return len(data), nil
+	}
+
+	if !w.overflow {
+		if int64(w.body.Len()+len(data)) > w.maxSize {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(data) // nolint: errcheck
+		}
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap allows the http.ResponseController
+// to access the underlying http.ResponseWriter.
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// parseCacheControl parses the given Cache-Control header values
+// and returns the directives, with their value if any.
+func parseCacheControl(values []string) map[string]string {
+
+	directives := map[string]string{}
+
+	for _, v := range values {
+		for _, directive := range strings.Split(v, ",") {
+
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+
+			name, value, _ := strings.Cut(directive, "=")
+			directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+
+	return directives
+}
@@ -0,0 +1,359 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// cacheUpstream is a http.Handler counting the requests
+// and responding with the given headers and body.
+type cacheUpstream struct {
+	header   http.Header
+	body     string
+	requests []*http.Request
+}
+
+func (u *cacheUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	u.requests = append(u.requests, r)
+
+	for k, v := range u.header {
+		w.Header()[k] = v
+	}
+
+	if etag := u.header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "upstream")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(u.body))
+}
+
+func TestResponseCache(t *testing.T) {
+
+	makeCache := func(upstream *cacheUpstream) *responseCache {
+
+		cfg := newGatewayConfig()
+		OptionResponseCache(1024*1024, 16, NewDefaultSourceExtractor(""))(cfg)
+		cfg.responseCacheMetricsRegisterer = prometheus.NewRegistry()
+
+		c, err := newResponseCache(
+			upstream,
+			func(w http.ResponseWriter, r *http.Request) http.Header {
+				w.Header().Set("Access-Control-Allow-Origin", "gateway")
+				return w.Header()
+			},
+			cfg,
+		)
+		So(err, ShouldBeNil)
+
+		return c
+	}
+
+	call := func(c *responseCache, header http.Header) *httptest.ResponseRecorder {
+
+		req := httptest.NewRequest(http.MethodGet, "/cats?name=felix", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, req)
+
+		return w
+	}
+
+	requests := func(c *responseCache, result string) float64 {
+		return testutil.ToFloat64(c.requestsMetric.WithLabelValues(result))
+	}
+
+	alice := http.Header{"Authorization": {"Bearer alice"}}
+	bob := http.Header{"Authorization": {"Bearer bob"}}
+
+	Convey("Given I have a response cache and a fresh response", t, func() {
+
+		upstream := &cacheUpstream{
+			header: http.Header{"Cache-Control": {"private, max-age=60"}},
+			body:   "felix",
+		}
+		c := makeCache(upstream)
+
+		Convey("When I send the same request twice", func() {
+
+			w1 := call(c, alice)
+			w2 := call(c, alice)
+
+			Convey("Then the second response should come from the cache", func() {
+				So(upstream.requests, ShouldHaveLength, 1)
+				So(w1.Header().Get("X-Cache"), ShouldEqual, "MISS")
+				So(w2.Header().Get("X-Cache"), ShouldEqual, "HIT")
+				So(w2.Code, ShouldEqual, http.StatusOK)
+				So(w2.Body.String(), ShouldEqual, "felix")
+				So(w2.Header().Get("Cache-Control"), ShouldEqual, "private, max-age=60")
+				So(w2.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "gateway")
+				So(w2.Header().Get("Age"), ShouldEqual, "0")
+				So(requests(c, "miss"), ShouldEqual, 1)
+				So(requests(c, "hit"), ShouldEqual, 1)
+				So(testutil.ToFloat64(c.entriesMetric), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I send the same request from another source", func() {
+
+			call(c, alice)
+			w := call(c, bob)
+
+			Convey("Then the response should not come from the cache", func() {
+				So(upstream.requests, ShouldHaveLength, 2)
+				So(w.Header().Get("X-Cache"), ShouldEqual, "MISS")
+			})
+		})
+
+		Convey("When I send the same request without authentication", func() {
+
+			call(c, nil)
+			w := call(c, nil)
+
+			Convey("Then the cache should be bypassed", func() {
+				So(upstream.requests, ShouldHaveLength, 2)
+				So(w.Header().Get("X-Cache"), ShouldEqual, "BYPASS")
+			})
+		})
+
+		Convey("When I send the same request in another namespace", func() {
+
+			call(c, http.Header{"Authorization": {"Bearer alice"}, "X-Namespace": {"/a"}})
+			w := call(c, http.Header{"Authorization": {"Bearer alice"}, "X-Namespace": {"/b"}})
+
+			Convey("Then the response should not come from the cache", func() {
+				So(upstream.requests, ShouldHaveLength, 2)
+				So(w.Header().Get("X-Cache"), ShouldEqual, "MISS")
+			})
+		})
+
+		Convey("When I send requests whose prefix has been trimmed to the same path", func() {
+
+			for _, path := range []string{"/_v2/cats", "/cats"} {
+				req := httptest.NewRequest(http.MethodGet, "/cats?name=felix", nil)
+				req.Header.Set("Authorization", "Bearer alice")
+				c.ServeHTTP(httptest.NewRecorder(), withOriginalPath(req, path))
+			}
+
+			Convey("Then the response should not be shared", func() {
+				So(upstream.requests, ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When I send a conditional request matching the cached response", func() {
+
+			upstream.header.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+
+			call(c, alice)
+			w := call(c, http.Header{
+				"Authorization":     {"Bearer alice"},
+				"If-Modified-Since": {"Mon, 02 Jan 2006 15:04:05 GMT"},
+			})
+
+			Convey("Then I should get a 304 from the cache", func() {
+				So(upstream.requests, ShouldHaveLength, 1)
+				So(w.Code, ShouldEqual, http.StatusNotModified)
+				So(w.Body.String(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I send a request with Cache-Control no-store", func() {
+
+			call(c, alice)
+			w := call(c, http.Header{"Authorization": {"Bearer alice"}, "Cache-Control": {"no-store"}})
+
+			Convey("Then the cache should be bypassed", func() {
+				So(upstream.requests, ShouldHaveLength, 2)
+				So(w.Header().Get("X-Cache"), ShouldEqual, "BYPASS")
+				So(requests(c, "bypass"), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I send a request that is not a GET", func() {
+
+			call(c, alice)
+
+			req := httptest.NewRequest(http.MethodPost, "/cats?name=felix", nil)
+			req.Header.Set("Authorization", "Bearer alice")
+			c.ServeHTTP(httptest.NewRecorder(), req)
+
+			Convey("Then the cache should not be used", func() {
+				So(upstream.requests, ShouldHaveLength, 2)
+			})
+		})
+	})
+
+	Convey("Given I have a response cache and a response varying on Accept", t, func() {
+
+		upstream := &cacheUpstream{
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept"}},
+			body:   "felix",
+		}
+		c := makeCache(upstream)
+
+		Convey("When I send requests with different Accept headers", func() {
+
+			call(c, http.Header{"Authorization": {"Bearer alice"}, "Accept": {"application/json"}})
+			w1 := call(c, http.Header{"Authorization": {"Bearer alice"}, "Accept": {"application/msgpack"}})
+			w2 := call(c, http.Header{"Authorization": {"Bearer alice"}, "Accept": {"application/msgpack"}})
+
+			Convey("Then only the requests with the same Accept header should share the response", func() {
+				So(upstream.requests, ShouldHaveLength, 2)
+				So(w1.Header().Get("X-Cache"), ShouldEqual, "MISS")
+				So(w2.Header().Get("X-Cache"), ShouldEqual, "HIT")
+			})
+		})
+	})
+
+	Convey("Given I have a response cache and responses that cannot be cached", t, func() {
+
+		for _, tc := range []struct {
+			name   string
+			header http.Header
+			body   string
+		}{
+			{"no-store", http.Header{"Cache-Control": {"no-store, max-age=60"}}, "felix"},
+			{"cookie", http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=b"}}, "felix"},
+			{"vary *", http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}, "felix"},
+			{"no freshness", http.Header{}, "felix"},
+			{"too large", http.Header{"Cache-Control": {"max-age=60"}}, "felix the cat is too large"},
+		} {
+
+			Convey("When I send the same request twice with "+tc.name, func() {
+
+				upstream := &cacheUpstream{header: tc.header, body: tc.body}
+				c := makeCache(upstream)
+
+				call(c, alice)
+				w := call(c, alice)
+
+				Convey("Then both should reach the upstream", func() {
+					So(upstream.requests, ShouldHaveLength, 2)
+					So(w.Body.String(), ShouldEqual, tc.body)
+				})
+			})
+		}
+	})
+
+	Convey("Given I have a response cache and a response to revalidate", t, func() {
+
+		upstream := &cacheUpstream{
+			header: http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}},
+			body:   "felix",
+		}
+		c := makeCache(upstream)
+
+		Convey("When I send the same request twice", func() {
+
+			call(c, alice)
+			w := call(c, alice)
+
+			Convey("Then the response should be revalidated with the upstream", func() {
+				So(upstream.requests, ShouldHaveLength, 2)
+				So(upstream.requests[1].Header.Get("If-None-Match"), ShouldEqual, `"v1"`)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, "felix")
+				So(w.Header().Get("X-Cache"), ShouldEqual, "REVALIDATED")
+				So(requests(c, "revalidated"), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the response changed upstream", func() {
+
+			call(c, alice)
+			upstream.header.Set("ETag", `"v2"`)
+			upstream.body = "garfield"
+			w := call(c, alice)
+
+			Convey("Then I should get the new response", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, "garfield")
+				So(w.Header().Get("X-Cache"), ShouldEqual, "MISS")
+			})
+		})
+
+		Convey("When I send a request with my own conditional headers", func() {
+
+			call(c, alice)
+			w := call(c, http.Header{"Authorization": {"Bearer alice"}, "If-None-Match": {`"v1"`}})
+
+			Convey("Then the 304 of the upstream should be forwarded", func() {
+				So(w.Code, ShouldEqual, http.StatusNotModified)
+			})
+		})
+	})
+
+	Convey("Given I have a response cache and a fresh response with a validator", t, func() {
+
+		upstream := &cacheUpstream{
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Etag": {`"v1"`}},
+			body:   "felix",
+		}
+		c := makeCache(upstream)
+
+		Convey("When I send a request with Cache-Control no-cache", func() {
+
+			call(c, alice)
+			w := call(c, http.Header{"Authorization": {"Bearer alice"}, "Cache-Control": {"no-cache"}})
+
+			Convey("Then the response should be revalidated", func() {
+				So(upstream.requests, ShouldHaveLength, 2)
+				So(w.Header().Get("X-Cache"), ShouldEqual, "REVALIDATED")
+			})
+		})
+	})
+}
+
+func TestParseCacheControl(t *testing.T) {
+
+	Convey("Calling parseCacheControl should work", t, func() {
+		So(parseCacheControl(nil), ShouldResemble, map[string]string{})
+		So(
+			parseCacheControl([]string{`Max-Age=60, private="Set-Cookie"`, "no-cache,"}),
+			ShouldResemble,
+			map[string]string{"max-age": "60", "private": "Set-Cookie", "no-cache": ""},
+		)
+	})
+}
+
+func TestCachedResponseFreshness(t *testing.T) {
+
+	Convey("Given I have a response cache", t, func() {
+
+		c := &responseCache{}
+		now := time.Now()
+		req := httptest.NewRequest(http.MethodGet, "/cats", nil)
+
+		Convey("Then the freshness should be computed from Expires and Age", func() {
+
+			e, ok := c.makeEntry(req, http.StatusOK, http.Header{
+				"Date":    {now.UTC().Format(http.TimeFormat)},
+				"Expires": {now.Add(time.Minute).UTC().Format(http.TimeFormat)},
+				"Age":     {"10"},
+			}, nil, now)
+
+			So(ok, ShouldBeTrue)
+			So(e.fresh(now.Add(45*time.Second)), ShouldBeTrue)
+			So(e.fresh(now.Add(55*time.Second)), ShouldBeFalse)
+		})
+
+		Convey("Then a response with an uncacheable status should not be stored", func() {
+
+			_, ok := c.makeEntry(req, http.StatusInternalServerError, http.Header{"Cache-Control": {"max-age=60"}}, nil, now)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
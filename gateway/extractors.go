@@ -7,6 +7,10 @@ import (
 	"github.com/cespare/xxhash"
 )
 
+// defaultSource is the source returned by the default SourceExtractor
+// for the requests without any authentication string.
+const defaultSource = "default"
+
 type defaultSourceExtractor struct {
 	authCookieName string
 }
@@ -41,7 +45,7 @@ func (f defaultSourceExtractor) ExtractSource(r *http.Request) (string, error) {
 	case authHeader != "":
 		v = authHeader
 	default:
-		return "default", nil
+		return defaultSource, nil
 	}
 
 	return fmt.Sprintf("%d", xxhash.Sum64([]byte(v))), nil
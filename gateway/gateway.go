@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/armon/go-proxyproto"
@@ -21,6 +22,7 @@ import (
 	"github.com/vulcand/oxy/v2/utils"
 	"go.aporeto.io/bahamut"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
 )
 
 // An gateway is cool
@@ -28,17 +30,24 @@ type gateway struct {
 	server                 *http.Server
 	upstreamer             Upstreamer
 	upstreamerLatency      LatencyBasedUpstreamer
+	upstreamerOutcome      OutcomeBasedUpstreamer
+	upstreamerOpenAPI      OpenAPIUpstreamer
 	forwarder              *httputil.ReverseProxy
+	retrier                *retrier
+	sourceLimiter          *sourceLimiter
 	proxyHTTPHandler       http.Handler
 	proxyWSHandler         http.Handler
 	listener               net.Listener
 	goodbyeServer          *http.Server
+	adminServer            *http.Server
+	adminListener          net.Listener
 	gatewayConfig          *gwconfig
 	corsOriginInjectorFunc func(w http.ResponseWriter, r *http.Request) http.Header
+	maintenance            atomic.Bool
 }
 
 // New returns a new Gateway.
-func New(listenAddr string, upstreamer Upstreamer, options ...Option) (Gateway, error) {
+func New(listenAddr string, upstreamer Upstreamer, options ...Option) (gw Gateway, err error) {
 
 	cfg := newGatewayConfig()
 	for _, o := range options {
@@ -56,6 +65,14 @@ func New(listenAddr string, upstreamer Upstreamer, options ...Option) (Gateway,
 		return nil, fmt.Errorf("unable build fast tcp listener: %s", err)
 	}
 
+	// The remaining steps can fail. In that case, the
+	// listeners are closed so their ports are not left bound.
+	defer func() {
+		if err != nil {
+			_ = rootListener.Close()
+		}
+	}()
+
 	if cfg.tcpGlobalRateLimitingEnabled {
 		rootListener = newLimitedListener(
 			rootListener,
@@ -110,10 +127,45 @@ func New(listenAddr string, upstreamer Upstreamer, options ...Option) (Gateway,
 		gatewayConfig: cfg,
 	}
 
+	s.maintenance.Store(cfg.maintenance)
+
+	if cfg.adminListenAddr != "" {
+
+		if s.adminListener, err = net.Listen("tcp", cfg.adminListenAddr); err != nil {
+			return nil, fmt.Errorf("unable to listen on admin address: %s", err)
+		}
+
+		defer func() {
+			if err != nil {
+				_ = s.adminListener.Close()
+			}
+		}()
+
+		if cfg.adminTLSConfig != nil {
+			s.adminListener = tls.NewListener(s.adminListener, cfg.adminTLSConfig)
+		}
+
+		s.adminServer = &http.Server{
+			ReadTimeout:  cfg.httpReadTimeout,
+			WriteTimeout: cfg.httpWriteTimeout,
+			IdleTimeout:  cfg.httpIdleTimeout,
+			ErrorLog:     serverLogger,
+			Handler:      s.makeAdminHandler(),
+		}
+	}
+
 	if u, ok := s.upstreamer.(LatencyBasedUpstreamer); ok {
 		s.upstreamerLatency = u
 	}
 
+	if u, ok := s.upstreamer.(OutcomeBasedUpstreamer); ok {
+		s.upstreamerOutcome = u
+	}
+
+	if u, ok := s.upstreamer.(OpenAPIUpstreamer); ok {
+		s.upstreamerOpenAPI = u
+	}
+
 	s.server = &http.Server{
 		ReadTimeout:  cfg.httpReadTimeout,
 		WriteTimeout: cfg.httpWriteTimeout,
@@ -154,12 +206,13 @@ func New(listenAddr string, upstreamer Upstreamer, options ...Option) (Gateway,
 	s.forwarder.BufferPool = newPool(1024 * 1024)
 	s.forwarder.ErrorHandler = (&errorHandler{corsOriginInjector: s.corsOriginInjectorFunc}).ServeHTTP
 	s.forwarder.Director = nil
-	s.forwarder.Transport = &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		DualStack: true,
+	}
+	s.forwarder.Transport = s.wrapTransport(&http.Transport{
+		DialContext:         dialer.DialContext,
 		ForceAttemptHTTP2:   cfg.upstreamUseHTTP2,
 		TLSClientConfig:     cfg.upstreamTLSConfig,
 		DisableCompression:  !cfg.upstreamEnableCompression,
@@ -168,12 +221,13 @@ func New(listenAddr string, upstreamer Upstreamer, options ...Option) (Gateway,
 		MaxIdleConnsPerHost: cfg.upstreamMaxIdleConnsPerHost,
 		TLSHandshakeTimeout: cfg.upstreamTLSHandshakeTimeout,
 		IdleConnTimeout:     cfg.upstreamIdleConnTimeout,
-	}
+	})
 	s.forwarder.Rewrite = (&requestRewriter{
-		blockOpenTracing:   (!cfg.exposePrivateAPIs && cfg.blockOpenTracingHeaders),
-		private:            cfg.exposePrivateAPIs,
-		customRewriter:     cfg.requestRewriter,
-		trustForwardHeader: cfg.trustForwardHeader,
+		blockOpenTracing:       (!cfg.exposePrivateAPIs && cfg.blockOpenTracingHeaders),
+		private:                cfg.exposePrivateAPIs,
+		customRewriter:         cfg.requestRewriter,
+		trustForwardHeader:     cfg.trustForwardHeader,
+		legacyClientCertHeader: cfg.legacyClientCertHeader,
 	}).Rewrite
 	s.forwarder.ModifyResponse = func(resp *http.Response) error {
 
@@ -202,6 +256,47 @@ func New(listenAddr string, upstreamer Upstreamer, options ...Option) (Gateway,
 	topProxyHTTPHandler = s.forwarder
 	topProxyWSHandler = s.forwarder
 
+	if cfg.upstreamUseH2C {
+
+		// Websockets cannot be upgraded over HTTP/2, so we
+		// keep a forwarder using the HTTP/1.1 transport for them.
+		wsForwarder := *s.forwarder
+		topProxyWSHandler = &wsForwarder
+
+		s.forwarder.Transport = s.wrapTransport(&http2.Transport{
+			AllowHTTP:          true,
+			DisableCompression: !cfg.upstreamEnableCompression,
+			DialTLSContext: func(ctx context.Context, network string, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		})
+	}
+
+	if len(cfg.retryPolicies) > 0 {
+
+		// Like without retries, the latencies are only
+		// collected when a metrics manager is set.
+		var latency LatencyBasedUpstreamer
+		if cfg.metricsManager != nil {
+			latency = s.upstreamerLatency
+		}
+
+		if s.retrier, err = newRetrier(s.forwarder, upstreamer, latency, cfg); err != nil {
+			return nil, fmt.Errorf("unable to initialize retrier: %s", err)
+		}
+		topProxyHTTPHandler = s.retrier
+	}
+
+	if cfg.responseCacheMaxSize > 0 {
+		if topProxyHTTPHandler, err = newResponseCache(topProxyHTTPHandler, s.corsOriginInjectorFunc, cfg); err != nil {
+			return nil, fmt.Errorf("unable to initialize response cache: %s", err)
+		}
+	}
+
+	if len(cfg.requestBodyTransformers) > 0 || len(cfg.responseBodyTransformers) > 0 {
+		topProxyHTTPHandler = newBodyTransformer(topProxyHTTPHandler, s.corsOriginInjectorFunc, cfg)
+	}
+
 	if topProxyHTTPHandler, err = buffer.New(
 		topProxyHTTPHandler,
 		buffer.MaxRequestBodyBytes(1024*1024),
@@ -227,7 +322,7 @@ func New(listenAddr string, upstreamer Upstreamer, options ...Option) (Gateway,
 	}
 
 	if cfg.sourceRateLimitingEnabled {
-		srcLimiter := newSourceLimiter(
+		s.sourceLimiter = newSourceLimiter(
 			topProxyHTTPHandler,
 			topProxyWSHandler,
 			cfg.sourceRateLimitingRPS,
@@ -237,8 +332,8 @@ func New(listenAddr string, upstreamer Upstreamer, options ...Option) (Gateway,
 			&errorHandler{corsOriginInjector: s.corsOriginInjectorFunc},
 			cfg.sourceRateLimitingMetricManager,
 		)
-		topProxyHTTPHandler = srcLimiter
-		topProxyWSHandler = srcLimiter
+		topProxyHTTPHandler = s.sourceLimiter
+		topProxyWSHandler = s.sourceLimiter
 	}
 
 	if cfg.upstreamCircuitBreakerCond != "" {
@@ -257,6 +352,18 @@ func New(listenAddr string, upstreamer Upstreamer, options ...Option) (Gateway,
 	return s, nil
 }
 
+// wrapTransport wraps the given transport so the outcome
+// of the round trips is reported to the upstreamer, if it
+// implements OutcomeBasedUpstreamer.
+func (s *gateway) wrapTransport(transport http.RoundTripper) http.RoundTripper {
+
+	if s.upstreamerOutcome == nil {
+		return transport
+	}
+
+	return &outcomeRoundTripper{next: transport, upstreamer: s.upstreamerOutcome}
+}
+
 // Start starts the http server
 func (s *gateway) Start() {
 
@@ -269,6 +376,17 @@ func (s *gateway) Start() {
 			zap.L().Fatal("Unable to start internal API server", zap.Error(err))
 		}
 	}()
+
+	if s.adminServer != nil {
+		go func() {
+			if err := s.adminServer.Serve(s.adminListener); err != nil {
+				if err == http.ErrServerClosed {
+					return
+				}
+				zap.L().Fatal("Unable to start admin server", zap.Error(err))
+			}
+		}()
+	}
 }
 
 func (s *gateway) Stop() {
@@ -283,6 +401,11 @@ func (s *gateway) Stop() {
 		} else {
 			zap.L().Debug("Internet API server stopped")
 		}
+		if s.adminServer != nil {
+			if err := s.adminServer.Shutdown(stopCtx); err != nil {
+				zap.L().Error("Could not gracefully stop admin server", zap.Error(err))
+			}
+		}
 	}()
 
 	// We start a temporary server to tell the world we are not serving requests anymore
@@ -367,7 +490,7 @@ func (s *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.gatewayConfig.maintenance {
+	if s.maintenance.Load() && !s.bypassMaintenance(r) {
 		h := w.Header()
 		h.Set("Content-Type", "application/msgpack, application/json")
 		injectCORSHeader(
@@ -384,6 +507,15 @@ func (s *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	path := r.URL.Path
 
+	// The response cache, the body transformers and the retrier
+	// need the path before it is rewritten by the routing.
+	if s.retrier != nil ||
+		s.gatewayConfig.responseCacheMaxSize > 0 ||
+		len(s.gatewayConfig.requestBodyTransformers) > 0 ||
+		len(s.gatewayConfig.responseBodyTransformers) > 0 {
+		r = withOriginalPath(r, path)
+	}
+
 	var upstream string
 	var interceptAction InterceptorAction
 	var err error
@@ -434,11 +566,30 @@ HANDLE_INTERCEPTION:
 		return
 	}
 
+	// An upstream returned by an interceptor must not
+	// be changed when retrying the request.
+	if upstream != "" && s.retrier != nil {
+		r = withFixedUpstream(r)
+	}
+
 	// If we don't have an upstream returned by an interceptor,
 	// we find it as usual.
 	if upstream == "" {
 
-		if upstream, err = s.upstreamer.Upstream(r); err != nil {
+		if s.upstreamerOpenAPI != nil && s.serveOpenAPI(w, r) {
+			return
+		}
+
+		upstream, err = s.upstreamer.Upstream(r)
+
+		// The requests allowed to bypass the maintenance are
+		// forwarded to the upstream chosen by the Upstreamer.
+		var merr *MaintenanceError
+		if errors.As(err, &merr) && merr.Upstream != "" && s.bypassMaintenance(r) {
+			upstream, err = merr.Upstream, nil
+		}
+
+		if err != nil {
 
 			switch {
 
@@ -450,6 +601,13 @@ HANDLE_INTERCEPTION:
 				s.corsOriginInjectorFunc(w, r)
 				writeError(w, r, errRateLimit)
 
+			case merr != nil:
+
+				if mm := s.gatewayConfig.metricsManager; mm != nil {
+					mm.MeasureRequest(r.Method, path)(http.StatusLocked, nil)
+				}
+				s.writeMaintenanceError(w, r, merr)
+
 			default:
 
 				zap.L().Error("Upstreamer error",
@@ -542,7 +700,9 @@ HANDLE_INTERCEPTION:
 
 		if finish != nil {
 			rt := finish(0, nil)
-			if s.upstreamerLatency != nil {
+			// The retrier collects the latency of each attempt,
+			// and the responses served from the cache have none.
+			if s.upstreamerLatency != nil && s.retrier == nil && w.Header().Get(cacheStatusHeader) != cacheStatusHit {
 				s.upstreamerLatency.CollectLatency(upstream, rt)
 			}
 		}
@@ -5,6 +5,7 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
@@ -575,3 +576,34 @@ func TestGateway(t *testing.T) {
 
 	})
 }
+
+func TestGateway_NewError(t *testing.T) {
+
+	Convey("Given I have an admin address and an invalid circuit breaker condition", t, func() {
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		adminAddr := l.Addr().String()
+		So(l.Close(), ShouldBeNil)
+
+		Convey("When I create the gateway", func() {
+
+			gw, err := New(
+				"127.0.0.1:7767",
+				&simpleUpstreamer{},
+				OptionAdminServer(adminAddr, nil),
+				OptionUpstreamConfig(0, 0, 0, 0, 0, "not a condition(", false),
+			)
+
+			Convey("Then it should fail and release the admin address", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "unable to initialize circuit breaker:")
+				So(gw, ShouldBeNil)
+
+				l, err := net.Listen("tcp", adminAddr)
+				So(err, ShouldBeNil)
+				So(l.Close(), ShouldBeNil)
+			})
+		})
+	})
+}
@@ -2,9 +2,11 @@ package gateway
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"go.aporeto.io/bahamut"
 	"golang.org/x/time/rate"
 )
 
@@ -14,6 +16,39 @@ import (
 // the client.
 var ErrUpstreamerTooManyRequests = errors.New("Please retry in a moment")
 
+// A MaintenanceError can be returned by an Upstreamer to instruct
+// the bahamut.Gateway to return a 423 Locked error to the client
+// because the requested service is in maintenance.
+type MaintenanceError struct {
+
+	// Message is the message returned to the client.
+	// If it is empty, a default message is used.
+	Message string
+
+	// Upstream is the upstream the request would have been
+	// forwarded to. If set, the requests allowed to bypass
+	// the maintenance are forwarded to it.
+	// See OptionMaintenanceBypass.
+	Upstream string
+
+	// RetryAfter is returned to the client in
+	// the Retry-After header, if it is not 0.
+	RetryAfter time.Duration
+}
+
+func (e *MaintenanceError) Error() string {
+
+	if e.Message == "" {
+		return "service in maintenance"
+	}
+
+	return fmt.Sprintf("service in maintenance: %s", e.Message)
+}
+
+// ErrUpstreamerUnknownEndpoint can be returned by a ControllableUpstreamer
+// when asked to act on an endpoint it does not know about.
+var ErrUpstreamerUnknownEndpoint = errors.New("unknown endpoint")
+
 // An Upstreamer is the interface that can compute upstreams.
 type Upstreamer interface {
 
@@ -44,6 +79,10 @@ type SourceExtractor interface {
 	ExtractSource(req *http.Request) (token string, err error)
 }
 
+// A ClaimsExtractor returns the claims of the client sending
+// the given request, like "@auth:realm=certificate".
+type ClaimsExtractor func(req *http.Request) []string
+
 // A RateExtractor is used to decide rates per token.
 // This allows to perform advanced computation to determine how
 // to rate limit one unique client.
@@ -61,6 +100,45 @@ type LatencyBasedUpstreamer interface {
 	Upstreamer
 }
 
+// An OutcomeBasedUpstreamer is the interface that can circle back
+// the outcome of the requests forwarded to an upstream, allowing
+// the Upstreamer to detect failing upstreams.
+// The statusCode is 0 when the upstream could not be reached, in
+// which case err is set.
+type OutcomeBasedUpstreamer interface {
+	CollectOutcome(address string, statusCode int, err error)
+	Upstreamer
+}
+
+// An InspectableUpstreamer is the interface of the Upstreamers
+// that can describe their state. It is used by the admin server
+// to list the services, endpoints and peers the Upstreamer knows.
+type InspectableUpstreamer interface {
+	Inspect() UpstreamerState
+	Upstreamer
+}
+
+// An OpenAPIUpstreamer is the interface of the Upstreamers that
+// can aggregate the OpenAPI documents of the services they route
+// to. The gateway serves them at /_meta/openapi/v/:version.
+type OpenAPIUpstreamer interface {
+	OpenAPI() map[int]*bahamut.OpenAPIDocument
+	Upstreamer
+}
+
+// A ControllableUpstreamer is the interface of the Upstreamers
+// that can be asked to stop using an endpoint. It is used by the
+// admin server to let the operators drain or eject endpoints.
+//
+// A drained endpoint does not receive new requests until it is undrained.
+// An ejected endpoint does not receive new requests for the given duration.
+// Ejecting an endpoint for a duration of 0 puts it back in service.
+type ControllableUpstreamer interface {
+	DrainEndpoint(address string, drained bool) error
+	EjectEndpoint(address string, duration time.Duration) error
+	Upstreamer
+}
+
 // A Gateway can be used as an api gateway.
 type Gateway interface {
 	Start()
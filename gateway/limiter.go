@@ -3,6 +3,7 @@ package gateway
 import (
 	"errors"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/karlseguin/ccache/v2"
@@ -13,7 +14,15 @@ const maxCacheSize = 65536
 
 var errTooManyRequest = errors.New("Please retry in a moment")
 
+// sourceRates holds the rates set from the admin
+// server, overriding the configured ones.
+type sourceRates struct {
+	limit rate.Limit
+	burst int
+}
+
 type sourceLimiter struct {
+	override        atomic.Pointer[sourceRates]
 	nextHTTP        http.Handler
 	nextWS          http.Handler
 	sourceExtractor SourceExtractor
@@ -70,7 +79,10 @@ func (l *sourceLimiter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var limit rate.Limit
 	var burst int
 
-	if l.rateExtractor != nil {
+	if o := l.override.Load(); o != nil {
+		limit = o.limit
+		burst = o.burst
+	} else if l.rateExtractor != nil {
 		limit, burst, err = l.rateExtractor.ExtractRates(req)
 		if err != nil {
 			l.errorHandler.ServeHTTP(w, req, errTooManyRequest)
@@ -113,3 +125,15 @@ func (l *sourceLimiter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		l.nextHTTP.ServeHTTP(w, req)
 	}
 }
+
+// setOverride sets the rates to use for all the sources instead
+// of the configured ones. Passing a limit of 0 removes the override.
+func (l *sourceLimiter) setOverride(limit rate.Limit, burst int) {
+
+	if limit == 0 {
+		l.override.Store(nil)
+		return
+	}
+
+	l.override.Store(&sourceRates{limit: limit, burst: burst})
+}
@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// bypassMaintenance returns true if the given request is
+// allowed to bypass the maintenance mode.
+// See OptionMaintenanceBypass.
+func (s *gateway) bypassMaintenance(r *http.Request) bool {
+
+	cfg := s.gatewayConfig
+
+	if len(cfg.maintenanceBypassNetworks) > 0 {
+
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		if ip := net.ParseIP(host); ip != nil {
+			for _, network := range cfg.maintenanceBypassNetworks {
+				if network.Contains(ip) {
+					return true
+				}
+			}
+		}
+	}
+
+	if len(cfg.maintenanceBypassClaims) > 0 {
+
+		claims := map[string]struct{}{}
+		for _, claim := range cfg.maintenanceClaimsExtractor(r) {
+			claims[claim] = struct{}{}
+		}
+
+		for _, claim := range cfg.maintenanceBypassClaims {
+			if _, ok := claims[claim]; !ok {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// writeMaintenanceError writes the 423 Locked
+// error described by the given MaintenanceError.
+func (s *gateway) writeMaintenanceError(w http.ResponseWriter, r *http.Request, merr *MaintenanceError) {
+
+	eerr := errLocked
+	if merr.Message != "" {
+		eerr = makeError(http.StatusLocked, errLocked.Title, merr.Message)
+	}
+
+	if merr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(merr.RetryAfter.Seconds()))))
+	}
+
+	s.corsOriginInjectorFunc(w, r)
+	writeError(w, r, eerr)
+}
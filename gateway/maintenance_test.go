@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type maintenanceUpstreamer struct {
+	err error
+}
+
+func (u *maintenanceUpstreamer) Upstream(*http.Request) (string, error) {
+
+	if u.err != nil {
+		return "", u.err
+	}
+
+	return "1.1.1.1:1", nil
+}
+
+func TestMaintenance(t *testing.T) {
+
+	makeGateway := func(u Upstreamer, options ...Option) (*gateway, *string) {
+
+		cfg := newGatewayConfig()
+		for _, o := range options {
+			o(cfg)
+		}
+
+		var forwarded string
+
+		return &gateway{
+			upstreamer:             u,
+			gatewayConfig:          cfg,
+			corsOriginInjectorFunc: func(w http.ResponseWriter, r *http.Request) http.Header { return w.Header() },
+			proxyHTTPHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded = r.URL.Host
+			}),
+		}, &forwarded
+	}
+
+	call := func(s *gateway, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+
+		req := httptest.NewRequest(http.MethodGet, "/cats", nil)
+		req.RemoteAddr = remoteAddr
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+
+		return w
+	}
+
+	bypass := OptionMaintenanceBypass(
+		[]string{"10.0.0.0/8"},
+		[]string{"@auth:role=operator", "@auth:org=acme"},
+		func(r *http.Request) []string { return r.Header.Values("X-Claims") },
+	)
+
+	Convey("Given I have a gateway whose upstreamer returns a maintenance error", t, func() {
+
+		s, forwarded := makeGateway(
+			&maintenanceUpstreamer{err: &MaintenanceError{Message: "upgrading", RetryAfter: 1500 * time.Millisecond, Upstream: "2.2.2.2:1"}},
+			bypass,
+		)
+
+		Convey("When I send a request that cannot bypass the maintenance", func() {
+
+			w := call(s, "192.168.1.1:4000", http.Header{"X-Claims": {"@auth:role=operator"}})
+
+			Convey("Then I should get a 423 with the message and Retry-After", func() {
+				So(w.Code, ShouldEqual, http.StatusLocked)
+				So(w.Header().Get("Retry-After"), ShouldEqual, "2")
+				So(w.Body.String(), ShouldContainSubstring, "upgrading")
+				So(*forwarded, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I send a request from a bypassing network", func() {
+
+			call(s, "10.1.2.3:4000", nil)
+
+			Convey("Then it should be forwarded to the upstream chosen by the upstreamer", func() {
+				So(*forwarded, ShouldEqual, "2.2.2.2:1")
+			})
+		})
+
+		Convey("When I send a request with the bypassing claims", func() {
+
+			call(s, "192.168.1.1:4000", http.Header{"X-Claims": {"@auth:org=acme", "@auth:role=operator"}})
+
+			Convey("Then it should be forwarded to the upstream chosen by the upstreamer", func() {
+				So(*forwarded, ShouldEqual, "2.2.2.2:1")
+			})
+		})
+	})
+
+	Convey("Given I have a gateway whose upstreamer returns a maintenance error without upstream", t, func() {
+
+		s, forwarded := makeGateway(&maintenanceUpstreamer{err: &MaintenanceError{}}, bypass)
+
+		Convey("When I send a request from a bypassing network", func() {
+
+			w := call(s, "10.1.2.3:4000", nil)
+
+			Convey("Then I should get a 423 without Retry-After", func() {
+				So(w.Code, ShouldEqual, http.StatusLocked)
+				So(w.Header().Get("Retry-After"), ShouldBeEmpty)
+				So(*forwarded, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given I have a gateway in maintenance", t, func() {
+
+		s, forwarded := makeGateway(&maintenanceUpstreamer{}, bypass, OptionEnableMaintenance(true))
+		s.maintenance.Store(true)
+
+		Convey("When I send a request that cannot bypass the maintenance", func() {
+
+			w := call(s, "192.168.1.1:4000", nil)
+
+			Convey("Then I should get a 423", func() {
+				So(w.Code, ShouldEqual, http.StatusLocked)
+				So(*forwarded, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I send a request from a bypassing network", func() {
+
+			call(s, "10.1.2.3:4000", nil)
+
+			Convey("Then it should be forwarded", func() {
+				So(*forwarded, ShouldEqual, "1.1.1.1:1")
+			})
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const openAPIPathPrefix = "/_meta/openapi/v/"
+
+// serveOpenAPI writes the OpenAPI document aggregated by the
+// OpenAPIUpstreamer for the version requested by the given
+// request. It returns false if the request does not target
+// an OpenAPI document.
+func (s *gateway) serveOpenAPI(w http.ResponseWriter, r *http.Request) bool {
+
+	if r.Method != http.MethodGet {
+		return false
+	}
+
+	v, ok := strings.CutPrefix(r.URL.Path, openAPIPathPrefix)
+	if !ok {
+		return false
+	}
+
+	s.corsOriginInjectorFunc(w, r)
+
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		writeError(w, r, makeError(http.StatusNotFound, "Not Found", fmt.Sprintf("Invalid api version '%s'", v)))
+		return true
+	}
+
+	doc, ok := s.upstreamerOpenAPI.OpenAPI()[version]
+	if !ok || doc == nil {
+		writeError(w, r, makeError(http.StatusNotFound, "Not Found", fmt.Sprintf("No OpenAPI document for api version %d", version)))
+		return true
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		writeError(w, r, makeError(http.StatusInternalServerError, "Internal Server Error", fmt.Sprintf("Unable to encode OpenAPI document: %s", err)))
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data) // nolint: errcheck
+
+	return true
+}
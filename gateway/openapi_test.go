@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+)
+
+type openAPIUpstreamer struct {
+	maintenanceUpstreamer
+	docs map[int]*bahamut.OpenAPIDocument
+}
+
+func (u *openAPIUpstreamer) OpenAPI() map[int]*bahamut.OpenAPIDocument {
+	return u.docs
+}
+
+func TestServeOpenAPI(t *testing.T) {
+
+	Convey("Given I have a gateway whose upstreamer aggregates OpenAPI documents", t, func() {
+
+		u := &openAPIUpstreamer{
+			docs: map[int]*bahamut.OpenAPIDocument{
+				1: {OpenAPI: "3.0.3", Info: bahamut.OpenAPIInfo{Title: "gateway", Version: "1"}},
+			},
+		}
+
+		var forwarded string
+
+		s := &gateway{
+			upstreamer:             u,
+			upstreamerOpenAPI:      u,
+			gatewayConfig:          newGatewayConfig(),
+			corsOriginInjectorFunc: func(w http.ResponseWriter, r *http.Request) http.Header { return w.Header() },
+			proxyHTTPHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded = r.URL.Path
+			}),
+		}
+
+		call := func(method string, path string) *httptest.ResponseRecorder {
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+
+			return w
+		}
+
+		Convey("When I retrieve the document of a known version", func() {
+
+			w := call(http.MethodGet, "/_meta/openapi/v/1")
+
+			Convey("Then I should get it", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=UTF-8")
+
+				doc := &bahamut.OpenAPIDocument{}
+				So(json.Unmarshal(w.Body.Bytes(), doc), ShouldBeNil)
+				So(doc.Info.Title, ShouldEqual, "gateway")
+				So(forwarded, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I retrieve the document of an unknown version", func() {
+
+			w := call(http.MethodGet, "/_meta/openapi/v/2")
+
+			Convey("Then I should get a 404", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				So(forwarded, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I retrieve the document of an invalid version", func() {
+
+			w := call(http.MethodGet, "/_meta/openapi/v/nope")
+
+			Convey("Then I should get a 404", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When I send another request", func() {
+
+			call(http.MethodGet, "/cats")
+
+			Convey("Then it should be forwarded", func() {
+				So(forwarded, ShouldEqual, "/cats")
+			})
+		})
+	})
+}
@@ -2,10 +2,13 @@ package gateway
 
 import (
 	"crypto/tls"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.aporeto.io/bahamut"
 	"go.aporeto.io/elemental"
 	"golang.org/x/time/rate"
@@ -21,6 +24,17 @@ type RequestRewriter func(req *httputil.ProxyRequest, private bool) error
 // before it is sent back to the client
 type ResponseRewriter func(*http.Response) error
 
+// A BodyTransformer can be used to transform the elemental payload of the
+// body of a request before it is sent to the upstream, or of a response
+// before it is sent back to the client.
+// The payload is decoded from JSON or MsgPack according to the Content-Type
+// of the body, and is a map[string]any for a single object or a []any for a
+// list of objects. It can be mutated in place and returned, or replaced. The
+// returned value is then encoded back using the same encoding.
+// If it returns an error, the client receives a 400 Bad Request error for a
+// request and a 502 Bad Gateway error for a response.
+type BodyTransformer func(r *http.Request, payload any) (any, error)
+
 // An InterceptorFunc is a function that can be used to intercept and request
 // based on its prefix and apply custom operation and returns an InterceptorAction
 // to tell the gateway it should proceed from there.
@@ -63,6 +77,10 @@ const (
 
 type gwconfig struct {
 	sourceExtractor                    SourceExtractor
+	responseCacheSourceExtractor       SourceExtractor
+	maintenanceClaimsExtractor         ClaimsExtractor
+	retryMetricsRegisterer             prometheus.Registerer
+	responseCacheMetricsRegisterer     prometheus.Registerer
 	metricsManager                     bahamut.MetricsManager
 	sourceRateLimitingMetricManager    LimiterMetricManager
 	tcpClientSourceExtractor           SourceExtractor
@@ -72,15 +90,26 @@ type gwconfig struct {
 	requestRewriter                    RequestRewriter
 	upstreamTLSConfig                  *tls.Config
 	serverTLSConfig                    *tls.Config
+	adminTLSConfig                     *tls.Config
 	responseRewriter                   ResponseRewriter
 	prefixInterceptors                 map[string]InterceptorFunc
+	retryPolicies                      map[string]RetryPolicy
+	requestBodyTransformers            map[string]BodyTransformer
+	responseBodyTransformers           map[string]BodyTransformer
 	suffixInterceptors                 map[string]InterceptorFunc
 	corsOrigin                         string
+	adminListenAddr                    string
 	proxyProtocolSubnet                string
 	upstreamCircuitBreakerCond         string
 	upstreamURLScheme                  string
 	additionalCorsOrigin               []string
+	maintenanceBypassNetworks          []*net.IPNet
+	maintenanceBypassClaims            []string
 	tcpClientMaxConnections            int
+	retryBudgetRatio                   float64
+	retryBudgetMinPerSecond            float64
+	responseCacheMaxSize               int64
+	responseCacheMaxEntrySize          int64
 	upstreamIdleConnTimeout            time.Duration
 	sourceRateLimitingRPS              rate.Limit
 	httpIdleTimeout                    time.Duration
@@ -95,6 +124,7 @@ type gwconfig struct {
 	httpWriteTimeout                   time.Duration
 	tcpClientMaxConnectionsEnabled     bool
 	upstreamUseHTTP2                   bool
+	upstreamUseH2C                     bool
 	trace                              bool
 	maintenance                        bool
 	tcpGlobalRateLimitingEnabled       bool
@@ -106,29 +136,35 @@ type gwconfig struct {
 	corsAllowCredentials               bool
 	blockOpenTracingHeaders            bool
 	trustForwardHeader                 bool
+	legacyClientCertHeader             bool
 }
 
 func newGatewayConfig() *gwconfig {
 	return &gwconfig{
-		corsOrigin:                  bahamut.CORSOriginMirror,
-		corsAllowCredentials:        true,
-		prefixInterceptors:          map[string]InterceptorFunc{},
-		suffixInterceptors:          map[string]InterceptorFunc{},
-		exactInterceptors:           map[string]InterceptorFunc{},
-		tcpGlobalRateLimitingBurst:  200,
-		tcpGlobalRateLimitingCPS:    100.0,
-		upstreamIdleConnTimeout:     time.Hour,
-		upstreamMaxConnsPerHost:     64,
-		upstreamMaxIdleConns:        32000,
-		upstreamMaxIdleConnsPerHost: 64,
-		upstreamTLSHandshakeTimeout: 10 * time.Second,
-		upstreamURLScheme:           "https",
-		upstreamUseHTTP2:            false,
-		httpIdleTimeout:             240 * time.Second,
-		httpReadTimeout:             120 * time.Second,
-		httpWriteTimeout:            240 * time.Second,
-		sourceExtractor:             &defaultSourceExtractor{},
-		tcpClientSourceExtractor:    &defaultTCPSourceExtractor{},
+		corsOrigin:                     bahamut.CORSOriginMirror,
+		corsAllowCredentials:           true,
+		legacyClientCertHeader:         true,
+		prefixInterceptors:             map[string]InterceptorFunc{},
+		suffixInterceptors:             map[string]InterceptorFunc{},
+		exactInterceptors:              map[string]InterceptorFunc{},
+		tcpGlobalRateLimitingBurst:     200,
+		tcpGlobalRateLimitingCPS:       100.0,
+		upstreamIdleConnTimeout:        time.Hour,
+		upstreamMaxConnsPerHost:        64,
+		upstreamMaxIdleConns:           32000,
+		upstreamMaxIdleConnsPerHost:    64,
+		upstreamTLSHandshakeTimeout:    10 * time.Second,
+		upstreamURLScheme:              "https",
+		upstreamUseHTTP2:               false,
+		httpIdleTimeout:                240 * time.Second,
+		httpReadTimeout:                120 * time.Second,
+		httpWriteTimeout:               240 * time.Second,
+		sourceExtractor:                &defaultSourceExtractor{},
+		tcpClientSourceExtractor:       &defaultTCPSourceExtractor{},
+		retryBudgetRatio:               0.2,
+		retryBudgetMinPerSecond:        10,
+		retryMetricsRegisterer:         prometheus.DefaultRegisterer,
+		responseCacheMetricsRegisterer: prometheus.DefaultRegisterer,
 	}
 }
 
@@ -219,6 +255,8 @@ func OptionEnableTrace(enabled bool) Option {
 }
 
 // OptionEnableMaintenance enables the maintenance mode.
+// The maintenance mode can also be toggled at runtime
+// from the admin server. See OptionAdminServer.
 func OptionEnableMaintenance(enabled bool) Option {
 	return func(cfg *gwconfig) {
 		cfg.maintenance = enabled
@@ -280,6 +318,20 @@ func OptionUpstreamURLScheme(scheme string) Option {
 	}
 }
 
+// OptionUpstreamUseH2C makes the gateway use HTTP/2 over cleartext TCP
+// (h2c) to connect to the upstreams. The upstreams must support h2c, for
+// instance using bahamut.OptH2C. As h2c does not use TLS, this also sets
+// the upstream URL scheme to http. Websocket connections are still
+// forwarded using HTTP/1.1.
+func OptionUpstreamUseH2C(enable bool) Option {
+	return func(cfg *gwconfig) {
+		cfg.upstreamUseH2C = enable
+		if enable {
+			cfg.upstreamURLScheme = "http"
+		}
+	}
+}
+
 // OptionUpstreamEnableCompression enables using compression between
 // the gateway and the upstreams. This can lead to performance issues.
 func OptionUpstreamEnableCompression(enable bool) Option {
@@ -288,6 +340,40 @@ func OptionUpstreamEnableCompression(enable bool) Option {
 	}
 }
 
+// OptionUpstreamRetryPolicy sets the RetryPolicy to use for the requests
+// whose path, as sent by the client, starts with the given prefix. An
+// empty prefix matches all the requests. When several prefixes match,
+// the longest one is used.
+// Requests forwarded directly or as websockets, and requests to an
+// upstream returned by an interceptor, are never retried.
+func OptionUpstreamRetryPolicy(prefix string, policy RetryPolicy) Option {
+	return func(cfg *gwconfig) {
+		if cfg.retryPolicies == nil {
+			cfg.retryPolicies = map[string]RetryPolicy{}
+		}
+		cfg.retryPolicies[prefix] = policy
+	}
+}
+
+// OptionUpstreamRetryBudget sets the budget limiting the retries and the
+// hedged requests to the given ratio of the requests, plus minPerSecond
+// per second. The default is 0.2 and 10.
+func OptionUpstreamRetryBudget(ratio float64, minPerSecond float64) Option {
+	return func(cfg *gwconfig) {
+		cfg.retryBudgetRatio = ratio
+		cfg.retryBudgetMinPerSecond = minPerSecond
+	}
+}
+
+// OptionUpstreamRetryMetricsRegisterer sets the prometheus.Registerer used
+// to register the upstream attempts and retry budget counters. The default
+// is prometheus.DefaultRegisterer. Passing nil disables the metrics.
+func OptionUpstreamRetryMetricsRegisterer(registerer prometheus.Registerer) Option {
+	return func(cfg *gwconfig) {
+		cfg.retryMetricsRegisterer = registerer
+	}
+}
+
 // OptionMetricsManager registers set the MetricsManager to use.
 // This will enable response time load balancing of endpoints.
 func OptionMetricsManager(metricsManager bahamut.MetricsManager) Option {
@@ -332,7 +418,9 @@ func OptionSetCustomResponseRewriter(r ResponseRewriter) Option {
 }
 
 // OptionServerTLSConfig sets the tls.Config to use for the
-// front end server.
+// front end server. To reload the certificates and the client CA
+// pool when they change on disk, you can pass the result of the
+// TLSConfig method of a bahamut.TLSReloader.
 func OptionServerTLSConfig(tlsConfig *tls.Config) Option {
 	return func(cfg *gwconfig) {
 		cfg.serverTLSConfig = tlsConfig
@@ -387,6 +475,19 @@ func OptionTrustForwardHeader(trust bool) Option {
 	}
 }
 
+// OptionLegacyClientCertificateHeader configures if the gateway forwards
+// the client certificate in the X-TLS-Client-Certificate header using the
+// legacy encoding, which only contains the leaf certificate. This is the
+// default, as the upstreams using an older version of bahamut cannot decode
+// the versioned encoding described in mtls.EncodeCertificatesHeader, which
+// contains the full chain. Passing false must only be done once all the
+// upstreams have been upgraded.
+func OptionLegacyClientCertificateHeader(legacy bool) Option {
+	return func(cfg *gwconfig) {
+		cfg.legacyClientCertHeader = legacy
+	}
+}
+
 // OptionTCPGlobalRateLimitingManager sets the LimiterMetricManager to
 // use to get metrics on the TCP global rate limiter.
 func OptionTCPGlobalRateLimitingManager(m LimiterMetricManager) Option {
@@ -402,3 +503,164 @@ func OptionSourceRateLimitingManager(m LimiterMetricManager) Option {
 		cfg.sourceRateLimitingMetricManager = m
 	}
 }
+
+// OptionAdminServer enables the admin server on the given address. The admin
+// server lets the operators inspect the services, endpoints and peers known by
+// the Upstreamer, toggle the maintenance mode, drain or eject endpoints and
+// override the source rate limits at runtime.
+//
+// The admin server has no authentication of its own, so it must not be
+// reachable by the clients of the gateway. If tlsConfig is set, the admin
+// server is served using TLS and must require and verify the certificates
+// of the clients. If tlsConfig is nil, the admin server is served over plain
+// HTTP and listenAddr must be a loopback address, like 127.0.0.1:9999.
+//
+// The listing of the services and peers requires an InspectableUpstreamer
+// and the control of the endpoints requires a ControllableUpstreamer.
+func OptionAdminServer(listenAddr string, tlsConfig *tls.Config) Option {
+
+	if tlsConfig != nil && tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+		panic("tlsConfig must require and verify client certificates")
+	}
+
+	if tlsConfig == nil && !isLoopbackAddress(listenAddr) {
+		panic(fmt.Sprintf("listenAddr '%s' must be a loopback address when tlsConfig is nil", listenAddr))
+	}
+
+	return func(cfg *gwconfig) {
+		cfg.adminListenAddr = listenAddr
+		cfg.adminTLSConfig = tlsConfig
+	}
+}
+
+// OptionMaintenanceBypass lets the requests coming from one of the given
+// networks, or carrying all the given claims, bypass the maintenance mode,
+// whether it is enabled for the whole gateway or returned by the Upstreamer
+// for the requested service using a MaintenanceError.
+//
+// The networks are given in CIDR notation, like "10.0.0.0/8", or as
+// single IP addresses. The claims of the requests are retrieved using
+// the given ClaimsExtractor, which is required if claims are given.
+func OptionMaintenanceBypass(networks []string, claims []string, claimsExtractor ClaimsExtractor) Option {
+	return func(cfg *gwconfig) {
+
+		cfg.maintenanceBypassNetworks = make([]*net.IPNet, len(networks))
+		for i, n := range networks {
+
+			if ip := net.ParseIP(n); ip != nil {
+				n = ip.String() + "/128"
+				if ip.To4() != nil {
+					n = ip.String() + "/32"
+				}
+			}
+
+			_, network, err := net.ParseCIDR(n)
+			if err != nil {
+				panic(fmt.Sprintf("invalid network '%s': %s", networks[i], err))
+			}
+
+			cfg.maintenanceBypassNetworks[i] = network
+		}
+
+		if len(claims) > 0 && claimsExtractor == nil {
+			panic("claimsExtractor cannot be nil when claims are given")
+		}
+
+		cfg.maintenanceBypassClaims = claims
+		cfg.maintenanceClaimsExtractor = claimsExtractor
+	}
+}
+
+// OptionResponseCache enables the caching of the responses to the GET
+// requests. The responses are cached according to their Cache-Control,
+// Expires and Vary headers, and the stale responses having an ETag or a
+// Last-Modified header are revalidated with the upstreams.
+//
+// The responses are cached per source, as returned by the given
+// SourceExtractor, and per namespace, so a response is never served to
+// another client than the one it was generated for. The SourceExtractor
+// must discriminate the clients using all the ways they can authenticate,
+// like their client certificate, API key or session cookie. The requests
+// for which it returns an error, an empty source or the source 'default'
+// used by the default SourceExtractor for the requests without any
+// Authorization header or auth cookie, are never cached. The responses
+// setting cookies are never cached either.
+//
+// maxSize is the maximum total size in bytes of the cached responses, and
+// maxEntrySize the maximum size of the body of a single cached response.
+func OptionResponseCache(maxSize int64, maxEntrySize int64, sourceExtractor SourceExtractor) Option {
+
+	if maxSize <= 0 {
+		panic("maxSize cannot be <= 0")
+	}
+
+	if maxEntrySize <= 0 {
+		panic("maxEntrySize cannot be <= 0")
+	}
+
+	if sourceExtractor == nil {
+		panic("sourceExtractor cannot be nil")
+	}
+
+	return func(cfg *gwconfig) {
+		cfg.responseCacheMaxSize = maxSize
+		cfg.responseCacheMaxEntrySize = maxEntrySize
+		cfg.responseCacheSourceExtractor = sourceExtractor
+	}
+}
+
+// OptionResponseCacheMetricsRegisterer sets the prometheus.Registerer used
+// to register the response cache metrics. The default is
+// prometheus.DefaultRegisterer. Passing nil disables the metrics.
+func OptionResponseCacheMetricsRegisterer(registerer prometheus.Registerer) Option {
+	return func(cfg *gwconfig) {
+		cfg.responseCacheMetricsRegisterer = registerer
+	}
+}
+
+// OptionRequestBodyTransformer sets the BodyTransformer to use for the
+// bodies of the requests whose path starts with the given prefix. The path
+// is the one sent by the client, before the Upstreamer rewrites it, like
+// the push Upstreamer trimming the prefix of the services. An empty
+// prefix matches all the requests. When several prefixes match, the longest
+// one is used. The bodies that are not elemental payloads, or that are
+// compressed, are not transformed.
+// Requests forwarded directly or as websockets are never transformed.
+func OptionRequestBodyTransformer(prefix string, transformer BodyTransformer) Option {
+
+	if transformer == nil {
+		panic("transformer cannot be nil")
+	}
+
+	return func(cfg *gwconfig) {
+		if cfg.requestBodyTransformers == nil {
+			cfg.requestBodyTransformers = map[string]BodyTransformer{}
+		}
+		cfg.requestBodyTransformers[prefix] = transformer
+	}
+}
+
+// OptionResponseBodyTransformer sets the BodyTransformer to use for the
+// bodies of the responses to the requests whose path starts with the given
+// prefix. Only the successful responses are transformed, and they are buffered
+// to do so. The other responses are streamed untouched. As the transformed
+// body does not match them anymore, the ETag, Last-Modified and Content-MD5
+// headers of the upstream are removed. Like for the requests,
+// the longest matching prefix is used and only the uncompressed elemental
+// payloads are transformed, so the Accept-Encoding header of the requests
+// is removed to prevent the upstreams from compressing the responses.
+// Responses served from the response cache are transformed too, so the
+// BodyTransformer can depend on the request.
+func OptionResponseBodyTransformer(prefix string, transformer BodyTransformer) Option {
+
+	if transformer == nil {
+		panic("transformer cannot be nil")
+	}
+
+	return func(cfg *gwconfig) {
+		if cfg.responseBodyTransformers == nil {
+			cfg.responseBodyTransformers = map[string]BodyTransformer{}
+		}
+		cfg.responseBodyTransformers[prefix] = transformer
+	}
+}
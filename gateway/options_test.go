@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	// nolint:revive // Allow dot imports for readability in tests
 	. "github.com/smartystreets/goconvey/convey"
 	"go.aporeto.io/bahamut"
@@ -211,6 +212,20 @@ func Test_Options(t *testing.T) {
 		So(c.trustForwardHeader, ShouldBeTrue)
 	})
 
+	Convey("Calling OptionLegacyClientCertificateHeader should work", t, func() {
+		c := newGatewayConfig()
+		So(c.legacyClientCertHeader, ShouldBeTrue)
+		OptionLegacyClientCertificateHeader(false)(c)
+		So(c.legacyClientCertHeader, ShouldBeFalse)
+	})
+
+	Convey("Calling OptionUpstreamUseH2C should work", t, func() {
+		c := newGatewayConfig()
+		OptionUpstreamUseH2C(true)(c)
+		So(c.upstreamUseH2C, ShouldBeTrue)
+		So(c.upstreamURLScheme, ShouldEqual, "http")
+	})
+
 	Convey("Calling OptionUpstreamEnableCompression should work", t, func() {
 		c := newGatewayConfig()
 		OptionUpstreamEnableCompression(true)(c)
@@ -236,4 +251,69 @@ func Test_Options(t *testing.T) {
 		OptionSourceRateLimitingManager(m)(c)
 		So(c.sourceRateLimitingMetricManager, ShouldEqual, m)
 	})
+
+	Convey("Calling OptionAdminServer should work", t, func() {
+		c := newGatewayConfig()
+		tlsConfig := &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert}
+		OptionAdminServer(":9999", tlsConfig)(c)
+		So(c.adminListenAddr, ShouldEqual, ":9999")
+		So(c.adminTLSConfig, ShouldEqual, tlsConfig)
+
+		OptionAdminServer("127.0.0.1:9999", nil)(c)
+		So(c.adminListenAddr, ShouldEqual, "127.0.0.1:9999")
+		So(c.adminTLSConfig, ShouldBeNil)
+
+		So(func() { OptionAdminServer(":9999", &tls.Config{}) }, ShouldPanicWith, "tlsConfig must require and verify client certificates")
+		So(func() { OptionAdminServer(":9999", nil) }, ShouldPanicWith, "listenAddr ':9999' must be a loopback address when tlsConfig is nil")
+	})
+
+	Convey("Calling OptionMaintenanceBypass should work", t, func() {
+		c := newGatewayConfig()
+		OptionMaintenanceBypass([]string{"10.0.0.0/8", "1.2.3.4", "::1"}, []string{"a=b"}, func(*http.Request) []string { return nil })(c)
+		So(c.maintenanceBypassNetworks, ShouldHaveLength, 3)
+		So(c.maintenanceBypassNetworks[0].String(), ShouldEqual, "10.0.0.0/8")
+		So(c.maintenanceBypassNetworks[1].String(), ShouldEqual, "1.2.3.4/32")
+		So(c.maintenanceBypassNetworks[2].String(), ShouldEqual, "::1/128")
+		So(c.maintenanceBypassClaims, ShouldResemble, []string{"a=b"})
+		So(c.maintenanceClaimsExtractor, ShouldNotBeNil)
+
+		So(func() { OptionMaintenanceBypass([]string{"nope"}, nil, nil)(c) }, ShouldPanicWith, `invalid network 'nope': invalid CIDR address: nope`)
+		So(func() { OptionMaintenanceBypass(nil, []string{"a=b"}, nil)(c) }, ShouldPanicWith, `claimsExtractor cannot be nil when claims are given`)
+	})
+
+	Convey("Calling OptionResponseCache should work", t, func() {
+		c := newGatewayConfig()
+		e := NewDefaultSourceExtractor("")
+		OptionResponseCache(1024, 128, e)(c)
+		So(c.responseCacheMaxSize, ShouldEqual, 1024)
+		So(c.responseCacheMaxEntrySize, ShouldEqual, 128)
+		So(c.responseCacheSourceExtractor, ShouldResemble, e)
+
+		So(func() { OptionResponseCache(0, 128, e) }, ShouldPanicWith, `maxSize cannot be <= 0`)
+		So(func() { OptionResponseCache(1024, 0, e) }, ShouldPanicWith, `maxEntrySize cannot be <= 0`)
+		So(func() { OptionResponseCache(1024, 128, nil) }, ShouldPanicWith, `sourceExtractor cannot be nil`)
+	})
+
+	Convey("Calling OptionResponseCacheMetricsRegisterer should work", t, func() {
+		c := newGatewayConfig()
+		So(c.responseCacheMetricsRegisterer, ShouldEqual, prometheus.DefaultRegisterer)
+		OptionResponseCacheMetricsRegisterer(nil)(c)
+		So(c.responseCacheMetricsRegisterer, ShouldBeNil)
+	})
+
+	Convey("Calling OptionRequestBodyTransformer should work", t, func() {
+		c := newGatewayConfig()
+		OptionRequestBodyTransformer("/cats", func(*http.Request, any) (any, error) { return nil, nil })(c)
+		So(c.requestBodyTransformers, ShouldContainKey, "/cats")
+
+		So(func() { OptionRequestBodyTransformer("/cats", nil) }, ShouldPanicWith, `transformer cannot be nil`)
+	})
+
+	Convey("Calling OptionResponseBodyTransformer should work", t, func() {
+		c := newGatewayConfig()
+		OptionResponseBodyTransformer("/cats", func(*http.Request, any) (any, error) { return nil, nil })(c)
+		So(c.responseBodyTransformers, ShouldContainKey, "/cats")
+
+		So(func() { OptionResponseBodyTransformer("/cats", nil) }, ShouldPanicWith, `transformer cannot be nil`)
+	})
 }
@@ -0,0 +1,34 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// outcomeRoundTripper is an http.RoundTripper that reports
+// the outcome of each round trip to an OutcomeBasedUpstreamer.
+type outcomeRoundTripper struct {
+	next       http.RoundTripper
+	upstreamer OutcomeBasedUpstreamer
+}
+
+func (t *outcomeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	resp, err := t.next.RoundTrip(req)
+
+	switch {
+
+	case err == nil:
+		t.upstreamer.CollectOutcome(req.URL.Host, resp.StatusCode, nil)
+
+	// If the request has been canceled, by the client or because
+	// another attempt won, this says nothing about the upstream.
+	case errors.Is(err, context.Canceled), req.Context().Err() != nil:
+
+	default:
+		t.upstreamer.CollectOutcome(req.URL.Host, 0, err)
+	}
+
+	return resp, err
+}
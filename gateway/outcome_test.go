@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type outcome struct {
+	err     error
+	address string
+	code    int
+}
+
+type outcomeUpstreamer struct {
+	outcomes []outcome
+	lock     sync.Mutex
+}
+
+func (u *outcomeUpstreamer) Upstream(*http.Request) (string, error) { return "", nil }
+
+func (u *outcomeUpstreamer) CollectOutcome(address string, statusCode int, err error) {
+
+	u.lock.Lock()
+	u.outcomes = append(u.outcomes, outcome{address: address, code: statusCode, err: err})
+	u.lock.Unlock()
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestOutcomeRoundTripper(t *testing.T) {
+
+	Convey("Given I have an outcome round tripper", t, func() {
+
+		u := &outcomeUpstreamer{}
+		errConn := errors.New("connection refused")
+
+		rt := &outcomeRoundTripper{
+			upstreamer: u,
+			next: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				switch req.URL.Path {
+				case "/fail":
+					return nil, errConn
+				case "/canceled":
+					return nil, context.Canceled
+				default:
+					return &http.Response{StatusCode: http.StatusBadGateway}, nil
+				}
+			}),
+		}
+
+		Convey("When I send requests", func() {
+
+			resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://a:1/ok", nil))
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadGateway)
+
+			_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://b:1/fail", nil))
+			So(err, ShouldEqual, errConn)
+
+			_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://c:1/canceled", nil))
+			So(err, ShouldEqual, context.Canceled)
+
+			Convey("Then the outcomes should be collected, except for canceled requests", func() {
+				So(u.outcomes, ShouldResemble, []outcome{
+					{address: "a:1", code: http.StatusBadGateway},
+					{address: "b:1", err: errConn},
+				})
+			})
+		})
+	})
+}
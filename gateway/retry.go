@@ -0,0 +1,731 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.aporeto.io/bahamut/internal/metrics"
+	"golang.org/x/time/rate"
+)
+
+// A RetryPolicy configures how the gateway retries the requests
+// failing with a transient error, and hedges the slow ones.
+//
+// A retry or a hedged request is sent to an upstream returned by the
+// Upstreamer that has not been tried yet for the request, if possible.
+// Only the first response that is not retryable, or the last response
+// if no more attempt can be made, is sent back to the client.
+type RetryPolicy struct {
+
+	// MaxAttempts is the maximum number of requests sent to the
+	// upstreams, including the first one. The default is 2.
+	MaxAttempts int
+
+	// Methods are the HTTP methods of the requests that can be retried.
+	// The default is the idempotent methods: GET, HEAD, OPTIONS, TRACE,
+	// PUT and DELETE.
+	Methods []string
+
+	// StatusCodes are the response status codes triggering a retry.
+	// The default is 502, 503 and 504. Errors connecting to the
+	// upstreams are reported as 502 or 504 by the gateway.
+	StatusCodes []int
+
+	// HedgePercentile enables request hedging when it is greater than 0.
+	// If the upstream did not respond after this percentile of the recent
+	// response times, like 0.95, a second request is sent to another
+	// upstream, and the first response wins.
+	HedgePercentile float64
+
+	// HedgeMinDelay is the minimum time to wait before sending a hedged request.
+	HedgeMinDelay time.Duration
+}
+
+var defaultRetryMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPut,
+	http.MethodDelete,
+}
+
+var defaultRetryStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+const (
+	attemptKindFirst = "first"
+	attemptKindRetry = "retry"
+	attemptKindHedge = "hedge"
+
+	attemptResultSuccess  = "success"
+	attemptResultFailure  = "failure"
+	attemptResultCanceled = "canceled"
+
+	// minHedgeSamples is the number of response times
+	// needed before hedging requests.
+	minHedgeSamples = 20
+)
+
+type retryPolicy struct {
+	prefix      string
+	methods     map[string]struct{}
+	statusCodes map[int]struct{}
+	latencies   *latencyTracker
+	maxAttempts int
+	percentile  float64
+	minDelay    time.Duration
+}
+
+func newRetryPolicy(prefix string, p RetryPolicy) *retryPolicy {
+
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 2
+	}
+
+	if len(p.Methods) == 0 {
+		p.Methods = defaultRetryMethods
+	}
+
+	if len(p.StatusCodes) == 0 {
+		p.StatusCodes = defaultRetryStatusCodes
+	}
+
+	rp := &retryPolicy{
+		prefix:      prefix,
+		methods:     make(map[string]struct{}, len(p.Methods)),
+		statusCodes: make(map[int]struct{}, len(p.StatusCodes)),
+		maxAttempts: p.MaxAttempts,
+		percentile:  p.HedgePercentile,
+		minDelay:    p.HedgeMinDelay,
+	}
+
+	for _, m := range p.Methods {
+		rp.methods[strings.ToUpper(m)] = struct{}{}
+	}
+
+	for _, c := range p.StatusCodes {
+		rp.statusCodes[c] = struct{}{}
+	}
+
+	if rp.percentile > 0 {
+		rp.latencies = newLatencyTracker(1000)
+	}
+
+	return rp
+}
+
+func (p *retryPolicy) retryable(code int) bool {
+
+	_, ok := p.statusCodes[code]
+
+	return ok
+}
+
+// hedgeDelay returns how long to wait before sending
+// a hedged request, or false if hedging is disabled.
+func (p *retryPolicy) hedgeDelay() (time.Duration, bool) {
+
+	if p.latencies == nil || p.maxAttempts < 2 {
+		return 0, false
+	}
+
+	d, ok := p.latencies.percentile(p.percentile)
+	if !ok {
+		return 0, false
+	}
+
+	if d < p.minDelay {
+		d = p.minDelay
+	}
+
+	return d, true
+}
+
+// A latencyTracker keeps the most recent response
+// times to compute their percentiles.
+type latencyTracker struct {
+	samples []time.Duration
+	sorted  []time.Duration
+	next    int
+	count   int
+	dirty   int
+	lock    sync.Mutex
+}
+
+func newLatencyTracker(size int) *latencyTracker {
+
+	return &latencyTracker{
+		samples: make([]time.Duration, size),
+	}
+}
+
+func (t *latencyTracker) observe(d time.Duration) {
+
+	t.lock.Lock()
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % len(t.samples)
+	if t.count < len(t.samples) {
+		t.count++
+	}
+	t.dirty++
+	t.lock.Unlock()
+}
+
+// percentile returns the given percentile of the response times. The
+// samples are sorted again every 100 observations, so the result may
+// slightly lag behind. It returns false if there are not enough samples.
+func (t *latencyTracker) percentile(p float64) (time.Duration, bool) {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.count < minHedgeSamples {
+		return 0, false
+	}
+
+	if t.sorted == nil || t.dirty >= 100 {
+		t.sorted = append(t.sorted[:0], t.samples[:t.count]...)
+		sort.Slice(t.sorted, func(i, j int) bool { return t.sorted[i] < t.sorted[j] })
+		t.dirty = 0
+	}
+
+	idx := int(p * float64(len(t.sorted)))
+	if idx >= len(t.sorted) {
+		idx = len(t.sorted) - 1
+	}
+
+	return t.sorted[idx], true
+}
+
+// A retryBudget limits the number of retries and hedged requests
+// to a ratio of the requests, plus a minimum number per second, to
+// prevent retry storms when the upstreams are overloaded.
+type retryBudget struct {
+	reserve *rate.Limiter
+	ratio   float64
+	balance float64
+	max     float64
+	lock    sync.Mutex
+}
+
+func newRetryBudget(ratio float64, minPerSecond float64) *retryBudget {
+
+	return &retryBudget{
+		reserve: rate.NewLimiter(rate.Limit(minPerSecond), int(math.Ceil(minPerSecond))),
+		ratio:   ratio,
+		max:     ratio * 1000,
+	}
+}
+
+// deposit must be called for every request.
+func (b *retryBudget) deposit() {
+
+	b.lock.Lock()
+	if b.balance += b.ratio; b.balance > b.max {
+		b.balance = b.max
+	}
+	b.lock.Unlock()
+}
+
+// withdraw returns true if a retry can be made.
+func (b *retryBudget) withdraw() bool {
+
+	b.lock.Lock()
+	if b.balance >= 1 {
+		b.balance--
+		b.lock.Unlock()
+		return true
+	}
+	b.lock.Unlock()
+
+	return b.reserve.Allow()
+}
+
+type fixedUpstreamContextKey struct{}
+
+// withFixedUpstream marks the request as targeting an upstream
+// that must not be changed, like one returned by an interceptor.
+func withFixedUpstream(r *http.Request) *http.Request {
+
+	return r.WithContext(context.WithValue(r.Context(), fixedUpstreamContextKey{}, true))
+}
+
+// A retrier is an http.Handler forwarding the requests to the next
+// handler, and retrying or hedging them according to the RetryPolicy
+// matching their path.
+type retrier struct {
+	next           http.Handler
+	upstreamer     Upstreamer
+	latency        LatencyBasedUpstreamer
+	budget         *retryBudget
+	attemptsMetric *prometheus.CounterVec
+	budgetMetric   prometheus.Counter
+	policies       []*retryPolicy
+}
+
+func newRetrier(next http.Handler, upstreamer Upstreamer, latency LatencyBasedUpstreamer, cfg *gwconfig) (*retrier, error) {
+
+	rt := &retrier{
+		next:       next,
+		upstreamer: upstreamer,
+		latency:    latency,
+		budget:     newRetryBudget(cfg.retryBudgetRatio, cfg.retryBudgetMinPerSecond),
+	}
+
+	for prefix, p := range cfg.retryPolicies {
+		rt.policies = append(rt.policies, newRetryPolicy(prefix, p))
+	}
+
+	// The longest prefixes are checked first.
+	sort.Slice(rt.policies, func(i, j int) bool { return len(rt.policies[i].prefix) > len(rt.policies[j].prefix) })
+
+	if registerer := cfg.retryMetricsRegisterer; registerer != nil {
+
+		var err error
+
+		if rt.attemptsMetric, err = metrics.RegisterCollector(registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_upstream_attempts_total",
+				Help: "The total number of requests sent to the upstreams, per kind of attempt and result.",
+			},
+			[]string{"kind", "result"},
+		)); err != nil {
+			return nil, fmt.Errorf("unable to register upstream attempts metric: %w", err)
+		}
+
+		if rt.budgetMetric, err = metrics.RegisterCollector(registerer, prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "gateway_upstream_retry_budget_exhausted_total",
+				Help: "The total number of retries or hedged requests not sent because the retry budget was exhausted.",
+			},
+		)); err != nil {
+			return nil, fmt.Errorf("unable to register retry budget metric: %w", err)
+		}
+	}
+
+	return rt, nil
+}
+
+// policyFor returns the retryPolicy matching the path of the given
+// request before it was routed, as the Upstreamer can rewrite it.
+func (rt *retrier) policyFor(r *http.Request) *retryPolicy {
+
+	path := originalPath(r)
+
+	for _, p := range rt.policies {
+
+		if !strings.HasPrefix(path, p.prefix) {
+			continue
+		}
+
+		if _, ok := p.methods[r.Method]; !ok {
+			return nil
+		}
+
+		return p
+	}
+
+	return nil
+}
+
+func (rt *retrier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	rt.budget.deposit()
+
+	policy := rt.policyFor(r)
+	if policy == nil || policy.maxAttempts < 2 || r.Context().Value(fixedUpstreamContextKey{}) != nil {
+
+		start := time.Now()
+		rt.next.ServeHTTP(w, r)
+
+		if rt.latency != nil {
+			rt.latency.CollectLatency(r.URL.Host, time.Since(start))
+		}
+
+		return
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			writeError(w, r, makeError(http.StatusBadRequest, "Bad Request", fmt.Sprintf("unable to read request body: %s", err)))
+			return
+		}
+	}
+
+	(&retryState{
+		retrier: rt,
+		policy:  policy,
+		w:       w,
+		r:       r,
+		body:    body,
+		tried:   map[string]struct{}{},
+		done:    make(chan *attemptWriter),
+	}).serve()
+}
+
+func (rt *retrier) observeAttempt(kind string, result string) {
+
+	if rt.attemptsMetric != nil {
+		rt.attemptsMetric.WithLabelValues(kind, result).Inc()
+	}
+}
+
+// nextUpstream asks the Upstreamer for an upstream that has not been
+// tried yet. If it keeps returning tried upstreams, the last one is
+// returned. It returns an empty string if there is no upstream.
+//
+// The Upstreamer is given a copy of the request with the path it had
+// before being routed, since the first call to Upstream may have
+// rewritten it, like the push Upstreamer trimming the prefix of the
+// services, and the request is shared with the running attempts.
+func (rt *retrier) nextUpstream(r *http.Request, tried map[string]struct{}) string {
+
+	var upstream string
+
+	for i := 0; i < 3; i++ {
+
+		req := r.Clone(r.Context())
+		req.URL.Path = originalPath(r)
+		req.URL.RawPath = ""
+		req.RequestURI = req.URL.RequestURI()
+
+		u, err := rt.upstreamer.Upstream(req)
+		if err != nil || u == "" {
+			break
+		}
+
+		if upstream = u; !isTried(tried, u) {
+			break
+		}
+	}
+
+	return upstream
+}
+
+func isTried(tried map[string]struct{}, upstream string) bool {
+
+	_, ok := tried[upstream]
+
+	return ok
+}
+
+// A retryState holds the state of the attempts for a single request.
+type retryState struct {
+	retrier  *retrier
+	policy   *retryPolicy
+	w        http.ResponseWriter
+	r        *http.Request
+	tried    map[string]struct{}
+	done     chan *attemptWriter
+	winner   *attemptWriter
+	body     []byte
+	all      []*attemptWriter
+	attempts int
+	pending  int
+	lock     sync.Mutex
+}
+
+func (s *retryState) serve() {
+
+	s.attempts = 1
+	s.start(s.r.URL.Host, attemptKindFirst)
+	running := 1
+
+	var hedge <-chan time.Time
+	if delay, ok := s.policy.hedgeDelay(); ok {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	var lastPanic any
+
+	for running > 0 {
+
+		select {
+
+		case a := <-s.done:
+
+			running--
+
+			s.lock.Lock()
+			if !a.wroteHeader {
+				s.pending--
+			}
+			winner := s.winner
+			s.lock.Unlock()
+
+			if a.panicked != nil {
+				lastPanic = a.panicked
+			}
+
+			s.observe(a, winner)
+
+			if a.next != "" {
+				s.start(a.next, attemptKindRetry)
+				running++
+			}
+
+		case <-hedge:
+
+			hedge = nil
+
+			s.lock.Lock()
+			var upstream string
+			if s.winner == nil && s.pending > 0 {
+				upstream = s.reserve()
+			}
+			s.lock.Unlock()
+
+			if upstream != "" {
+				s.start(upstream, attemptKindHedge)
+				running++
+			}
+		}
+	}
+
+	switch {
+
+	case s.winner != nil && s.winner.panicked != nil:
+		// The response has been partially written, the
+		// server must abort it like it would have without retries.
+		panic(s.winner.panicked)
+
+	case s.winner == nil && lastPanic != nil:
+		panic(lastPanic)
+
+	case s.winner == nil:
+		writeError(s.w, s.r, errBadGateway)
+	}
+}
+
+// start starts a new attempt to the given upstream.
+func (s *retryState) start(upstream string, kind string) {
+
+	ctx, cancel := context.WithCancel(s.r.Context())
+
+	req := s.r.Clone(ctx)
+	req.URL.Host = upstream
+	if s.body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(s.body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(s.body)), nil }
+	}
+
+	a := &attemptWriter{
+		state:    s,
+		header:   http.Header{},
+		upstream: upstream,
+		kind:     kind,
+		cancel:   cancel,
+		start:    time.Now(),
+	}
+
+	s.lock.Lock()
+	s.tried[upstream] = struct{}{}
+	s.all = append(s.all, a)
+	s.pending++
+	winner := s.winner
+	s.lock.Unlock()
+
+	// A response may have been committed while
+	// a retry was being started.
+	if winner != nil {
+		cancel()
+	}
+
+	go func() {
+
+		defer func() {
+			a.panicked = recover()
+			a.end = time.Now()
+			cancel()
+			s.done <- a
+		}()
+
+		s.retrier.next.ServeHTTP(a, req)
+	}()
+}
+
+// reserve reserves a new attempt and returns its upstream, or
+// an empty string if no more attempt can be made. The lock must be held.
+func (s *retryState) reserve() string {
+
+	if s.attempts >= s.policy.maxAttempts {
+		return ""
+	}
+
+	upstream := s.retrier.nextUpstream(s.r, s.tried)
+	if upstream == "" {
+		return ""
+	}
+
+	if !s.retrier.budget.withdraw() {
+		if s.retrier.budgetMetric != nil {
+			s.retrier.budgetMetric.Inc()
+		}
+		return ""
+	}
+
+	s.attempts++
+
+	return upstream
+}
+
+// commit makes the given attempt the one sent back
+// to the client. The lock must be held.
+func (s *retryState) commit(a *attemptWriter, code int) {
+
+	s.winner = a
+
+	for _, other := range s.all {
+		if other != a {
+			other.cancel()
+		}
+	}
+
+	h := s.w.Header()
+	for k, v := range a.header {
+		h[k] = v
+	}
+
+	s.w.WriteHeader(code)
+}
+
+func (s *retryState) observe(a *attemptWriter, winner *attemptWriter) {
+
+	var result string
+
+	switch {
+	case a == winner && !s.policy.retryable(a.status):
+		result = attemptResultSuccess
+	case a == winner, a.next != "", a.wroteHeader && s.policy.retryable(a.status):
+		result = attemptResultFailure
+	case winner != nil:
+		// The attempt has been canceled because another one won.
+		result = attemptResultCanceled
+	default:
+		result = attemptResultFailure
+	}
+
+	s.retrier.observeAttempt(a.kind, result)
+
+	if result == attemptResultCanceled {
+		return
+	}
+
+	if result == attemptResultSuccess && s.policy.latencies != nil {
+		s.policy.latencies.observe(a.headerTime.Sub(a.start))
+	}
+
+	if s.retrier.latency != nil {
+		s.retrier.latency.CollectLatency(a.upstream, a.end.Sub(a.start))
+	}
+}
+
+// An attemptWriter is the http.ResponseWriter of a single attempt.
+// It decides when the response status is known if the response is
+// sent back to the client, or discarded because another attempt
+// will be made or has already been sent back.
+type attemptWriter struct {
+	state       *retryState
+	header      http.Header
+	cancel      context.CancelFunc
+	panicked    any
+	start       time.Time
+	headerTime  time.Time
+	end         time.Time
+	upstream    string
+	kind        string
+	next        string
+	status      int
+	wroteHeader bool
+	discard     bool
+}
+
+func (a *attemptWriter) Header() http.Header {
+
+	a.state.lock.Lock()
+	defer a.state.lock.Unlock()
+
+	if a.state.winner == a {
+		return a.state.w.Header()
+	}
+
+	return a.header
+}
+
+func (a *attemptWriter) WriteHeader(code int) {
+
+	if a.wroteHeader {
+		return
+	}
+
+	a.wroteHeader = true
+	a.status = code
+	a.headerTime = time.Now()
+
+	s := a.state
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pending--
+
+	if s.winner != nil {
+		a.discard = true
+		return
+	}
+
+	if s.policy.retryable(code) {
+
+		// Another attempt is still running and may succeed.
+		if s.pending > 0 {
+			a.discard = true
+			return
+		}
+
+		if a.next = s.reserve(); a.next != "" {
+			a.discard = true
+			return
+		}
+	}
+
+	s.commit(a, code)
+}
+
+func (a *attemptWriter) Write(data []byte) (int, error) {
+
+	if !a.wroteHeader {
+		a.WriteHeader(http.StatusOK)
+	}
+
+	if a.discard {
+		return len(data), nil
+	}
+
+	return a.state.w.Write(data)
+}
+
+func (a *attemptWriter) Flush() {
+
+	if !a.wroteHeader || a.discard {
+		return
+	}
+
+	_ = http.NewResponseController(a.state.w).Flush()
+}
@@ -0,0 +1,449 @@
+package gateway
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut/gateway/upstreamer/static"
+)
+
+// roundRobinUpstreamer returns the given upstreams in turn.
+type roundRobinUpstreamer struct {
+	upstreams []string
+	latencies map[string]int
+	next      int
+	lock      sync.Mutex
+}
+
+func (u *roundRobinUpstreamer) Upstream(*http.Request) (string, error) {
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if len(u.upstreams) == 0 {
+		return "", nil
+	}
+
+	up := u.upstreams[u.next%len(u.upstreams)]
+	u.next++
+
+	return up, nil
+}
+
+func (u *roundRobinUpstreamer) CollectLatency(address string, _ time.Duration) {
+
+	u.lock.Lock()
+	if u.latencies == nil {
+		u.latencies = map[string]int{}
+	}
+	u.latencies[address]++
+	u.lock.Unlock()
+}
+
+// fakeUpstreams is an http.Handler acting like the forwarder,
+// calling a handler per upstream.
+type fakeUpstreams struct {
+	handlers map[string]http.HandlerFunc
+	calls    map[string]*int64
+}
+
+func newFakeUpstreams(handlers map[string]http.HandlerFunc) *fakeUpstreams {
+
+	f := &fakeUpstreams{handlers: handlers, calls: map[string]*int64{}}
+	for k := range handlers {
+		f.calls[k] = new(int64)
+	}
+
+	return f
+}
+
+func (f *fakeUpstreams) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	atomic.AddInt64(f.calls[r.URL.Host], 1)
+	f.handlers[r.URL.Host](w, r)
+}
+
+func (f *fakeUpstreams) count(host string) int64 {
+	return atomic.LoadInt64(f.calls[host])
+}
+
+func statusHandler(code int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Body", body)
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func makeTestRetrier(next http.Handler, u *roundRobinUpstreamer, options ...Option) *retrier {
+
+	cfg := newGatewayConfig()
+	cfg.retryMetricsRegisterer = prometheus.NewRegistry()
+	for _, opt := range options {
+		opt(cfg)
+	}
+
+	rt, err := newRetrier(next, u, u, cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return rt
+}
+
+func doRetrierRequest(rt *retrier, method string, path string, upstream string, body string) *httptest.ResponseRecorder {
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, "http://gateway"+path, reader)
+	req.URL.Host = upstream
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestRetrier_Retry(t *testing.T) {
+
+	Convey("Given I have a retrier and a failing upstream", t, func() {
+
+		upstreams := newFakeUpstreams(map[string]http.HandlerFunc{
+			"a": statusHandler(http.StatusServiceUnavailable, "a"),
+			"b": func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(append([]byte("b:"), data...))
+			},
+		})
+
+		u := &roundRobinUpstreamer{upstreams: []string{"a", "b"}}
+		rt := makeTestRetrier(upstreams, u, OptionUpstreamRetryPolicy("", RetryPolicy{}))
+
+		Convey("When I send an idempotent request", func() {
+
+			w := doRetrierRequest(rt, http.MethodPut, "/lists", "a", "hello")
+
+			Convey("Then it should be retried on another upstream with the same body", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, "b:hello")
+				So(w.Header().Get("X-Upstream-Body"), ShouldBeEmpty)
+				So(upstreams.count("a"), ShouldEqual, 1)
+				So(upstreams.count("b"), ShouldEqual, 1)
+				So(testutil.ToFloat64(rt.attemptsMetric.WithLabelValues(attemptKindFirst, attemptResultFailure)), ShouldEqual, 1)
+				So(testutil.ToFloat64(rt.attemptsMetric.WithLabelValues(attemptKindRetry, attemptResultSuccess)), ShouldEqual, 1)
+				So(u.latencies, ShouldResemble, map[string]int{"a": 1, "b": 1})
+			})
+		})
+
+		Convey("When I send a non idempotent request", func() {
+
+			w := doRetrierRequest(rt, http.MethodPost, "/lists", "a", "hello")
+
+			Convey("Then it should not be retried", func() {
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(w.Body.String(), ShouldEqual, "a")
+				So(w.Header().Get("X-Upstream-Body"), ShouldEqual, "a")
+				So(upstreams.count("b"), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When I send a request to an upstream that must not change", func() {
+
+			req := withFixedUpstream(httptest.NewRequest(http.MethodGet, "http://gateway/lists", nil))
+			req.URL.Host = "a"
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, req)
+
+			Convey("Then it should not be retried", func() {
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(upstreams.count("b"), ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given I have a retrier and only failing upstreams", t, func() {
+
+		upstreams := newFakeUpstreams(map[string]http.HandlerFunc{
+			"a": statusHandler(http.StatusBadGateway, "a"),
+			"b": statusHandler(http.StatusGatewayTimeout, "b"),
+			"c": statusHandler(http.StatusServiceUnavailable, "c"),
+		})
+
+		u := &roundRobinUpstreamer{upstreams: []string{"a", "b", "c"}}
+		rt := makeTestRetrier(upstreams, u, OptionUpstreamRetryPolicy("/lists", RetryPolicy{MaxAttempts: 3}))
+
+		Convey("When I send a request", func() {
+
+			w := doRetrierRequest(rt, http.MethodGet, "/lists", "a", "")
+
+			Convey("Then the last response should be returned", func() {
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(w.Body.String(), ShouldEqual, "c")
+				So(upstreams.count("a"), ShouldEqual, 1)
+				So(upstreams.count("b"), ShouldEqual, 1)
+				So(upstreams.count("c"), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I send a request on a path without policy", func() {
+
+			w := doRetrierRequest(rt, http.MethodGet, "/tasks", "a", "")
+
+			Convey("Then it should not be retried", func() {
+				So(w.Code, ShouldEqual, http.StatusBadGateway)
+				So(upstreams.count("b"), ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given I have a retrier with policies for several prefixes", t, func() {
+
+		upstreams := newFakeUpstreams(map[string]http.HandlerFunc{
+			"a": statusHandler(http.StatusServiceUnavailable, "a"),
+			"b": statusHandler(http.StatusOK, "b"),
+		})
+
+		u := &roundRobinUpstreamer{upstreams: []string{"a", "b"}}
+		rt := makeTestRetrier(
+			upstreams,
+			u,
+			OptionUpstreamRetryPolicy("/", RetryPolicy{}),
+			OptionUpstreamRetryPolicy("/lists", RetryPolicy{Methods: []string{"post"}}),
+		)
+
+		Convey("Then the longest prefix should be used", func() {
+			So(doRetrierRequest(rt, http.MethodPost, "/lists", "a", "").Code, ShouldEqual, http.StatusOK)
+			So(doRetrierRequest(rt, http.MethodGet, "/lists", "a", "").Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(doRetrierRequest(rt, http.MethodGet, "/tasks", "a", "").Code, ShouldEqual, http.StatusOK)
+		})
+	})
+
+	Convey("Given I have a retrier with an exhausted budget", t, func() {
+
+		upstreams := newFakeUpstreams(map[string]http.HandlerFunc{
+			"a": statusHandler(http.StatusServiceUnavailable, "a"),
+			"b": statusHandler(http.StatusOK, "b"),
+		})
+
+		u := &roundRobinUpstreamer{upstreams: []string{"a", "b"}}
+		rt := makeTestRetrier(
+			upstreams,
+			u,
+			OptionUpstreamRetryPolicy("", RetryPolicy{}),
+			OptionUpstreamRetryBudget(0.5, 1),
+		)
+
+		Convey("When I send several failing requests", func() {
+
+			var codes []int
+			for i := 0; i < 3; i++ {
+				codes = append(codes, doRetrierRequest(rt, http.MethodGet, "/lists", "a", "").Code)
+			}
+
+			Convey("Then only the first ones should be retried", func() {
+				So(codes, ShouldResemble, []int{http.StatusOK, http.StatusOK, http.StatusServiceUnavailable})
+				So(testutil.ToFloat64(rt.budgetMetric), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestRetrier_PrefixedService(t *testing.T) {
+
+	Convey("Given I have a retrier using an upstreamer with a prefixed service", t, func() {
+
+		upstreams := newFakeUpstreams(map[string]http.HandlerFunc{
+			"a:1": statusHandler(http.StatusServiceUnavailable, "a"),
+			"b:1": statusHandler(http.StatusOK, "b"),
+			"c:1": statusHandler(http.StatusOK, "c"),
+		})
+
+		// The second endpoint of the prefixed service is heavily
+		// weighted so the retry does not pick the failing one again.
+		u, err := static.NewUpstreamer(&static.Config{
+			Services: []*static.Service{
+				{
+					Name:       "cats-v2",
+					Prefix:     "v2",
+					Identities: []string{"cats"},
+					Endpoints:  []*static.Endpoint{{Address: "a:1", Weight: 1}, {Address: "b:1", Weight: 1000000}},
+				},
+				{
+					Name:       "cats",
+					Identities: []string{"cats"},
+					Endpoints:  []*static.Endpoint{{Address: "c:1"}},
+				},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		cfg := newGatewayConfig()
+		OptionUpstreamRetryPolicy("/_v2/", RetryPolicy{})(cfg)
+
+		rt, err := newRetrier(upstreams, u, nil, cfg)
+		So(err, ShouldBeNil)
+
+		Convey("When the first upstream of the prefixed service fails", func() {
+
+			req := httptest.NewRequest(http.MethodGet, "http://gateway/_v2/cats", nil)
+			req = withOriginalPath(req, req.URL.Path)
+
+			_, err := u.Upstream(req)
+			So(err, ShouldBeNil)
+			So(req.URL.Path, ShouldEqual, "/cats")
+			req.URL.Host = "a:1"
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, req)
+
+			Convey("Then it should be retried on another upstream of the same service", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, "b")
+				So(upstreams.count("c:1"), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestRetrier_Hedge(t *testing.T) {
+
+	Convey("Given I have a retrier with hedging and a slow upstream", t, func() {
+
+		release := make(chan struct{})
+		defer close(release)
+
+		upstreams := newFakeUpstreams(map[string]http.HandlerFunc{
+			"slow": func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-release:
+				case <-r.Context().Done():
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte("slow"))
+			},
+			"fast": statusHandler(http.StatusOK, "fast"),
+		})
+
+		u := &roundRobinUpstreamer{upstreams: []string{"fast"}}
+		rt := makeTestRetrier(upstreams, u, OptionUpstreamRetryPolicy("", RetryPolicy{HedgePercentile: 0.9, HedgeMinDelay: 10 * time.Millisecond}))
+
+		Convey("When there are not enough response times", func() {
+
+			_, ok := rt.policies[0].hedgeDelay()
+
+			Convey("Then requests should not be hedged", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When I send a request after collecting response times", func() {
+
+			for i := 0; i < minHedgeSamples; i++ {
+				rt.policies[0].latencies.observe(time.Millisecond)
+			}
+
+			w := doRetrierRequest(rt, http.MethodGet, "/lists", "slow", "")
+
+			Convey("Then the hedged request should win", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, "fast")
+				So(upstreams.count("slow"), ShouldEqual, 1)
+				So(upstreams.count("fast"), ShouldEqual, 1)
+				So(testutil.ToFloat64(rt.attemptsMetric.WithLabelValues(attemptKindHedge, attemptResultSuccess)), ShouldEqual, 1)
+				So(testutil.ToFloat64(rt.attemptsMetric.WithLabelValues(attemptKindFirst, attemptResultCanceled)), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestRetrier_latencyTracker(t *testing.T) {
+
+	Convey("Given I have a latency tracker", t, func() {
+
+		tracker := newLatencyTracker(100)
+
+		Convey("When I observe more samples than its size", func() {
+
+			for i := 1; i <= 200; i++ {
+				tracker.observe(time.Duration(i) * time.Millisecond)
+			}
+
+			Convey("Then only the most recent samples should be used", func() {
+				p50, ok := tracker.percentile(0.5)
+				So(ok, ShouldBeTrue)
+				So(p50, ShouldEqual, 151*time.Millisecond)
+				p100, _ := tracker.percentile(1)
+				So(p100, ShouldEqual, 200*time.Millisecond)
+			})
+		})
+	})
+}
+
+func TestGateway_Retry(t *testing.T) {
+
+	Convey("Given I have a gateway with a retry policy, a failing and a working upstream", t, func() {
+
+		failing := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer failing.Close()
+
+		working := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer working.Close()
+
+		u := &roundRobinUpstreamer{
+			upstreams: []string{
+				strings.TrimPrefix(failing.URL, "https://"),
+				strings.TrimPrefix(working.URL, "https://"),
+			},
+		}
+
+		gw, err := New(
+			"127.0.0.1:7766",
+			u,
+			OptionUpstreamTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+			OptionUpstreamRetryPolicy("", RetryPolicy{}),
+			OptionUpstreamRetryMetricsRegisterer(prometheus.NewRegistry()),
+		)
+		So(err, ShouldBeNil)
+
+		gw.Start()
+		defer gw.Stop()
+
+		Convey("When I send a request routed to the failing upstream", func() {
+
+			req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:7766/lists", nil)
+			req.Close = true
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close() // nolint: errcheck
+
+			data, _ := io.ReadAll(resp.Body)
+
+			Convey("Then it should be retried on the working upstream", func() {
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(string(data), ShouldEqual, "ok")
+			})
+		})
+	})
+}
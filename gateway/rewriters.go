@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"net"
@@ -8,6 +9,7 @@ import (
 	"net/http/httputil"
 	"strings"
 
+	"go.aporeto.io/bahamut/authorizer/mtls"
 	"go.aporeto.io/tg/tglib"
 	"go.uber.org/zap"
 )
@@ -15,10 +17,11 @@ import (
 const internalWSMarkingHeader = "__internal_ws__"
 
 type requestRewriter struct {
-	customRewriter     RequestRewriter
-	blockOpenTracing   bool
-	private            bool
-	trustForwardHeader bool
+	customRewriter         RequestRewriter
+	blockOpenTracing       bool
+	private                bool
+	trustForwardHeader     bool
+	legacyClientCertHeader bool
 }
 
 func (s *requestRewriter) Rewrite(r *httputil.ProxyRequest) {
@@ -67,18 +70,33 @@ func (s *requestRewriter) Rewrite(r *httputil.ProxyRequest) {
 		r.Out.Header.Del(internalWSMarkingHeader)
 	}
 
-	if r.In.TLS != nil && len(r.In.TLS.PeerCertificates) == 1 {
+	if r.In.TLS != nil && len(r.In.TLS.PeerCertificates) > 0 {
 
-		block, err := tglib.CertToPEM(r.In.TLS.PeerCertificates[0])
+		header, err := s.encodeClientCertificates(r.In.TLS.PeerCertificates)
 		if err != nil {
 			zap.L().Error("Unable to handle client TLS certificate", zap.Error(err))
 			panic(fmt.Sprintf("unable to handle client TLS certificate: %s", err)) // panic are recovered from oxy
 		}
 
-		r.Out.Header.Add("X-TLS-Client-Certificate", strings.ReplaceAll(string(pem.EncodeToMemory(block)), "\n", " "))
+		r.Out.Header.Set("X-TLS-Client-Certificate", header)
 	}
 }
 
+func (s *requestRewriter) encodeClientCertificates(certs []*x509.Certificate) (string, error) {
+
+	if !s.legacyClientCertHeader {
+		return mtls.EncodeCertificatesHeader(certs)
+	}
+
+	// The legacy encoding only supports the leaf certificate.
+	block, err := tglib.CertToPEM(certs[0])
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ReplaceAll(string(pem.EncodeToMemory(block)), "\n", " "), nil
+}
+
 type circuitBreakerHandler struct{}
 
 func (h *circuitBreakerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
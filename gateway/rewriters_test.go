@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -23,6 +24,18 @@ func Test_requestRewriter(t *testing.T) {
 
 		rw := &requestRewriter{}
 
+		certData := []byte(`-----BEGIN CERTIFICATE-----
+MIIBKjCB0qADAgECAhBLliCl1URppVpoHheDFLdKMAoGCCqGSM49BAMCMA8xDTAL
+BgNVBAMTBHRvdG8wHhcNMjAwMjI4MTgzMTA2WhcNMzAwMTA2MTgzMTA2WjAPMQ0w
+CwYDVQQDEwR0b3RvMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEy6WbbGdtOM0b
+DXryjj9EZiFKphTurtQTeo5whOoTTodNHJwMxAj2pSl+lAYDxokdk6PdUd/jZ5s2
++LXXqIhT0aMQMA4wDAYDVR0TAQH/BAIwADAKBggqhkjOPQQDAgNHADBEAiAxmm8w
+Ag5DuK1V5vjCqZeuXWyVrfoL3rbMHdrpsYVqSAIgeY9F6wqMEpqIPjAtbCkSC+DG
+f6eiTREm5FRLzNkfhxQ=
+-----END CERTIFICATE-----
+`,
+		)
+
 		customReqriter := func(req *httputil.ProxyRequest, private bool) error {
 			switch private {
 			case true:
@@ -147,18 +160,6 @@ func Test_requestRewriter(t *testing.T) {
 
 		Convey("When I call Rewrite it with a valid TLS client certificate", func() {
 
-			certData := []byte(`-----BEGIN CERTIFICATE-----
-MIIBKjCB0qADAgECAhBLliCl1URppVpoHheDFLdKMAoGCCqGSM49BAMCMA8xDTAL
-BgNVBAMTBHRvdG8wHhcNMjAwMjI4MTgzMTA2WhcNMzAwMTA2MTgzMTA2WjAPMQ0w
-CwYDVQQDEwR0b3RvMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEy6WbbGdtOM0b
-DXryjj9EZiFKphTurtQTeo5whOoTTodNHJwMxAj2pSl+lAYDxokdk6PdUd/jZ5s2
-+LXXqIhT0aMQMA4wDAYDVR0TAQH/BAIwADAKBggqhkjOPQQDAgNHADBEAiAxmm8w
-Ag5DuK1V5vjCqZeuXWyVrfoL3rbMHdrpsYVqSAIgeY9F6wqMEpqIPjAtbCkSC+DG
-f6eiTREm5FRLzNkfhxQ=
------END CERTIFICATE-----
-`,
-			)
-
 			cert, err := tglib.ParseCertificate(certData)
 			if err != nil {
 				panic(err)
@@ -173,6 +174,48 @@ f6eiTREm5FRLzNkfhxQ=
 			rw.Rewrite(&httputil.ProxyRequest{In: r, Out: r2})
 
 			Convey("Then the response should be correct", func() {
+				So(r2.Header.Get("X-TLS-Client-Certificate"), ShouldEqual, "v=2;Chain="+url.QueryEscape(string(certData)))
+			})
+		})
+
+		Convey("When I call Rewrite it with a valid TLS client certificate chain", func() {
+
+			cert, err := tglib.ParseCertificate(certData)
+			if err != nil {
+				panic(err)
+			}
+
+			r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1", nil)
+			r.Header.Set("X-TLS-Client-Certificate", "spoofed")
+			r.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert, cert},
+			}
+			r2 := r.Clone(context.Background())
+
+			rw.Rewrite(&httputil.ProxyRequest{In: r, Out: r2})
+
+			Convey("Then the header should contain the full chain", func() {
+				So(r2.Header.Values("X-TLS-Client-Certificate"), ShouldResemble, []string{"v=2;Chain=" + url.QueryEscape(string(certData)+string(certData))})
+			})
+		})
+
+		Convey("When I call Rewrite it with a valid TLS client certificate chain using the legacy encoding", func() {
+
+			cert, err := tglib.ParseCertificate(certData)
+			if err != nil {
+				panic(err)
+			}
+
+			r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1", nil)
+			r.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert, cert},
+			}
+			r2 := r.Clone(context.Background())
+
+			rw := &requestRewriter{legacyClientCertHeader: true}
+			rw.Rewrite(&httputil.ProxyRequest{In: r, Out: r2})
+
+			Convey("Then the header should only contain the leaf certificate", func() {
 				So(r2.Header.Get("X-TLS-Client-Certificate"), ShouldEqual, strings.ReplaceAll(string(certData), "\n", " "))
 			})
 		})
@@ -0,0 +1,253 @@
+package gateway
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.aporeto.io/elemental"
+	"go.uber.org/zap"
+)
+
+// A prefixedBodyTransformer is a BodyTransformer
+// used for the requests matching a path prefix.
+type prefixedBodyTransformer struct {
+	transform BodyTransformer
+	prefix    string
+}
+
+func makePrefixedBodyTransformers(transformers map[string]BodyTransformer) []prefixedBodyTransformer {
+
+	out := make([]prefixedBodyTransformer, 0, len(transformers))
+	for prefix, t := range transformers {
+		out = append(out, prefixedBodyTransformer{prefix: prefix, transform: t})
+	}
+
+	// The longest prefixes are checked first.
+	sort.Slice(out, func(i, j int) bool { return len(out[i].prefix) > len(out[j].prefix) })
+
+	return out
+}
+
+// bodyTransformerFor returns the BodyTransformer matching the path of
+// the given request before it was routed, as the Upstreamer can rewrite
+// it, like the push Upstreamer trimming the prefix of the services.
+func bodyTransformerFor(transformers []prefixedBodyTransformer, r *http.Request) BodyTransformer {
+
+	path := originalPath(r)
+
+	for _, t := range transformers {
+		if strings.HasPrefix(path, t.prefix) {
+			return t.transform
+		}
+	}
+
+	return nil
+}
+
+// A bodyTransformer is a http.Handler transforming the elemental
+// bodies of the requests and responses using the BodyTransformers
+// registered for their path. The bodies of the requests and responses
+// without a matching BodyTransformer are streamed untouched.
+type bodyTransformer struct {
+	next                 http.Handler
+	corsInjector         errorHeaderInjector
+	requestTransformers  []prefixedBodyTransformer
+	responseTransformers []prefixedBodyTransformer
+}
+
+func newBodyTransformer(next http.Handler, corsInjector errorHeaderInjector, cfg *gwconfig) *bodyTransformer {
+
+	return &bodyTransformer{
+		next:                 next,
+		corsInjector:         corsInjector,
+		requestTransformers:  makePrefixedBodyTransformers(cfg.requestBodyTransformers),
+		responseTransformers: makePrefixedBodyTransformers(cfg.responseBodyTransformers),
+	}
+}
+
+func (t *bodyTransformer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if transform := bodyTransformerFor(t.requestTransformers, r); transform != nil && r.Body != nil && r.Body != http.NoBody {
+
+		if encoding, ok := bodyEncoding(r.Header); ok {
+
+			data, err := io.ReadAll(r.Body)
+			_ = r.Body.Close()
+			if err == nil {
+				data, err = transformBody(transform, r, encoding, data)
+			}
+
+			if err != nil {
+				t.corsInjector(w, r)
+				writeError(w, r, makeError(http.StatusBadRequest, "Bad Request", fmt.Sprintf("Unable to transform request body: %s", err)))
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+			r.ContentLength = int64(len(data))
+			r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+		}
+	}
+
+	// The responses to HEAD requests have no body to transform.
+	transform := bodyTransformerFor(t.responseTransformers, r)
+	if transform == nil || r.Method == http.MethodHead {
+		t.next.ServeHTTP(w, r)
+		return
+	}
+
+	// The compressed responses cannot be transformed, so the
+	// upstream must not compress them. If the compression is
+	// enabled with the upstreams, the transport asks for it and
+	// decompresses the response before it reaches the transformer.
+	r.Header.Del("Accept-Encoding")
+
+	tw := &transformWriter{ResponseWriter: w}
+	t.next.ServeHTTP(tw, r)
+
+	if !tw.buffering {
+		return
+	}
+
+	data, err := transformBody(transform, r, tw.encoding, tw.body.Bytes())
+	if err != nil {
+
+		zap.L().Error("Unable to transform response body",
+			zap.String("path", r.URL.Path),
+			zap.Error(err),
+		)
+
+		h := w.Header()
+		for k := range h {
+			delete(h, k)
+		}
+
+		t.corsInjector(w, r)
+		writeError(w, r, makeError(http.StatusBadGateway, "Bad Gateway", fmt.Sprintf("Unable to transform response body: %s", err)))
+		return
+	}
+
+	// The validators of the upstream do not
+	// match the transformed body anymore.
+	if !bytes.Equal(data, tw.body.Bytes()) {
+		w.Header().Del("ETag")
+		w.Header().Del("Last-Modified")
+		w.Header().Del("Content-MD5")
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(tw.code)
+	w.Write(data) // nolint: errcheck
+}
+
+// A transformWriter is a http.ResponseWriter buffering the
+// successful responses with an elemental body, and passing
+// through the other ones.
+type transformWriter struct {
+	http.ResponseWriter
+	encoding  elemental.EncodingType
+	body      bytes.Buffer
+	code      int
+	buffering bool
+}
+
+func (w *transformWriter) WriteHeader(code int) {
+
+	if w.code != 0 {
+		return
+	}
+
+	w.code = code
+
+	if code >= 200 && code < 300 && code != http.StatusNoContent {
+		if w.encoding, w.buffering = bodyEncoding(w.Header()); w.buffering {
+			return
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *transformWriter) Write(data []byte) (int, error) {
+
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.buffering {
+		return w.body.Write(data)
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush flushes the underlying http.ResponseWriter
+// unless the response is buffered.
+func (w *transformWriter) Flush() {
+
+	if w.buffering {
+		return
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap allows the http.ResponseController
+// to access the underlying http.ResponseWriter.
+func (w *transformWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// bodyEncoding returns the elemental encoding of the body
+// described by the given headers, or false if the body is
+// not an uncompressed elemental payload.
+func bodyEncoding(h http.Header) (elemental.EncodingType, bool) {
+
+	if h.Get("Content-Encoding") != "" {
+		return "", false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return "", false
+	}
+
+	switch encoding := elemental.EncodingType(mediaType); encoding {
+	case elemental.EncodingTypeJSON, elemental.EncodingTypeMSGPACK:
+		return encoding, true
+	default:
+		return "", false
+	}
+}
+
+// transformBody decodes the given data, transforms it
+// using the given BodyTransformer and encodes the result.
+func transformBody(transform BodyTransformer, r *http.Request, encoding elemental.EncodingType, data []byte) ([]byte, error) {
+
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	var payload any
+	if err := elemental.Decode(encoding, data, &payload); err != nil {
+		return nil, fmt.Errorf("unable to decode body: %w", err)
+	}
+
+	payload, err := transform(r, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err = elemental.Encode(encoding, payload); err != nil {
+		return nil, fmt.Errorf("unable to encode body: %w", err)
+	}
+
+	return data, nil
+}
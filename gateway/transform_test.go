@@ -0,0 +1,296 @@
+package gateway
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBodyTransformer(t *testing.T) {
+
+	stripSecret := func(r *http.Request, payload any) (any, error) {
+
+		switch p := payload.(type) {
+		case map[string]any:
+			delete(p, "secret")
+		case []any:
+			for _, o := range p {
+				delete(o.(map[string]any), "secret")
+			}
+		}
+
+		return payload, nil
+	}
+
+	failing := func(r *http.Request, payload any) (any, error) {
+		return nil, fmt.Errorf("boom")
+	}
+
+	makeTransformer := func(next http.Handler, options ...Option) *bodyTransformer {
+
+		cfg := newGatewayConfig()
+		for _, o := range options {
+			o(cfg)
+		}
+
+		return newBodyTransformer(
+			next,
+			func(w http.ResponseWriter, r *http.Request) http.Header {
+				w.Header().Set("Access-Control-Allow-Origin", "gateway")
+				return w.Header()
+			},
+			cfg,
+		)
+	}
+
+	Convey("Given I have a body transformer for the requests", t, func() {
+
+		var received string
+		var receivedLength int64
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			received = string(data)
+			receivedLength = r.ContentLength
+		})
+
+		bt := makeTransformer(
+			next,
+			OptionRequestBodyTransformer("/cats", failing),
+			OptionRequestBodyTransformer("/cats/kittens", stripSecret),
+		)
+
+		call := func(path string, contentType string, body string) *httptest.ResponseRecorder {
+
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+
+			w := httptest.NewRecorder()
+			bt.ServeHTTP(w, req)
+
+			return w
+		}
+
+		Convey("When I send an elemental request matching the longest prefix", func() {
+
+			w := call("/cats/kittens", "application/json; charset=UTF-8", `{"name":"felix","secret":"catnip"}`)
+
+			Convey("Then the body should be transformed", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(received, ShouldEqual, `{"name":"felix"}`)
+				So(receivedLength, ShouldEqual, len(`{"name":"felix"}`))
+			})
+		})
+
+		Convey("When I send a request that is not elemental", func() {
+
+			call("/cats/kittens", "text/plain", `{"name":"felix","secret":"catnip"}`)
+
+			Convey("Then the body should not be transformed", func() {
+				So(received, ShouldEqual, `{"name":"felix","secret":"catnip"}`)
+			})
+		})
+
+		Convey("When I send a request not matching any prefix", func() {
+
+			call("/dogs", "application/json", `{"name":"rex","secret":"bone"}`)
+
+			Convey("Then the body should not be transformed", func() {
+				So(received, ShouldEqual, `{"name":"rex","secret":"bone"}`)
+			})
+		})
+
+		Convey("When the transformation fails", func() {
+
+			w := call("/cats", "application/json", `{"name":"felix"}`)
+
+			Convey("Then I should get a 400", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "gateway")
+				So(w.Body.String(), ShouldContainSubstring, "boom")
+				So(received, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I send an invalid body", func() {
+
+			w := call("/cats/kittens", "application/json", `{"name":`)
+
+			Convey("Then I should get a 400", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(received, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given I have a body transformer for the responses", t, func() {
+
+		code := http.StatusOK
+		contentType := "application/json"
+		body := `[{"name":"felix","secret":"catnip"},{"name":"garfield","secret":"lasagna"}]`
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Header().Set("Content-MD5", "Q2hlY2sgSW50ZWdyaXR5IQ==")
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(body))
+		})
+
+		call := func(bt *bodyTransformer) *httptest.ResponseRecorder {
+
+			w := httptest.NewRecorder()
+			bt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cats", nil))
+
+			return w
+		}
+
+		Convey("When I get a successful elemental response", func() {
+
+			w := call(makeTransformer(next, OptionResponseBodyTransformer("", stripSecret)))
+
+			Convey("Then the body should be transformed", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, `[{"name":"felix"},{"name":"garfield"}]`)
+				So(w.Header().Get("Content-Length"), ShouldEqual, fmt.Sprintf("%d", w.Body.Len()))
+			})
+
+			Convey("Then the validators of the upstream should be removed", func() {
+				So(w.Header().Get("ETag"), ShouldBeEmpty)
+				So(w.Header().Get("Last-Modified"), ShouldBeEmpty)
+				So(w.Header().Get("Content-MD5"), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I get a successful elemental response the transformer does not change", func() {
+
+			body = `[{"name":"felix"}]`
+			w := call(makeTransformer(next, OptionResponseBodyTransformer("", stripSecret)))
+
+			Convey("Then the validators of the upstream should be kept", func() {
+				So(w.Body.String(), ShouldEqual, body)
+				So(w.Header().Get("ETag"), ShouldEqual, `"v1"`)
+			})
+		})
+
+		Convey("When the upstream compresses the responses the client accepts compressed", func() {
+
+			gzipping := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+				w.Header().Set("Content-Type", contentType)
+
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					w.WriteHeader(code)
+					_, _ = w.Write([]byte(body))
+					return
+				}
+
+				w.Header().Set("Content-Encoding", "gzip")
+				w.WriteHeader(code)
+				gw := gzip.NewWriter(w)
+				_, _ = gw.Write([]byte(body))
+				_ = gw.Close()
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/cats", nil)
+			req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+			w := httptest.NewRecorder()
+			makeTransformer(gzipping, OptionResponseBodyTransformer("", stripSecret)).ServeHTTP(w, req)
+
+			Convey("Then the body should be transformed", func() {
+				So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+				So(w.Body.String(), ShouldEqual, `[{"name":"felix"},{"name":"garfield"}]`)
+			})
+		})
+
+		Convey("When I send a HEAD request", func() {
+
+			w := httptest.NewRecorder()
+			makeTransformer(next, OptionResponseBodyTransformer("", stripSecret)).ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/cats", nil))
+
+			Convey("Then the response should not be transformed", func() {
+				So(w.Header().Get("Content-Length"), ShouldEqual, fmt.Sprintf("%d", len(body)))
+				So(w.Header().Get("ETag"), ShouldEqual, `"v1"`)
+			})
+		})
+
+		Convey("When I send a request whose prefix has been trimmed by the upstreamer", func() {
+
+			bt := makeTransformer(next, OptionResponseBodyTransformer("/_v2/", stripSecret))
+			req := httptest.NewRequest(http.MethodGet, "/cats", nil)
+
+			w := httptest.NewRecorder()
+			bt.ServeHTTP(w, withOriginalPath(req, "/_v2/cats"))
+
+			Convey("Then the transformer registered for the original path should be used", func() {
+				So(w.Body.String(), ShouldEqual, `[{"name":"felix"},{"name":"garfield"}]`)
+			})
+		})
+
+		Convey("When I get an error response", func() {
+
+			code = http.StatusNotFound
+			w := call(makeTransformer(next, OptionResponseBodyTransformer("", stripSecret)))
+
+			Convey("Then the body should not be transformed", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				So(w.Body.String(), ShouldEqual, body)
+			})
+		})
+
+		Convey("When I get a response that is not elemental", func() {
+
+			contentType = "text/plain"
+			w := call(makeTransformer(next, OptionResponseBodyTransformer("", stripSecret)))
+
+			Convey("Then the body should not be transformed", func() {
+				So(w.Body.String(), ShouldEqual, body)
+			})
+		})
+
+		Convey("When the transformation fails", func() {
+
+			w := call(makeTransformer(next, OptionResponseBodyTransformer("", failing)))
+
+			Convey("Then I should get a 502", func() {
+				So(w.Code, ShouldEqual, http.StatusBadGateway)
+				So(w.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "gateway")
+				So(w.Header().Get("Content-Length"), ShouldBeEmpty)
+				So(w.Body.String(), ShouldContainSubstring, "boom")
+			})
+		})
+	})
+}
+
+func TestBodyEncoding(t *testing.T) {
+
+	Convey("Calling bodyEncoding should work", t, func() {
+
+		enc, ok := bodyEncoding(http.Header{"Content-Type": {"application/json; charset=UTF-8"}})
+		So(ok, ShouldBeTrue)
+		So(enc, ShouldEqual, "application/json")
+
+		enc, ok = bodyEncoding(http.Header{"Content-Type": {"application/msgpack"}})
+		So(ok, ShouldBeTrue)
+		So(enc, ShouldEqual, "application/msgpack")
+
+		_, ok = bodyEncoding(http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}})
+		So(ok, ShouldBeFalse)
+
+		_, ok = bodyEncoding(http.Header{"Content-Type": {"text/html"}})
+		So(ok, ShouldBeFalse)
+
+		_, ok = bodyEncoding(http.Header{})
+		So(ok, ShouldBeFalse)
+	})
+}
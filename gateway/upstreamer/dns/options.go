@@ -0,0 +1,109 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"go.aporeto.io/bahamut/gateway/upstreamer/static"
+)
+
+// A Resolver is used by the Upstreamer to resolve the DNS names.
+// *net.Resolver implements this interface.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service string, proto string, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// An UpstreamerOption represents a configuration option
+// for the Upstreamer.
+type UpstreamerOption func(*upstreamConfig)
+
+type upstreamConfig struct {
+	resolver          Resolver
+	tlsConfig         *tls.Config
+	randomizer        static.Randomizer
+	resolveInterval   time.Duration
+	requestTimeout    time.Duration
+	routesTTL         time.Duration
+	exposePrivateAPIs bool
+}
+
+func newUpstreamConfig() upstreamConfig {
+	return upstreamConfig{
+		resolver:        net.DefaultResolver,
+		resolveInterval: 10 * time.Second,
+		requestTimeout:  5 * time.Second,
+		routesTTL:       time.Minute,
+	}
+}
+
+// OptionUpstreamerResolver sets the Resolver to use to
+// resolve the DNS names. The default is net.DefaultResolver.
+func OptionUpstreamerResolver(resolver Resolver) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.resolver = resolver
+	}
+}
+
+// OptionUpstreamerResolveInterval sets how often the DNS
+// names are resolved. The default is 10s.
+func OptionUpstreamerResolveInterval(interval time.Duration) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.resolveInterval = interval
+		if cfg.resolveInterval <= 0 {
+			panic("interval cannot be <= 0")
+		}
+	}
+}
+
+// OptionUpstreamerTLSConfig sets the TLS configuration to use to
+// retrieve the routes of the endpoints. If set, the routes are
+// retrieved using https. Otherwise they are retrieved using http.
+func OptionUpstreamerTLSConfig(tlsConfig *tls.Config) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.tlsConfig = tlsConfig
+	}
+}
+
+// OptionUpstreamerRequestTimeout sets the timeout of the requests
+// retrieving the routes of the endpoints. The default is 5s.
+func OptionUpstreamerRequestTimeout(timeout time.Duration) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.requestTimeout = timeout
+		if cfg.requestTimeout <= 0 {
+			panic("timeout cannot be <= 0")
+		}
+	}
+}
+
+// OptionUpstreamerRoutesTTL sets for how long the routes of an endpoint
+// are used before being retrieved again, so the identities added to a
+// service are routed even if its endpoints keep their addresses, like
+// behind a ClusterIP service or when a pod keeps its IP across a rollout.
+// The routes are retrieved again during the first resolution following
+// their expiration. The default is 1m.
+func OptionUpstreamerRoutesTTL(ttl time.Duration) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.routesTTL = ttl
+		if cfg.routesTTL <= 0 {
+			panic("ttl cannot be <= 0")
+		}
+	}
+}
+
+// OptionUpstreamerExposePrivateAPIs configures the Upstreamer to expose
+// the private APIs.
+func OptionUpstreamerExposePrivateAPIs(enabled bool) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.exposePrivateAPIs = enabled
+	}
+}
+
+// OptionUpstreamerRandomizer set a custom Randomizer.
+func OptionUpstreamerRandomizer(randomizer static.Randomizer) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.randomizer = randomizer
+	}
+}
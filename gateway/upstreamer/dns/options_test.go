@@ -0,0 +1,64 @@
+package dns
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fixedRandomizer int
+
+func (r fixedRandomizer) Intn(int) int { return int(r) }
+
+func Test_Options(t *testing.T) {
+
+	c := newUpstreamConfig()
+
+	Convey("Calling OptionUpstreamerResolver should work", t, func() {
+		r := &net.Resolver{}
+		OptionUpstreamerResolver(r)(&c)
+		So(c.resolver, ShouldEqual, r)
+	})
+
+	Convey("Calling OptionUpstreamerResolveInterval should work", t, func() {
+		OptionUpstreamerResolveInterval(time.Hour)(&c)
+		So(c.resolveInterval, ShouldEqual, time.Hour)
+
+		So(func() { OptionUpstreamerResolveInterval(0)(&c) }, ShouldPanicWith, `interval cannot be <= 0`)
+	})
+
+	Convey("Calling OptionUpstreamerTLSConfig should work", t, func() {
+		tlsConfig := &tls.Config{}
+		OptionUpstreamerTLSConfig(tlsConfig)(&c)
+		So(c.tlsConfig, ShouldEqual, tlsConfig)
+	})
+
+	Convey("Calling OptionUpstreamerRequestTimeout should work", t, func() {
+		OptionUpstreamerRequestTimeout(time.Hour)(&c)
+		So(c.requestTimeout, ShouldEqual, time.Hour)
+
+		So(func() { OptionUpstreamerRequestTimeout(0)(&c) }, ShouldPanicWith, `timeout cannot be <= 0`)
+	})
+
+	Convey("Calling OptionUpstreamerRoutesTTL should work", t, func() {
+		OptionUpstreamerRoutesTTL(time.Hour)(&c)
+		So(c.routesTTL, ShouldEqual, time.Hour)
+
+		So(func() { OptionUpstreamerRoutesTTL(0)(&c) }, ShouldPanicWith, `ttl cannot be <= 0`)
+	})
+
+	Convey("Calling OptionUpstreamerExposePrivateAPIs should work", t, func() {
+		OptionUpstreamerExposePrivateAPIs(true)(&c)
+		So(c.exposePrivateAPIs, ShouldBeTrue)
+	})
+
+	Convey("Calling OptionUpstreamerRandomizer should work", t, func() {
+		r := fixedRandomizer(1)
+		OptionUpstreamerRandomizer(r)(&c)
+		So(c.randomizer, ShouldEqual, r)
+	})
+}
@@ -0,0 +1,434 @@
+// Package dns provides a gateway.Upstreamer discovering the endpoints
+// of the services using DNS, for instance using the SRV records or the
+// A records of Kubernetes headless services, so the services do not need
+// to run a push.Notifier.
+//
+// The routes served by each endpoint are retrieved from its /_meta/routes
+// endpoint when the endpoint is discovered, then again once they expire,
+// and the requests are routed like the push.Upstreamer does.
+package dns // import "go.aporeto.io/bahamut/gateway/upstreamer/dns"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/bahamut/gateway/upstreamer/static"
+	"go.uber.org/zap"
+)
+
+// A Target is a DNS name resolving to the endpoints of a service.
+type Target struct {
+
+	// Name is the name of the service. It is only used
+	// in the logs.
+	Name string
+
+	// Host is the DNS name to resolve.
+	Host string
+
+	// Prefix is the optional prefix of the service. If set, its
+	// identities are routed from /_<prefix>/<identity>.
+	Prefix string
+
+	// EventsAPI is the optional name of the event API
+	// of the service, for instance "events".
+	EventsAPI string
+
+	// Port is the port of the endpoints. If it is 0, the SRV
+	// records of Host are used to find the addresses and the ports
+	// of the endpoints. Otherwise, its A or AAAA records are used.
+	Port int
+}
+
+func (t Target) validate() error {
+
+	if t.Host == "" {
+		return fmt.Errorf("empty host")
+	}
+
+	if t.Port < 0 || t.Port > 65535 {
+		return fmt.Errorf("invalid port '%d'", t.Port)
+	}
+
+	if strings.Contains(t.Prefix, "/") {
+		return fmt.Errorf("invalid prefix '%s': must not contain /", t.Prefix)
+	}
+
+	return nil
+}
+
+// endpointRoutes are the routes served by an endpoint.
+type endpointRoutes struct {
+	fetched time.Time
+	routes  map[int][]bahamut.RouteInfo
+}
+
+// An Upstreamer is a gateway.Upstreamer discovering the
+// endpoints of the services using DNS. It also implements
+// the gateway.LatencyBasedUpstreamer interface.
+type Upstreamer struct {
+	routing     *static.Upstreamer
+	client      *http.Client
+	endpoints   [][]*static.Endpoint
+	routes      map[string]*endpointRoutes
+	scheme      string
+	targets     []Target
+	config      upstreamConfig
+	resolveLock sync.Mutex
+}
+
+// NewUpstreamer returns a new *Upstreamer discovering the
+// endpoints of the given targets. Call Start to start
+// resolving the targets.
+func NewUpstreamer(targets []Target, options ...UpstreamerOption) (*Upstreamer, error) {
+
+	cfg := newUpstreamConfig()
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	for i, t := range targets {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("invalid target %d: %w", i, err)
+		}
+	}
+
+	routingOptions := []static.UpstreamerOption{
+		static.OptionUpstreamerExposePrivateAPIs(cfg.exposePrivateAPIs),
+	}
+	if cfg.randomizer != nil {
+		routingOptions = append(routingOptions, static.OptionUpstreamerRandomizer(cfg.randomizer))
+	}
+
+	routing, err := static.NewUpstreamer(&static.Config{}, routingOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "http"
+	if cfg.tlsConfig != nil {
+		scheme = "https"
+	}
+
+	return &Upstreamer{
+		routing: routing,
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: cfg.tlsConfig},
+			Timeout:   cfg.requestTimeout,
+		},
+		routes:  map[string]*endpointRoutes{},
+		scheme:  scheme,
+		targets: append([]Target{}, targets...),
+		config:  cfg,
+	}, nil
+}
+
+// Upstream implements the gateway.Upstreamer interface.
+func (u *Upstreamer) Upstream(req *http.Request) (string, error) {
+	return u.routing.Upstream(req)
+}
+
+// CollectLatency implements the gateway.LatencyBasedUpstreamer interface.
+func (u *Upstreamer) CollectLatency(address string, responseTime time.Duration) {
+	u.routing.CollectLatency(address, responseTime)
+}
+
+// Start resolves the targets immediately, then at the interval set by
+// OptionUpstreamerResolveInterval, until the given context is canceled.
+// The returned channel is closed once the targets have been resolved
+// for the first time.
+func (u *Upstreamer) Start(ctx context.Context) (chan struct{}, *sync.WaitGroup) {
+
+	ready := make(chan struct{})
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+
+		defer wg.Done()
+
+		if err := u.Resolve(ctx); err != nil {
+			zap.L().Error("Unable to resolve upstreams", zap.Error(err))
+		}
+		close(ready)
+
+		ticker := time.NewTicker(u.config.resolveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+
+			case <-ticker.C:
+				if err := u.Resolve(ctx); err != nil {
+					zap.L().Error("Unable to resolve upstreams", zap.Error(err))
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ready, &wg
+}
+
+// Resolve resolves the targets, retrieves the routes of the new
+// endpoints and the expired routes of the known ones, and updates
+// the routing table. If a target cannot be resolved, its previous
+// endpoints are kept. The new endpoints whose routes cannot be
+// retrieved are ignored until the next resolution, and the known
+// ones keep their expired routes. The returned error contains all
+// the errors that happened.
+func (u *Upstreamer) Resolve(ctx context.Context) error {
+
+	u.resolveLock.Lock()
+	defer u.resolveLock.Unlock()
+
+	var errs []error
+
+	endpoints := make([][]*static.Endpoint, len(u.targets))
+	for i, t := range u.targets {
+
+		eps, err := u.lookup(ctx, t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to resolve '%s': %w", t.Host, err))
+			if u.endpoints != nil {
+				eps = u.endpoints[i]
+			}
+		}
+
+		endpoints[i] = eps
+	}
+
+	now := time.Now()
+	routes := map[string]*endpointRoutes{}
+	var missing []string
+
+	for _, eps := range endpoints {
+		for _, ep := range eps {
+
+			if _, ok := routes[ep.Address]; ok {
+				continue
+			}
+
+			r, ok := u.routes[ep.Address]
+			routes[ep.Address] = r
+
+			if !ok || now.Sub(r.fetched) >= u.config.routesTTL {
+				missing = append(missing, ep.Address)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+
+	for _, address := range missing {
+
+		wg.Add(1)
+		go func(address string) {
+
+			defer wg.Done()
+
+			r, err := u.fetchRoutes(ctx, address)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				if routes[address] == nil {
+					delete(routes, address)
+				}
+				errs = append(errs, fmt.Errorf("unable to retrieve routes of '%s': %w", address, err))
+				return
+			}
+
+			routes[address] = &endpointRoutes{routes: r, fetched: now}
+		}(address)
+	}
+
+	wg.Wait()
+
+	u.endpoints = endpoints
+	u.routes = routes
+
+	if err := u.routing.Update(u.makeConfig()); err != nil {
+		errs = append(errs, fmt.Errorf("unable to update routes: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (u *Upstreamer) lookup(ctx context.Context, t Target) ([]*static.Endpoint, error) {
+
+	var out []*static.Endpoint
+	seen := map[string]struct{}{}
+
+	add := func(address string, weight int) {
+		if _, ok := seen[address]; !ok {
+			seen[address] = struct{}{}
+			out = append(out, &static.Endpoint{Address: address, Weight: weight})
+		}
+	}
+
+	if t.Port != 0 {
+
+		hosts, err := u.config.resolver.LookupHost(ctx, t.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(hosts)
+		for _, h := range hosts {
+			add(net.JoinHostPort(h, strconv.Itoa(t.Port)), 0)
+		}
+
+		return out, nil
+	}
+
+	_, srvs, err := u.config.resolver.LookupSRV(ctx, "", "", t.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(srvs) == 0 {
+		return nil, nil
+	}
+
+	// We only use the records with the lowest
+	// priority, as described in RFC 2782.
+	priority := srvs[0].Priority
+	for _, srv := range srvs {
+		if srv.Priority < priority {
+			priority = srv.Priority
+		}
+	}
+
+	for _, srv := range srvs {
+		if srv.Priority == priority {
+			add(net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))), int(srv.Weight))
+		}
+	}
+
+	return out, nil
+}
+
+func (u *Upstreamer) fetchRoutes(ctx context.Context, address string) (map[int][]bahamut.RouteInfo, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.scheme+"://"+address+"/_meta/routes", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	routes := map[int][]bahamut.RouteInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(&routes); err != nil {
+		return nil, fmt.Errorf("unable to decode routes: %w", err)
+	}
+
+	return routes, nil
+}
+
+// makeConfig builds the static.Config routing each identity
+// to the endpoints serving it. If several targets serve the same
+// identity, the first target declared wins.
+func (u *Upstreamer) makeConfig() *static.Config {
+
+	type group struct {
+		service  *static.Service
+		identity string
+		target   int
+		private  bool
+	}
+
+	groups := map[string]*group{}
+
+	for i, t := range u.targets {
+
+		for _, ep := range u.endpoints[i] {
+
+			r, ok := u.routes[ep.Address]
+			if !ok {
+				continue
+			}
+
+			identities := map[string]bool{}
+			for _, version := range r.routes {
+				for _, route := range version {
+					identities[route.Identity] = route.Private
+				}
+			}
+
+			if t.EventsAPI != "" {
+				identities[t.EventsAPI] = false
+			}
+
+			for identity, private := range identities {
+
+				if identity == "" || strings.Contains(identity, "/") {
+					continue
+				}
+
+				key := t.Prefix + "/" + identity
+
+				g, ok := groups[key]
+				if !ok {
+					g = &group{
+						service:  &static.Service{Name: t.Name, Prefix: t.Prefix},
+						identity: identity,
+						target:   i,
+						private:  private,
+					}
+					groups[key] = g
+				}
+
+				if g.target != i {
+					continue
+				}
+
+				g.service.Endpoints = append(g.service.Endpoints, &static.Endpoint{Address: ep.Address, Weight: ep.Weight})
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	config := &static.Config{}
+	for _, k := range keys {
+
+		g := groups[k]
+		if g.private {
+			g.service.PrivateIdentities = []string{g.identity}
+		} else {
+			g.service.Identities = []string{g.identity}
+		}
+
+		config.Services = append(config.Services, g.service)
+	}
+
+	return config
+}
@@ -0,0 +1,334 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+	"go.aporeto.io/bahamut/gateway"
+)
+
+type fakeResolver struct {
+	srvs  map[string][]*net.SRV
+	hosts map[string][]string
+	err   error
+	lock  sync.Mutex
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, _ string, _ string, name string) (string, []*net.SRV, error) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.err != nil {
+		return "", nil, r.err
+	}
+
+	return name, r.srvs[name], nil
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return r.hosts[host], nil
+}
+
+func (r *fakeResolver) setSRVs(name string, srvs ...*net.SRV) {
+
+	r.lock.Lock()
+	r.srvs[name] = srvs
+	r.lock.Unlock()
+}
+
+// makeService returns a server serving the given routes
+// on /_meta/routes and counting the requests.
+func makeService(routes map[int][]bahamut.RouteInfo) (*httptest.Server, *int) {
+
+	data, err := json.Marshal(routes)
+	if err != nil {
+		panic(err)
+	}
+
+	var calls int
+	var lock sync.Mutex
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		lock.Lock()
+		calls++
+		lock.Unlock()
+
+		if r.URL.Path != "/_meta/routes" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(data)
+	})), &calls
+}
+
+func srvOf(s *httptest.Server, priority uint16, weight uint16) *net.SRV {
+
+	host, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	return &net.SRV{Target: host + ".", Port: uint16(p), Priority: priority, Weight: weight}
+}
+
+func upstream(u *Upstreamer, path string) string {
+
+	up, err := u.Upstream(&http.Request{URL: &url.URL{Path: path}})
+	So(err, ShouldBeNil)
+
+	return up
+}
+
+func TestNewUpstreamer(t *testing.T) {
+
+	var _ gateway.LatencyBasedUpstreamer = &Upstreamer{}
+
+	Convey("Given I have invalid targets", t, func() {
+
+		for _, tc := range []struct {
+			target Target
+			msg    string
+		}{
+			{Target{}, "invalid target 0: empty host"},
+			{Target{Host: "a", Port: 70000}, "invalid target 0: invalid port '70000'"},
+			{Target{Host: "a", Prefix: "a/b"}, "invalid target 0: invalid prefix 'a/b': must not contain /"},
+		} {
+			_, err := NewUpstreamer([]Target{tc.target})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, tc.msg)
+		}
+	})
+}
+
+func TestUpstreamer(t *testing.T) {
+
+	Convey("Given I have two services discovered with SRV records", t, func() {
+
+		cats1, cats1Calls := makeService(map[int][]bahamut.RouteInfo{
+			1: {
+				{Identity: "cats", URL: "/cats", Verbs: []string{http.MethodGet}},
+				{Identity: "litters", URL: "/litters", Verbs: []string{http.MethodGet}, Private: true},
+			},
+		})
+		defer cats1.Close()
+
+		cats2, _ := makeService(map[int][]bahamut.RouteInfo{
+			1: {{Identity: "cats", URL: "/cats", Verbs: []string{http.MethodGet}}},
+		})
+		defer cats2.Close()
+
+		dogs, _ := makeService(map[int][]bahamut.RouteInfo{
+			1: {
+				{Identity: "dogs", URL: "/dogs", Verbs: []string{http.MethodGet}},
+				{Identity: "cats", URL: "/dogs/:id/cats", Verbs: []string{http.MethodGet}},
+			},
+		})
+		defer dogs.Close()
+
+		resolver := &fakeResolver{
+			srvs: map[string][]*net.SRV{
+				"cats.local": {srvOf(cats1, 1, 1), srvOf(cats2, 1, 1), srvOf(dogs, 2, 1)},
+				"dogs.local": {srvOf(dogs, 1, 1)},
+			},
+		}
+
+		u, err := NewUpstreamer(
+			[]Target{
+				{Name: "cats", Host: "cats.local", EventsAPI: "events"},
+				{Name: "dogs", Host: "dogs.local", Prefix: "dogs"},
+			},
+			OptionUpstreamerResolver(resolver),
+		)
+		So(err, ShouldBeNil)
+
+		So(u.Resolve(context.Background()), ShouldBeNil)
+
+		catsAddresses := []string{cats1.Listener.Addr().String(), cats2.Listener.Addr().String()}
+
+		Convey("Then the identities should be routed to the endpoints serving them", func() {
+			So(upstream(u, "/cats"), ShouldBeIn, catsAddresses)
+			So(upstream(u, "/events"), ShouldBeIn, catsAddresses)
+			So(upstream(u, "/_dogs/dogs"), ShouldEqual, dogs.Listener.Addr().String())
+			So(upstream(u, "/_dogs/cats"), ShouldEqual, dogs.Listener.Addr().String())
+			So(upstream(u, "/dogs"), ShouldBeEmpty)
+		})
+
+		Convey("Then the private identities should not be routed", func() {
+			So(upstream(u, "/litters"), ShouldBeEmpty)
+		})
+
+		Convey("When I resolve again", func() {
+
+			So(u.Resolve(context.Background()), ShouldBeNil)
+
+			Convey("Then the routes should not be retrieved again", func() {
+				So(*cats1Calls, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I resolve again after the routes expired", func() {
+
+			u.config.routesTTL = time.Nanosecond
+			So(u.Resolve(context.Background()), ShouldBeNil)
+
+			Convey("Then the routes should be retrieved again", func() {
+				So(*cats1Calls, ShouldEqual, 2)
+				So(upstream(u, "/cats"), ShouldBeIn, catsAddresses)
+			})
+		})
+
+		Convey("When an endpoint disappears", func() {
+
+			resolver.setSRVs("cats.local", srvOf(cats2, 1, 1))
+			So(u.Resolve(context.Background()), ShouldBeNil)
+
+			Convey("Then it should not be used anymore", func() {
+				for i := 0; i < 20; i++ {
+					So(upstream(u, "/cats"), ShouldEqual, cats2.Listener.Addr().String())
+				}
+			})
+		})
+
+		Convey("When the resolution fails", func() {
+
+			resolver.lock.Lock()
+			resolver.err = fmt.Errorf("boom")
+			resolver.lock.Unlock()
+
+			err := u.Resolve(context.Background())
+
+			Convey("Then the previous endpoints should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "unable to resolve 'cats.local': boom\nunable to resolve 'dogs.local': boom")
+				So(upstream(u, "/cats"), ShouldBeIn, catsAddresses)
+			})
+		})
+
+		Convey("When an endpoint does not serve its routes", func() {
+
+			broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer broken.Close()
+
+			resolver.setSRVs("cats.local", srvOf(broken, 1, 1))
+			err := u.Resolve(context.Background())
+
+			Convey("Then it should be ignored", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, fmt.Sprintf("unable to retrieve routes of '%s': unexpected status code 404", broken.Listener.Addr().String()))
+				So(upstream(u, "/cats"), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given I have a service whose routes change", t, func() {
+
+		var lock sync.Mutex
+		routes := `{"1":[{"identity":"cats","url":"/cats","verbs":["GET"]}]}`
+		code := http.StatusOK
+
+		cats := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(routes))
+		}))
+		defer cats.Close()
+
+		u, err := NewUpstreamer(
+			[]Target{{Name: "cats", Host: "cats.local"}},
+			OptionUpstreamerResolver(&fakeResolver{srvs: map[string][]*net.SRV{"cats.local": {srvOf(cats, 1, 1)}}}),
+			OptionUpstreamerRoutesTTL(time.Nanosecond),
+		)
+		So(err, ShouldBeNil)
+
+		So(u.Resolve(context.Background()), ShouldBeNil)
+		So(upstream(u, "/dogs"), ShouldBeEmpty)
+
+		Convey("When a new identity is served and the routes expired", func() {
+
+			lock.Lock()
+			routes = `{"1":[{"identity":"cats","url":"/cats","verbs":["GET"]},{"identity":"dogs","url":"/dogs","verbs":["GET"]}]}`
+			lock.Unlock()
+
+			So(u.Resolve(context.Background()), ShouldBeNil)
+
+			Convey("Then it should be routed", func() {
+				So(upstream(u, "/dogs"), ShouldEqual, cats.Listener.Addr().String())
+			})
+		})
+
+		Convey("When the expired routes cannot be retrieved again", func() {
+
+			lock.Lock()
+			code = http.StatusServiceUnavailable
+			lock.Unlock()
+
+			err := u.Resolve(context.Background())
+
+			Convey("Then the previous routes should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(upstream(u, "/cats"), ShouldEqual, cats.Listener.Addr().String())
+			})
+		})
+	})
+
+	Convey("Given I have a service discovered with A records exposing private APIs", t, func() {
+
+		cats, _ := makeService(map[int][]bahamut.RouteInfo{
+			1: {{Identity: "litters", URL: "/litters", Verbs: []string{http.MethodGet}, Private: true}},
+		})
+		defer cats.Close()
+
+		_, port, _ := net.SplitHostPort(cats.Listener.Addr().String())
+		p, _ := strconv.Atoi(port)
+
+		u, err := NewUpstreamer(
+			[]Target{{Name: "cats", Host: "cats.local", Port: p}},
+			OptionUpstreamerResolver(&fakeResolver{hosts: map[string][]string{"cats.local": {"127.0.0.1", "127.0.0.1"}}}),
+			OptionUpstreamerExposePrivateAPIs(true),
+		)
+		So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ready, wg := u.Start(ctx)
+
+		select {
+		case <-ready:
+		case <-time.After(2 * time.Second):
+			panic("not ready in time")
+		}
+
+		cancel()
+		wg.Wait()
+
+		Convey("Then the private identities should be routed", func() {
+			So(upstream(u, "/litters"), ShouldEqual, cats.Listener.Addr().String())
+			So(u.endpoints[0], ShouldHaveLength, 1)
+		})
+	})
+}
@@ -0,0 +1,165 @@
+package push
+
+import (
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.aporeto.io/bahamut/gateway"
+)
+
+// Inspect implements the gateway.InspectableUpstreamer interface.
+func (c *Upstreamer) Inspect() gateway.UpstreamerState {
+
+	now := time.Now()
+
+	c.lock.RLock()
+
+	services := map[string]*gateway.ServiceState{}
+	seen := map[string]struct{}{}
+
+	for key, endpoints := range c.apis {
+
+		for _, ep := range endpoints {
+
+			srv, ok := services[ep.service]
+			if !ok {
+				srv = &gateway.ServiceState{Name: ep.service}
+				services[ep.service] = srv
+			}
+
+			srv.Routes = append(srv.Routes, key)
+
+			if _, ok := seen[ep.address]; ok {
+				continue
+			}
+			seen[ep.address] = struct{}{}
+
+			srv.Endpoints = append(srv.Endpoints, c.inspectEndpoint(ep, now))
+		}
+	}
+
+	c.lock.RUnlock()
+
+	state := gateway.UpstreamerState{
+		Services: make([]gateway.ServiceState, 0, len(services)),
+		Peers:    []gateway.PeerState{},
+	}
+
+	for _, srv := range services {
+		sort.Strings(srv.Routes)
+		srv.Routes = slices.Compact(srv.Routes)
+		sort.Slice(srv.Endpoints, func(i, j int) bool { return srv.Endpoints[i].Address < srv.Endpoints[j].Address })
+		state.Services = append(state.Services, *srv)
+	}
+	sort.Slice(state.Services, func(i, j int) bool { return state.Services[i].Name < state.Services[j].Name })
+
+	c.peers.Range(func(id, date any) bool {
+		state.Peers = append(state.Peers, gateway.PeerState{ID: id.(string), LastSeen: date.(time.Time)})
+		return true
+	})
+	sort.Slice(state.Peers, func(i, j int) bool { return state.Peers[i].ID < state.Peers[j].ID })
+
+	return state
+}
+
+func (c *Upstreamer) inspectEndpoint(ep *endpointInfo, now time.Time) gateway.EndpointState {
+
+	ep.RLock()
+	defer ep.RUnlock()
+
+	out := gateway.EndpointState{
+		Address:  ep.address,
+		Version:  ep.version,
+		Load:     ep.lastLoad,
+		LastSeen: ep.lastSeen,
+	}
+
+	if v, ok := c.latencies.Load(ep.address); ok {
+		if avg, err := v.(movingAverage).average(); err == nil {
+			out.Latency = time.Duration(avg) * time.Microsecond
+		}
+	}
+
+	if h, ok := c.health.Load(ep.address); ok {
+		out.Ejected = h.(*endpointHealth).isEjected(now)
+		out.Drained = h.(*endpointHealth).drained.Load()
+	}
+
+	for identity, l := range ep.limiters {
+
+		if l.limiter == nil {
+			continue
+		}
+
+		if out.Limiters == nil {
+			out.Limiters = map[string]gateway.LimiterState{}
+		}
+
+		out.Limiters[identity] = gateway.LimiterState{
+			Limit:  l.limiter.Limit(),
+			Burst:  l.limiter.Burst(),
+			Tokens: l.limiter.TokensAt(now),
+		}
+	}
+
+	return out
+}
+
+// DrainEndpoint implements the gateway.ControllableUpstreamer interface.
+// A drained endpoint is only used if all the other endpoints serving
+// the requested identity are drained or ejected too.
+func (c *Upstreamer) DrainEndpoint(address string, drained bool) error {
+
+	if !c.hasEndpoint(address) {
+		return gateway.ErrUpstreamerUnknownEndpoint
+	}
+
+	c.endpointHealth(address).drained.Store(drained)
+	c.controlled.Store(true)
+
+	return nil
+}
+
+// EjectEndpoint implements the gateway.ControllableUpstreamer interface.
+// Like for the endpoints failing their health checks, an ejected endpoint
+// is only used if all the other endpoints serving the requested identity
+// are ejected or drained too.
+func (c *Upstreamer) EjectEndpoint(address string, duration time.Duration) error {
+
+	if !c.hasEndpoint(address) {
+		return gateway.ErrUpstreamerUnknownEndpoint
+	}
+
+	h := c.endpointHealth(address)
+
+	h.lock.Lock()
+	h.failures = [2]int{}
+	if duration > 0 {
+		atomic.StoreInt64(&h.ejectedUntil, time.Now().Add(duration).UnixNano())
+	} else {
+		atomic.StoreInt64(&h.ejectedUntil, 0)
+	}
+	h.lock.Unlock()
+
+	c.controlled.Store(true)
+
+	return nil
+}
+
+func (c *Upstreamer) hasEndpoint(address string) bool {
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, endpoints := range c.apis {
+		for _, ep := range endpoints {
+			if ep.address == address {
+				return true
+			}
+		}
+	}
+
+	return false
+}
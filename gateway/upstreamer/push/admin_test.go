@@ -0,0 +1,166 @@
+package push
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut/gateway"
+	"golang.org/x/time/rate"
+)
+
+func TestUpstreamerInspect(t *testing.T) {
+
+	var _ gateway.InspectableUpstreamer = &Upstreamer{}
+	var _ gateway.ControllableUpstreamer = &Upstreamer{}
+
+	Convey("Given I have an upstreamer with services and peers", t, func() {
+
+		now := time.Now()
+
+		u := NewUpstreamer(nil, "topic", "topic2")
+		u.config.latencySampleSize = 1
+
+		cats := &endpointInfo{
+			address:  "1.1.1.1:1",
+			service:  "cats",
+			version:  "v2",
+			lastLoad: 0.5,
+			lastSeen: now,
+			limiters: IdentityToAPILimitersRegistry{
+				"kittens": &APILimiter{limiter: rate.NewLimiter(10, 20)},
+			},
+		}
+		dogs := &endpointInfo{address: "2.2.2.2:1", service: "zoo/dogs", lastSeen: now}
+
+		u.apis = map[string][]*endpointInfo{
+			"/cats":     {cats},
+			"/kittens":  {cats},
+			"zoo/dogs":  {dogs},
+			"zoo/bones": {dogs},
+		}
+
+		u.CollectLatency("1.1.1.1:1", 3*time.Millisecond)
+		u.peers.Store("peer1", now)
+
+		So(u.DrainEndpoint("2.2.2.2:1", true), ShouldBeNil)
+
+		Convey("When I inspect it", func() {
+
+			state := u.Inspect()
+
+			Convey("Then the state should be correct", func() {
+
+				So(state.Peers, ShouldResemble, []gateway.PeerState{{ID: "peer1", LastSeen: now}})
+				So(state.Services, ShouldHaveLength, 2)
+
+				So(state.Services[0].Name, ShouldEqual, "cats")
+				So(state.Services[0].Routes, ShouldResemble, []string{"/cats", "/kittens"})
+				So(state.Services[0].Endpoints, ShouldHaveLength, 1)
+
+				ep := state.Services[0].Endpoints[0]
+				So(ep.Address, ShouldEqual, "1.1.1.1:1")
+				So(ep.Version, ShouldEqual, "v2")
+				So(ep.Load, ShouldEqual, 0.5)
+				So(ep.LastSeen, ShouldEqual, now)
+				So(ep.Latency, ShouldEqual, 3*time.Millisecond)
+				So(ep.Drained, ShouldBeFalse)
+				So(ep.Limiters["kittens"].Limit, ShouldEqual, rate.Limit(10))
+				So(ep.Limiters["kittens"].Burst, ShouldEqual, 20)
+
+				So(state.Services[1].Name, ShouldEqual, "zoo/dogs")
+				So(state.Services[1].Routes, ShouldResemble, []string{"zoo/bones", "zoo/dogs"})
+				So(state.Services[1].Endpoints[0].Drained, ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestUpstreamerControl(t *testing.T) {
+
+	Convey("Given I have an upstreamer with 2 endpoints", t, func() {
+
+		u := NewUpstreamer(nil, "topic", "topic2")
+		u.apis = map[string][]*endpointInfo{
+			"/cats": {
+				{address: "1.1.1.1:1", lastLoad: 1},
+				{address: "2.2.2.2:1", lastLoad: 1},
+			},
+		}
+
+		upstream := func() string {
+			up, err := u.Upstream(&http.Request{URL: &url.URL{Path: "/cats"}})
+			So(err, ShouldBeNil)
+			return up
+		}
+
+		Convey("When I act on an unknown endpoint", func() {
+
+			Convey("Then it should fail", func() {
+				So(u.DrainEndpoint("3.3.3.3:1", true), ShouldEqual, gateway.ErrUpstreamerUnknownEndpoint)
+				So(u.EjectEndpoint("3.3.3.3:1", time.Minute), ShouldEqual, gateway.ErrUpstreamerUnknownEndpoint)
+			})
+		})
+
+		Convey("When I drain an endpoint", func() {
+
+			So(u.DrainEndpoint("1.1.1.1:1", true), ShouldBeNil)
+
+			Convey("Then it should not be used", func() {
+				for i := 0; i < 20; i++ {
+					So(upstream(), ShouldEqual, "2.2.2.2:1")
+				}
+			})
+
+			Convey("When I undrain it", func() {
+
+				So(u.DrainEndpoint("1.1.1.1:1", false), ShouldBeNil)
+
+				Convey("Then it should be used again", func() {
+					seen := map[string]struct{}{}
+					for i := 0; i < 50; i++ {
+						seen[upstream()] = struct{}{}
+					}
+					So(seen, ShouldHaveLength, 2)
+				})
+			})
+		})
+
+		Convey("When I eject an endpoint", func() {
+
+			So(u.EjectEndpoint("2.2.2.2:1", time.Minute), ShouldBeNil)
+
+			Convey("Then it should not be used", func() {
+				for i := 0; i < 20; i++ {
+					So(upstream(), ShouldEqual, "1.1.1.1:1")
+				}
+			})
+
+			Convey("When I reinstate it", func() {
+
+				So(u.EjectEndpoint("2.2.2.2:1", 0), ShouldBeNil)
+
+				Convey("Then it should be used again", func() {
+					seen := map[string]struct{}{}
+					for i := 0; i < 50; i++ {
+						seen[upstream()] = struct{}{}
+					}
+					So(seen, ShouldHaveLength, 2)
+				})
+			})
+		})
+
+		Convey("When I drain all the endpoints", func() {
+
+			So(u.DrainEndpoint("1.1.1.1:1", true), ShouldBeNil)
+			So(u.DrainEndpoint("2.2.2.2:1", true), ShouldBeNil)
+
+			Convey("Then they should still be used", func() {
+				So(upstream(), ShouldNotBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,89 @@
+package push
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/cespare/xxhash"
+	"go.aporeto.io/bahamut/gateway"
+	"go.aporeto.io/bahamut/internal/routing"
+)
+
+type headerKeyExtractor struct {
+	header string
+}
+
+// NewHeaderKeyExtractor returns a gateway.SourceExtractor returning the
+// value of the given header. For instance, NewHeaderKeyExtractor("X-Namespace")
+// can be used with OptionUpstreamerConsistentHashing to hash on the namespace.
+func NewHeaderKeyExtractor(header string) gateway.SourceExtractor {
+	return headerKeyExtractor{header: header}
+}
+
+func (e headerKeyExtractor) ExtractSource(req *http.Request) (string, error) {
+	return req.Header.Get(e.header), nil
+}
+
+type objectIDKeyExtractor struct{}
+
+// NewObjectIDKeyExtractor returns a gateway.SourceExtractor returning the
+// ID of the object targeted by the request, or of its parent for the
+// requests targeting children, like /lists/xxx/tasks. The key is empty
+// for the requests that do not target any object.
+func NewObjectIDKeyExtractor() gateway.SourceExtractor {
+	return objectIDKeyExtractor{}
+}
+
+func (e objectIDKeyExtractor) ExtractSource(req *http.Request) (string, error) {
+
+	parts := strings.Split(
+		strings.Trim(
+			routing.TrimVersion(req.URL.Path),
+			"/",
+		),
+		"/",
+	)
+
+	if len(parts) > 1 && parts[0] != "" && parts[0][0] == '_' {
+		parts = parts[1:]
+	}
+
+	if len(parts) < 2 {
+		return "", nil
+	}
+
+	return parts[1], nil
+}
+
+// hashedUpstream returns the endpoint with the highest rendezvous
+// score for the key extracted from the request if the service of the
+// endpoints uses consistent hashing. It returns false if the key is
+// empty, or if the chosen endpoint is rate limited.
+func (c *Upstreamer) hashedUpstream(req *http.Request, identity string, endpoints []*endpointInfo) (string, bool) {
+
+	extractor, ok := c.config.hashKeyExtractors[endpoints[0].service]
+	if !ok {
+		return "", false
+	}
+
+	key, err := extractor.ExtractSource(req)
+	if err != nil || key == "" {
+		return "", false
+	}
+
+	var chosen *endpointInfo
+	var highest uint64
+
+	for _, ep := range endpoints {
+		if score := xxhash.Sum64String(key + "/" + ep.address); chosen == nil || score > highest {
+			chosen = ep
+			highest = score
+		}
+	}
+
+	if rl := c.endpointLimiter(chosen, identity); rl != nil && !rl.Allow() {
+		return "", false
+	}
+
+	return chosen.address, true
+}
@@ -0,0 +1,183 @@
+package push
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut/gateway"
+	"golang.org/x/time/rate"
+)
+
+func TestKeyExtractors(t *testing.T) {
+
+	Convey("Given I have a header key extractor", t, func() {
+
+		e := NewHeaderKeyExtractor("X-Namespace")
+
+		req, _ := http.NewRequest(http.MethodGet, "/cats", nil)
+		req.Header.Set("X-Namespace", "/acme")
+
+		Convey("Then the key should be correct", func() {
+			key, err := e.ExtractSource(req)
+			So(err, ShouldBeNil)
+			So(key, ShouldEqual, "/acme")
+		})
+	})
+
+	Convey("Given I have an object ID key extractor", t, func() {
+
+		e := NewObjectIDKeyExtractor()
+
+		for path, expected := range map[string]string{
+			"/cats":                "",
+			"/cats/xxx":            "xxx",
+			"/v/1/cats/xxx":        "xxx",
+			"/cats/xxx/kittens":    "xxx",
+			"/_prefix/cats":        "",
+			"/_prefix/cats/xxx":    "xxx",
+			"/_prefix/v/1/cats/yy": "yy",
+			"/":                    "",
+		} {
+			key, err := e.ExtractSource(&http.Request{URL: &url.URL{Path: path}})
+			So(err, ShouldBeNil)
+			So(key, ShouldEqual, expected)
+		}
+	})
+}
+
+func TestUpstreamerConsistentHashing(t *testing.T) {
+
+	makeUpstreamer := func(options ...UpstreamerOption) *Upstreamer {
+
+		u := NewUpstreamer(nil, "topic", "topic2", options...)
+		u.apis = map[string][]*endpointInfo{
+			"/cats": {
+				{address: "1.1.1.1:1", service: "cats", lastLoad: 1},
+				{address: "2.2.2.2:1", service: "cats", lastLoad: 1},
+				{address: "3.3.3.3:1", service: "cats", lastLoad: 1},
+				{address: "4.4.4.4:1", service: "cats", lastLoad: 1},
+			},
+		}
+
+		return u
+	}
+
+	upstream := func(u *Upstreamer, ns string) string {
+
+		req := &http.Request{URL: &url.URL{Path: "/cats"}, Header: http.Header{}}
+		req.Header.Set("X-Namespace", ns)
+
+		up, err := u.Upstream(req)
+		So(err, ShouldBeNil)
+
+		return up
+	}
+
+	Convey("Given I have an upstreamer using consistent hashing for a service", t, func() {
+
+		u := makeUpstreamer(OptionUpstreamerConsistentHashing("cats", NewHeaderKeyExtractor("X-Namespace")))
+
+		Convey("Then the requests with the same key should go to the same endpoint", func() {
+
+			first := upstream(u, "/acme")
+			for i := 0; i < 50; i++ {
+				So(upstream(u, "/acme"), ShouldEqual, first)
+			}
+		})
+
+		Convey("Then the requests with different keys should be spread", func() {
+
+			seen := map[string]struct{}{}
+			for i := 0; i < 100; i++ {
+				seen[upstream(u, "/ns"+string(rune('a'+i%26))+string(rune('a'+i/26)))] = struct{}{}
+			}
+
+			So(len(seen), ShouldEqual, 4)
+		})
+
+		Convey("Then only the keys of a removed endpoint should move", func() {
+
+			before := map[string]string{}
+			for i := 0; i < 100; i++ {
+				ns := "/ns" + string(rune('a'+i%26)) + string(rune('a'+i/26))
+				before[ns] = upstream(u, ns)
+			}
+
+			u.apis["/cats"] = u.apis["/cats"][1:]
+
+			for ns, up := range before {
+				if up != "1.1.1.1:1" {
+					So(upstream(u, ns), ShouldEqual, up)
+				} else {
+					So(upstream(u, ns), ShouldNotEqual, up)
+				}
+			}
+		})
+
+		Convey("When the chosen endpoint is rate limited", func() {
+
+			chosen := upstream(u, "/acme")
+			for _, ep := range u.apis["/cats"] {
+				if ep.address == chosen {
+					ep.limiters = IdentityToAPILimitersRegistry{
+						"cats": &APILimiter{limiter: rate.NewLimiter(rate.Limit(0), 0)},
+					}
+				}
+			}
+
+			Convey("Then another endpoint should be used", func() {
+				for i := 0; i < 20; i++ {
+					So(upstream(u, "/acme"), ShouldNotEqual, chosen)
+				}
+			})
+		})
+
+		Convey("When the request has no key", func() {
+
+			seen := map[string]struct{}{}
+			for i := 0; i < 100; i++ {
+				seen[upstream(u, "")] = struct{}{}
+			}
+
+			Convey("Then the default strategy should be used", func() {
+				So(len(seen), ShouldBeGreaterThan, 1)
+			})
+		})
+	})
+
+	Convey("Given I have an upstreamer using consistent hashing for another service", t, func() {
+
+		u := makeUpstreamer(OptionUpstreamerConsistentHashing("dogs", NewHeaderKeyExtractor("X-Namespace")))
+
+		Convey("Then the default strategy should be used", func() {
+
+			seen := map[string]struct{}{}
+			for i := 0; i < 100; i++ {
+				seen[upstream(u, "/acme")] = struct{}{}
+			}
+
+			So(len(seen), ShouldBeGreaterThan, 1)
+		})
+	})
+
+	Convey("Given I have an upstreamer using consistent hashing on the token", t, func() {
+
+		u := makeUpstreamer(OptionUpstreamerConsistentHashing("cats", gateway.NewDefaultSourceExtractor("")))
+
+		req := func() *http.Request {
+			return &http.Request{URL: &url.URL{Path: "/cats"}, Header: http.Header{"Authorization": {"Bearer token"}}}
+		}
+
+		Convey("Then the requests with the same token should go to the same endpoint", func() {
+
+			first, _ := u.Upstream(req())
+			for i := 0; i < 20; i++ {
+				up, _ := u.Upstream(req())
+				So(up, ShouldEqual, first)
+			}
+		})
+	})
+}
@@ -0,0 +1,240 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type healthSource int
+
+const (
+	healthSourceProbe healthSource = iota
+	healthSourceRequest
+)
+
+// endpointHealth holds the health state of an endpoint.
+type endpointHealth struct {
+	ejectedUntil int64 // unix nano, accessed atomically.
+	failures     [2]int
+	lock         sync.Mutex
+	drained      atomic.Bool
+}
+
+func (h *endpointHealth) isEjected(now time.Time) bool {
+
+	return now.UnixNano() < atomic.LoadInt64(&h.ejectedUntil)
+}
+
+// record records the success or failure of a probe or a request
+// and returns true if the endpoint just got ejected because it
+// reached the given threshold of consecutive failures.
+func (h *endpointHealth) record(source healthSource, success bool, threshold int, now time.Time, penalty time.Duration) bool {
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if success {
+		h.failures[source] = 0
+		return false
+	}
+
+	// While ejected, the endpoint serves its penalty.
+	if h.isEjected(now) {
+		return false
+	}
+
+	h.failures[source]++
+	if h.failures[source] < threshold {
+		return false
+	}
+
+	h.failures = [2]int{}
+	atomic.StoreInt64(&h.ejectedUntil, now.Add(penalty).UnixNano())
+
+	return true
+}
+
+// healthEnabled returns true if active health
+// checking or passive outlier detection is enabled.
+func (c *Upstreamer) healthEnabled() bool {
+	return c.config.healthCheckPath != "" || c.config.outlierThreshold > 0
+}
+
+// endpointHealth returns the health state of the given
+// endpoint, creating it if needed.
+func (c *Upstreamer) endpointHealth(address string) *endpointHealth {
+
+	if h, ok := c.health.Load(address); ok {
+		return h.(*endpointHealth)
+	}
+
+	h, _ := c.health.LoadOrStore(address, &endpointHealth{})
+
+	return h.(*endpointHealth)
+}
+
+// healthyEndpoints returns the endpoints that are not ejected nor drained.
+// If all of them are ejected, they are all returned, as trying
+// one of them is better than failing the request right away.
+func (c *Upstreamer) healthyEndpoints(endpoints []*endpointInfo) []*endpointInfo {
+
+	now := time.Now()
+
+	var out []*endpointInfo
+	for i, ep := range endpoints {
+
+		h, ok := c.health.Load(ep.address)
+		if !ok || !(h.(*endpointHealth).isEjected(now) || h.(*endpointHealth).drained.Load()) {
+			if out != nil {
+				out = append(out, ep)
+			}
+			continue
+		}
+
+		if out == nil {
+			out = make([]*endpointInfo, i, len(endpoints))
+			copy(out, endpoints[:i])
+		}
+	}
+
+	if len(out) == 0 {
+		return endpoints
+	}
+
+	return out
+}
+
+// CollectOutcome implements the gateway.OutcomeBasedUpstreamer interface
+// to detect the endpoints returning consecutive 5xx or connection errors.
+// It does nothing unless OptionUpstreamerOutlierDetection is set.
+func (c *Upstreamer) CollectOutcome(address string, statusCode int, err error) {
+
+	if c.config.outlierThreshold <= 0 {
+		return
+	}
+
+	// We only keep track of the known endpoints.
+	h, ok := c.health.Load(address)
+	if !ok {
+		return
+	}
+
+	if h.(*endpointHealth).record(
+		healthSourceRequest,
+		err == nil && statusCode < 500,
+		c.config.outlierThreshold,
+		time.Now(),
+		c.config.ejectionPenalty,
+	) {
+		zap.L().Warn("Ejected outlier endpoint",
+			zap.String("backend", address),
+			zap.Int("code", statusCode),
+			zap.Duration("penalty", c.config.ejectionPenalty),
+			zap.Error(err),
+		)
+	}
+}
+
+func (c *Upstreamer) checkHealth(ctx context.Context) {
+
+	scheme := "http"
+	if c.config.healthCheckTLSConfig != nil {
+		scheme = "https"
+	}
+
+	transport := &http.Transport{TLSClientConfig: c.config.healthCheckTLSConfig}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   c.config.healthCheckTimeout,
+	}
+
+	ticker := time.NewTicker(c.config.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+
+		case <-ticker.C:
+			c.probeEndpoints(ctx, client, scheme)
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Upstreamer) probeEndpoints(ctx context.Context, client *http.Client, scheme string) {
+
+	addresses := map[string]struct{}{}
+
+	c.lock.RLock()
+	for _, endpoints := range c.apis {
+		for _, ep := range endpoints {
+			addresses[ep.address] = struct{}{}
+		}
+	}
+	c.lock.RUnlock()
+
+	var wg sync.WaitGroup
+
+	for address := range addresses {
+
+		wg.Add(1)
+		go func(address string) {
+
+			defer wg.Done()
+
+			err := probe(ctx, client, scheme+"://"+address+c.config.healthCheckPath)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if c.endpointHealth(address).record(
+				healthSourceProbe,
+				err == nil,
+				c.config.healthCheckThreshold,
+				time.Now(),
+				c.config.ejectionPenalty,
+			) {
+				zap.L().Warn("Ejected endpoint failing health checks",
+					zap.String("backend", address),
+					zap.Duration("penalty", c.config.ejectionPenalty),
+					zap.Error(err),
+				)
+			}
+		}(address)
+	}
+
+	wg.Wait()
+}
+
+func probe(ctx context.Context, client *http.Client, url string) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut"
+)
+
+func TestEndpointHealth(t *testing.T) {
+
+	Convey("Given I have an endpoint health", t, func() {
+
+		h := &endpointHealth{}
+		now := time.Now()
+
+		Convey("When I record failures under the threshold", func() {
+
+			So(h.record(healthSourceRequest, false, 2, now, time.Minute), ShouldBeFalse)
+			So(h.record(healthSourceRequest, true, 2, now, time.Minute), ShouldBeFalse)
+			So(h.record(healthSourceRequest, false, 2, now, time.Minute), ShouldBeFalse)
+			So(h.record(healthSourceProbe, false, 2, now, time.Minute), ShouldBeFalse)
+
+			Convey("Then the endpoint should not be ejected", func() {
+				So(h.isEjected(now), ShouldBeFalse)
+			})
+		})
+
+		Convey("When I record consecutive failures reaching the threshold", func() {
+
+			So(h.record(healthSourceRequest, false, 2, now, time.Minute), ShouldBeFalse)
+			So(h.record(healthSourceRequest, false, 2, now, time.Minute), ShouldBeTrue)
+
+			Convey("Then the endpoint should be ejected for the penalty", func() {
+				So(h.isEjected(now), ShouldBeTrue)
+				So(h.isEjected(now.Add(time.Minute)), ShouldBeFalse)
+			})
+
+			Convey("Then failures during the penalty should not eject it again", func() {
+				So(h.record(healthSourceRequest, false, 1, now, time.Hour), ShouldBeFalse)
+				So(h.isEjected(now.Add(time.Minute)), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestUpstreamerOutlierDetection(t *testing.T) {
+
+	Convey("Given I have an upstreamer with outlier detection and 3 endpoints", t, func() {
+
+		u := NewUpstreamer(nil, "topic", "topic2", OptionUpstreamerOutlierDetection(2), OptionUpstreamerEjectionPenalty(time.Hour))
+		u.apis = map[string][]*endpointInfo{
+			"/cats": {
+				{address: "1.1.1.1:1", lastLoad: 1},
+				{address: "2.2.2.2:1", lastLoad: 1},
+				{address: "3.3.3.3:1", lastLoad: 1},
+			},
+		}
+		for _, ep := range u.apis["/cats"] {
+			u.endpointHealth(ep.address)
+		}
+
+		upstreams := func() map[string]int {
+			out := map[string]int{}
+			for i := 0; i < 100; i++ {
+				upstream, err := u.Upstream(&http.Request{URL: &url.URL{Path: "/cats"}})
+				So(err, ShouldBeNil)
+				out[upstream]++
+			}
+			return out
+		}
+
+		Convey("When an endpoint returns consecutive 5xx", func() {
+
+			u.CollectOutcome("1.1.1.1:1", http.StatusBadGateway, nil)
+			u.CollectOutcome("1.1.1.1:1", http.StatusInternalServerError, nil)
+
+			Convey("Then it should not be used anymore", func() {
+				ups := upstreams()
+				So(ups["1.1.1.1:1"], ShouldEqual, 0)
+				So(ups["2.2.2.2:1"], ShouldBeGreaterThan, 0)
+				So(ups["3.3.3.3:1"], ShouldBeGreaterThan, 0)
+			})
+		})
+
+		Convey("When two endpoints have connection errors", func() {
+
+			for i := 0; i < 2; i++ {
+				u.CollectOutcome("1.1.1.1:1", 0, errors.New("connection refused"))
+				u.CollectOutcome("2.2.2.2:1", 0, errors.New("connection refused"))
+			}
+
+			Convey("Then only the last one should be used", func() {
+				So(upstreams(), ShouldResemble, map[string]int{"3.3.3.3:1": 100})
+			})
+		})
+
+		Convey("When all endpoints are ejected", func() {
+
+			for _, ep := range u.apis["/cats"] {
+				u.CollectOutcome(ep.address, http.StatusServiceUnavailable, nil)
+				u.CollectOutcome(ep.address, http.StatusServiceUnavailable, nil)
+			}
+
+			Convey("Then they should all be used", func() {
+				So(len(upstreams()), ShouldEqual, 3)
+			})
+		})
+
+		Convey("When an endpoint alternates errors and successes", func() {
+
+			u.CollectOutcome("1.1.1.1:1", http.StatusBadGateway, nil)
+			u.CollectOutcome("1.1.1.1:1", http.StatusNotFound, nil)
+			u.CollectOutcome("1.1.1.1:1", http.StatusBadGateway, nil)
+
+			Convey("Then it should still be used", func() {
+				So(upstreams()["1.1.1.1:1"], ShouldBeGreaterThan, 0)
+			})
+		})
+
+		Convey("When I collect the outcome of an unknown endpoint", func() {
+
+			u.CollectOutcome("4.4.4.4:1", http.StatusBadGateway, nil)
+
+			Convey("Then it should be ignored", func() {
+				_, ok := u.health.Load("4.4.4.4:1")
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestUpstreamerHealthCheck(t *testing.T) {
+
+	Convey("Given I have an upstreamer with health checks and a healthy and a wedged endpoint", t, func() {
+
+		healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/health" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer healthy.Close()
+
+		wedged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer wedged.Close()
+
+		healthyAddress := strings.TrimPrefix(healthy.URL, "http://")
+		wedgedAddress := strings.TrimPrefix(wedged.URL, "http://")
+
+		pubsub := bahamut.NewLocalPubSubClient()
+		So(pubsub.Connect(context.Background()), ShouldBeNil)
+
+		u := NewUpstreamer(
+			pubsub,
+			"topic",
+			"topic2",
+			OptionUpstreamerHealthCheck("/health", 20*time.Millisecond, time.Second, 2),
+			OptionUpstreamerEjectionPenalty(time.Hour),
+		)
+		u.apis = map[string][]*endpointInfo{
+			"/cats": {
+				{address: healthyAddress},
+				{address: wedgedAddress},
+			},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, wg := u.Start(ctx)
+
+		isEjected := func(address string) bool {
+			h, ok := u.health.Load(address)
+			return ok && h.(*endpointHealth).isEjected(time.Now())
+		}
+
+		for i := 0; i < 100 && !isEjected(wedgedAddress); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		cancel()
+		wg.Wait()
+
+		Convey("Then the wedged endpoint should be ejected", func() {
+			So(isEjected(wedgedAddress), ShouldBeTrue)
+			So(isEjected(healthyAddress), ShouldBeFalse)
+		})
+
+		Convey("Then only the healthy endpoint should be used", func() {
+			for i := 0; i < 20; i++ {
+				upstream, err := u.Upstream(&http.Request{URL: &url.URL{Path: "/cats"}})
+				So(err, ShouldBeNil)
+				So(upstream, ShouldEqual, healthyAddress)
+			}
+		})
+	})
+}
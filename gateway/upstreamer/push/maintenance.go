@@ -0,0 +1,139 @@
+package push
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.aporeto.io/bahamut/gateway"
+)
+
+// maintenances holds the maintenance windows set on the Upstreamer.
+type maintenances struct {
+	windows atomic.Value // map[string]*maintenanceInfo
+	lock    sync.Mutex
+}
+
+func (m *maintenances) load() map[string]*maintenanceInfo {
+
+	windows, _ := m.windows.Load().(map[string]*maintenanceInfo)
+
+	return windows
+}
+
+// SetMaintenance puts the given target in maintenance. The target is either
+// the name of a service, prefixed by its prefix and a slash if it has one, or
+// a route in the form <prefix>/<identity>, like "/cats" or "zoo/dogs".
+//
+// While a target is in maintenance, Upstream returns a *gateway.MaintenanceError
+// with the given message and retryAfter for the requests targeting it, which
+// makes the gateway return a 423 Locked error, unless the request is allowed to
+// bypass the maintenance. See gateway.OptionMaintenanceBypass.
+//
+// The services can also announce they are in maintenance using
+// (*Notifier).SetMaintenance.
+func (c *Upstreamer) SetMaintenance(target string, message string, retryAfter time.Duration) {
+
+	c.maintenances.lock.Lock()
+	defer c.maintenances.lock.Unlock()
+
+	current := c.maintenances.load()
+
+	windows := make(map[string]*maintenanceInfo, len(current)+1)
+	for k, v := range current {
+		windows[k] = v
+	}
+	windows[target] = &maintenanceInfo{Message: message, RetryAfter: retryAfter}
+
+	c.maintenances.windows.Store(windows)
+}
+
+// ClearMaintenance ends the maintenance of the given target.
+// See SetMaintenance.
+func (c *Upstreamer) ClearMaintenance(target string) {
+
+	c.maintenances.lock.Lock()
+	defer c.maintenances.lock.Unlock()
+
+	current := c.maintenances.load()
+
+	windows := make(map[string]*maintenanceInfo, len(current))
+	for k, v := range current {
+		if k != target {
+			windows[k] = v
+		}
+	}
+
+	c.maintenances.windows.Store(windows)
+}
+
+// maintenance returns the maintenance set on the Upstreamer
+// for the given route key or its service, if any.
+func (c *Upstreamer) maintenance(key string, endpoints []*endpointInfo) *maintenanceInfo {
+
+	windows := c.maintenances.load()
+	if len(windows) == 0 {
+		return nil
+	}
+
+	if m, ok := windows[key]; ok {
+		return m
+	}
+
+	if len(endpoints) > 0 {
+		return windows[endpoints[0].service]
+	}
+
+	return nil
+}
+
+// availableEndpoints returns the endpoints that did not announce
+// they are in maintenance. If they all did, they are all returned
+// with the maintenance announced by the first one.
+func availableEndpoints(endpoints []*endpointInfo) ([]*endpointInfo, *maintenanceInfo) {
+
+	var out []*endpointInfo
+	var maintenance *maintenanceInfo
+
+	for i, ep := range endpoints {
+
+		ep.RLock()
+		m := ep.maintenance
+		ep.RUnlock()
+
+		if m == nil {
+			if out != nil {
+				out = append(out, ep)
+			}
+			continue
+		}
+
+		if maintenance == nil {
+			maintenance = m
+		}
+
+		if out == nil {
+			out = make([]*endpointInfo, i, len(endpoints))
+			copy(out, endpoints[:i])
+		}
+	}
+
+	if maintenance == nil {
+		return endpoints, nil
+	}
+
+	if len(out) == 0 {
+		return endpoints, maintenance
+	}
+
+	return out, nil
+}
+
+func makeMaintenanceError(m *maintenanceInfo, upstream string) error {
+
+	return &gateway.MaintenanceError{
+		Message:    m.Message,
+		RetryAfter: m.RetryAfter,
+		Upstream:   upstream,
+	}
+}
@@ -0,0 +1,159 @@
+package push
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut/gateway"
+)
+
+func TestUpstreamerMaintenance(t *testing.T) {
+
+	upstream := func(u *Upstreamer, path string) (string, *gateway.MaintenanceError) {
+
+		up, err := u.Upstream(&http.Request{URL: &url.URL{Path: path}})
+		if err == nil {
+			return up, nil
+		}
+
+		var merr *gateway.MaintenanceError
+		So(errors.As(err, &merr), ShouldBeTrue)
+		So(up, ShouldBeEmpty)
+
+		return "", merr
+	}
+
+	Convey("Given I have an upstreamer with 2 services", t, func() {
+
+		cats1 := &endpointInfo{address: "1.1.1.1:1", service: "cats", lastLoad: 1}
+
+		u := NewUpstreamer(nil, "topic", "topic2")
+		u.apis = map[string][]*endpointInfo{
+			"/cats": {
+				cats1,
+				{address: "2.2.2.2:1", service: "cats", lastLoad: 1},
+			},
+			"/kittens": {
+				cats1,
+			},
+			"zoo/dogs": {
+				{address: "3.3.3.3:1", service: "zoo/dogs", lastLoad: 1},
+			},
+		}
+
+		Convey("When I put a service in maintenance", func() {
+
+			u.SetMaintenance("cats", "upgrading", time.Minute)
+
+			Convey("Then its routes should be in maintenance", func() {
+
+				for _, path := range []string{"/cats", "/kittens"} {
+					_, merr := upstream(u, path)
+					So(merr, ShouldNotBeNil)
+					So(merr.Message, ShouldEqual, "upgrading")
+					So(merr.RetryAfter, ShouldEqual, time.Minute)
+					So(merr.Upstream, ShouldNotBeEmpty)
+				}
+			})
+
+			Convey("Then the other services should not be in maintenance", func() {
+				up, merr := upstream(u, "/_zoo/dogs")
+				So(merr, ShouldBeNil)
+				So(up, ShouldEqual, "3.3.3.3:1")
+			})
+
+			Convey("When I clear the maintenance", func() {
+
+				u.ClearMaintenance("cats")
+
+				Convey("Then the service should not be in maintenance anymore", func() {
+					_, merr := upstream(u, "/cats")
+					So(merr, ShouldBeNil)
+				})
+			})
+		})
+
+		Convey("When I put a route in maintenance", func() {
+
+			u.SetMaintenance("zoo/dogs", "", 0)
+
+			Convey("Then only this route should be in maintenance", func() {
+
+				_, merr := upstream(u, "/_zoo/dogs")
+				So(merr, ShouldNotBeNil)
+				So(merr.Upstream, ShouldEqual, "3.3.3.3:1")
+
+				_, merr = upstream(u, "/cats")
+				So(merr, ShouldBeNil)
+			})
+		})
+
+		Convey("When I put a route without endpoint in maintenance", func() {
+
+			u.SetMaintenance("/birds", "", 0)
+
+			Convey("Then the route should be in maintenance", func() {
+				_, merr := upstream(u, "/birds")
+				So(merr, ShouldNotBeNil)
+				So(merr.Upstream, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When an endpoint announces it is in maintenance", func() {
+
+			cats1.maintenance = &maintenanceInfo{Message: "upgrading"}
+
+			Convey("Then it should not be used", func() {
+				for i := 0; i < 20; i++ {
+					up, merr := upstream(u, "/cats")
+					So(merr, ShouldBeNil)
+					So(up, ShouldEqual, "2.2.2.2:1")
+				}
+			})
+
+			Convey("Then the routes it serves alone should be in maintenance", func() {
+				_, merr := upstream(u, "/kittens")
+				So(merr, ShouldNotBeNil)
+				So(merr.Message, ShouldEqual, "upgrading")
+				So(merr.Upstream, ShouldEqual, "1.1.1.1:1")
+			})
+		})
+	})
+}
+
+func TestHandleServicePingMaintenance(t *testing.T) {
+
+	Convey("Given I have a registered endpoint", t, func() {
+
+		services := servicesConfig{}
+		handleAddServicePing(services, servicePing{Name: "cats", Endpoint: "1.1.1.1:1", Status: entityStatusHello})
+
+		Convey("When it announces it is in maintenance", func() {
+
+			handleAddServicePing(services, servicePing{
+				Name:        "cats",
+				Endpoint:    "1.1.1.1:1",
+				Status:      entityStatusHello,
+				Maintenance: &maintenanceInfo{Message: "upgrading"},
+			})
+
+			Convey("Then the endpoint should be in maintenance", func() {
+				So(services["cats"].endpoints["1.1.1.1:1"].maintenance, ShouldResemble, &maintenanceInfo{Message: "upgrading"})
+			})
+
+			Convey("When it announces the end of the maintenance", func() {
+
+				handleAddServicePing(services, servicePing{Name: "cats", Endpoint: "1.1.1.1:1", Status: entityStatusHello})
+
+				Convey("Then the endpoint should not be in maintenance anymore", func() {
+					So(services["cats"].endpoints["1.1.1.1:1"].maintenance, ShouldBeNil)
+				})
+			})
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/process"
@@ -16,11 +17,15 @@ type Notifier struct {
 	pubsub             bahamut.PubSubClient
 	limiters           IdentityToAPILimitersRegistry
 	privateOverrides   map[string]bool
+	maintenance        atomic.Pointer[maintenanceInfo]
+	poke               chan struct{}
 	serviceName        string
 	endpoint           string
 	serviceStatusTopic string
 	prefix             string
+	version            string
 	frequency          time.Duration
+	announceOpenAPI    bool
 }
 
 // NewNotifier returns a new Wutai notifier.
@@ -45,7 +50,37 @@ func NewNotifier(
 		limiters:           cfg.rateLimits,
 		frequency:          cfg.pingInterval,
 		prefix:             cfg.prefix,
+		version:            cfg.version,
 		privateOverrides:   cfg.privateOverrides,
+		announceOpenAPI:    cfg.announceOpenAPI,
+		poke:               make(chan struct{}, 1),
+	}
+}
+
+// SetMaintenance announces that the service is in maintenance. The
+// gateways will stop forwarding the requests to this endpoint, and
+// will return a 423 Locked error with the given message and Retry-After
+// if all the endpoints of the service are in maintenance.
+// If the start hook is running, the new state is announced immediately.
+func (w *Notifier) SetMaintenance(message string, retryAfter time.Duration) {
+
+	w.maintenance.Store(&maintenanceInfo{Message: message, RetryAfter: retryAfter})
+	w.notify()
+}
+
+// ClearMaintenance announces that the service is not in maintenance anymore.
+// If the start hook is running, the new state is announced immediately.
+func (w *Notifier) ClearMaintenance() {
+
+	w.maintenance.Store(nil)
+	w.notify()
+}
+
+func (w *Notifier) notify() {
+
+	select {
+	case w.poke <- struct{}{}:
+	default:
 	}
 }
 
@@ -73,12 +108,18 @@ func (w *Notifier) MakeStartHook(ctx context.Context) func(server bahamut.Server
 		sp := servicePing{
 			Name:         w.serviceName,
 			Prefix:       w.prefix,
+			Version:      w.version,
 			Status:       entityStatusHello,
 			Endpoint:     w.endpoint,
 			Routes:       routes,
 			Versions:     server.VersionsInfo(),
 			PushEndpoint: server.PushEndpoint(),
 			APILimiters:  w.limiters,
+			Maintenance:  w.maintenance.Load(),
+		}
+
+		if p, ok := server.(bahamut.OpenAPIProvider); ok && w.announceOpenAPI {
+			sp.OpenAPI = p.OpenAPI()
 		}
 
 		pct, err := p.CPUPercent()
@@ -112,17 +153,22 @@ func (w *Notifier) MakeStartHook(ctx context.Context) func(server bahamut.Server
 
 					sp.Load = pct / cores
 
-					if err := pub.Encode(sp); err != nil {
-						zap.L().Error("Unable to encode service ping", zap.Error(err))
-						continue
-					}
+				case <-w.poke:
 
-					if err := w.pubsub.Publish(pub); err != nil {
-						zap.L().Error("Unable to send wutai up ping", zap.Error(err))
-					}
 				case <-ctx.Done():
 					return
 				}
+
+				sp.Maintenance = w.maintenance.Load()
+
+				if err := pub.Encode(sp); err != nil {
+					zap.L().Error("Unable to encode service ping", zap.Error(err))
+					continue
+				}
+
+				if err := w.pubsub.Publish(pub); err != nil {
+					zap.L().Error("Unable to send wutai up ping", zap.Error(err))
+				}
 			}
 		}()
 
@@ -10,7 +10,9 @@ type notifierConfig struct {
 	rateLimits       IdentityToAPILimitersRegistry
 	privateOverrides map[string]bool
 	prefix           string
+	version          string
 	pingInterval     time.Duration
+	announceOpenAPI  bool
 }
 
 func newNotifierConfig() notifierConfig {
@@ -69,3 +71,23 @@ func OptionNotifierPrivateAPIOverrides(overrides map[elemental.Identity]bool) No
 		}
 	}
 }
+
+// OptionNotifierAnnounceOpenAPI sets whether the notifier should send
+// the OpenAPI documents of the service along with the pings, so the
+// gateways can aggregate them. It has no effect if the server does
+// not implement bahamut.OpenAPIProvider. The default is false.
+func OptionNotifierAnnounceOpenAPI(announce bool) NotifierOption {
+	return func(c *notifierConfig) {
+		c.announceOpenAPI = announce
+	}
+}
+
+// OptionNotifierVersion sets the version of the deployment the
+// service belongs to, like "v2" or "canary". The gateways can use
+// it to split the traffic between several versions of the service.
+// See OptionUpstreamerTrafficSplits.
+func OptionNotifierVersion(version string) NotifierOption {
+	return func(c *notifierConfig) {
+		c.version = version
+	}
+}
@@ -40,4 +40,14 @@ func Test_NotiferOptions(t *testing.T) {
 		OptionNotifierPrivateAPIOverrides(ov)(&c)
 		So(c.privateOverrides, ShouldResemble, map[string]bool{"things": true})
 	})
+
+	Convey("Calling OptionNotifierAnnounceOpenAPI should work", t, func() {
+		OptionNotifierAnnounceOpenAPI(true)(&c)
+		So(c.announceOpenAPI, ShouldBeTrue)
+	})
+
+	Convey("Calling OptionNotifierVersion should work", t, func() {
+		OptionNotifierVersion("v2")(&c)
+		So(c.version, ShouldEqual, "v2")
+	})
 }
@@ -309,3 +309,31 @@ func TestPrefixedNotifier(t *testing.T) {
 		})
 	})
 }
+
+func TestNotifierMaintenance(t *testing.T) {
+
+	Convey("Given I have a notifier", t, func() {
+
+		n := NewNotifier(nil, "topic", "srv1", "1.1.1.1:1")
+
+		Convey("When I set the maintenance", func() {
+
+			n.SetMaintenance("upgrading", time.Minute)
+
+			Convey("Then the maintenance should be announced immediately", func() {
+				So(n.maintenance.Load(), ShouldResemble, &maintenanceInfo{Message: "upgrading", RetryAfter: time.Minute})
+				So(n.poke, ShouldHaveLength, 1)
+			})
+
+			Convey("When I clear the maintenance", func() {
+
+				n.ClearMaintenance()
+
+				Convey("Then the end of the maintenance should be announced", func() {
+					So(n.maintenance.Load(), ShouldBeNil)
+					So(n.poke, ShouldHaveLength, 1)
+				})
+			})
+		})
+	})
+}
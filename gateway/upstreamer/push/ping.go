@@ -1,6 +1,8 @@
 package push
 
 import (
+	"time"
+
 	"go.aporeto.io/bahamut"
 	"golang.org/x/time/rate"
 )
@@ -25,14 +27,23 @@ type APILimiter struct {
 // to an AnnouncedRateLimits.
 type IdentityToAPILimitersRegistry map[string]*APILimiter
 
+// maintenanceInfo describes a maintenance window.
+type maintenanceInfo struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
 type servicePing struct {
 	Routes       map[int][]bahamut.RouteInfo
+	OpenAPI      map[int]*bahamut.OpenAPIDocument
 	Versions     map[string]any
 	APILimiters  IdentityToAPILimitersRegistry
+	Maintenance  *maintenanceInfo
 	Name         string
 	Endpoint     string
 	PushEndpoint string
 	Prefix       string
+	Version      string
 	Status       entityStatus
 	Load         float64
 }
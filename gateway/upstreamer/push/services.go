@@ -12,15 +12,19 @@ type endpointInfo struct {
 	lastSeen          time.Time
 	lastLimiterAdjust time.Time
 	sync.RWMutex
-	limiters IdentityToAPILimitersRegistry
-	address  string
-	lastLoad float64
+	limiters    IdentityToAPILimitersRegistry
+	maintenance *maintenanceInfo
+	address     string
+	service     string
+	version     string
+	lastLoad    float64
 }
 
 type servicesConfig map[string]*service
 
 type service struct {
 	routes    map[int][]bahamut.RouteInfo
+	openAPI   map[int]*bahamut.OpenAPIDocument
 	versions  map[string]any
 	endpoints map[string]*endpointInfo
 	name      string
@@ -53,7 +57,7 @@ func (b *service) hasEndpoint(ep string) bool {
 	return ok
 }
 
-func (b *service) registerEndpoint(address string, load float64, apilimiters IdentityToAPILimitersRegistry) {
+func (b *service) registerEndpoint(address string, version string, load float64, apilimiters IdentityToAPILimitersRegistry) {
 
 	if apilimiters == nil {
 		apilimiters = IdentityToAPILimitersRegistry{}
@@ -69,16 +73,19 @@ func (b *service) registerEndpoint(address string, load float64, apilimiters Ide
 		lastSeen: time.Now(),
 		lastLoad: load,
 		address:  address,
+		service:  b.name,
+		version:  version,
 		limiters: apilimiters,
 	}
 }
 
-func (b *service) pokeEndpoint(ep string, load float64) {
+func (b *service) pokeEndpoint(ep string, load float64, maintenance *maintenanceInfo) {
 
 	if epi, ok := b.endpoints[ep]; ok {
 		epi.Lock()
 		epi.lastSeen = time.Now()
 		epi.lastLoad = load
+		epi.maintenance = maintenance
 		epi.Unlock()
 	}
 }
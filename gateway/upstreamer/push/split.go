@@ -0,0 +1,228 @@
+package push
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.aporeto.io/bahamut/internal/metrics"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	splitTargetCanary   = "canary"
+	splitTargetStable   = "stable"
+	splitTargetFallback = "fallback"
+)
+
+// A TrafficSplit sends a part of the requests for an identity to
+// the endpoints announcing a given version. See OptionNotifierVersion.
+//
+// A request is sent to the endpoints announcing the Version if it has all
+// the given Headers, or all the given Claims, or if it is part of the
+// Percent of requests randomly chosen. Otherwise, it is sent to the other
+// endpoints. If there is no endpoint to send the request to, all the
+// endpoints are used.
+type TrafficSplit struct {
+
+	// Name is the name of the split, used in the metrics.
+	Name string `yaml:"name" json:"name"`
+
+	// Prefix is the prefix of the service serving the identity, if any.
+	Prefix string `yaml:"prefix" json:"prefix"`
+
+	// Identity is the identity whose requests are split.
+	Identity string `yaml:"identity" json:"identity"`
+
+	// Version is the version announced by the endpoints
+	// receiving the requests matching the split.
+	Version string `yaml:"version" json:"version"`
+
+	// Percent is the percentage of the requests, between
+	// 0 and 100, sent to the endpoints announcing the Version.
+	Percent float64 `yaml:"percent" json:"percent"`
+
+	// Headers are the headers a request must have, with the given
+	// values, to be sent to the endpoints announcing the Version.
+	Headers map[string]string `yaml:"headers" json:"headers"`
+
+	// Claims are the claims a request must have to be sent to the endpoints
+	// announcing the Version. It requires OptionUpstreamerClaimsExtractor.
+	Claims []string `yaml:"claims" json:"claims"`
+}
+
+type trafficSplitTable struct {
+	splits map[string]*TrafficSplit
+	metric *prometheus.CounterVec
+}
+
+func (s TrafficSplit) key() string {
+	return s.Prefix + "/" + s.Identity
+}
+
+// ParseTrafficSplits parses the given YAML or JSON list of
+// traffic splits. Unknown fields are rejected.
+func ParseTrafficSplits(data []byte) ([]TrafficSplit, error) {
+
+	var splits []TrafficSplit
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&splits); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to decode traffic splits: %w", err)
+	}
+
+	return splits, nil
+}
+
+// SetTrafficSplits replaces the traffic splits used by the Upstreamer.
+// It can be called at any time, for instance when the configuration
+// of the gateway changes. If one of the splits is invalid, an error
+// is returned and the current splits are kept.
+func (c *Upstreamer) SetTrafficSplits(splits []TrafficSplit) error {
+
+	out := make(map[string]*TrafficSplit, len(splits))
+
+	for i, s := range splits {
+
+		if s.Identity == "" {
+			return fmt.Errorf("invalid traffic split %d: empty identity", i)
+		}
+
+		if s.Version == "" {
+			return fmt.Errorf("invalid traffic split %d: empty version", i)
+		}
+
+		if s.Percent < 0 || s.Percent > 100 {
+			return fmt.Errorf("invalid traffic split %d: percent must be between 0 and 100", i)
+		}
+
+		if len(s.Claims) > 0 && c.config.claimsExtractor == nil {
+			return fmt.Errorf("invalid traffic split %d: matching claims requires a claims extractor", i)
+		}
+
+		if _, ok := out[s.key()]; ok {
+			return fmt.Errorf("invalid traffic split %d: duplicate split for '%s'", i, s.key())
+		}
+
+		if s.Name == "" {
+			s.Name = s.key()
+		}
+
+		out[s.key()] = &s
+	}
+
+	if len(out) > 0 {
+		c.splitMetricOnce.Do(func() { c.splitMetric = makeSplitMetric(c.config.splitMetricsRegisterer) })
+	}
+
+	c.trafficSplits.Store(&trafficSplitTable{splits: out, metric: c.splitMetric})
+
+	return nil
+}
+
+// splitEndpoints returns the endpoints the request must be sent to
+// according to the traffic split configured for the given key, if any.
+func (c *Upstreamer) splitEndpoints(req *http.Request, key string, endpoints []*endpointInfo) []*endpointInfo {
+
+	table, _ := c.trafficSplits.Load().(*trafficSplitTable)
+	if table == nil {
+		return endpoints
+	}
+
+	split, ok := table.splits[key]
+	if !ok || len(endpoints) == 0 {
+		return endpoints
+	}
+
+	canary := c.matchSplit(req, split)
+
+	var out []*endpointInfo
+	for _, ep := range endpoints {
+		if (ep.version == split.Version) == canary {
+			out = append(out, ep)
+		}
+	}
+
+	target := splitTargetStable
+	if canary {
+		target = splitTargetCanary
+	}
+
+	if len(out) == 0 {
+		target = splitTargetFallback
+		out = endpoints
+	}
+
+	if table.metric != nil {
+		table.metric.WithLabelValues(split.Name, target).Inc()
+	}
+
+	return out
+}
+
+func (c *Upstreamer) matchSplit(req *http.Request, split *TrafficSplit) bool {
+
+	if len(split.Headers) > 0 {
+
+		matched := true
+		for k, v := range split.Headers {
+			if req.Header.Get(k) != v {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	if len(split.Claims) > 0 && c.config.claimsExtractor != nil {
+
+		claims := map[string]struct{}{}
+		for _, claim := range c.config.claimsExtractor(req) {
+			claims[claim] = struct{}{}
+		}
+
+		matched := true
+		for _, claim := range split.Claims {
+			if _, ok := claims[claim]; !ok {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return float64(c.config.randomizer.Intn(10000)) < split.Percent*100
+}
+
+func makeSplitMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+
+	if registerer == nil {
+		return nil
+	}
+
+	metric, err := metrics.RegisterCollector(registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_traffic_split_requests_total",
+			Help: "The total number of requests matching a traffic split, per split and target.",
+		},
+		[]string{"split", "target"},
+	))
+	if err != nil {
+		zap.L().Error("Unable to register traffic split metric", zap.Error(err))
+		return nil
+	}
+
+	return metric
+}
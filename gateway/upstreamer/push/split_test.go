@@ -0,0 +1,250 @@
+package push
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseTrafficSplits(t *testing.T) {
+
+	Convey("Given I have a valid configuration", t, func() {
+
+		splits, err := ParseTrafficSplits([]byte(`
+- name: cats-v2
+  identity: cats
+  version: v2
+  percent: 12.5
+  headers:
+    X-Canary: "true"
+- prefix: zoo
+  identity: dogs
+  version: v3
+  claims:
+    - "@auth:realm=certificate"
+`))
+
+		Convey("Then the splits should be correct", func() {
+			So(err, ShouldBeNil)
+			So(splits, ShouldResemble, []TrafficSplit{
+				{Name: "cats-v2", Identity: "cats", Version: "v2", Percent: 12.5, Headers: map[string]string{"X-Canary": "true"}},
+				{Prefix: "zoo", Identity: "dogs", Version: "v3", Claims: []string{"@auth:realm=certificate"}},
+			})
+		})
+	})
+
+	Convey("Given I have an empty configuration", t, func() {
+
+		splits, err := ParseTrafficSplits(nil)
+
+		Convey("Then there should be no split", func() {
+			So(err, ShouldBeNil)
+			So(splits, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given I have a configuration with an unknown field", t, func() {
+
+		_, err := ParseTrafficSplits([]byte(`- identity: cats
+  ratio: 10
+`))
+
+		Convey("Then it should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestUpstreamerSetTrafficSplits(t *testing.T) {
+
+	Convey("Given I have an upstreamer", t, func() {
+
+		u := NewUpstreamer(nil, "topic", "topic2", OptionUpstreamerTrafficSplitMetricsRegisterer(nil))
+
+		Convey("Then invalid splits should be rejected", func() {
+
+			for _, tc := range []struct {
+				split TrafficSplit
+				msg   string
+			}{
+				{TrafficSplit{Version: "v2"}, "invalid traffic split 0: empty identity"},
+				{TrafficSplit{Identity: "cats"}, "invalid traffic split 0: empty version"},
+				{TrafficSplit{Identity: "cats", Version: "v2", Percent: 101}, "invalid traffic split 0: percent must be between 0 and 100"},
+				{TrafficSplit{Identity: "cats", Version: "v2", Claims: []string{"a=b"}}, "invalid traffic split 0: matching claims requires a claims extractor"},
+			} {
+				err := u.SetTrafficSplits([]TrafficSplit{tc.split})
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, tc.msg)
+			}
+
+			err := u.SetTrafficSplits([]TrafficSplit{
+				{Identity: "cats", Version: "v2"},
+				{Identity: "cats", Version: "v3"},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "invalid traffic split 1: duplicate split for '/cats'")
+		})
+
+		Convey("When I set valid splits after invalid ones", func() {
+
+			So(u.SetTrafficSplits([]TrafficSplit{{Identity: "cats", Version: "v2"}}), ShouldBeNil)
+			So(u.SetTrafficSplits([]TrafficSplit{{Version: "v2"}}), ShouldNotBeNil)
+
+			Convey("Then the valid splits should be kept", func() {
+				table := u.trafficSplits.Load().(*trafficSplitTable)
+				So(table.splits, ShouldContainKey, "/cats")
+				So(table.splits["/cats"].Name, ShouldEqual, "/cats")
+			})
+		})
+	})
+}
+
+func TestUpstreamerTrafficSplit(t *testing.T) {
+
+	makeUpstreamer := func(random int, options ...UpstreamerOption) (*Upstreamer, *prometheus.Registry) {
+
+		registry := prometheus.NewRegistry()
+
+		u := NewUpstreamer(
+			nil,
+			"topic",
+			"topic2",
+			append(
+				[]UpstreamerOption{
+					OptionUpstreamerRandomizer(deterministicRandom{value: random}),
+					OptionUpstreamerTrafficSplitMetricsRegisterer(registry),
+				},
+				options...,
+			)...,
+		)
+
+		u.apis = map[string][]*endpointInfo{
+			"/cats": {
+				{address: "1.1.1.1:1", version: "v1", lastLoad: 1},
+				{address: "2.2.2.2:1", version: "v1", lastLoad: 1},
+				{address: "3.3.3.3:1", version: "v2", lastLoad: 1},
+			},
+			"zoo/dogs": {
+				{address: "4.4.4.4:1", version: "v1", lastLoad: 1},
+			},
+		}
+
+		return u, registry
+	}
+
+	upstream := func(u *Upstreamer, path string, header http.Header) string {
+
+		up, err := u.Upstream(&http.Request{URL: &url.URL{Path: path}, Header: header})
+		So(err, ShouldBeNil)
+
+		return up
+	}
+
+	count := func(u *Upstreamer, split string, target string) float64 {
+		return testutil.ToFloat64(u.splitMetric.WithLabelValues(split, target))
+	}
+
+	Convey("Given I have an upstreamer with a 10% split", t, func() {
+
+		split := TrafficSplit{Name: "cats-v2", Identity: "cats", Version: "v2", Percent: 10}
+
+		Convey("When the request is part of the 10%", func() {
+
+			u, _ := makeUpstreamer(999, OptionUpstreamerTrafficSplits(split))
+
+			Convey("Then it should go to the canary", func() {
+				So(upstream(u, "/cats", http.Header{}), ShouldEqual, "3.3.3.3:1")
+				So(count(u, "cats-v2", "canary"), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the request is not part of the 10%", func() {
+
+			u, _ := makeUpstreamer(1000, OptionUpstreamerTrafficSplits(split))
+
+			Convey("Then it should go to the stable endpoints", func() {
+				So(upstream(u, "/cats", http.Header{}), ShouldBeIn, []string{"1.1.1.1:1", "2.2.2.2:1"})
+				So(count(u, "cats-v2", "stable"), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the canary is gone", func() {
+
+			u, _ := makeUpstreamer(0, OptionUpstreamerTrafficSplits(split))
+			u.apis["/cats"] = u.apis["/cats"][:1]
+
+			Convey("Then the request should go to the other endpoints", func() {
+				So(upstream(u, "/cats", http.Header{}), ShouldEqual, "1.1.1.1:1")
+				So(count(u, "cats-v2", "fallback"), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I send a request for another identity", func() {
+
+			u, registry := makeUpstreamer(0, OptionUpstreamerTrafficSplits(split))
+
+			Convey("Then it should not be split", func() {
+				So(upstream(u, "/_zoo/dogs", http.Header{}), ShouldEqual, "4.4.4.4:1")
+				n, err := testutil.GatherAndCount(registry)
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given I have an upstreamer with a split on a header", t, func() {
+
+		u, _ := makeUpstreamer(9999, OptionUpstreamerTrafficSplits(
+			TrafficSplit{Identity: "cats", Version: "v2", Headers: map[string]string{"X-Canary": "true"}},
+		))
+
+		Convey("Then the requests with the header should go to the canary", func() {
+			So(upstream(u, "/cats", http.Header{"X-Canary": {"true"}}), ShouldEqual, "3.3.3.3:1")
+			So(count(u, "/cats", "canary"), ShouldEqual, 1)
+		})
+
+		Convey("Then the other requests should go to the stable endpoints", func() {
+			So(upstream(u, "/cats", http.Header{"X-Canary": {"false"}}), ShouldNotEqual, "3.3.3.3:1")
+			So(upstream(u, "/cats", http.Header{}), ShouldNotEqual, "3.3.3.3:1")
+		})
+	})
+
+	Convey("Given I have an upstreamer with a split on claims", t, func() {
+
+		u, _ := makeUpstreamer(
+			9999,
+			OptionUpstreamerClaimsExtractor(func(req *http.Request) []string { return req.Header.Values("X-Claims") }),
+			OptionUpstreamerTrafficSplits(
+				TrafficSplit{Identity: "cats", Version: "v2", Claims: []string{"org=acme", "role=beta"}},
+			),
+		)
+
+		Convey("Then the requests with all the claims should go to the canary", func() {
+			So(upstream(u, "/cats", http.Header{"X-Claims": {"role=beta", "org=acme", "a=b"}}), ShouldEqual, "3.3.3.3:1")
+		})
+
+		Convey("Then the requests with some of the claims should go to the stable endpoints", func() {
+			So(upstream(u, "/cats", http.Header{"X-Claims": {"org=acme"}}), ShouldNotEqual, "3.3.3.3:1")
+		})
+	})
+
+	Convey("Given I have an upstreamer without split", t, func() {
+
+		u, _ := makeUpstreamer(0)
+
+		Convey("When I add a split at runtime", func() {
+
+			So(u.SetTrafficSplits([]TrafficSplit{{Identity: "cats", Version: "v2", Percent: 100}}), ShouldBeNil)
+
+			Convey("Then it should be used", func() {
+				So(upstream(u, "/cats", http.Header{}), ShouldEqual, "3.3.3.3:1")
+				So(count(u, "/cats", "canary"), ShouldEqual, 1)
+			})
+		})
+	})
+}
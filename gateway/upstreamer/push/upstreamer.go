@@ -11,8 +11,10 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.aporeto.io/bahamut"
 	"go.aporeto.io/bahamut/gateway"
+	"go.aporeto.io/bahamut/internal/routing"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
 )
@@ -32,14 +34,22 @@ type rateSet struct {
 type Upstreamer struct {
 	lock               sync.RWMutex
 	latencies          sync.Map
+	health             sync.Map
+	maintenances       maintenances
+	peers              sync.Map
+	trafficSplits      atomic.Value
 	lastPeerChangeDate atomic.Value
 	lastRateSet        atomic.Value
 	pubsub             bahamut.PubSubClient
 	apis               map[string][]*endpointInfo
+	openAPI            map[int]*bahamut.OpenAPIDocument
 	serviceStatusTopic string
 	peerStatusTopic    string
+	splitMetric        *prometheus.CounterVec
+	splitMetricOnce    sync.Once
 	config             upstreamConfig
 	peersCount         int64
+	controlled         atomic.Bool
 }
 
 // NewUpstreamer returns a new push backed upstreamer latency based
@@ -55,13 +65,19 @@ func NewUpstreamer(
 		opt(&cfg)
 	}
 
-	return &Upstreamer{
+	c := &Upstreamer{
 		pubsub:             pubsub,
 		apis:               map[string][]*endpointInfo{},
 		serviceStatusTopic: serviceStatusTopic,
 		peerStatusTopic:    peerStatusTopic,
 		config:             cfg,
 	}
+
+	if err := c.SetTrafficSplits(cfg.trafficSplits); err != nil {
+		panic(err)
+	}
+
+	return c
 }
 
 // ExtractRates implements the gateway.Limiter interface.
@@ -86,7 +102,7 @@ func (c *Upstreamer) ExtractRates(r *http.Request) (rate.Limit, int, error) {
 // Upstream returns the upstream to go for the given path
 func (c *Upstreamer) Upstream(req *http.Request) (string, error) {
 
-	identity, prefix := getTargetIdentity(req.URL.Path)
+	identity, prefix := routing.TargetIdentity(req.URL.Path)
 	key := fmt.Sprintf("%s/%s", prefix, identity)
 
 	// we rewrite the request to trim the prefix out.
@@ -96,7 +112,40 @@ func (c *Upstreamer) Upstream(req *http.Request) (string, error) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
-	l := len(c.apis[key])
+	endpoints := c.apis[key]
+
+	maintenance := c.maintenance(key, endpoints)
+	if maintenance == nil {
+		endpoints, maintenance = availableEndpoints(endpoints)
+	}
+
+	if c.healthEnabled() || c.controlled.Load() {
+		endpoints = c.healthyEndpoints(endpoints)
+	}
+
+	endpoints = c.splitEndpoints(req, key, endpoints)
+
+	upstream, err := c.pickUpstream(req, identity, endpoints)
+	if err != nil || maintenance == nil {
+		return upstream, err
+	}
+
+	// The upstream is given so the requests allowed
+	// to bypass the maintenance can be forwarded.
+	return "", makeMaintenanceError(maintenance, upstream)
+}
+
+// pickUpstream returns the address of the endpoint
+// to use among the given ones.
+func (c *Upstreamer) pickUpstream(req *http.Request, identity string, endpoints []*endpointInfo) (string, error) {
+
+	l := len(endpoints)
+
+	if l > 1 {
+		if addr, ok := c.hashedUpstream(req, identity, endpoints); ok {
+			return addr, nil
+		}
+	}
 
 	var n1, n2 int
 
@@ -106,7 +155,7 @@ func (c *Upstreamer) Upstream(req *http.Request) (string, error) {
 		return "", nil
 
 	case 1:
-		ep := c.apis[key][0]
+		ep := endpoints[0]
 		ep.RLock()
 		defer ep.RUnlock()
 
@@ -119,63 +168,27 @@ func (c *Upstreamer) Upstream(req *http.Request) (string, error) {
 		n1, n2 = pick(c.config.randomizer, l)
 	}
 
-	epi1 := c.apis[key][n1]
-	epi2 := c.apis[key][n2]
+	epi1 := endpoints[n1]
+	epi2 := endpoints[n2]
 
 	addresses := [2]string{}
 	loads := [2]float64{}
 	rls := [2]*rate.Limiter{}
 
-	var rls1NeedsLimitingUpdate, rls2NeedsLimitingUpdate bool
-
 	// BEGIN LOCKED OPERATIONS
 	epi1.RLock()
 	addresses[0] = epi1.address
 	loads[0] = epi1.lastLoad
-
-	currentPeers := atomic.LoadInt64(&c.peersCount) + 1 // that's us!
-	lastPeerUpdate := func() time.Time { o, _ := c.lastPeerChangeDate.Load().(time.Time); return o }()
-
-	if epi1.limiters != nil && epi1.limiters[identity] != nil {
-
-		rls[0] = epi1.limiters[identity].limiter
-
-		if rls[0] != nil && epi1.lastLimiterAdjust.Before(lastPeerUpdate) {
-			rls1NeedsLimitingUpdate = true
-			rls[0].SetBurst(epi1.limiters[identity].Burst / int(currentPeers))
-			rls[0].SetLimit(epi1.limiters[identity].Limit / rate.Limit(currentPeers))
-		}
-	}
 	epi1.RUnlock()
 
 	epi2.RLock()
 	addresses[1] = epi2.address
 	loads[1] = epi2.lastLoad
-
-	if epi2.limiters != nil && epi2.limiters[identity] != nil {
-
-		rls[1] = epi2.limiters[identity].limiter
-
-		if rls[1] != nil && epi2.lastLimiterAdjust.Before(lastPeerUpdate) {
-			rls2NeedsLimitingUpdate = true
-			rls[1].SetBurst(epi2.limiters[identity].Burst / int(currentPeers))
-			rls[1].SetLimit(epi2.limiters[identity].Limit / rate.Limit(currentPeers))
-		}
-	}
 	epi2.RUnlock()
 	// END LOCKED OPERATIONS
 
-	if rls1NeedsLimitingUpdate {
-		epi1.Lock()
-		epi1.lastLimiterAdjust = lastPeerUpdate
-		epi1.Unlock()
-	}
-
-	if rls2NeedsLimitingUpdate {
-		epi2.Lock()
-		epi2.lastLimiterAdjust = lastPeerUpdate
-		epi2.Unlock()
-	}
+	rls[0] = c.endpointLimiter(epi1, identity)
+	rls[1] = c.endpointLimiter(epi2, identity)
 
 	w := [2]float64{.0, .0}
 
@@ -260,6 +273,38 @@ func (c *Upstreamer) Upstream(req *http.Request) (string, error) {
 	return "", gateway.ErrUpstreamerTooManyRequests
 }
 
+// endpointLimiter returns the rate limiter of the given endpoint for the
+// given identity, if any, after adjusting its limits to the number of peers.
+func (c *Upstreamer) endpointLimiter(epi *endpointInfo, identity string) *rate.Limiter {
+
+	currentPeers := atomic.LoadInt64(&c.peersCount) + 1 // that's us!
+	lastPeerUpdate := func() time.Time { o, _ := c.lastPeerChangeDate.Load().(time.Time); return o }()
+
+	var rl *rate.Limiter
+	var needsLimitingUpdate bool
+
+	epi.RLock()
+	if epi.limiters != nil && epi.limiters[identity] != nil {
+
+		rl = epi.limiters[identity].limiter
+
+		if rl != nil && epi.lastLimiterAdjust.Before(lastPeerUpdate) {
+			needsLimitingUpdate = true
+			rl.SetBurst(epi.limiters[identity].Burst / int(currentPeers))
+			rl.SetLimit(epi.limiters[identity].Limit / rate.Limit(currentPeers))
+		}
+	}
+	epi.RUnlock()
+
+	if needsLimitingUpdate {
+		epi.Lock()
+		epi.lastLimiterAdjust = lastPeerUpdate
+		epi.Unlock()
+	}
+
+	return rl
+}
+
 // Start starts for new backend services.
 func (c *Upstreamer) Start(ctx context.Context) (chan struct{}, *sync.WaitGroup) {
 
@@ -279,6 +324,14 @@ func (c *Upstreamer) Start(ctx context.Context) (chan struct{}, *sync.WaitGroup)
 		c.listenServices(ctx, ready)
 	}()
 
+	if c.config.healthCheckPath != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.checkHealth(ctx)
+		}()
+	}
+
 	return ready, &wg
 }
 
@@ -372,6 +425,7 @@ func (c *Upstreamer) listenServices(ctx context.Context, ready chan struct{}) {
 						},
 					)
 					c.latencies.Delete(ep)
+					c.health.Delete(ep)
 					zap.L().Info(
 						"Handled outdated service",
 						zap.String("name", srv.name),
@@ -383,6 +437,7 @@ func (c *Upstreamer) listenServices(ctx context.Context, ready chan struct{}) {
 			if foundOutdated {
 				c.lock.Lock()
 				c.apis = resyncRoutes(services, c.config.exposePrivateAPIs, c.config.eventsAPIs)
+				c.openAPI = resyncOpenAPI(services, c.config.exposePrivateAPIs)
 				c.lock.Unlock()
 			}
 
@@ -405,9 +460,14 @@ func (c *Upstreamer) listenServices(ctx context.Context, ready chan struct{}) {
 			switch sp.Status {
 			case entityStatusHello:
 
+				if c.healthEnabled() {
+					c.endpointHealth(sp.Endpoint)
+				}
+
 				if handleAddServicePing(services, sp) {
 					c.lock.Lock()
 					c.apis = resyncRoutes(services, c.config.exposePrivateAPIs, c.config.eventsAPIs)
+					c.openAPI = resyncOpenAPI(services, c.config.exposePrivateAPIs)
 					c.lock.Unlock()
 					zap.L().Debug(
 						"Handled service hello",
@@ -434,8 +494,10 @@ func (c *Upstreamer) listenServices(ctx context.Context, ready chan struct{}) {
 				if handleRemoveServicePing(services, sp) {
 					c.lock.Lock()
 					c.apis = resyncRoutes(services, c.config.exposePrivateAPIs, c.config.eventsAPIs)
+					c.openAPI = resyncOpenAPI(services, c.config.exposePrivateAPIs)
 					c.lock.Unlock()
 					c.latencies.Delete(sp.Endpoint)
+					c.health.Delete(sp.Endpoint)
 					zap.L().Debug(
 						"Handled service goodbye",
 						zap.String("key", sp.Key()),
@@ -484,8 +546,6 @@ func (c *Upstreamer) listenPeers(ctx context.Context) {
 	unsub := c.pubsub.Subscribe(pubs, errs, c.peerStatusTopic)
 	defer unsub()
 
-	peers := sync.Map{}
-
 	// Send the first ping immediately
 	if err := c.pubsub.Publish(helloPub); err != nil {
 		zap.L().Error("Unable to send initial hello to pubsub peers channel", zap.Error(err))
@@ -504,9 +564,9 @@ func (c *Upstreamer) listenPeers(ctx context.Context) {
 
 			now := time.Now()
 			var deleted int64
-			peers.Range(func(id, date any) bool {
+			c.peers.Range(func(id, date any) bool {
 				if now.After(date.(time.Time).Add(c.config.peerTimeout)) {
-					peers.Delete(id)
+					c.peers.Delete(id)
 					deleted++
 				}
 				return true
@@ -533,16 +593,16 @@ func (c *Upstreamer) listenPeers(ctx context.Context) {
 
 			switch ping.Status {
 			case entityStatusHello:
-				if _, ok := peers.Load(ping.RuntimeID); !ok {
+				if _, ok := c.peers.Load(ping.RuntimeID); !ok {
 					atomic.AddInt64(&c.peersCount, 1)
 					c.lastPeerChangeDate.Store(time.Now())
 					c.lastRateSet.Store(emptyRateSet)
 				}
-				peers.Store(ping.RuntimeID, time.Now())
+				c.peers.Store(ping.RuntimeID, time.Now())
 
 			case entityStatusGoodbye:
-				if _, ok := peers.Load(ping.RuntimeID); ok {
-					peers.Delete(ping.RuntimeID)
+				if _, ok := c.peers.Load(ping.RuntimeID); ok {
+					c.peers.Delete(ping.RuntimeID)
 					atomic.AddInt64(&c.peersCount, -1)
 					c.lastPeerChangeDate.Store(time.Now())
 					c.lastRateSet.Store(emptyRateSet)
@@ -562,6 +622,20 @@ func (c *Upstreamer) listenPeers(ctx context.Context) {
 	}
 }
 
+// OpenAPI returns the OpenAPI documents announced by the
+// services, aggregated into a single document per version.
+// Services only announce their documents when their Notifier
+// is configured with OptionNotifierAnnounceOpenAPI. This implements
+// the gateway.OpenAPIUpstreamer interface, so the gateway serves them
+// at /_meta/openapi/v/:version.
+func (c *Upstreamer) OpenAPI() map[int]*bahamut.OpenAPIDocument {
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.openAPI
+}
+
 // CollectLatency implement the LatencyBasedUpstreamer interface to add new
 // samples into the latencies sync map
 func (c *Upstreamer) CollectLatency(address string, responseTime time.Duration) {
@@ -1,8 +1,11 @@
 package push
 
 import (
+	"crypto/tls"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"go.aporeto.io/bahamut/gateway"
 	"golang.org/x/time/rate"
 )
 
@@ -12,17 +15,28 @@ type UpstreamerOption func(*upstreamConfig)
 
 type upstreamConfig struct {
 	randomizer                  Randomizer
+	splitMetricsRegisterer      prometheus.Registerer
+	claimsExtractor             gateway.ClaimsExtractor
+	healthCheckTLSConfig        *tls.Config
 	eventsAPIs                  map[string]string
+	hashKeyExtractors           map[string]gateway.SourceExtractor
 	overrideEndpointAddress     string
 	globalServiceTopic          string
+	healthCheckPath             string
 	requiredServices            []string
+	trafficSplits               []TrafficSplit
 	serviceTimeoutCheckInterval time.Duration
 	serviceTimeout              time.Duration
 	peerTimeout                 time.Duration
 	peerTimeoutCheckInterval    time.Duration
 	peerPingInterval            time.Duration
+	healthCheckInterval         time.Duration
+	healthCheckTimeout          time.Duration
+	ejectionPenalty             time.Duration
 	latencySampleSize           int
 	tokenLimitingBurst          int
+	healthCheckThreshold        int
+	outlierThreshold            int
 	tokenLimitingRPS            rate.Limit
 	exposePrivateAPIs           bool
 }
@@ -30,6 +44,7 @@ type upstreamConfig struct {
 func newUpstreamConfig() upstreamConfig {
 	return upstreamConfig{
 		eventsAPIs:                  map[string]string{},
+		hashKeyExtractors:           map[string]gateway.SourceExtractor{},
 		latencySampleSize:           20,
 		serviceTimeout:              30 * time.Second,
 		serviceTimeoutCheckInterval: 5 * time.Second,
@@ -39,6 +54,8 @@ func newUpstreamConfig() upstreamConfig {
 		randomizer:                  newRandomizer(),
 		tokenLimitingBurst:          2000,
 		tokenLimitingRPS:            500,
+		ejectionPenalty:             30 * time.Second,
+		splitMetricsRegisterer:      prometheus.DefaultRegisterer,
 	}
 }
 
@@ -147,3 +164,110 @@ func OptionUpstreamerGlobalServiceTopic(topic string) UpstreamerOption {
 		cfg.globalServiceTopic = topic
 	}
 }
+
+// OptionUpstreamerHealthCheck enables active health checking of the
+// endpoints. Every interval, the Upstreamer will send a GET request
+// to the given path on each endpoint, and will consider the probe
+// failed if it does not get a 2xx response before timeout.
+// After unhealthyThreshold consecutive failed probes, the endpoint
+// is ejected for the duration set by OptionUpstreamerEjectionPenalty.
+// Active health checking is disabled by default.
+func OptionUpstreamerHealthCheck(path string, interval time.Duration, timeout time.Duration, unhealthyThreshold int) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.healthCheckPath = path
+		cfg.healthCheckInterval = interval
+		cfg.healthCheckTimeout = timeout
+		cfg.healthCheckThreshold = unhealthyThreshold
+		if cfg.healthCheckInterval <= 0 {
+			panic("interval cannot be <= 0")
+		}
+		if cfg.healthCheckTimeout <= 0 {
+			panic("timeout cannot be <= 0")
+		}
+		if cfg.healthCheckThreshold <= 0 {
+			panic("unhealthyThreshold cannot be <= 0")
+		}
+	}
+}
+
+// OptionUpstreamerHealthCheckTLSConfig sets the TLS configuration
+// to use to send the health check probes. If set, the probes
+// are sent using https. Otherwise they are sent using http.
+func OptionUpstreamerHealthCheckTLSConfig(tlsConfig *tls.Config) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.healthCheckTLSConfig = tlsConfig
+	}
+}
+
+// OptionUpstreamerOutlierDetection enables passive outlier detection.
+// An endpoint for which the gateway sees consecutiveFailures consecutive
+// 5xx responses or connection errors is ejected for the duration set by
+// OptionUpstreamerEjectionPenalty.
+// Passive outlier detection is disabled by default.
+func OptionUpstreamerOutlierDetection(consecutiveFailures int) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.outlierThreshold = consecutiveFailures
+		if cfg.outlierThreshold <= 0 {
+			panic("consecutiveFailures cannot be <= 0")
+		}
+	}
+}
+
+// OptionUpstreamerEjectionPenalty sets for how long an endpoint
+// that failed its health checks or has been detected as an outlier
+// is excluded from the possible upstreams.
+// The default is 30s.
+func OptionUpstreamerEjectionPenalty(penalty time.Duration) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.ejectionPenalty = penalty
+		if cfg.ejectionPenalty <= 0 {
+			panic("penalty cannot be <= 0")
+		}
+	}
+}
+
+// OptionUpstreamerConsistentHashing configures the Upstreamer to route the
+// requests to the given service using rendezvous hashing on the key returned
+// by the given extractor, so the requests with the same key always go to
+// the same endpoint as long as it is available. This gives affinity to the
+// endpoints keeping caches per tenant, for instance.
+//
+// The serviceName is the name of the service, prefixed by its prefix and
+// a slash if it has one. Use NewHeaderKeyExtractor or NewObjectIDKeyExtractor
+// to hash on a header, like the namespace, or on the ID of the targeted object,
+// or gateway.NewDefaultSourceExtractor to hash on the token.
+//
+// If the extracted key is empty, or if the chosen endpoint is rate limited,
+// the default power-of-two choices strategy is used.
+func OptionUpstreamerConsistentHashing(serviceName string, extractor gateway.SourceExtractor) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.hashKeyExtractors[serviceName] = extractor
+	}
+}
+
+// OptionUpstreamerTrafficSplits sets the initial traffic splits used to
+// send a part of the requests for an identity to the endpoints announcing
+// a given version, like a canary. The splits can be updated at runtime
+// using (*Upstreamer).SetTrafficSplits.
+func OptionUpstreamerTrafficSplits(splits ...TrafficSplit) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.trafficSplits = splits
+	}
+}
+
+// OptionUpstreamerClaimsExtractor sets the gateway.ClaimsExtractor used
+// to match the requests against the claims of the traffic splits.
+func OptionUpstreamerClaimsExtractor(extractor gateway.ClaimsExtractor) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.claimsExtractor = extractor
+	}
+}
+
+// OptionUpstreamerTrafficSplitMetricsRegisterer sets the prometheus.Registerer
+// used to register the traffic split counter. The default is
+// prometheus.DefaultRegisterer. Passing nil disables the metric.
+func OptionUpstreamerTrafficSplitMetricsRegisterer(registerer prometheus.Registerer) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.splitMetricsRegisterer = registerer
+	}
+}
@@ -1,12 +1,16 @@
 package push
 
 import (
+	"crypto/tls"
 	"math/rand"
+	"net/http"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	// nolint:revive // Allow dot imports for readability in tests
 	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut/gateway"
 )
 
 func Test_Options(t *testing.T) {
@@ -76,4 +80,58 @@ func Test_Options(t *testing.T) {
 		OptionUpstreamerGlobalServiceTopic("global")(&c)
 		So(c.globalServiceTopic, ShouldEqual, "global")
 	})
+
+	Convey("Calling OptionUpstreamerHealthCheck should work", t, func() {
+		OptionUpstreamerHealthCheck("/health", time.Hour, time.Minute, 3)(&c)
+		So(c.healthCheckPath, ShouldEqual, "/health")
+		So(c.healthCheckInterval, ShouldEqual, time.Hour)
+		So(c.healthCheckTimeout, ShouldEqual, time.Minute)
+		So(c.healthCheckThreshold, ShouldEqual, 3)
+
+		So(func() { OptionUpstreamerHealthCheck("/health", 0, time.Minute, 3)(&c) }, ShouldPanicWith, `interval cannot be <= 0`)
+		So(func() { OptionUpstreamerHealthCheck("/health", time.Hour, 0, 3)(&c) }, ShouldPanicWith, `timeout cannot be <= 0`)
+		So(func() { OptionUpstreamerHealthCheck("/health", time.Hour, time.Minute, 0)(&c) }, ShouldPanicWith, `unhealthyThreshold cannot be <= 0`)
+	})
+
+	Convey("Calling OptionUpstreamerHealthCheckTLSConfig should work", t, func() {
+		tlsConfig := &tls.Config{}
+		OptionUpstreamerHealthCheckTLSConfig(tlsConfig)(&c)
+		So(c.healthCheckTLSConfig, ShouldEqual, tlsConfig)
+	})
+
+	Convey("Calling OptionUpstreamerOutlierDetection should work", t, func() {
+		OptionUpstreamerOutlierDetection(5)(&c)
+		So(c.outlierThreshold, ShouldEqual, 5)
+
+		So(func() { OptionUpstreamerOutlierDetection(0)(&c) }, ShouldPanicWith, `consecutiveFailures cannot be <= 0`)
+	})
+
+	Convey("Calling OptionUpstreamerEjectionPenalty should work", t, func() {
+		OptionUpstreamerEjectionPenalty(time.Hour)(&c)
+		So(c.ejectionPenalty, ShouldEqual, time.Hour)
+
+		So(func() { OptionUpstreamerEjectionPenalty(0)(&c) }, ShouldPanicWith, `penalty cannot be <= 0`)
+	})
+
+	Convey("Calling OptionUpstreamerConsistentHashing should work", t, func() {
+		e := NewHeaderKeyExtractor("X-Namespace")
+		OptionUpstreamerConsistentHashing("srva", e)(&c)
+		So(c.hashKeyExtractors, ShouldResemble, map[string]gateway.SourceExtractor{"srva": e})
+	})
+
+	Convey("Calling OptionUpstreamerTrafficSplits should work", t, func() {
+		OptionUpstreamerTrafficSplits(TrafficSplit{Identity: "cats", Version: "v2", Percent: 10})(&c)
+		So(c.trafficSplits, ShouldResemble, []TrafficSplit{{Identity: "cats", Version: "v2", Percent: 10}})
+	})
+
+	Convey("Calling OptionUpstreamerClaimsExtractor should work", t, func() {
+		OptionUpstreamerClaimsExtractor(func(*http.Request) []string { return nil })(&c)
+		So(c.claimsExtractor, ShouldNotBeNil)
+	})
+
+	Convey("Calling OptionUpstreamerTrafficSplitMetricsRegisterer should work", t, func() {
+		r := prometheus.NewRegistry()
+		OptionUpstreamerTrafficSplitMetricsRegisterer(r)(&c)
+		So(c.splitMetricsRegisterer, ShouldEqual, r)
+	})
 }
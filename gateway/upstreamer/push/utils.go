@@ -1,38 +1,12 @@
 package push
 
 import (
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
-)
-
-var vregexp = regexp.MustCompile(`/v/\d+`)
-
-func getTargetIdentity(path string) (string, string) {
-
-	parts := strings.Split(
-		strings.TrimPrefix(
-			vregexp.ReplaceAllString(path, ""),
-			"/",
-		),
-		"/",
-	)
-
-	prefix := ""
-	if len(parts) > 1 && parts[0][0] == '_' {
-		prefix = parts[0][1:]
-		parts = append([]string{}, parts[1:]...)
-	}
-
-	switch len(parts) {
 
-	case 1:
-		return parts[0], prefix
-	case 2:
-		return parts[0], prefix
-	default:
-		return parts[2], prefix
-	}
-}
+	"go.aporeto.io/bahamut"
+)
 
 func pick(randomizer Randomizer, length int) (int, int) {
 
@@ -65,7 +39,7 @@ func handleAddServicePing(services servicesConfig, sp servicePing) bool {
 	// In any case we poke the endpoint. This will
 	// only do something if the endpoint is already
 	// registered.
-	defer srv.pokeEndpoint(sp.Endpoint, sp.Load)
+	defer srv.pokeEndpoint(sp.Endpoint, sp.Load, sp.Maintenance)
 
 	if srv.hasEndpoint(sp.Endpoint) {
 		return false
@@ -74,9 +48,10 @@ func handleAddServicePing(services servicesConfig, sp servicePing) bool {
 	// We update the info to the latest.
 	srv.routes = sp.Routes
 	srv.versions = sp.Versions
+	srv.openAPI = sp.OpenAPI
 
 	// We register the new endpoint.
-	srv.registerEndpoint(sp.Endpoint, sp.Load, sp.APILimiters)
+	srv.registerEndpoint(sp.Endpoint, sp.Version, sp.Load, sp.APILimiters)
 
 	return true
 }
@@ -131,6 +106,105 @@ func resyncRoutes(services servicesConfig, includePrivate bool, events map[strin
 	return apis
 }
 
+// resyncOpenAPI aggregates the OpenAPI documents announced by
+// all the services into a single document per version.
+func resyncOpenAPI(services servicesConfig, includePrivate bool) map[int]*bahamut.OpenAPIDocument {
+
+	docs := map[int]*bahamut.OpenAPIDocument{}
+
+	// We sort the services so that, if two services declare
+	// a component with the same name, the result is stable.
+	keys := make([]string, 0, len(services))
+	for k := range services {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, serviceName := range keys {
+
+		config := services[serviceName]
+		_, prefix := extractPrefix(serviceName)
+
+		for version, doc := range config.openAPI {
+
+			if doc == nil {
+				continue
+			}
+
+			private := map[string]bool{}
+			for _, route := range config.routes[version] {
+				if route.Private && !includePrivate {
+					private[route.Identity] = true
+				}
+			}
+
+			out, ok := docs[version]
+			if !ok {
+				out = &bahamut.OpenAPIDocument{
+					OpenAPI: doc.OpenAPI,
+					Info:    bahamut.OpenAPIInfo{Title: "gateway", Version: strconv.Itoa(version)},
+					Servers: []bahamut.OpenAPIServer{{URL: "/v/" + strconv.Itoa(version)}},
+					Paths:   map[string]*bahamut.OpenAPIPathItem{},
+					Components: bahamut.OpenAPIComponents{
+						Schemas:    map[string]*bahamut.OpenAPISchema{},
+						Parameters: map[string]*bahamut.OpenAPIParameter{},
+						Responses:  map[string]*bahamut.OpenAPIResponse{},
+					},
+				}
+				docs[version] = out
+			}
+
+			for p, item := range doc.Paths {
+
+				if private[openAPIPathIdentity(p)] {
+					continue
+				}
+
+				if prefix != "" {
+					p = "/_" + prefix + p
+				}
+
+				out.Paths[p] = item
+			}
+
+			for k, v := range doc.Components.Schemas {
+				if _, ok := out.Components.Schemas[k]; !ok {
+					out.Components.Schemas[k] = v
+				}
+			}
+
+			for k, v := range doc.Components.Parameters {
+				if _, ok := out.Components.Parameters[k]; !ok {
+					out.Components.Parameters[k] = v
+				}
+			}
+
+			for k, v := range doc.Components.Responses {
+				if _, ok := out.Components.Responses[k]; !ok {
+					out.Components.Responses[k] = v
+				}
+			}
+		}
+	}
+
+	return docs
+}
+
+// openAPIPathIdentity returns the category of the
+// identity targeted by the given OpenAPI path.
+func openAPIPathIdentity(path string) string {
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] != "{id}" {
+			return parts[i]
+		}
+	}
+
+	return ""
+}
+
 func extractPrefix(key string) (name string, prefix string) {
 
 	name = key
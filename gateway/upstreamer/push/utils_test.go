@@ -13,143 +13,6 @@ import (
 	"go.aporeto.io/bahamut"
 )
 
-func Test_getTargetIdentity(t *testing.T) {
-	type args struct {
-		path string
-	}
-	tests := []struct {
-		name         string
-		args         args
-		wantIdentity string
-		wantPrefix   string
-	}{
-		{
-			"/",
-			args{
-				"/",
-			},
-			"",
-			"",
-		},
-		{
-			"/users",
-			args{
-				"/users",
-			},
-			"users",
-			"",
-		},
-		{
-			"/users/id",
-			args{
-				"/users/id",
-			},
-			"users",
-			"",
-		},
-		{
-			"/users/id/groups",
-			args{
-				"/users/id/groups",
-			},
-			"groups",
-			"",
-		},
-		{
-			"/v/1/users",
-			args{
-				"/v/1/users",
-			},
-			"users",
-			"",
-		},
-		{
-			"/v/1/users/id",
-			args{
-				"/v/1/users/id",
-			},
-			"users",
-			"",
-		},
-		{
-			"/v/1/users/id/groups",
-			args{
-				"/v/1/users/id/groups",
-			},
-			"groups",
-			"",
-		},
-		// prefixed
-		{
-			"_prefix/",
-			args{
-				"_prefix/",
-			},
-			"",
-			"prefix",
-		},
-		{
-			"_prefix/users",
-			args{
-				"_prefix/users",
-			},
-			"users",
-			"prefix",
-		},
-		{
-			"_prefix/users/id",
-			args{
-				"_prefix/users/id",
-			},
-			"users",
-			"prefix",
-		},
-		{
-			"_prefix/users/id/groups",
-			args{
-				"_prefix/users/id/groups",
-			},
-			"groups",
-			"prefix",
-		},
-		{
-			"_prefix/v/1/users",
-			args{
-				"_prefix/v/1/users",
-			},
-			"users",
-			"prefix",
-		},
-		{
-			"_prefix/v/1/users/id",
-			args{
-				"_prefix/v/1/users/id",
-			},
-			"users",
-			"prefix",
-		},
-		{
-			"_prefix/v/1/users/id/groups",
-			args{
-				"_prefix/v/1/users/id/groups",
-			},
-			"groups",
-			"prefix",
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			identity, prefix := getTargetIdentity(tt.args.path)
-			if identity != tt.wantIdentity {
-				t.Errorf("getTargetIdentity() identity = %v, want %v", identity, tt.wantIdentity)
-			}
-			if prefix != tt.wantPrefix {
-				t.Errorf("getTargetIdentity() prefix = %v, want %v", prefix, tt.wantPrefix)
-			}
-		})
-	}
-}
-
 func TestHandleServicePings(t *testing.T) {
 
 	// TODO: CHECK ROUTES AND VERSIONS
@@ -728,6 +591,95 @@ func Test_resyncRoutes(t *testing.T) {
 	}
 }
 
+func Test_resyncOpenAPI(t *testing.T) {
+
+	Convey("Given I have services announcing openapi documents", t, func() {
+
+		makeDoc := func(schema string, paths ...string) *bahamut.OpenAPIDocument {
+			doc := &bahamut.OpenAPIDocument{
+				OpenAPI: "3.0.3",
+				Paths:   map[string]*bahamut.OpenAPIPathItem{},
+				Components: bahamut.OpenAPIComponents{
+					Schemas:    map[string]*bahamut.OpenAPISchema{schema: {Type: "object", Description: schema}},
+					Parameters: map[string]*bahamut.OpenAPIParameter{"page": {Name: "page"}},
+				},
+			}
+			for _, p := range paths {
+				doc.Paths[p] = &bahamut.OpenAPIPathItem{Get: &bahamut.OpenAPIOperation{OperationID: p}}
+			}
+			return doc
+		}
+
+		services := servicesConfig{
+			"srv1": &service{
+				name: "srv1",
+				routes: map[int][]bahamut.RouteInfo{
+					1: {
+						{Identity: "cats", URL: "/cats"},
+						{Identity: "kittens", URL: "/cats/:id/kittens", Private: true},
+					},
+				},
+				openAPI: map[int]*bahamut.OpenAPIDocument{
+					1: makeDoc("cat", "/cats", "/cats/{id}", "/cats/{id}/kittens"),
+				},
+			},
+			"prefix/srv2": &service{
+				name: "prefix/srv2",
+				routes: map[int][]bahamut.RouteInfo{
+					1: {{Identity: "dogs", URL: "/dogs"}},
+				},
+				openAPI: map[int]*bahamut.OpenAPIDocument{
+					1: makeDoc("dog", "/dogs"),
+				},
+			},
+			"srv3": &service{
+				name: "srv3",
+			},
+		}
+
+		Convey("When I call resyncOpenAPI without private apis", func() {
+
+			docs := resyncOpenAPI(services, false)
+
+			Convey("Then the documents should be aggregated", func() {
+				So(len(docs), ShouldEqual, 1)
+				So(docs[1].Info.Version, ShouldEqual, "1")
+				So(docs[1].Servers[0].URL, ShouldEqual, "/v/1")
+
+				var paths []string
+				for p := range docs[1].Paths {
+					paths = append(paths, p)
+				}
+				sort.Strings(paths)
+				So(paths, ShouldResemble, []string{"/_prefix/dogs", "/cats", "/cats/{id}"})
+
+				So(docs[1].Components.Schemas["cat"], ShouldNotBeNil)
+				So(docs[1].Components.Schemas["dog"], ShouldNotBeNil)
+				So(docs[1].Components.Parameters["page"], ShouldNotBeNil)
+			})
+		})
+
+		Convey("When I call resyncOpenAPI with private apis", func() {
+
+			docs := resyncOpenAPI(services, true)
+
+			Convey("Then the private paths should be included", func() {
+				So(docs[1].Paths["/cats/{id}/kittens"], ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func Test_openAPIPathIdentity(t *testing.T) {
+
+	Convey("Given I have some openapi paths", t, func() {
+		So(openAPIPathIdentity("/cats"), ShouldEqual, "cats")
+		So(openAPIPathIdentity("/cats/{id}"), ShouldEqual, "cats")
+		So(openAPIPathIdentity("/cats/{id}/kittens"), ShouldEqual, "kittens")
+		So(openAPIPathIdentity("/"), ShouldEqual, "")
+	})
+}
+
 func TestPick(t *testing.T) {
 	r1 := rand.New(rand.NewSource(time.Now().UnixNano()))
 
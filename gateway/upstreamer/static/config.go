@@ -0,0 +1,164 @@
+package static
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A Config describes the services
+// the Upstreamer routes the requests to.
+type Config struct {
+	Services []*Service `yaml:"services" json:"services"`
+}
+
+// A Service describes a set of endpoints serving some APIs.
+type Service struct {
+
+	// Name is the name of the service. It is only used
+	// in the error messages.
+	Name string `yaml:"name" json:"name"`
+
+	// Prefix is the optional prefix of the service. If set, its
+	// identities are routed from /_<prefix>/<identity> and the prefix
+	// is removed from the path before forwarding the request.
+	Prefix string `yaml:"prefix" json:"prefix"`
+
+	// Identities is the list of the identity categories
+	// the service serves publicly.
+	Identities []string `yaml:"identities" json:"identities"`
+
+	// PrivateIdentities is the list of the identity categories the
+	// service serves privately. They are only routed if the
+	// Upstreamer is configured to expose the private APIs.
+	PrivateIdentities []string `yaml:"privateIdentities" json:"privateIdentities"`
+
+	// Paths is a list of path prefixes routed to the service. They
+	// are used for the requests that do not target a known identity,
+	// the longest matching prefix winning.
+	Paths []string `yaml:"paths" json:"paths"`
+
+	// Endpoints is the list of endpoints of the service.
+	Endpoints []*Endpoint `yaml:"endpoints" json:"endpoints"`
+}
+
+// An Endpoint is an address serving a Service.
+type Endpoint struct {
+
+	// Address is the address of the endpoint in the form host:port.
+	Address string `yaml:"address" json:"address"`
+
+	// Weight is the relative weight of the endpoint. An endpoint
+	// with a weight of 2 receives twice as many requests as an
+	// endpoint with a weight of 1. The default is 1.
+	Weight int `yaml:"weight" json:"weight"`
+}
+
+// ParseConfig parses the given YAML or JSON document
+// and returns the Config it describes.
+func ParseConfig(data []byte) (*Config, error) {
+
+	c := &Config{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to decode config: %w", err)
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Validate validates the Config.
+func (c *Config) Validate() error {
+
+	identities := map[string]string{}
+	paths := map[string]string{}
+
+	for i, s := range c.Services {
+
+		if s == nil {
+			return fmt.Errorf("service %d is empty", i)
+		}
+
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("invalid service '%s': %w", name, err)
+		}
+
+		for _, identity := range append(append([]string{}, s.Identities...), s.PrivateIdentities...) {
+			key := routeKey(s.Prefix, identity)
+			if other, ok := identities[key]; ok {
+				return fmt.Errorf("invalid service '%s': identity '%s' already served by service '%s'", name, key, other)
+			}
+			identities[key] = name
+		}
+
+		for _, p := range s.Paths {
+			if other, ok := paths[p]; ok {
+				return fmt.Errorf("invalid service '%s': path '%s' already served by service '%s'", name, p, other)
+			}
+			paths[p] = name
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) validate() error {
+
+	if strings.Contains(s.Prefix, "/") {
+		return fmt.Errorf("invalid prefix '%s': must not contain /", s.Prefix)
+	}
+
+	for _, identity := range append(append([]string{}, s.Identities...), s.PrivateIdentities...) {
+		if identity == "" || strings.Contains(identity, "/") {
+			return fmt.Errorf("invalid identity '%s'", identity)
+		}
+	}
+
+	for _, p := range s.Paths {
+		if !strings.HasPrefix(p, "/") {
+			return fmt.Errorf("invalid path '%s': must start with /", p)
+		}
+	}
+
+	if len(s.Endpoints) == 0 {
+		return fmt.Errorf("no endpoints")
+	}
+
+	for i, ep := range s.Endpoints {
+
+		if ep == nil {
+			return fmt.Errorf("endpoint %d is empty", i)
+		}
+
+		if _, _, err := net.SplitHostPort(ep.Address); err != nil {
+			return fmt.Errorf("invalid endpoint address '%s': %w", ep.Address, err)
+		}
+
+		if ep.Weight < 0 {
+			return fmt.Errorf("invalid endpoint weight '%d': must be positive", ep.Weight)
+		}
+	}
+
+	return nil
+}
+
+func routeKey(prefix string, identity string) string {
+	return prefix + "/" + identity
+}
@@ -0,0 +1,98 @@
+package static
+
+import (
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testConfigYAML = `
+services:
+  - name: cats
+    identities: [cats, kittens]
+    privateIdentities: [litters]
+    endpoints:
+      - address: 10.0.0.1:443
+        weight: 3
+      - address: 10.0.0.2:443
+
+  - name: assets
+    prefix: assets
+    identities: [images]
+    paths: [/static, /static/private/]
+    endpoints:
+      - address: 10.0.1.1:443
+`
+
+func TestParseConfig(t *testing.T) {
+
+	Convey("Given I have a YAML config", t, func() {
+
+		c, err := ParseConfig([]byte(testConfigYAML))
+
+		Convey("Then it should be parsed", func() {
+			So(err, ShouldBeNil)
+			So(len(c.Services), ShouldEqual, 2)
+			So(c.Services[0].Identities, ShouldResemble, []string{"cats", "kittens"})
+			So(c.Services[0].PrivateIdentities, ShouldResemble, []string{"litters"})
+			So(c.Services[0].Endpoints, ShouldResemble, []*Endpoint{{Address: "10.0.0.1:443", Weight: 3}, {Address: "10.0.0.2:443"}})
+			So(c.Services[1].Prefix, ShouldEqual, "assets")
+			So(c.Services[1].Paths, ShouldResemble, []string{"/static", "/static/private/"})
+		})
+	})
+
+	Convey("Given I have a JSON config", t, func() {
+
+		c, err := ParseConfig([]byte(`{"services": [{"identities": ["cats"], "endpoints": [{"address": "127.0.0.1:1"}]}]}`))
+
+		Convey("Then it should be parsed", func() {
+			So(err, ShouldBeNil)
+			So(c.Services[0].Endpoints[0].Address, ShouldEqual, "127.0.0.1:1")
+		})
+	})
+
+	Convey("Given I have invalid configs", t, func() {
+
+		for _, tc := range []struct {
+			doc string
+			msg string
+		}{
+			{`services: [~]`, "service 0 is empty"},
+			{`services: [{name: a, identities: [cats]}]`, "invalid service 'a': no endpoints"},
+			{`services: [{identities: [cats], endpoints: [~]}]`, "invalid service '#0': endpoint 0 is empty"},
+			{`services: [{name: a, endpoints: [{address: nope}]}]`, "invalid service 'a': invalid endpoint address 'nope': address nope: missing port in address"},
+			{`services: [{name: a, endpoints: [{address: "a:1", weight: -1}]}]`, "invalid service 'a': invalid endpoint weight '-1': must be positive"},
+			{`services: [{name: a, prefix: a/b, endpoints: [{address: "a:1"}]}]`, "invalid service 'a': invalid prefix 'a/b': must not contain /"},
+			{`services: [{name: a, identities: [a/b], endpoints: [{address: "a:1"}]}]`, "invalid service 'a': invalid identity 'a/b'"},
+			{`services: [{name: a, paths: [static], endpoints: [{address: "a:1"}]}]`, "invalid service 'a': invalid path 'static': must start with /"},
+			{
+				`services: [{name: a, identities: [cats], endpoints: [{address: "a:1"}]}, {name: b, privateIdentities: [cats], endpoints: [{address: "a:1"}]}]`,
+				"invalid service 'b': identity '/cats' already served by service 'a'",
+			},
+			{
+				`services: [{name: a, paths: [/a], endpoints: [{address: "a:1"}]}, {name: b, paths: [/a], endpoints: [{address: "a:1"}]}]`,
+				"invalid service 'b': path '/a' already served by service 'a'",
+			},
+		} {
+			_, err := ParseConfig([]byte(tc.doc))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, tc.msg)
+		}
+
+		_, err := ParseConfig([]byte(`services: [{unknown: true}]`))
+		So(err, ShouldNotBeNil)
+
+		_, err = ParseConfig([]byte(`{{{`))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given I have the same identity in two prefixed services", t, func() {
+
+		_, err := ParseConfig([]byte(`services: [{prefix: a, identities: [cats], endpoints: [{address: "a:1"}]}, {identities: [cats], endpoints: [{address: "a:1"}]}]`))
+
+		Convey("Then err should be nil", func() {
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package static
+
+import (
+	"math/rand"
+	"time"
+)
+
+// A Randomizer reprensents an interface to randomize.
+// It must be safe for concurrent use by multiple goroutines.
+type Randomizer interface {
+	Intn(int) int
+}
+
+type defaultRandomizer struct{}
+
+func (defaultRandomizer) Intn(n int) int { return rand.Intn(n) }
+
+// An UpstreamerOption represents a configuration option
+// for the Upstreamer.
+type UpstreamerOption func(*upstreamConfig)
+
+type upstreamConfig struct {
+	randomizer        Randomizer
+	reloadInterval    time.Duration
+	latencyDecay      float64
+	exposePrivateAPIs bool
+}
+
+func newUpstreamConfig() upstreamConfig {
+	return upstreamConfig{
+		randomizer:     defaultRandomizer{},
+		reloadInterval: 5 * time.Second,
+		latencyDecay:   0.1,
+	}
+}
+
+// OptionUpstreamerExposePrivateAPIs configures the Upstreamer to expose
+// the private APIs.
+func OptionUpstreamerExposePrivateAPIs(enabled bool) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.exposePrivateAPIs = enabled
+	}
+}
+
+// OptionUpstreamerReloadInterval sets how often the Upstreamer
+// checks if its configuration file changed when it is started.
+// The default is 5s.
+func OptionUpstreamerReloadInterval(interval time.Duration) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.reloadInterval = interval
+		if cfg.reloadInterval <= 0 {
+			panic("interval cannot be <= 0")
+		}
+	}
+}
+
+// OptionUpstreamerLatencyDecay sets the weight given to a new latency
+// sample in the moving average of the latency of an endpoint. It must
+// be in ]0, 1]. The higher it is, the faster the Upstreamer reacts to
+// latency changes. The default is 0.1.
+func OptionUpstreamerLatencyDecay(decay float64) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.latencyDecay = decay
+		if cfg.latencyDecay <= 0 || cfg.latencyDecay > 1 {
+			panic("decay must be in ]0, 1]")
+		}
+	}
+}
+
+// OptionUpstreamerRandomizer set a custom Randomizer.
+func OptionUpstreamerRandomizer(randomizer Randomizer) UpstreamerOption {
+	return func(cfg *upstreamConfig) {
+		cfg.randomizer = randomizer
+	}
+}
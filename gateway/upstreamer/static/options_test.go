@@ -0,0 +1,40 @@
+package static
+
+import (
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_Options(t *testing.T) {
+
+	c := newUpstreamConfig()
+
+	Convey("Calling OptionUpstreamerExposePrivateAPIs should work", t, func() {
+		OptionUpstreamerExposePrivateAPIs(true)(&c)
+		So(c.exposePrivateAPIs, ShouldBeTrue)
+	})
+
+	Convey("Calling OptionUpstreamerReloadInterval should work", t, func() {
+		OptionUpstreamerReloadInterval(time.Hour)(&c)
+		So(c.reloadInterval, ShouldEqual, time.Hour)
+
+		So(func() { OptionUpstreamerReloadInterval(0)(&c) }, ShouldPanicWith, `interval cannot be <= 0`)
+	})
+
+	Convey("Calling OptionUpstreamerLatencyDecay should work", t, func() {
+		OptionUpstreamerLatencyDecay(0.5)(&c)
+		So(c.latencyDecay, ShouldEqual, 0.5)
+
+		So(func() { OptionUpstreamerLatencyDecay(0)(&c) }, ShouldPanicWith, `decay must be in ]0, 1]`)
+		So(func() { OptionUpstreamerLatencyDecay(1.1)(&c) }, ShouldPanicWith, `decay must be in ]0, 1]`)
+	})
+
+	Convey("Calling OptionUpstreamerRandomizer should work", t, func() {
+		r := fixedRandomizer(1)
+		OptionUpstreamerRandomizer(r)(&c)
+		So(c.randomizer, ShouldEqual, r)
+	})
+}
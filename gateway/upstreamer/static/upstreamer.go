@@ -0,0 +1,361 @@
+// Package static provides a gateway.Upstreamer routing the requests
+// according to a static configuration, usually loaded from a YAML or
+// JSON file, which allows to run a gateway without any pubsub.
+//
+// Example:
+//
+//	services:
+//	  - name: cats
+//	    identities: [cats, kittens]
+//	    privateIdentities: [litters]
+//	    endpoints:
+//	      - address: 10.0.0.1:443
+//	        weight: 2
+//	      - address: 10.0.0.2:443
+//
+//	  - name: assets
+//	    prefix: assets
+//	    identities: [images]
+//	    paths: [/static/]
+//	    endpoints:
+//	      - address: 10.0.1.1:443
+package static // import "go.aporeto.io/bahamut/gateway/upstreamer/static"
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.aporeto.io/bahamut/internal/filewatch"
+	"go.aporeto.io/bahamut/internal/routing"
+	"go.uber.org/zap"
+)
+
+type endpoint struct {
+	address string
+	weight  int
+}
+
+type route struct {
+	endpoints   []endpoint
+	totalWeight int
+}
+
+type pathRoute struct {
+	route *route
+	path  string
+}
+
+type routingTable struct {
+	identities map[string]*route
+	addresses  map[string]struct{}
+	paths      []pathRoute
+}
+
+type movingAverage struct {
+	value float64
+	lock  sync.Mutex
+}
+
+// An Upstreamer is a gateway.Upstreamer routing the requests
+// according to a Config. It also implements the
+// gateway.LatencyBasedUpstreamer interface.
+type Upstreamer struct {
+	table     *routingTable
+	watcher   *filewatch.Watcher
+	latencies sync.Map
+	path      string
+	config    upstreamConfig
+	lock      sync.RWMutex
+}
+
+// NewUpstreamer returns a new *Upstreamer routing the
+// requests according to the given Config.
+func NewUpstreamer(config *Config, options ...UpstreamerOption) (*Upstreamer, error) {
+
+	cfg := newUpstreamConfig()
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	u := &Upstreamer{config: cfg}
+
+	if err := u.Update(config); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// NewFileUpstreamer returns a new *Upstreamer routing the requests
+// according to the Config defined in the given YAML or JSON file.
+// The file is loaded immediately and an error is returned if it is
+// not valid. Call Watch to reload the file when it changes.
+func NewFileUpstreamer(path string, options ...UpstreamerOption) (*Upstreamer, error) {
+
+	cfg := newUpstreamConfig()
+	for _, opt := range options {
+		opt(&cfg)
+	}
+
+	u := &Upstreamer{
+		config: cfg,
+		path:   path,
+	}
+	u.watcher = filewatch.New(u.load, path)
+
+	if _, err := u.Reload(); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Update replaces the current Config. If the given
+// Config is not valid, the current one is kept.
+func (u *Upstreamer) Update(config *Config) error {
+
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	u.setTable(makeRoutingTable(config, u.config.exposePrivateAPIs))
+
+	return nil
+}
+
+// Reload replaces the current Config with the one of the file if the
+// file changed, and returns true if it did. A file that is not valid
+// or has no service, like an empty or truncated file read while it is
+// being written, is rejected and the current Config is kept.
+func (u *Upstreamer) Reload() (bool, error) {
+
+	if u.watcher == nil {
+		return false, fmt.Errorf("upstreamer has no file")
+	}
+
+	return u.watcher.Reload()
+}
+
+// Watch calls Reload at the interval set by OptionUpstreamerReloadInterval
+// until the given context is canceled.
+func (u *Upstreamer) Watch(ctx context.Context) {
+
+	filewatch.Watch(ctx, u.config.reloadInterval, u.Reload, func(err error) {
+		zap.L().Error("Unable to reload upstreamer config", zap.String("path", u.path), zap.Error(err))
+	})
+}
+
+func (u *Upstreamer) load(data [][]byte) error {
+
+	config, err := ParseConfig(data[0])
+	if err != nil {
+		return err
+	}
+
+	if len(config.Services) == 0 {
+		return fmt.Errorf("upstreamer config file has no services")
+	}
+
+	u.setTable(makeRoutingTable(config, u.config.exposePrivateAPIs))
+
+	zap.L().Debug("Upstreamer config loaded", zap.String("path", u.path), zap.Int("services", len(config.Services)))
+
+	return nil
+}
+
+// Upstream implements the gateway.Upstreamer interface.
+func (u *Upstreamer) Upstream(req *http.Request) (string, error) {
+
+	u.lock.RLock()
+	table := u.table
+	u.lock.RUnlock()
+
+	identity, prefix := routing.TargetIdentity(req.URL.Path)
+
+	if r, ok := table.identities[routeKey(prefix, identity)]; ok {
+
+		// we rewrite the request to trim the prefix out.
+		if prefix != "" {
+			req.URL.Path = strings.TrimPrefix(req.URL.Path, "/_"+prefix)
+			req.RequestURI = req.URL.String()
+		}
+
+		return u.pick(r), nil
+	}
+
+	for _, p := range table.paths {
+		if matchPath(req.URL.Path, p.path) {
+			return u.pick(p.route), nil
+		}
+	}
+
+	return "", nil
+}
+
+// CollectLatency implements the gateway.LatencyBasedUpstreamer
+// interface to keep a moving average of the latency of the endpoints.
+func (u *Upstreamer) CollectLatency(address string, responseTime time.Duration) {
+
+	u.lock.RLock()
+	_, known := u.table.addresses[address]
+	u.lock.RUnlock()
+
+	if !known {
+		return
+	}
+
+	sample := float64(responseTime.Microseconds())
+
+	v, loaded := u.latencies.LoadOrStore(address, &movingAverage{value: sample})
+	if !loaded {
+		return
+	}
+
+	ma := v.(*movingAverage)
+	ma.lock.Lock()
+	ma.value += u.config.latencyDecay * (sample - ma.value)
+	ma.lock.Unlock()
+}
+
+func (u *Upstreamer) latency(address string) (float64, bool) {
+
+	v, ok := u.latencies.Load(address)
+	if !ok {
+		return 0, false
+	}
+
+	ma := v.(*movingAverage)
+	ma.lock.Lock()
+	defer ma.lock.Unlock()
+
+	return ma.value, true
+}
+
+func (u *Upstreamer) setTable(table *routingTable) {
+
+	u.lock.Lock()
+	u.table = table
+	u.lock.Unlock()
+
+	// We forget about the latencies of
+	// the endpoints that are gone.
+	u.latencies.Range(func(address, _ any) bool {
+		if _, ok := table.addresses[address.(string)]; !ok {
+			u.latencies.Delete(address)
+		}
+		return true
+	})
+}
+
+// pick draws two endpoints according to their weights and, if the
+// latencies of both are known, returns one of them with a probability
+// inversely proportional to its latency. Otherwise it returns the first.
+func (u *Upstreamer) pick(r *route) string {
+
+	if len(r.endpoints) == 1 {
+		return r.endpoints[0].address
+	}
+
+	i := u.draw(r, -1)
+	j := u.draw(r, i)
+
+	li, oki := u.latency(r.endpoints[i].address)
+	lj, okj := u.latency(r.endpoints[j].address)
+
+	if !oki || !okj {
+		return r.endpoints[i].address
+	}
+
+	if float64(u.config.randomizer.Intn(int(li+lj)+1)) <= lj {
+		return r.endpoints[i].address
+	}
+
+	return r.endpoints[j].address
+}
+
+// draw returns the index of an endpoint drawn according
+// to the weights, excluding the endpoint at the given index.
+func (u *Upstreamer) draw(r *route, exclude int) int {
+
+	total := r.totalWeight
+	if exclude >= 0 {
+		total -= r.endpoints[exclude].weight
+	}
+
+	n := u.config.randomizer.Intn(total)
+
+	for k, ep := range r.endpoints {
+
+		if k == exclude {
+			continue
+		}
+
+		if n < ep.weight {
+			return k
+		}
+
+		n -= ep.weight
+	}
+
+	panic("draw: weights are inconsistent")
+}
+
+func makeRoutingTable(config *Config, includePrivate bool) *routingTable {
+
+	table := &routingTable{
+		identities: map[string]*route{},
+		addresses:  map[string]struct{}{},
+	}
+
+	for _, s := range config.Services {
+
+		r := &route{}
+		for _, ep := range s.Endpoints {
+
+			weight := ep.Weight
+			if weight == 0 {
+				weight = 1
+			}
+
+			r.endpoints = append(r.endpoints, endpoint{address: ep.Address, weight: weight})
+			r.totalWeight += weight
+			table.addresses[ep.Address] = struct{}{}
+		}
+
+		for _, identity := range s.Identities {
+			table.identities[routeKey(s.Prefix, identity)] = r
+		}
+
+		if includePrivate {
+			for _, identity := range s.PrivateIdentities {
+				table.identities[routeKey(s.Prefix, identity)] = r
+			}
+		}
+
+		for _, p := range s.Paths {
+			table.paths = append(table.paths, pathRoute{path: p, route: r})
+		}
+	}
+
+	sort.Slice(table.paths, func(i, j int) bool {
+		return len(table.paths[i].path) > len(table.paths[j].path)
+	})
+
+	return table
+}
+
+// matchPath returns true if the given path is the given
+// prefix, or is under it.
+func matchPath(path string, prefix string) bool {
+
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
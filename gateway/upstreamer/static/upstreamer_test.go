@@ -0,0 +1,339 @@
+package static
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/bahamut/gateway"
+)
+
+// fixedRandomizer always returns the same value, capped to n-1.
+type fixedRandomizer int
+
+func (r fixedRandomizer) Intn(n int) int {
+	if int(r) >= n {
+		return n - 1
+	}
+	return int(r)
+}
+
+func upstream(u *Upstreamer, path string) (string, string) {
+
+	req := &http.Request{URL: &url.URL{Path: path}}
+	upstream, err := u.Upstream(req)
+	So(err, ShouldBeNil)
+
+	return upstream, req.URL.Path
+}
+
+func TestUpstreamer(t *testing.T) {
+
+	var _ gateway.LatencyBasedUpstreamer = &Upstreamer{}
+
+	Convey("Given I have an upstreamer", t, func() {
+
+		c, err := ParseConfig([]byte(testConfigYAML))
+		So(err, ShouldBeNil)
+
+		u, err := NewUpstreamer(c)
+		So(err, ShouldBeNil)
+
+		Convey("Then the identities should be routed", func() {
+
+			up, _ := upstream(u, "/cats")
+			So(up, ShouldBeIn, []string{"10.0.0.1:443", "10.0.0.2:443"})
+
+			up, _ = upstream(u, "/v/1/cats/xxx/kittens")
+			So(up, ShouldBeIn, []string{"10.0.0.1:443", "10.0.0.2:443"})
+
+			up, _ = upstream(u, "/dogs")
+			So(up, ShouldBeEmpty)
+		})
+
+		Convey("Then the private identities should not be routed", func() {
+			up, _ := upstream(u, "/litters")
+			So(up, ShouldBeEmpty)
+		})
+
+		Convey("Then the prefixed identities should be routed and rewritten", func() {
+
+			up, path := upstream(u, "/_assets/images/xxx")
+			So(up, ShouldEqual, "10.0.1.1:443")
+			So(path, ShouldEqual, "/images/xxx")
+
+			up, _ = upstream(u, "/images")
+			So(up, ShouldBeEmpty)
+		})
+
+		Convey("Then the paths should be routed", func() {
+
+			up, path := upstream(u, "/static/a/b.png")
+			So(up, ShouldEqual, "10.0.1.1:443")
+			So(path, ShouldEqual, "/static/a/b.png")
+
+			up, _ = upstream(u, "/static")
+			So(up, ShouldEqual, "10.0.1.1:443")
+
+			up, _ = upstream(u, "/staticx")
+			So(up, ShouldBeEmpty)
+		})
+
+		Convey("When I update the config with an invalid one", func() {
+
+			err := u.Update(&Config{Services: []*Service{{Name: "a"}}})
+
+			Convey("Then the current config should be kept", func() {
+				So(err, ShouldNotBeNil)
+				up, _ := upstream(u, "/cats")
+				So(up, ShouldNotBeEmpty)
+			})
+		})
+
+		Convey("When I reload an upstreamer without file", func() {
+
+			_, err := u.Reload()
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given I have an upstreamer exposing private APIs", t, func() {
+
+		c, err := ParseConfig([]byte(testConfigYAML))
+		So(err, ShouldBeNil)
+
+		u, err := NewUpstreamer(c, OptionUpstreamerExposePrivateAPIs(true))
+		So(err, ShouldBeNil)
+
+		Convey("Then the private identities should be routed", func() {
+			up, _ := upstream(u, "/litters")
+			So(up, ShouldBeIn, []string{"10.0.0.1:443", "10.0.0.2:443"})
+		})
+	})
+}
+
+func TestUpstreamerWeights(t *testing.T) {
+
+	Convey("Given I have an upstreamer with weighted endpoints", t, func() {
+
+		c, err := ParseConfig([]byte(testConfigYAML))
+		So(err, ShouldBeNil)
+
+		u, err := NewUpstreamer(c)
+		So(err, ShouldBeNil)
+
+		Convey("When I get many upstreams without latencies", func() {
+
+			counts := map[string]int{}
+			for i := 0; i < 4000; i++ {
+				up, _ := upstream(u, "/cats")
+				counts[up]++
+			}
+
+			Convey("Then the distribution should follow the weights", func() {
+				So(counts["10.0.0.1:443"], ShouldBeBetween, 2700, 3300)
+				So(counts["10.0.0.2:443"], ShouldBeBetween, 700, 1300)
+			})
+		})
+
+		Convey("When I collect latencies", func() {
+
+			u.CollectLatency("10.0.0.1:443", 100*time.Millisecond)
+			u.CollectLatency("10.0.0.2:443", time.Millisecond)
+			u.CollectLatency("10.0.0.3:443", time.Millisecond)
+
+			counts := map[string]int{}
+			for i := 0; i < 4000; i++ {
+				up, _ := upstream(u, "/cats")
+				counts[up]++
+			}
+
+			Convey("Then the fastest endpoint should be preferred", func() {
+				So(counts["10.0.0.2:443"], ShouldBeGreaterThan, 3800)
+			})
+
+			Convey("Then unknown endpoints should be ignored", func() {
+				_, ok := u.latency("10.0.0.3:443")
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given I have an upstreamer with a fixed randomizer", t, func() {
+
+		u, err := NewUpstreamer(
+			&Config{
+				Services: []*Service{
+					{
+						Identities: []string{"cats"},
+						Endpoints: []*Endpoint{
+							{Address: "a:1", Weight: 2},
+							{Address: "b:1"},
+							{Address: "c:1", Weight: 3},
+						},
+					},
+				},
+			},
+			OptionUpstreamerRandomizer(fixedRandomizer(2)),
+			OptionUpstreamerLatencyDecay(1),
+		)
+		So(err, ShouldBeNil)
+
+		Convey("Then the endpoints should be drawn according to the weights", func() {
+			r := u.table.identities["/cats"]
+			So(r.totalWeight, ShouldEqual, 6)
+			So(u.draw(r, -1), ShouldEqual, 1)
+			So(u.draw(r, 1), ShouldEqual, 2)
+			So(u.draw(r, 2), ShouldEqual, 1)
+		})
+
+		Convey("When I collect latencies", func() {
+
+			u.CollectLatency("b:1", 10*time.Microsecond)
+			u.CollectLatency("b:1", 1*time.Microsecond)
+			u.CollectLatency("c:1", 5*time.Microsecond)
+
+			Convey("Then the last sample should be used", func() {
+				l, _ := u.latency("b:1")
+				So(l, ShouldEqual, 1)
+			})
+
+			Convey("Then the pick should be correct", func() {
+				up, _ := upstream(u, "/cats")
+				So(up, ShouldEqual, "b:1")
+			})
+		})
+
+		Convey("When I update the config", func() {
+
+			u.CollectLatency("b:1", time.Millisecond)
+			So(u.Update(&Config{Services: []*Service{{Identities: []string{"cats"}, Endpoints: []*Endpoint{{Address: "a:1"}}}}}), ShouldBeNil)
+
+			Convey("Then the latencies of the removed endpoints should be forgotten", func() {
+				_, ok := u.latency("b:1")
+				So(ok, ShouldBeFalse)
+				up, _ := upstream(u, "/cats")
+				So(up, ShouldEqual, "a:1")
+			})
+		})
+	})
+}
+
+func TestFileUpstreamer(t *testing.T) {
+
+	Convey("Given I have an upstreamer with a config file", t, func() {
+
+		path := filepath.Join(t.TempDir(), "upstreams.yaml")
+		So(os.WriteFile(path, []byte(`services: [{identities: [cats], endpoints: [{address: "a:1"}]}]`), 0600), ShouldBeNil)
+
+		u, err := NewFileUpstreamer(path, OptionUpstreamerReloadInterval(10*time.Millisecond))
+		So(err, ShouldBeNil)
+
+		up, _ := upstream(u, "/cats")
+		So(up, ShouldEqual, "a:1")
+
+		Convey("When the file does not change", func() {
+
+			changed, err := u.Reload()
+
+			Convey("Then the config should not be replaced", func() {
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the file is updated with an invalid config", func() {
+
+			So(os.WriteFile(path, []byte(`services: [{identities: [cats]}]`), 0600), ShouldBeNil)
+			changed, err := u.Reload()
+
+			Convey("Then the current config should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(changed, ShouldBeFalse)
+				up, _ := upstream(u, "/cats")
+				So(up, ShouldEqual, "a:1")
+			})
+		})
+
+		Convey("When the file is emptied", func() {
+
+			So(os.WriteFile(path, nil, 0600), ShouldBeNil)
+			changed, err := u.Reload()
+
+			Convey("Then the current config should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "upstreamer config file has no services")
+				So(changed, ShouldBeFalse)
+				up, _ := upstream(u, "/cats")
+				So(up, ShouldEqual, "a:1")
+			})
+		})
+
+		Convey("When the file is updated while watching", func() {
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				u.Watch(ctx)
+				close(done)
+			}()
+
+			So(os.WriteFile(path, []byte(`services: [{identities: [cats], endpoints: [{address: "b:1"}]}]`), 0600), ShouldBeNil)
+
+			var up string
+			for i := 0; i < 100; i++ {
+				if up, _ = upstream(u, "/cats"); up == "b:1" {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			cancel()
+			<-done
+
+			Convey("Then the new config should be used", func() {
+				So(up, ShouldEqual, "b:1")
+			})
+		})
+
+		Convey("When I create an upstreamer with a missing file", func() {
+
+			_, err := NewFileUpstreamer(path + ".nope")
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func Test_matchPath(t *testing.T) {
+
+	Convey("Given I have some paths", t, func() {
+
+		for _, tc := range []struct {
+			path   string
+			prefix string
+			match  bool
+		}{
+			{"/static", "/static", true},
+			{"/static/a", "/static", true},
+			{"/staticx", "/static", false},
+			{"/stat", "/static", false},
+			{"/static/a", "/static/", true},
+			{"/static", "/static/", false},
+			{"/anything", "/", true},
+		} {
+			So(matchPath(tc.path, tc.prefix), ShouldEqual, tc.match)
+		}
+	})
+}
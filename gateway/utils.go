@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -10,6 +11,44 @@ import (
 	"go.aporeto.io/bahamut"
 )
 
+type originalPathContextKey struct{}
+
+// withOriginalPath stores the path of the request before it is
+// routed, as the Upstreamer or the interceptors can rewrite it.
+func withOriginalPath(r *http.Request, path string) *http.Request {
+
+	return r.WithContext(context.WithValue(r.Context(), originalPathContextKey{}, path))
+}
+
+// originalPath returns the path of the request before it was
+// routed, or its current path if it was not stored.
+func originalPath(r *http.Request) string {
+
+	if path, ok := r.Context().Value(originalPathContextKey{}).(string); ok {
+		return path
+	}
+
+	return r.URL.Path
+}
+
+// isLoopbackAddress returns true if the given
+// host:port address only listens on a loopback interface.
+func isLoopbackAddress(address string) bool {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
 func injectGeneralHeader(h http.Header) http.Header {
 
 	h.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
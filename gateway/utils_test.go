@@ -279,6 +279,20 @@ func Test_makeProxyProtocolSourceChecker(t *testing.T) {
 	})
 }
 
+func Test_isLoopbackAddress(t *testing.T) {
+
+	Convey("Calling isLoopbackAddress should work", t, func() {
+		So(isLoopbackAddress("127.0.0.1:9999"), ShouldBeTrue)
+		So(isLoopbackAddress("[::1]:9999"), ShouldBeTrue)
+		So(isLoopbackAddress("localhost:9999"), ShouldBeTrue)
+		So(isLoopbackAddress(":9999"), ShouldBeFalse)
+		So(isLoopbackAddress("0.0.0.0:9999"), ShouldBeFalse)
+		So(isLoopbackAddress("10.0.0.1:9999"), ShouldBeFalse)
+		So(isLoopbackAddress("admin.local:9999"), ShouldBeFalse)
+		So(isLoopbackAddress("127.0.0.1"), ShouldBeFalse)
+	})
+}
+
 func TestMakeGoodByeServer(t *testing.T) {
 
 	Convey("Given I call makeGoodbyeServer", t, func() {
@@ -16,6 +16,7 @@ require (
 	github.com/cespare/xxhash v1.1.0
 	github.com/go-zoo/bone v1.3.0
 	github.com/gofrs/uuid v4.4.0+incompatible
+	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/golang/mock v1.6.0
 	github.com/gorilla/websocket v1.5.0
 	github.com/karlseguin/ccache/v2 v2.0.8
@@ -29,7 +30,9 @@ require (
 	github.com/valyala/tcplisten v1.0.0
 	github.com/vulcand/oxy/v2 v2.0.0-20221121151423-d5cb734e4467
 	go.uber.org/zap v1.24.0
+	golang.org/x/net v0.21.0
 	golang.org/x/time v0.3.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -67,7 +70,6 @@ require (
 	go.uber.org/atomic v1.10.0 // indirect
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/crypto v0.22.0 // indirect
-	golang.org/x/net v0.21.0 // indirect
 	golang.org/x/sys v0.19.0 // indirect
 	golang.org/x/term v0.19.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
@@ -147,6 +147,17 @@ func makeErrorResponse(ctx context.Context, response *elemental.Response, err er
 	return response
 }
 
+// disableOutputDataPushWithOutbox disables the automatic push of the
+// output data when a PushOutbox is configured. That push happens after the
+// processor returns, outside of its transaction, so the processor must add
+// the event to the outbox itself.
+func disableOutputDataPushWithOutbox(ctx *bcontext, cfg config) {
+
+	if cfg.pushServer.outbox != nil {
+		ctx.disableOutputDataPush = true
+	}
+}
+
 func runDispatcher(ctx *bcontext, r *elemental.Response, d func() error, disablePanicRecovery bool, marshallers map[elemental.Identity]CustomMarshaller, errorTransformer func(error) error) (out *elemental.Response) {
 
 	defer func() {
@@ -271,6 +282,8 @@ func handleCreate(ctx *bcontext, cfg config, processorFinder processorFinderFunc
 		)
 	}
 
+	disableOutputDataPushWithOutbox(ctx, cfg)
+
 	return runDispatcher(
 		ctx,
 		response,
@@ -280,6 +293,8 @@ func handleCreate(ctx *bcontext, cfg config, processorFinder processorFinderFunc
 				processorFinder,
 				cfg.model.modelManagers[ctx.request.Version],
 				cfg.model.unmarshallers[ctx.request.Identity],
+				cfg.model.validators[ctx.request.Identity],
+				cfg.model.strictDecoding,
 				cfg.security.requestAuthenticators,
 				cfg.security.authorizers,
 				pusherFunc,
@@ -317,6 +332,8 @@ func handleUpdate(ctx *bcontext, cfg config, processorFinder processorFinderFunc
 		)
 	}
 
+	disableOutputDataPushWithOutbox(ctx, cfg)
+
 	return runDispatcher(
 		ctx,
 		response,
@@ -326,6 +343,8 @@ func handleUpdate(ctx *bcontext, cfg config, processorFinder processorFinderFunc
 				processorFinder,
 				cfg.model.modelManagers[ctx.request.Version],
 				cfg.model.unmarshallers[ctx.request.Identity],
+				cfg.model.validators[ctx.request.Identity],
+				cfg.model.strictDecoding,
 				cfg.security.requestAuthenticators,
 				cfg.security.authorizers,
 				pusherFunc,
@@ -363,6 +382,8 @@ func handleDelete(ctx *bcontext, cfg config, processorFinder processorFinderFunc
 		)
 	}
 
+	disableOutputDataPushWithOutbox(ctx, cfg)
+
 	return runDispatcher(
 		ctx,
 		response,
@@ -449,6 +470,8 @@ func handlePatch(ctx *bcontext, cfg config, processorFinder processorFinderFunc,
 		)
 	}
 
+	disableOutputDataPushWithOutbox(ctx, cfg)
+
 	return runDispatcher(
 		ctx,
 		response,
@@ -458,6 +481,8 @@ func handlePatch(ctx *bcontext, cfg config, processorFinder processorFinderFunc,
 				processorFinder,
 				cfg.model.modelManagers[ctx.request.Version],
 				cfg.model.unmarshallers[ctx.request.Identity],
+				cfg.model.validators[ctx.request.Identity],
+				cfg.model.strictDecoding,
 				cfg.security.requestAuthenticators,
 				cfg.security.authorizers,
 				pusherFunc,
@@ -92,6 +92,34 @@ type Server interface {
 	Run(context.Context)
 }
 
+// An OpenAPIProvider is a Server that can describe its api with
+// OpenAPI 3 documents. The Server returned by New implements it.
+type OpenAPIProvider interface {
+
+	// OpenAPI returns the OpenAPI 3 document of
+	// each version of the api.
+	OpenAPI() map[int]*OpenAPIDocument
+
+	Server
+}
+
+// An HTTP3Server is a server serving HTTP/3 over QUIC.
+// For instance, *http3.Server from github.com/quic-go/quic-go/http3
+// satisfies this interface.
+type HTTP3Server interface {
+
+	// ListenAndServe listens on the configured UDP address
+	// and serves the requests until the server is closed.
+	ListenAndServe() error
+
+	// Close immediately closes the server.
+	Close() error
+}
+
+// An HTTP3ServerMaker returns an HTTP3Server listening on the given
+// address, using the given *tls.Config and serving the given http.Handler.
+type HTTP3ServerMaker func(address string, tlsConfig *tls.Config, handler http.Handler) (HTTP3Server, error)
+
 // A ResponseWriter is a function you can use in
 // the Context to handle the writing of the response by
 // yourself. You are responsible for the full handling of the response,
@@ -127,6 +155,7 @@ type Context interface {
 
 	// SetDisableOutputDataPush will instruct the bahamut server to
 	// not automatically push the content of OutputData.
+	// It is always disabled when a PushOutbox is configured.
 	SetDisableOutputDataPush(bool)
 
 	// SetResponseWriter sets the ResponseWriter function to use to write the response back to the client.
@@ -0,0 +1,105 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filewatch loads a set of files again when their content
+// changes. It is shared by the components reloading their
+// configuration from disk, like the TLSReloader, the file based
+// authorizers and the static upstreamer.
+package filewatch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// A LoadFunc loads the content of the watched files, given in the
+// order of their paths. It returns an error if the content is not
+// valid, in which case the previous content must be kept.
+type LoadFunc func(data [][]byte) error
+
+// A Watcher calls a LoadFunc with the content of a set of files
+// when it changes.
+type Watcher struct {
+	load        LoadFunc
+	paths       []string
+	fingerprint [sha256.Size]byte
+	loaded      bool
+	lock        sync.Mutex
+}
+
+// New returns a new Watcher calling the given LoadFunc
+// with the content of the files at the given paths.
+func New(load LoadFunc, paths ...string) *Watcher {
+
+	return &Watcher{
+		load:  load,
+		paths: paths,
+	}
+}
+
+// Reload reads the files and calls the LoadFunc if their content
+// changed since its last successful call. It returns true if the
+// LoadFunc has been called and succeeded. Concurrent calls are
+// serialized.
+func (w *Watcher) Reload() (bool, error) {
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	data := make([][]byte, len(w.paths))
+	for i, path := range w.paths {
+
+		var err error
+		if data[i], err = os.ReadFile(path); err != nil {
+			return false, fmt.Errorf("unable to read file: %w", err)
+		}
+	}
+
+	fingerprint := sha256.Sum256(bytes.Join(data, []byte{0}))
+	if w.loaded && w.fingerprint == fingerprint {
+		return false, nil
+	}
+
+	if err := w.load(data); err != nil {
+		return false, err
+	}
+
+	w.fingerprint = fingerprint
+	w.loaded = true
+
+	return true, nil
+}
+
+// Watch calls reload at the given interval until the given context
+// is canceled. The errors returned by reload are passed to onError.
+func Watch(ctx context.Context, interval time.Duration, reload func() (bool, error), onError func(error)) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+
+		case <-ticker.C:
+			if _, err := reload(); err != nil {
+				onError(err)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
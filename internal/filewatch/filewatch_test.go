@@ -0,0 +1,159 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filewatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWatcher(t *testing.T) {
+
+	Convey("Given I have a watcher on two files", t, func() {
+
+		dir := t.TempDir()
+		pathA := filepath.Join(dir, "a")
+		pathB := filepath.Join(dir, "b")
+		So(os.WriteFile(pathA, []byte("a1"), 0600), ShouldBeNil)
+		So(os.WriteFile(pathB, []byte("b1"), 0600), ShouldBeNil)
+
+		var loaded []string
+		var loadErr error
+
+		w := New(
+			func(data [][]byte) error {
+				if loadErr != nil {
+					return loadErr
+				}
+				loaded = []string{string(data[0]), string(data[1])}
+				return nil
+			},
+			pathA,
+			pathB,
+		)
+
+		changed, err := w.Reload()
+		So(err, ShouldBeNil)
+		So(changed, ShouldBeTrue)
+		So(loaded, ShouldResemble, []string{"a1", "b1"})
+
+		Convey("When the files do not change", func() {
+
+			loaded = nil
+			changed, err := w.Reload()
+
+			Convey("Then the load function should not be called", func() {
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeFalse)
+				So(loaded, ShouldBeNil)
+			})
+		})
+
+		Convey("When one of the files changes", func() {
+
+			So(os.WriteFile(pathB, []byte("b2"), 0600), ShouldBeNil)
+			changed, err := w.Reload()
+
+			Convey("Then the load function should be called with all the files", func() {
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeTrue)
+				So(loaded, ShouldResemble, []string{"a1", "b2"})
+			})
+		})
+
+		Convey("When the load function rejects the new content", func() {
+
+			So(os.WriteFile(pathA, []byte("a2"), 0600), ShouldBeNil)
+			loadErr = fmt.Errorf("boom")
+			changed, err := w.Reload()
+
+			Convey("Then the error should be returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "boom")
+				So(changed, ShouldBeFalse)
+			})
+
+			Convey("Then the content should be loaded again once accepted", func() {
+				loadErr = nil
+				changed, err := w.Reload()
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeTrue)
+				So(loaded, ShouldResemble, []string{"a2", "b1"})
+			})
+		})
+
+		Convey("When a file is missing", func() {
+
+			So(os.Remove(pathA), ShouldBeNil)
+			changed, err := w.Reload()
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "unable to read file: ")
+				So(changed, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestWatch(t *testing.T) {
+
+	Convey("Given I watch a failing reload function", t, func() {
+
+		var calls atomic.Int64
+		errs := make(chan error, 10)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		go func() {
+			Watch(
+				ctx,
+				time.Millisecond,
+				func() (bool, error) {
+					calls.Add(1)
+					return false, fmt.Errorf("boom")
+				},
+				func(err error) {
+					select {
+					case errs <- err:
+					default:
+					}
+				},
+			)
+			close(done)
+		}()
+
+		var err error
+		select {
+		case err = <-errs:
+		case <-time.After(2 * time.Second):
+		}
+
+		cancel()
+		<-done
+
+		Convey("Then the errors should be reported until the context is canceled", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "boom")
+			So(calls.Load(), ShouldBeGreaterThan, 0)
+		})
+	})
+}
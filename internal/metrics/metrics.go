@@ -0,0 +1,45 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics contains the helpers shared by
+// the components exposing prometheus metrics.
+package metrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterCollector registers the given collector using the given
+// prometheus.Registerer. If an identical collector of the same type
+// is already registered, like when several components share the same
+// registerer, the existing collector is returned so they share the
+// metric.
+func RegisterCollector[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+
+	if err := registerer.Register(collector); err != nil {
+
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return collector, err
+		}
+
+		existing, ok := are.ExistingCollector.(T)
+		if !ok {
+			return collector, err
+		}
+
+		return existing, nil
+	}
+
+	return collector, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegisterCollector(t *testing.T) {
+
+	makeCounter := func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "things_total", Help: "Things."}, []string{"result"})
+	}
+
+	Convey("Given I have a registry", t, func() {
+
+		registry := prometheus.NewRegistry()
+
+		first, err := RegisterCollector(registry, makeCounter())
+		So(err, ShouldBeNil)
+
+		Convey("When I register the same collector again", func() {
+
+			second, err := RegisterCollector(registry, makeCounter())
+
+			Convey("Then the existing collector should be returned", func() {
+				So(err, ShouldBeNil)
+				So(second, ShouldEqual, first)
+			})
+		})
+
+		Convey("When I register a collector with the same name and another type", func() {
+
+			_, err := RegisterCollector(registry, prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "things_total", Help: "Things."}, []string{"result"}))
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When I register a collector with the same name and other labels", func() {
+
+			_, err := RegisterCollector(registry, prometheus.NewCounterVec(prometheus.CounterOpts{Name: "things_total", Help: "Things."}, []string{"kind"}))
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
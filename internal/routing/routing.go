@@ -0,0 +1,56 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package routing contains the helpers shared by the
+// upstreamers to find the target of the requests.
+package routing
+
+import (
+	"regexp"
+	"strings"
+)
+
+var vregexp = regexp.MustCompile(`/v/\d+`)
+
+// TrimVersion removes the api version, like /v/1,
+// from the given path.
+func TrimVersion(path string) string {
+
+	return vregexp.ReplaceAllString(path, "")
+}
+
+// TargetIdentity returns the category of the identity targeted by the
+// given path, and the prefix of the service, like prefix for /_prefix/users.
+func TargetIdentity(path string) (identity string, prefix string) {
+
+	parts := strings.Split(
+		strings.TrimPrefix(
+			TrimVersion(path),
+			"/",
+		),
+		"/",
+	)
+
+	if len(parts) > 1 && len(parts[0]) > 1 && parts[0][0] == '_' {
+		prefix = parts[0][1:]
+		parts = append([]string{}, parts[1:]...)
+	}
+
+	switch len(parts) {
+
+	case 1:
+		return parts[0], prefix
+	case 2:
+		return parts[0], prefix
+	default:
+		return parts[2], prefix
+	}
+}
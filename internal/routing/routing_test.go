@@ -0,0 +1,161 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"testing"
+)
+
+func TestTargetIdentity(t *testing.T) {
+	type args struct {
+		path string
+	}
+	tests := []struct {
+		name         string
+		args         args
+		wantIdentity string
+		wantPrefix   string
+	}{
+		{
+			"/",
+			args{
+				"/",
+			},
+			"",
+			"",
+		},
+		{
+			"/users",
+			args{
+				"/users",
+			},
+			"users",
+			"",
+		},
+		{
+			"/users/id",
+			args{
+				"/users/id",
+			},
+			"users",
+			"",
+		},
+		{
+			"/users/id/groups",
+			args{
+				"/users/id/groups",
+			},
+			"groups",
+			"",
+		},
+		{
+			"/v/1/users",
+			args{
+				"/v/1/users",
+			},
+			"users",
+			"",
+		},
+		{
+			"/v/1/users/id",
+			args{
+				"/v/1/users/id",
+			},
+			"users",
+			"",
+		},
+		{
+			"/v/1/users/id/groups",
+			args{
+				"/v/1/users/id/groups",
+			},
+			"groups",
+			"",
+		},
+		{
+			"//users",
+			args{
+				"//users",
+			},
+			"",
+			"",
+		},
+		// prefixed
+		{
+			"_prefix/",
+			args{
+				"_prefix/",
+			},
+			"",
+			"prefix",
+		},
+		{
+			"_prefix/users",
+			args{
+				"_prefix/users",
+			},
+			"users",
+			"prefix",
+		},
+		{
+			"_prefix/users/id",
+			args{
+				"_prefix/users/id",
+			},
+			"users",
+			"prefix",
+		},
+		{
+			"_prefix/users/id/groups",
+			args{
+				"_prefix/users/id/groups",
+			},
+			"groups",
+			"prefix",
+		},
+		{
+			"_prefix/v/1/users",
+			args{
+				"_prefix/v/1/users",
+			},
+			"users",
+			"prefix",
+		},
+		{
+			"_prefix/v/1/users/id",
+			args{
+				"_prefix/v/1/users/id",
+			},
+			"users",
+			"prefix",
+		},
+		{
+			"_prefix/v/1/users/id/groups",
+			args{
+				"_prefix/v/1/users/id/groups",
+			},
+			"groups",
+			"prefix",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, prefix := TargetIdentity(tt.args.path)
+			if identity != tt.wantIdentity {
+				t.Errorf("TargetIdentity() identity = %v, want %v", identity, tt.wantIdentity)
+			}
+			if prefix != tt.wantPrefix {
+				t.Errorf("TargetIdentity() prefix = %v, want %v", prefix, tt.wantPrefix)
+			}
+		})
+	}
+}
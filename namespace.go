@@ -0,0 +1,150 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.aporeto.io/elemental"
+)
+
+// IsNamespaceVisible returns true if the given namespace is visible from
+// the given root namespace. A namespace is visible if it is the root itself
+// or, when recursive is true, one of its descendants. For instance /a/b is
+// visible from /a in recursive mode, but /ab is not. An empty namespace is
+// considered to be /.
+func IsNamespaceVisible(namespace string, root string, recursive bool) bool {
+
+	namespace = normalizeNamespace(namespace)
+	root = normalizeNamespace(root)
+
+	if namespace == root {
+		return true
+	}
+
+	if !recursive {
+		return false
+	}
+
+	if root == "/" {
+		return true
+	}
+
+	return strings.HasPrefix(namespace, root+"/")
+}
+
+// NamespaceRootsFromClaims returns the values of all the
+// claims with the given key, like @auth:namespace.
+func NamespaceRootsFromClaims(claims []string, key string) []string {
+
+	var roots []string
+
+	for _, claim := range claims {
+		if k, v, ok := strings.Cut(claim, "="); ok && k == key && v != "" {
+			roots = append(roots, v)
+		}
+	}
+
+	return roots
+}
+
+// A NamespaceMatcher matches namespaces against a set of root namespaces.
+type NamespaceMatcher struct {
+	roots     []string
+	recursive bool
+}
+
+// NewNamespaceMatcher returns a new NamespaceMatcher
+// matching the namespaces visible from the given roots.
+func NewNamespaceMatcher(roots []string, recursive bool) NamespaceMatcher {
+
+	return NamespaceMatcher{
+		roots:     roots,
+		recursive: recursive,
+	}
+}
+
+// Match returns true if the given namespace is visible from at
+// least one of the roots. See IsNamespaceVisible for details.
+func (m NamespaceMatcher) Match(namespace string) bool {
+
+	for _, root := range m.roots {
+		if IsNamespaceVisible(namespace, root, m.recursive) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeNamespace(namespace string) string {
+
+	if namespace = strings.TrimRight(namespace, "/"); namespace == "" {
+		return "/"
+	}
+
+	return namespace
+}
+
+// namespaceEnforcer checks the namespace of the requests and
+// events against the namespace roots given by the claims.
+type namespaceEnforcer struct {
+	excludedIdentities map[string]struct{}
+	claimKey           string
+	recursive          bool
+}
+
+func (e namespaceEnforcer) matcher(claims []string) NamespaceMatcher {
+
+	return NewNamespaceMatcher(NamespaceRootsFromClaims(claims, e.claimKey), e.recursive)
+}
+
+// IsAuthorized implements the Authorizer interface. It never grants the
+// access by itself: it returns an error if the namespace of the request
+// is not visible from the claims, and AuthActionContinue otherwise.
+// Requests on excluded identities are not checked.
+func (e namespaceEnforcer) IsAuthorized(ctx Context) (AuthAction, error) {
+
+	if _, ok := e.excludedIdentities[ctx.Request().Identity.Name]; ok {
+		return AuthActionContinue, nil
+	}
+
+	namespace := ctx.Request().Namespace
+
+	if !e.matcher(ctx.Claims()).Match(namespace) {
+		return AuthActionKO, elemental.NewError(
+			"Forbidden",
+			fmt.Sprintf("You are not allowed to access namespace %s.", normalizeNamespace(namespace)),
+			"bahamut",
+			http.StatusForbidden,
+		)
+	}
+
+	return AuthActionContinue, nil
+}
+
+// eventNamespace returns the namespace of the entity of the given event,
+// or an empty string if the entity has no namespace.
+func eventNamespace(event *elemental.Event) (string, error) {
+
+	entity := struct {
+		Namespace string `msgpack:"namespace" json:"namespace"`
+	}{}
+
+	if err := event.Decode(&entity); err != nil {
+		return "", err
+	}
+
+	return entity.Namespace, nil
+}
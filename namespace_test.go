@@ -0,0 +1,184 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+func TestNamespace_IsNamespaceVisible(t *testing.T) {
+
+	Convey("Given I have some namespaces", t, func() {
+
+		for _, tc := range []struct {
+			namespace string
+			root      string
+			recursive bool
+			visible   bool
+		}{
+			{"/a", "/a", false, true},
+			{"/a/", "/a", false, true},
+			{"/a/b", "/a", false, false},
+			{"/a/b", "/a", true, true},
+			{"/a/b/c", "/a/", true, true},
+			{"/ab", "/a", true, false},
+			{"/a", "/a/b", true, false},
+			{"/", "/", false, true},
+			{"", "/", false, true},
+			{"/a", "/", false, false},
+			{"/a", "/", true, true},
+			{"/a", "", true, true},
+		} {
+			So(IsNamespaceVisible(tc.namespace, tc.root, tc.recursive), ShouldEqual, tc.visible)
+		}
+	})
+}
+
+func TestNamespace_NamespaceMatcher(t *testing.T) {
+
+	Convey("Given I have a matcher built from claims", t, func() {
+
+		roots := NamespaceRootsFromClaims(
+			[]string{"@auth:namespace=/a", "@auth:namespace=/b/c", "@auth:namespace=", "@auth:subject=/d", "invalid"},
+			"@auth:namespace",
+		)
+
+		m := NewNamespaceMatcher(roots, true)
+
+		Convey("Then it should be correct", func() {
+			So(roots, ShouldResemble, []string{"/a", "/b/c"})
+			So(m.Match("/a/x"), ShouldBeTrue)
+			So(m.Match("/b/c"), ShouldBeTrue)
+			So(m.Match("/b"), ShouldBeFalse)
+			So(m.Match("/d"), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given I have a matcher without roots", t, func() {
+
+		m := NewNamespaceMatcher(nil, true)
+
+		Convey("Then nothing should match", func() {
+			So(m.Match("/"), ShouldBeFalse)
+			So(m.Match("/a"), ShouldBeFalse)
+		})
+	})
+}
+
+func TestNamespace_namespaceEnforcer(t *testing.T) {
+
+	Convey("Given I have a namespace enforcer", t, func() {
+
+		e := namespaceEnforcer{claimKey: "@auth:namespace", recursive: true}
+
+		makeCtx := func(ns string, claims ...string) Context {
+			ctx := NewMockContext(context.Background())
+			ctx.MockRequest = elemental.NewRequest()
+			ctx.MockRequest.Namespace = ns
+			ctx.MockClaims = claims
+			return ctx
+		}
+
+		Convey("When I authorize a request in a visible namespace", func() {
+
+			action, err := e.IsAuthorized(makeCtx("/a/b", "@auth:namespace=/a"))
+
+			Convey("Then it should continue", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, AuthActionContinue)
+			})
+		})
+
+		Convey("When I authorize a request in another namespace", func() {
+
+			action, err := e.IsAuthorized(makeCtx("/ab", "@auth:namespace=/a"))
+
+			Convey("Then it should be rejected", func() {
+				So(err, ShouldNotBeNil)
+				So(err.(elemental.Error).Description, ShouldEqual, "You are not allowed to access namespace /ab.")
+				So(err.(elemental.Error).Code, ShouldEqual, http.StatusForbidden)
+				So(action, ShouldEqual, AuthActionKO)
+			})
+		})
+
+		Convey("When I authorize a request without namespace claim", func() {
+
+			action, err := e.IsAuthorized(makeCtx(""))
+
+			Convey("Then it should be rejected", func() {
+				So(err, ShouldNotBeNil)
+				So(err.(elemental.Error).Description, ShouldEqual, "You are not allowed to access namespace /.")
+				So(action, ShouldEqual, AuthActionKO)
+			})
+		})
+
+		Convey("When I authorize a request without namespace claim on an excluded identity", func() {
+
+			e.excludedIdentities = map[string]struct{}{testmodel.UserIdentity.Name: {}}
+
+			ctx := makeCtx("")
+			ctx.(*MockContext).MockRequest.Identity = testmodel.UserIdentity
+
+			action, err := e.IsAuthorized(ctx)
+
+			Convey("Then it should continue", func() {
+				So(err, ShouldBeNil)
+				So(action, ShouldEqual, AuthActionContinue)
+			})
+		})
+	})
+}
+
+func TestNamespace_eventNamespace(t *testing.T) {
+
+	Convey("Given I have an event on a namespaced entity", t, func() {
+
+		event := &elemental.Event{
+			RawData:  []byte(`{"ID":"x","namespace":"/a/b"}`),
+			Encoding: elemental.EncodingTypeJSON,
+		}
+
+		Convey("When I extract the namespace", func() {
+
+			ns, err := eventNamespace(event)
+
+			Convey("Then it should be correct", func() {
+				So(err, ShouldBeNil)
+				So(ns, ShouldEqual, "/a/b")
+			})
+		})
+	})
+
+	Convey("Given I have an event with invalid data", t, func() {
+
+		event := &elemental.Event{
+			RawData:  []byte(`not json`),
+			Encoding: elemental.EncodingTypeJSON,
+		}
+
+		Convey("When I extract the namespace", func() {
+
+			_, err := eventNamespace(event)
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
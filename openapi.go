@@ -0,0 +1,577 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.aporeto.io/elemental"
+)
+
+const openAPIVersion = "3.0.3"
+
+// An OpenAPIDocument is an OpenAPI 3 document describing
+// the API of a single version of a bahamut server.
+type OpenAPIDocument struct {
+	Paths      map[string]*OpenAPIPathItem `msgpack:"paths" json:"paths"`
+	Components OpenAPIComponents           `msgpack:"components" json:"components"`
+	Info       OpenAPIInfo                 `msgpack:"info" json:"info"`
+	OpenAPI    string                      `msgpack:"openapi" json:"openapi"`
+	Servers    []OpenAPIServer             `msgpack:"servers,omitempty" json:"servers,omitempty"`
+}
+
+// OpenAPIInfo contains the metadata of an OpenAPIDocument.
+type OpenAPIInfo struct {
+	Title   string `msgpack:"title" json:"title"`
+	Version string `msgpack:"version" json:"version"`
+}
+
+// OpenAPIServer describes a server serving the API.
+type OpenAPIServer struct {
+	URL string `msgpack:"url" json:"url"`
+}
+
+// OpenAPIComponents holds the reusable objects of an OpenAPIDocument.
+type OpenAPIComponents struct {
+	Schemas    map[string]*OpenAPISchema    `msgpack:"schemas,omitempty" json:"schemas,omitempty"`
+	Parameters map[string]*OpenAPIParameter `msgpack:"parameters,omitempty" json:"parameters,omitempty"`
+	Responses  map[string]*OpenAPIResponse  `msgpack:"responses,omitempty" json:"responses,omitempty"`
+}
+
+// OpenAPIPathItem describes the operations available on a single path.
+type OpenAPIPathItem struct {
+	Get        *OpenAPIOperation   `msgpack:"get,omitempty" json:"get,omitempty"`
+	Put        *OpenAPIOperation   `msgpack:"put,omitempty" json:"put,omitempty"`
+	Post       *OpenAPIOperation   `msgpack:"post,omitempty" json:"post,omitempty"`
+	Delete     *OpenAPIOperation   `msgpack:"delete,omitempty" json:"delete,omitempty"`
+	Patch      *OpenAPIOperation   `msgpack:"patch,omitempty" json:"patch,omitempty"`
+	Head       *OpenAPIOperation   `msgpack:"head,omitempty" json:"head,omitempty"`
+	Parameters []*OpenAPIParameter `msgpack:"parameters,omitempty" json:"parameters,omitempty"`
+}
+
+// OpenAPIOperation describes a single API operation on a path.
+type OpenAPIOperation struct {
+	RequestBody *OpenAPIRequestBody         `msgpack:"requestBody,omitempty" json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `msgpack:"responses" json:"responses"`
+	OperationID string                      `msgpack:"operationId" json:"operationId"`
+	Summary     string                      `msgpack:"summary,omitempty" json:"summary,omitempty"`
+	Tags        []string                    `msgpack:"tags,omitempty" json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `msgpack:"parameters,omitempty" json:"parameters,omitempty"`
+	Deprecated  bool                        `msgpack:"deprecated,omitempty" json:"deprecated,omitempty"`
+}
+
+// OpenAPIParameter describes a single operation parameter.
+type OpenAPIParameter struct {
+	Schema      *OpenAPISchema `msgpack:"schema,omitempty" json:"schema,omitempty"`
+	Ref         string         `msgpack:"$ref,omitempty" json:"$ref,omitempty"`
+	Name        string         `msgpack:"name,omitempty" json:"name,omitempty"`
+	In          string         `msgpack:"in,omitempty" json:"in,omitempty"`
+	Description string         `msgpack:"description,omitempty" json:"description,omitempty"`
+	Required    bool           `msgpack:"required,omitempty" json:"required,omitempty"`
+}
+
+// OpenAPIRequestBody describes the body of a request.
+type OpenAPIRequestBody struct {
+	Content  map[string]*OpenAPIMediaType `msgpack:"content" json:"content"`
+	Required bool                         `msgpack:"required,omitempty" json:"required,omitempty"`
+}
+
+// OpenAPIResponse describes a single response of an operation.
+type OpenAPIResponse struct {
+	Headers     map[string]*OpenAPIHeader    `msgpack:"headers,omitempty" json:"headers,omitempty"`
+	Content     map[string]*OpenAPIMediaType `msgpack:"content,omitempty" json:"content,omitempty"`
+	Ref         string                       `msgpack:"$ref,omitempty" json:"$ref,omitempty"`
+	Description string                       `msgpack:"description,omitempty" json:"description,omitempty"`
+}
+
+// OpenAPIHeader describes a header returned in a response.
+type OpenAPIHeader struct {
+	Schema      *OpenAPISchema `msgpack:"schema,omitempty" json:"schema,omitempty"`
+	Description string         `msgpack:"description,omitempty" json:"description,omitempty"`
+}
+
+// OpenAPIMediaType describes the content of a body.
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `msgpack:"schema,omitempty" json:"schema,omitempty"`
+}
+
+// OpenAPISchema is the subset of the OpenAPI schema object
+// needed to describe elemental models.
+type OpenAPISchema struct {
+	Default              any                       `msgpack:"default,omitempty" json:"default,omitempty"`
+	Items                *OpenAPISchema            `msgpack:"items,omitempty" json:"items,omitempty"`
+	AdditionalProperties *OpenAPISchema            `msgpack:"additionalProperties,omitempty" json:"additionalProperties,omitempty"`
+	Properties           map[string]*OpenAPISchema `msgpack:"properties,omitempty" json:"properties,omitempty"`
+	Ref                  string                    `msgpack:"$ref,omitempty" json:"$ref,omitempty"`
+	Type                 string                    `msgpack:"type,omitempty" json:"type,omitempty"`
+	Format               string                    `msgpack:"format,omitempty" json:"format,omitempty"`
+	Description          string                    `msgpack:"description,omitempty" json:"description,omitempty"`
+	Enum                 []string                  `msgpack:"enum,omitempty" json:"enum,omitempty"`
+	Required             []string                  `msgpack:"required,omitempty" json:"required,omitempty"`
+	ReadOnly             bool                      `msgpack:"readOnly,omitempty" json:"readOnly,omitempty"`
+	Deprecated           bool                      `msgpack:"deprecated,omitempty" json:"deprecated,omitempty"`
+}
+
+func buildVersionedOpenAPI(modelManagers map[int]elemental.ModelManager, processorFinder processorFinderFunc, info OpenAPIInfo, apiPrefix string) map[int]*OpenAPIDocument {
+
+	docs := make(map[int]*OpenAPIDocument, len(modelManagers))
+
+	for version, modelManager := range modelManagers {
+		docs[version] = buildOpenAPI(modelManager, processorFinder, info, path.Join("/", apiPrefix, "v", strconv.Itoa(version)))
+	}
+
+	return docs
+}
+
+// buildOpenAPI builds the OpenAPI document for the given model manager.
+// Only the operations that are declared in the relationships and implemented
+// by the registered processor are documented.
+func buildOpenAPI(modelManager elemental.ModelManager, processorFinder processorFinderFunc, info OpenAPIInfo, serverURL string) *OpenAPIDocument {
+
+	doc := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    info,
+		Servers: []OpenAPIServer{{URL: serverURL}},
+		Paths:   map[string]*OpenAPIPathItem{},
+		Components: OpenAPIComponents{
+			Schemas:    map[string]*OpenAPISchema{"Error": openAPIErrorSchema()},
+			Parameters: openAPICommonParameters(),
+			Responses: map[string]*OpenAPIResponse{
+				"Error": {
+					Description: "An error occurred.",
+					Content:     openAPIJSONContent(&OpenAPISchema{Type: "array", Items: &OpenAPISchema{Ref: "#/components/schemas/Error"}}),
+				},
+			},
+		},
+	}
+
+	pathItem := func(url string) *OpenAPIPathItem {
+
+		item, ok := doc.Paths[url]
+		if !ok {
+			item = &OpenAPIPathItem{}
+			if strings.Contains(url, "{id}") {
+				item.Parameters = []*OpenAPIParameter{
+					{Name: "id", In: "path", Required: true, Description: "The identifier of the object.", Schema: &OpenAPISchema{Type: "string"}},
+				}
+			}
+			doc.Paths[url] = item
+		}
+
+		return item
+	}
+
+	for identity, relationship := range modelManager.Relationships() {
+
+		// If we don't have a processor registered for the given model, we skip.
+		processor, err := processorFinder(identity)
+		if err != nil {
+			continue
+		}
+
+		schema := openAPIModelSchema(modelManager, modelManager.Identifiable(identity), doc.Components.Schemas)
+		if schema == nil {
+			schema = &OpenAPISchema{Type: "object"}
+		}
+
+		tags := []string{identity.Category}
+
+		if _, ok := processor.(RetrieveManyProcessor); ok {
+			for parent, ri := range relationship.RetrieveMany {
+				url, suffix := openAPIParentURL(modelManager, identity, parent)
+				op := newOpenAPIOperation("retrieve-many-"+identity.Category+suffix, fmt.Sprintf("Retrieves the list of %s.", identity.Category), tags, ri)
+				op.Parameters = append(op.Parameters, openAPIParameterRefs("X-Namespace", "X-Fields", "page", "pagesize", "after", "limit", "order", "q", "recursive")...)
+				op.Responses["200"] = &OpenAPIResponse{
+					Description: fmt.Sprintf("The list of %s.", identity.Category),
+					Headers:     openAPIListHeaders(),
+					Content:     openAPIJSONContent(&OpenAPISchema{Type: "array", Items: schema}),
+				}
+				pathItem(url).Get = op
+			}
+		}
+
+		if _, ok := processor.(InfoProcessor); ok {
+			for parent, ri := range relationship.Info {
+				url, suffix := openAPIParentURL(modelManager, identity, parent)
+				op := newOpenAPIOperation("info-"+identity.Category+suffix, fmt.Sprintf("Retrieves the number of %s.", identity.Category), tags, ri)
+				op.Parameters = append(op.Parameters, openAPIParameterRefs("X-Namespace", "q", "recursive")...)
+				op.Responses["200"] = &OpenAPIResponse{
+					Description: fmt.Sprintf("The number of %s.", identity.Category),
+					Headers:     openAPIListHeaders(),
+				}
+				pathItem(url).Head = op
+			}
+		}
+
+		if _, ok := processor.(CreateProcessor); ok {
+			for parent, ri := range relationship.Create {
+				url, suffix := openAPIParentURL(modelManager, identity, parent)
+				op := newOpenAPIOperation("create-"+identity.Name+suffix, fmt.Sprintf("Creates a new %s.", identity.Name), tags, ri)
+				op.Parameters = append(op.Parameters, openAPIParameterRefs("X-Namespace", "X-Fields")...)
+				op.RequestBody = &OpenAPIRequestBody{Required: true, Content: openAPIJSONContent(schema)}
+				op.Responses["200"] = &OpenAPIResponse{Description: fmt.Sprintf("The created %s.", identity.Name), Content: openAPIJSONContent(schema)}
+				pathItem(url).Post = op
+			}
+		}
+
+		url := fmt.Sprintf("/%s/{id}", identity.Category)
+
+		if _, ok := processor.(RetrieveProcessor); ok {
+			if ri, ok := relationship.Retrieve["root"]; ok {
+				op := newOpenAPIOperation("retrieve-"+identity.Name, fmt.Sprintf("Retrieves the %s with the given ID.", identity.Name), tags, ri)
+				op.Parameters = append(op.Parameters, openAPIParameterRefs("X-Namespace", "X-Fields")...)
+				op.Responses["200"] = &OpenAPIResponse{Description: fmt.Sprintf("The %s.", identity.Name), Content: openAPIJSONContent(schema)}
+				pathItem(url).Get = op
+			}
+		}
+
+		if _, ok := processor.(UpdateProcessor); ok {
+			if ri, ok := relationship.Update["root"]; ok {
+				op := newOpenAPIOperation("update-"+identity.Name, fmt.Sprintf("Updates the %s with the given ID.", identity.Name), tags, ri)
+				op.Parameters = append(op.Parameters, openAPIParameterRefs("X-Namespace", "X-Fields")...)
+				op.RequestBody = &OpenAPIRequestBody{Required: true, Content: openAPIJSONContent(schema)}
+				op.Responses["200"] = &OpenAPIResponse{Description: fmt.Sprintf("The updated %s.", identity.Name), Content: openAPIJSONContent(schema)}
+				pathItem(url).Put = op
+			}
+		}
+
+		if _, ok := processor.(PatchProcessor); ok {
+			if ri, ok := relationship.Patch["root"]; ok {
+				op := newOpenAPIOperation("patch-"+identity.Name, fmt.Sprintf("Partially updates the %s with the given ID.", identity.Name), tags, ri)
+				op.Parameters = append(op.Parameters, openAPIParameterRefs("X-Namespace", "X-Fields")...)
+				op.RequestBody = &OpenAPIRequestBody{Required: true, Content: openAPIJSONContent(schema)}
+				op.Responses["200"] = &OpenAPIResponse{Description: fmt.Sprintf("The patched %s.", identity.Name), Content: openAPIJSONContent(schema)}
+				pathItem(url).Patch = op
+			}
+		}
+
+		if _, ok := processor.(DeleteProcessor); ok {
+			if ri, ok := relationship.Delete["root"]; ok {
+				op := newOpenAPIOperation("delete-"+identity.Name, fmt.Sprintf("Deletes the %s with the given ID.", identity.Name), tags, ri)
+				op.Parameters = append(op.Parameters, openAPIParameterRefs("X-Namespace", "X-Fields")...)
+				op.Responses["200"] = &OpenAPIResponse{Description: fmt.Sprintf("The deleted %s.", identity.Name), Content: openAPIJSONContent(schema)}
+				pathItem(url).Delete = op
+			}
+		}
+	}
+
+	return doc
+}
+
+// newOpenAPIOperation returns a new operation with the parameters
+// declared in the given relationship info.
+func newOpenAPIOperation(id string, summary string, tags []string, ri *elemental.RelationshipInfo) *OpenAPIOperation {
+
+	op := &OpenAPIOperation{
+		OperationID: id,
+		Summary:     summary,
+		Tags:        tags,
+		Responses: map[string]*OpenAPIResponse{
+			"default": {Ref: "#/components/responses/Error"},
+		},
+	}
+
+	if ri == nil {
+		return op
+	}
+
+	op.Deprecated = ri.Deprecated
+
+	for _, p := range ri.Parameters {
+		op.Parameters = append(op.Parameters, &OpenAPIParameter{
+			Name:   p.Name,
+			In:     "query",
+			Schema: openAPIParameterSchema(p),
+		})
+	}
+
+	sort.Slice(op.Parameters, func(i int, j int) bool {
+		return op.Parameters[i].Name < op.Parameters[j].Name
+	})
+
+	return op
+}
+
+// openAPIModelSchema registers the schema of the given identifiable
+// in the given schemas, as well as the schemas of all the models it
+// references, and returns a reference to it.
+func openAPIModelSchema(modelManager elemental.ModelManager, identifiable elemental.Identifiable, schemas map[string]*OpenAPISchema) *OpenAPISchema {
+
+	if identifiable == nil {
+		return nil
+	}
+
+	spec, ok := identifiable.(elemental.AttributeSpecifiable)
+	if !ok {
+		return nil
+	}
+
+	name := identifiable.Identity().Name
+	ref := &OpenAPISchema{Ref: "#/components/schemas/" + name}
+
+	if _, ok := schemas[name]; ok {
+		return ref
+	}
+
+	schema := &OpenAPISchema{
+		Type:       "object",
+		Properties: map[string]*OpenAPISchema{},
+	}
+
+	// We register the schema before walking the attributes
+	// to support models referencing themselves.
+	schemas[name] = schema
+
+	for _, s := range spec.AttributeSpecifications() {
+
+		if !s.Exposed {
+			continue
+		}
+
+		prop := openAPIAttributeSchema(modelManager, s, schemas)
+		prop.Description = s.Description
+		prop.ReadOnly = s.ReadOnly || s.Autogenerated
+		prop.Deprecated = s.Deprecated
+
+		switch s.Type {
+		case "string", "enum", "integer", "float", "boolean":
+			prop.Default = s.DefaultValue
+		}
+
+		schema.Properties[s.Name] = prop
+
+		if s.Required {
+			schema.Required = append(schema.Required, s.Name)
+		}
+	}
+
+	sort.Strings(schema.Required)
+
+	return ref
+}
+
+// openAPIAttributeSchema returns the schema of the given attribute.
+func openAPIAttributeSchema(modelManager elemental.ModelManager, spec elemental.AttributeSpecification, schemas map[string]*OpenAPISchema) *OpenAPISchema {
+
+	switch spec.Type {
+
+	case "enum":
+		return &OpenAPISchema{Type: "string", Enum: spec.AllowedChoices}
+
+	case "list", "refList":
+		return &OpenAPISchema{Type: "array", Items: openAPISubTypeSchema(modelManager, spec.SubType, schemas)}
+
+	case "refMap":
+		return &OpenAPISchema{Type: "object", AdditionalProperties: openAPISubTypeSchema(modelManager, spec.SubType, schemas)}
+
+	case "ref":
+		return openAPISubTypeSchema(modelManager, spec.SubType, schemas)
+
+	case "object":
+		if s := openAPIModelSchema(modelManager, identifiableFromSubType(modelManager, spec.SubType), schemas); s != nil {
+			return s
+		}
+		return &OpenAPISchema{Type: "object"}
+
+	default:
+		return openAPITypeSchema(spec.Type)
+	}
+}
+
+// openAPISubTypeSchema returns the schema of the given subtype, which
+// may either be a primitive type or the name of another model.
+func openAPISubTypeSchema(modelManager elemental.ModelManager, subType string, schemas map[string]*OpenAPISchema) *OpenAPISchema {
+
+	if s := openAPIModelSchema(modelManager, identifiableFromSubType(modelManager, subType), schemas); s != nil {
+		return s
+	}
+
+	return openAPITypeSchema(subType)
+}
+
+// openAPITypeSchema returns the schema of the given primitive type.
+// Unknown types are described by an empty schema, accepting any value.
+func openAPITypeSchema(typ string) *OpenAPISchema {
+
+	switch typ {
+	case "string":
+		return &OpenAPISchema{Type: "string"}
+	case "integer":
+		return &OpenAPISchema{Type: "integer"}
+	case "float":
+		return &OpenAPISchema{Type: "number"}
+	case "boolean":
+		return &OpenAPISchema{Type: "boolean"}
+	case "time":
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case "duration":
+		return &OpenAPISchema{Type: "string", Format: "duration"}
+	default:
+		return &OpenAPISchema{}
+	}
+}
+
+// openAPIParameterSchema returns the schema of the given relationship parameter.
+func openAPIParameterSchema(p elemental.ParameterDefinition) *OpenAPISchema {
+
+	var schema *OpenAPISchema
+
+	if p.Type == "enum" {
+		schema = &OpenAPISchema{Type: "string", Enum: p.AllowedChoices}
+	} else {
+		schema = openAPITypeSchema(string(p.Type))
+	}
+
+	if p.Multiple {
+		return &OpenAPISchema{Type: "array", Items: schema}
+	}
+
+	return schema
+}
+
+// openAPICommonParameters returns the parameters
+// shared by the operations of all the identities.
+func openAPICommonParameters() map[string]*OpenAPIParameter {
+
+	return map[string]*OpenAPIParameter{
+		"X-Namespace": {
+			Name:        "X-Namespace",
+			In:          "header",
+			Description: "The namespace of the request.",
+			Schema:      &OpenAPISchema{Type: "string"},
+		},
+		"X-Fields": {
+			Name:        "X-Fields",
+			In:          "header",
+			Description: "The list of fields to return. All fields are returned if not set.",
+			Schema:      &OpenAPISchema{Type: "array", Items: &OpenAPISchema{Type: "string"}},
+		},
+		"page": {
+			Name:        "page",
+			In:          "query",
+			Description: "The page number to retrieve.",
+			Schema:      &OpenAPISchema{Type: "integer"},
+		},
+		"pagesize": {
+			Name:        "pagesize",
+			In:          "query",
+			Description: "The number of objects per page.",
+			Schema:      &OpenAPISchema{Type: "integer"},
+		},
+		"after": {
+			Name:        "after",
+			In:          "query",
+			Description: "The value of X-Next returned by the previous request, to retrieve the next objects.",
+			Schema:      &OpenAPISchema{Type: "string"},
+		},
+		"limit": {
+			Name:        "limit",
+			In:          "query",
+			Description: "The maximum number of objects to retrieve when using after.",
+			Schema:      &OpenAPISchema{Type: "integer"},
+		},
+		"order": {
+			Name:        "order",
+			In:          "query",
+			Description: "The attributes to order the objects by.",
+			Schema:      &OpenAPISchema{Type: "array", Items: &OpenAPISchema{Type: "string"}},
+		},
+		"q": {
+			Name:        "q",
+			In:          "query",
+			Description: "The filter to apply.",
+			Schema:      &OpenAPISchema{Type: "string"},
+		},
+		"recursive": {
+			Name:        "recursive",
+			In:          "query",
+			Description: "Also retrieve the objects from the child namespaces.",
+			Schema:      &OpenAPISchema{Type: "boolean"},
+		},
+	}
+}
+
+// openAPIListHeaders returns the headers
+// returned along with a list of objects.
+func openAPIListHeaders() map[string]*OpenAPIHeader {
+
+	return map[string]*OpenAPIHeader{
+		"X-Count-Total": {
+			Description: "The total number of objects.",
+			Schema:      &OpenAPISchema{Type: "integer"},
+		},
+		"X-Next": {
+			Description: "The value to pass as the after parameter to retrieve the next objects.",
+			Schema:      &OpenAPISchema{Type: "string"},
+		},
+	}
+}
+
+// openAPIErrorSchema returns the schema of an elemental.Error.
+func openAPIErrorSchema() *OpenAPISchema {
+
+	return &OpenAPISchema{
+		Type: "object",
+		Properties: map[string]*OpenAPISchema{
+			"code":        {Type: "integer"},
+			"title":       {Type: "string"},
+			"description": {Type: "string"},
+			"subject":     {Type: "string"},
+			"trace":       {Type: "string"},
+			"data":        {},
+		},
+	}
+}
+
+func openAPIParameterRefs(names ...string) []*OpenAPIParameter {
+
+	out := make([]*OpenAPIParameter, len(names))
+	for i, name := range names {
+		out[i] = &OpenAPIParameter{Ref: "#/components/parameters/" + name}
+	}
+
+	return out
+}
+
+func openAPIJSONContent(schema *OpenAPISchema) map[string]*OpenAPIMediaType {
+
+	return map[string]*OpenAPIMediaType{
+		"application/json": {Schema: schema},
+	}
+}
+
+// openAPIParentURL returns the url of the given identity under the given parent,
+// and the suffix to add to the operation ID to make it unique.
+func openAPIParentURL(modelManager elemental.ModelManager, identity elemental.Identity, parent string) (string, string) {
+
+	if parent == "root" {
+		return fmt.Sprintf("/%s", identity.Category), ""
+	}
+
+	parentIdentity := modelManager.IdentityFromName(parent)
+
+	return fmt.Sprintf("/%s/{id}/%s", parentIdentity.Category, identity.Category), "-in-" + parentIdentity.Name
+}
+
+func identifiableFromSubType(modelManager elemental.ModelManager, subType string) elemental.Identifiable {
+
+	switch subType {
+	case "", "string", "integer", "float", "boolean", "time", "duration":
+		return nil
+	}
+
+	return modelManager.IdentifiableFromString(subType)
+}
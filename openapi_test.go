@@ -0,0 +1,174 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+func TestOpenAPI_buildVersionedOpenAPI(t *testing.T) {
+
+	Convey("Given I have a model manager and processors for all identities", t, func() {
+
+		pf := func(identity elemental.Identity) (Processor, error) {
+			return &mockProcessor{}, nil
+		}
+
+		docs := buildVersionedOpenAPI(
+			map[int]elemental.ModelManager{1: testmodel.Manager()},
+			pf,
+			OpenAPIInfo{Title: "test", Version: "1.0"},
+			"/api",
+		)
+
+		Convey("Then I should have one document per version", func() {
+			So(len(docs), ShouldEqual, 1)
+			So(docs[1], ShouldNotBeNil)
+			So(docs[1].OpenAPI, ShouldEqual, openAPIVersion)
+			So(docs[1].Info.Title, ShouldEqual, "test")
+			So(docs[1].Servers[0].URL, ShouldEqual, "/api/v/1")
+		})
+
+		Convey("Then the paths should be correct", func() {
+			paths := docs[1].Paths
+
+			So(paths["/lists"].Get, ShouldNotBeNil)
+			So(paths["/lists"].Post, ShouldNotBeNil)
+			So(paths["/lists"].Parameters, ShouldBeEmpty)
+			So(paths["/lists/{id}"].Get, ShouldNotBeNil)
+			So(paths["/lists/{id}"].Put, ShouldNotBeNil)
+			So(paths["/lists/{id}"].Delete, ShouldNotBeNil)
+			So(paths["/lists/{id}"].Parameters[0].Name, ShouldEqual, "id")
+			So(paths["/lists/{id}"].Parameters[0].In, ShouldEqual, "path")
+			So(paths["/lists/{id}/tasks"].Get, ShouldNotBeNil)
+			So(paths["/lists/{id}/tasks"].Post, ShouldNotBeNil)
+			So(paths["/lists/{id}/tasks"].Post.OperationID, ShouldEqual, "create-task-in-list")
+		})
+
+		Convey("Then the retrieve many operations should document pagination", func() {
+			op := docs[1].Paths["/lists"].Get
+
+			So(op.Parameters, ShouldContain, &OpenAPIParameter{Ref: "#/components/parameters/page"})
+			So(op.Parameters, ShouldContain, &OpenAPIParameter{Ref: "#/components/parameters/pagesize"})
+			So(op.Parameters, ShouldContain, &OpenAPIParameter{Ref: "#/components/parameters/X-Fields"})
+			So(op.Parameters, ShouldContain, &OpenAPIParameter{Ref: "#/components/parameters/X-Namespace"})
+			So(op.Responses["200"].Headers["X-Next"], ShouldNotBeNil)
+			So(op.Responses["200"].Content["application/json"].Schema.Items.Ref, ShouldEqual, "#/components/schemas/list")
+		})
+
+		Convey("Then the schemas should come from the attribute specifications", func() {
+			schema := docs[1].Components.Schemas["list"]
+
+			So(schema, ShouldNotBeNil)
+			So(schema.Type, ShouldEqual, "object")
+			So(schema.Properties["name"].Type, ShouldEqual, "string")
+			So(schema.Properties["ID"].ReadOnly, ShouldBeTrue)
+			So(schema.Required, ShouldContain, "name")
+		})
+
+		Convey("Then the common parameters should be declared", func() {
+			for _, name := range []string{"X-Fields", "X-Namespace", "page", "pagesize", "after", "limit"} {
+				So(docs[1].Components.Parameters[name], ShouldNotBeNil)
+			}
+		})
+
+		Convey("Then the documents should be encodable", func() {
+			_, err := json.Marshal(docs)
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("Given I have a model manager and no processors", t, func() {
+
+		pf := func(identity elemental.Identity) (Processor, error) {
+			return nil, fmt.Errorf("boom")
+		}
+
+		docs := buildVersionedOpenAPI(map[int]elemental.ModelManager{1: testmodel.Manager()}, pf, OpenAPIInfo{}, "")
+
+		Convey("Then the document should not have any path", func() {
+			So(docs[1].Paths, ShouldBeEmpty)
+			So(docs[1].Servers[0].URL, ShouldEqual, "/v/1")
+		})
+	})
+
+	Convey("Given I have a model manager and processors implementing nothing", t, func() {
+
+		pf := func(identity elemental.Identity) (Processor, error) {
+			return struct{}{}, nil
+		}
+
+		docs := buildVersionedOpenAPI(map[int]elemental.ModelManager{1: testmodel.Manager()}, pf, OpenAPIInfo{}, "")
+
+		Convey("Then the document should not have any path", func() {
+			So(docs[1].Paths, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestOpenAPI_openAPIAttributeSchema(t *testing.T) {
+
+	Convey("Given I have a model manager", t, func() {
+
+		m := testmodel.Manager()
+		schemas := map[string]*OpenAPISchema{}
+
+		So(openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "string"}, schemas), ShouldResemble, &OpenAPISchema{Type: "string"})
+		So(openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "integer"}, schemas), ShouldResemble, &OpenAPISchema{Type: "integer"})
+		So(openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "float"}, schemas), ShouldResemble, &OpenAPISchema{Type: "number"})
+		So(openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "boolean"}, schemas), ShouldResemble, &OpenAPISchema{Type: "boolean"})
+		So(openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "time"}, schemas), ShouldResemble, &OpenAPISchema{Type: "string", Format: "date-time"})
+		So(openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "external"}, schemas), ShouldResemble, &OpenAPISchema{})
+		So(openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "object"}, schemas), ShouldResemble, &OpenAPISchema{Type: "object"})
+		So(
+			openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "enum", AllowedChoices: []string{"a", "b"}}, schemas),
+			ShouldResemble,
+			&OpenAPISchema{Type: "string", Enum: []string{"a", "b"}},
+		)
+		So(
+			openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "list", SubType: "string"}, schemas),
+			ShouldResemble,
+			&OpenAPISchema{Type: "array", Items: &OpenAPISchema{Type: "string"}},
+		)
+		So(
+			openAPIAttributeSchema(m, elemental.AttributeSpecification{Type: "refList", SubType: "task"}, schemas),
+			ShouldResemble,
+			&OpenAPISchema{Type: "array", Items: &OpenAPISchema{Ref: "#/components/schemas/task"}},
+		)
+		So(schemas["task"], ShouldNotBeNil)
+	})
+}
+
+func TestOpenAPI_openAPIParameterSchema(t *testing.T) {
+
+	Convey("Given I have some parameter definitions", t, func() {
+
+		So(openAPIParameterSchema(elemental.ParameterDefinition{Type: "boolean"}), ShouldResemble, &OpenAPISchema{Type: "boolean"})
+		So(
+			openAPIParameterSchema(elemental.ParameterDefinition{Type: "enum", AllowedChoices: []string{"a"}}),
+			ShouldResemble,
+			&OpenAPISchema{Type: "string", Enum: []string{"a"}},
+		)
+		So(
+			openAPIParameterSchema(elemental.ParameterDefinition{Type: "string", Multiple: true}),
+			ShouldResemble,
+			&OpenAPISchema{Type: "array", Items: &OpenAPISchema{Type: "string"}},
+		)
+	})
+}
@@ -93,6 +93,51 @@ func OptDisableCompression() Option {
 	}
 }
 
+// OptH2C enables HTTP/2 over cleartext TCP (h2c) on the rest server
+// when it is not configured to use TLS. Clients using HTTP/1.1 are
+// still served. This is useful when TLS is terminated by a proxy that
+// can use h2c to reach the service, like the gateway.
+func OptH2C() Option {
+	return func(c *config) {
+		c.restServer.h2cEnabled = true
+	}
+}
+
+// OptHTTP3 configures the rest server to also serve the api over HTTP/3
+// on the given UDP address, using the HTTP3Server returned by the given
+// HTTP3ServerMaker. The TCP server will then advertise the HTTP/3 endpoint
+// using the Alt-Svc header. As QUIC requires TLS, the rest server must be
+// configured with server certificates.
+func OptHTTP3(listenAddress string, maker HTTP3ServerMaker) Option {
+	return func(c *config) {
+		c.restServer.http3ListenAddress = listenAddress
+		c.restServer.http3ServerMaker = maker
+	}
+}
+
+// OptMaxRequestBodySize sets the maximum size in bytes of the body of
+// the requests handled by the rest server. Requests with a larger body
+// are rejected with a 413 error. If the body is gzip encoded, the limit
+// also applies to the decompressed data. 0 means no limit.
+func OptMaxRequestBodySize(size int64) Option {
+	return func(c *config) {
+		c.restServer.maxRequestBodySize = size
+	}
+}
+
+// OptIdentityMaxRequestBodySize sets the maximum size in bytes of
+// the body of the requests targeting the given identity. It takes
+// precedence over the size set by OptMaxRequestBodySize. 0 means no limit.
+func OptIdentityMaxRequestBodySize(identity elemental.Identity, size int64) Option {
+	return func(c *config) {
+		if c.restServer.maxRequestBodySizes == nil {
+			c.restServer.maxRequestBodySizes = map[elemental.Identity]int64{}
+		}
+
+		c.restServer.maxRequestBodySizes[identity] = size
+	}
+}
+
 // OptCustomRootHandler configures the custom root (/) handler.
 func OptCustomRootHandler(handler http.HandlerFunc) Option {
 	return func(c *config) {
@@ -210,6 +255,22 @@ func OptPushPublishHandler(publishHandler PushPublishHandler) Option {
 	}
 }
 
+// OptPushOutbox configures the push outbox.
+//
+// When set, the events pushed by the server are stored in the given
+// PushOutbox, and a relay publishes them into the PubSubClient every
+// pollInterval (one second if zero) with at-least-once semantics.
+// The output data of create, update, delete and patch operations is not
+// pushed automatically anymore: processors must add the events to the outbox
+// as part of their own transaction, and not pass them to EnqueueEvents.
+// This option has no effect if OptPushServer is not set.
+func OptPushOutbox(outbox PushOutbox, pollInterval time.Duration) Option {
+	return func(c *config) {
+		c.pushServer.outbox = outbox
+		c.pushServer.outboxPollInterval = pollInterval
+	}
+}
+
 // OptHealthServer enables and configures the health server.
 //
 // ListenAddress is the general listening address for the health server.
@@ -296,6 +357,17 @@ func OptTLS(certs []tls.Certificate, certRetriever func(*tls.ClientHelloInfo) (*
 	}
 }
 
+// OptTLSReloader configures the rest server to use the certificate and,
+// if configured, the client CA pool managed by the given TLSReloader.
+// It takes precedence over the certificates passed to OptTLS and over the
+// client CA pool passed to OptMTLS. You are responsible for calling the
+// Watch method of the TLSReloader to reload the files when they change.
+func OptTLSReloader(reloader *TLSReloader) Option {
+	return func(c *config) {
+		c.tls.reloader = reloader
+	}
+}
+
 // OptTLSNextProtos configures server TLS next protocols.
 //
 // You can use it to set it to []string{'h2'} for instance to
@@ -363,6 +435,38 @@ func OptAuthorizers(authorizers []Authorizer) Option {
 	}
 }
 
+// OptNamespaceEnforcement enables the enforcement of the namespaces.
+//
+// The values of the claims with the given key define the root namespaces
+// a client is allowed to access, like @auth:namespace=/acme. Requests
+// targeting a namespace that is not visible from these roots are rejected
+// with a 403 error, after the authentication and before any Authorizer.
+// Push sessions only receive the events on entities from visible namespaces.
+// Events on entities without namespace are not filtered.
+//
+// Requests without any root namespace in their claims are rejected, which
+// includes the anonymous ones, like the requests issuing a token. Their
+// identities must be given as excluded to let the Authorizers decide.
+// Requests on excluded identities are not checked, but the events on these
+// identities are still filtered.
+//
+// If recursive is true, the descendants of the root namespaces are visible
+// too. See IsNamespaceVisible for details.
+func OptNamespaceEnforcement(claimKey string, recursive bool, excluded ...elemental.Identity) Option {
+	return func(c *config) {
+		c.security.namespaceEnforcer = &namespaceEnforcer{
+			claimKey:  claimKey,
+			recursive: recursive,
+		}
+		if len(excluded) > 0 {
+			c.security.namespaceEnforcer.excludedIdentities = make(map[string]struct{}, len(excluded))
+			for _, i := range excluded {
+				c.security.namespaceEnforcer.excludedIdentities[i.Name] = struct{}{}
+			}
+		}
+	}
+}
+
 // OptAuditer configures the auditor to use to audit the requests.
 //
 // The Audit() method will be run in a go routine so there is no
@@ -443,6 +547,29 @@ func OptUnmarshallers(unmarshallers map[elemental.Identity]CustomUmarshaller) Op
 	}
 }
 
+// OptValidators sets the custom validators.
+//
+// Validators contains a list of custom validators per identity.
+// They are called after the standard validation of the decoded
+// identifiable, and all the errors they return are sent back
+// to the client at once.
+func OptValidators(validators map[elemental.Identity][]CustomValidator) Option {
+	return func(c *config) {
+		c.model.validators = validators
+	}
+}
+
+// OptStrictDecoding enables the strict decoding of the request data.
+//
+// When enabled, requests containing attributes that are not
+// part of the model of the targeted identity are rejected
+// with a validation error per unknown attribute.
+func OptStrictDecoding() Option {
+	return func(c *config) {
+		c.model.strictDecoding = true
+	}
+}
+
 // OptMarshallers sets the custom marshallers.
 //
 // Marshallers contains a list of custom marshaller per identity.
@@ -71,6 +71,34 @@ func TestBahamut_Options(t *testing.T) {
 		So(c.restServer.disableCompression, ShouldEqual, true)
 	})
 
+	Convey("Calling OptTLSReloader should work", t, func() {
+		r := &TLSReloader{}
+		OptTLSReloader(r)(&c)
+		So(c.tls.reloader, ShouldEqual, r)
+	})
+
+	Convey("Calling OptH2C should work", t, func() {
+		OptH2C()(&c)
+		So(c.restServer.h2cEnabled, ShouldBeTrue)
+	})
+
+	Convey("Calling OptHTTP3 should work", t, func() {
+		maker := func(string, *tls.Config, http.Handler) (HTTP3Server, error) { return nil, nil }
+		OptHTTP3(":443", maker)(&c)
+		So(c.restServer.http3ListenAddress, ShouldEqual, ":443")
+		So(c.restServer.http3ServerMaker, ShouldNotBeNil)
+	})
+
+	Convey("Calling OptMaxRequestBodySize should work", t, func() {
+		OptMaxRequestBodySize(1024)(&c)
+		So(c.restServer.maxRequestBodySize, ShouldEqual, 1024)
+	})
+
+	Convey("Calling OptIdentityMaxRequestBodySize should work", t, func() {
+		OptIdentityMaxRequestBodySize(testmodel.ListIdentity, 42)(&c)
+		So(c.restServer.maxRequestBodySizes, ShouldResemble, map[elemental.Identity]int64{testmodel.ListIdentity: 42})
+	})
+
 	Convey("Calling OptCustomRootHandler should work", t, func() {
 		h := func(http.ResponseWriter, *http.Request) {}
 		OptCustomRootHandler(h)(&c)
@@ -111,6 +139,13 @@ func TestBahamut_Options(t *testing.T) {
 		So(c.pushServer.subjectHierarchiesEnabled, ShouldEqual, true)
 	})
 
+	Convey("Calling OptPushOutbox should work", t, func() {
+		o := NewMemoryPushOutbox()
+		OptPushOutbox(o, 2*time.Second)(&c)
+		So(c.pushServer.outbox, ShouldEqual, o)
+		So(c.pushServer.outboxPollInterval, ShouldEqual, 2*time.Second)
+	})
+
 	Convey("Calling OptHealthServer should work", t, func() {
 		h := func() error { return nil }
 		OptHealthServer("1.2.3.4:123", h)(&c)
@@ -212,6 +247,19 @@ func TestBahamut_Options(t *testing.T) {
 		So(c.security.authorizers, ShouldResemble, ra)
 	})
 
+	Convey("Calling OptNamespaceEnforcement should work", t, func() {
+		OptNamespaceEnforcement("@auth:namespace", true)(&c)
+		So(c.security.namespaceEnforcer, ShouldResemble, &namespaceEnforcer{claimKey: "@auth:namespace", recursive: true})
+	})
+
+	Convey("Calling OptNamespaceEnforcement with excluded identities should work", t, func() {
+		OptNamespaceEnforcement("@auth:namespace", false, testmodel.UserIdentity)(&c)
+		So(c.security.namespaceEnforcer, ShouldResemble, &namespaceEnforcer{
+			claimKey:           "@auth:namespace",
+			excludedIdentities: map[string]struct{}{testmodel.UserIdentity.Name: {}},
+		})
+	})
+
 	Convey("Calling OptAuditer should work", t, func() {
 		a := &mockAuditer{}
 		OptAuditer(a)(&c)
@@ -265,6 +313,17 @@ func TestBahamut_Options(t *testing.T) {
 		So(c.model.marshallers, ShouldResemble, u)
 	})
 
+	Convey("Calling OptValidators should work", t, func() {
+		v := map[elemental.Identity][]CustomValidator{testmodel.ListIdentity: {func(*elemental.Request, elemental.Identifiable) error { return nil }}}
+		OptValidators(v)(&c)
+		So(len(c.model.validators[testmodel.ListIdentity]), ShouldEqual, 1)
+	})
+
+	Convey("Calling OptStrictDecoding should work", t, func() {
+		OptStrictDecoding()(&c)
+		So(c.model.strictDecoding, ShouldBeTrue)
+	})
+
 	Convey("Calling OptServiceInfo should work", t, func() {
 		sb := map[string]any{}
 		OptServiceInfo("n", "v", sb)(&c)
@@ -57,6 +57,7 @@ type Publication struct {
 	span         opentracing.Span
 	TrackingData opentracing.TextMapCarrier `msgpack:"trackingData,omitempty" json:"trackingData,omitempty"`
 	replyCh      chan *Publication
+	ID           string                 `msgpack:"id,omitempty" json:"id,omitempty"`
 	Topic        string                 `msgpack:"topic,omitempty" json:"topic,omitempty"`
 	TrackingName string                 `msgpack:"trackingName,omitempty" json:"trackingName,omitempty"`
 	Encoding     elemental.EncodingType `msgpack:"encoding,omitempty" json:"encoding,omitempty"`
@@ -153,6 +154,7 @@ func (p *Publication) Duplicate() *Publication {
 	defer p.mux.Unlock()
 
 	pub := NewPublication(p.Topic)
+	pub.ID = p.ID
 	pub.Data = p.Data
 	pub.Partition = p.Partition
 	pub.TrackingName = p.TrackingName
@@ -175,7 +177,7 @@ func (p *Publication) Reply(response *Publication) error {
 
 	switch {
 	case p.timedOut:
-		return errors.New("took too long to reply to publication")
+		return newPubSubError("reply", p.Topic, ErrPubSubReplyExpired, nil)
 	case response == nil:
 		return errors.New("response cannot be nil")
 	case p.replied:
@@ -199,6 +199,7 @@ func TestPublication_Duplicate(t *testing.T) {
 	Convey("Given I have a publication", t, func() {
 
 		pub := NewPublication("topic")
+		pub.ID = "id"
 		pub.Data = []byte("data")
 		pub.Partition = 12
 		pub.TrackingName = "TrackingName"
@@ -209,6 +210,7 @@ func TestPublication_Duplicate(t *testing.T) {
 
 			Convey("Then the copy should be correct", func() {
 				So(dup, ShouldNotEqual, pub)
+				So(dup.ID, ShouldEqual, pub.ID)
 				So(dup.Data, ShouldResemble, pub.Data)
 				So(dup.Partition, ShouldEqual, pub.Partition)
 				So(dup.TrackingName, ShouldEqual, pub.TrackingName)
@@ -0,0 +1,178 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Various sentinel errors returned by PubSubClient operations.
+// They are always wrapped into a *PubSubError, and must be
+// checked using errors.Is.
+var (
+	// ErrPubSubNotConnected is returned when the client is not
+	// connected to the pubsub backend.
+	ErrPubSubNotConnected = errors.New("not connected")
+
+	// ErrPubSubRequestTimeout is returned when a request
+	// did not receive a response in time.
+	ErrPubSubRequestTimeout = errors.New("request timeout")
+
+	// ErrPubSubNoResponders is returned when a request
+	// has been sent to a topic nobody is listening to.
+	ErrPubSubNoResponders = errors.New("no responders")
+
+	// ErrPubSubInvalidAck is returned when the response
+	// to a request requiring an ack is not a valid ack.
+	ErrPubSubInvalidAck = errors.New("invalid ack")
+
+	// ErrPubSubEncode is returned when a publication
+	// cannot be encoded.
+	ErrPubSubEncode = errors.New("unable to encode publication")
+
+	// ErrPubSubDecode is returned when a publication
+	// cannot be decoded.
+	ErrPubSubDecode = errors.New("unable to decode publication")
+
+	// ErrPubSubReplyExpired is returned when a reply to a
+	// publication is sent after the reply deadline.
+	ErrPubSubReplyExpired = errors.New("reply expired")
+
+	// ErrPubSubBackend is returned when the pubsub backend
+	// returned an error that does not match any other kind.
+	ErrPubSubBackend = errors.New("backend error")
+)
+
+// A PubSubError is the error returned by the PubSubClient operations.
+// Err is always one of the ErrPubSub* sentinel errors, and Cause, if
+// any, contains the underlying error returned by the backend.
+type PubSubError struct {
+	Err   error
+	Cause error
+	Op    string
+	Topic string
+}
+
+// newPubSubError returns a new *PubSubError.
+func newPubSubError(op string, topic string, err error, cause error) *PubSubError {
+
+	return &PubSubError{
+		Op:    op,
+		Topic: topic,
+		Err:   err,
+		Cause: cause,
+	}
+}
+
+// Error implements the error interface.
+func (e *PubSubError) Error() string {
+
+	msg := fmt.Sprintf("pubsub %s", e.Op)
+	if e.Topic != "" {
+		msg = fmt.Sprintf("%s on '%s'", msg, e.Topic)
+	}
+
+	if e.Cause == nil {
+		return fmt.Sprintf("%s: %s", msg, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", msg, e.Err, e.Cause)
+}
+
+// Unwrap returns the sentinel error and the underlying cause.
+func (e *PubSubError) Unwrap() []error {
+
+	if e.Cause == nil {
+		return []error{e.Err}
+	}
+
+	return []error{e.Err, e.Cause}
+}
+
+// IsRetryablePubSubError returns true if the given error
+// is a transient pubsub error for which retrying the operation
+// makes sense.
+func IsRetryablePubSubError(err error) bool {
+
+	return errors.Is(err, ErrPubSubNotConnected) ||
+		errors.Is(err, ErrPubSubRequestTimeout) ||
+		errors.Is(err, ErrPubSubNoResponders)
+}
+
+// A PubSubRetryPolicy configures how PublishWithRetry retries
+// to publish a publication.
+type PubSubRetryPolicy struct {
+
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// If zero or less, a single attempt is made.
+	MaxAttempts int
+
+	// InitialBackoff is the time to wait before the second attempt.
+	// It is doubled after every attempt.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the time waited between two attempts.
+	// If zero, the backoff is not capped.
+	MaxBackoff time.Duration
+}
+
+// DefaultPubSubRetryPolicy returns the default PubSubRetryPolicy.
+func DefaultPubSubRetryPolicy() PubSubRetryPolicy {
+
+	return PubSubRetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     1 * time.Second,
+	}
+}
+
+// backoff returns the time to wait after the given attempt.
+func (p PubSubRetryPolicy) backoff(attempt int) time.Duration {
+
+	d := p.InitialBackoff
+	for i := 0; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+
+	return d
+}
+
+// PublishWithRetry publishes the given publication using the given PubSubClient
+// and retries with an exponential backoff according to the given PubSubRetryPolicy
+// as long as the returned error is retryable (see IsRetryablePubSubError).
+// It returns the last error if all attempts failed or if the given context is canceled.
+func PublishWithRetry(ctx context.Context, client PubSubClient, publication *Publication, policy PubSubRetryPolicy, opts ...PubSubOptPublish) (err error) {
+
+	for attempt := 0; ; attempt++ {
+
+		if err = client.Publish(publication, opts...); err == nil {
+			return nil
+		}
+
+		if !IsRetryablePubSubError(err) || attempt+1 >= policy.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-time.After(policy.backoff(attempt)):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
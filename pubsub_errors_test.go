@@ -0,0 +1,187 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type sequencePubSubClient struct {
+	mockPubSubServer
+	errs []error
+}
+
+func (p *sequencePubSubClient) Publish(publication *Publication, opts ...PubSubOptPublish) error {
+
+	p.publications = append(p.publications, publication)
+
+	if len(p.errs) == 0 {
+		return nil
+	}
+
+	err := p.errs[0]
+	p.errs = p.errs[1:]
+
+	return err
+}
+
+func TestPubSubError(t *testing.T) {
+
+	Convey("Given I have a PubSubError with a cause", t, func() {
+
+		cause := errors.New("boom")
+		err := error(newPubSubError("request", "topic", ErrPubSubRequestTimeout, cause))
+
+		Convey("Then Error should be correct", func() {
+			So(err.Error(), ShouldEqual, "pubsub request on 'topic': request timeout: boom")
+		})
+
+		Convey("Then errors.Is should match the sentinel and the cause", func() {
+			So(errors.Is(err, ErrPubSubRequestTimeout), ShouldBeTrue)
+			So(errors.Is(err, cause), ShouldBeTrue)
+			So(errors.Is(err, ErrPubSubInvalidAck), ShouldBeFalse)
+		})
+
+		Convey("Then errors.As should work", func() {
+			var perr *PubSubError
+			So(errors.As(err, &perr), ShouldBeTrue)
+			So(perr.Op, ShouldEqual, "request")
+			So(perr.Topic, ShouldEqual, "topic")
+		})
+	})
+
+	Convey("Given I have a PubSubError without a cause nor topic", t, func() {
+
+		err := newPubSubError("publish", "", ErrPubSubNotConnected, nil)
+
+		Convey("Then Error should be correct", func() {
+			So(err.Error(), ShouldEqual, "pubsub publish: not connected")
+		})
+	})
+}
+
+func TestIsRetryablePubSubError(t *testing.T) {
+
+	Convey("Given I have various errors", t, func() {
+
+		So(IsRetryablePubSubError(newPubSubError("publish", "", ErrPubSubNotConnected, nil)), ShouldBeTrue)
+		So(IsRetryablePubSubError(newPubSubError("request", "", ErrPubSubRequestTimeout, nil)), ShouldBeTrue)
+		So(IsRetryablePubSubError(newPubSubError("request", "", ErrPubSubNoResponders, nil)), ShouldBeTrue)
+		So(IsRetryablePubSubError(newPubSubError("request", "", ErrPubSubInvalidAck, nil)), ShouldBeFalse)
+		So(IsRetryablePubSubError(newPubSubError("publish", "", ErrPubSubEncode, nil)), ShouldBeFalse)
+		So(IsRetryablePubSubError(newPubSubError("request", "", ErrPubSubDecode, nil)), ShouldBeFalse)
+		So(IsRetryablePubSubError(newPubSubError("reply", "", ErrPubSubReplyExpired, nil)), ShouldBeFalse)
+		So(IsRetryablePubSubError(newPubSubError("publish", "", ErrPubSubBackend, nil)), ShouldBeFalse)
+		So(IsRetryablePubSubError(errors.New("nope")), ShouldBeFalse)
+		So(IsRetryablePubSubError(nil), ShouldBeFalse)
+	})
+}
+
+func TestPubSubRetryPolicy_backoff(t *testing.T) {
+
+	Convey("Given I have a retry policy", t, func() {
+
+		p := PubSubRetryPolicy{
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     50 * time.Millisecond,
+		}
+
+		So(p.backoff(0), ShouldEqual, 10*time.Millisecond)
+		So(p.backoff(1), ShouldEqual, 20*time.Millisecond)
+		So(p.backoff(2), ShouldEqual, 40*time.Millisecond)
+		So(p.backoff(3), ShouldEqual, 50*time.Millisecond)
+		So(p.backoff(100), ShouldEqual, 50*time.Millisecond)
+	})
+}
+
+func TestPublishWithRetry(t *testing.T) {
+
+	policy := PubSubRetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}
+
+	Convey("Given I have a client that works", t, func() {
+
+		c := &sequencePubSubClient{}
+		err := PublishWithRetry(context.Background(), c, NewPublication("topic"), policy)
+
+		So(err, ShouldBeNil)
+		So(len(c.publications), ShouldEqual, 1)
+	})
+
+	Convey("Given I have a client that fails with retryable errors and then works", t, func() {
+
+		c := &sequencePubSubClient{
+			errs: []error{
+				newPubSubError("publish", "topic", ErrPubSubNotConnected, nil),
+				newPubSubError("publish", "topic", ErrPubSubRequestTimeout, nil),
+			},
+		}
+		err := PublishWithRetry(context.Background(), c, NewPublication("topic"), policy)
+
+		So(err, ShouldBeNil)
+		So(len(c.publications), ShouldEqual, 3)
+	})
+
+	Convey("Given I have a client that always fails with retryable errors", t, func() {
+
+		c := &sequencePubSubClient{
+			errs: []error{
+				newPubSubError("publish", "topic", ErrPubSubNotConnected, nil),
+				newPubSubError("publish", "topic", ErrPubSubNotConnected, nil),
+				newPubSubError("publish", "topic", ErrPubSubNoResponders, nil),
+				newPubSubError("publish", "topic", ErrPubSubNotConnected, nil),
+			},
+		}
+		err := PublishWithRetry(context.Background(), c, NewPublication("topic"), policy)
+
+		So(errors.Is(err, ErrPubSubNoResponders), ShouldBeTrue)
+		So(len(c.publications), ShouldEqual, 3)
+	})
+
+	Convey("Given I have a client that fails with a non retryable error", t, func() {
+
+		c := &sequencePubSubClient{
+			errs: []error{
+				newPubSubError("publish", "topic", ErrPubSubEncode, nil),
+			},
+		}
+		err := PublishWithRetry(context.Background(), c, NewPublication("topic"), policy)
+
+		So(errors.Is(err, ErrPubSubEncode), ShouldBeTrue)
+		So(len(c.publications), ShouldEqual, 1)
+	})
+
+	Convey("Given I have a canceled context and a client that fails", t, func() {
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		c := &sequencePubSubClient{
+			errs: []error{
+				newPubSubError("publish", "topic", ErrPubSubNotConnected, nil),
+				newPubSubError("publish", "topic", ErrPubSubNotConnected, nil),
+			},
+		}
+		err := PublishWithRetry(ctx, c, NewPublication("topic"), PubSubRetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour})
+
+		So(errors.Is(err, ErrPubSubNotConnected), ShouldBeTrue)
+		So(len(c.publications), ShouldEqual, 1)
+	})
+}
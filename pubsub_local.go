@@ -13,6 +13,7 @@ package bahamut
 
 import (
 	"context"
+	"errors"
 	"sync"
 )
 
@@ -54,6 +55,16 @@ func newlocalPubSub() *localPubSub {
 // Publish publishes a publication.
 func (p *localPubSub) Publish(publication *Publication, opts ...PubSubOptPublish) error {
 
+	if publication == nil {
+		return newPubSubError("publish", "", ErrPubSubEncode, errors.New("publication cannot be nil"))
+	}
+
+	select {
+	case <-p.stop:
+		return newPubSubError("publish", publication.Topic, ErrPubSubNotConnected, nil)
+	default:
+	}
+
 	p.publications <- publication
 
 	return nil
@@ -13,6 +13,7 @@ package bahamut
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -50,6 +51,23 @@ func TestLocalPubSub_ConnectDisconnect(t *testing.T) {
 		Convey("Whan I call Disconnect nothing should happen", func() {
 			_ = ps.Disconnect()
 		})
+
+		Convey("When I publish after calling Disconnect", func() {
+			_ = ps.Disconnect()
+			err := ps.Publish(NewPublication("topic"))
+
+			Convey("Then err should be ErrPubSubNotConnected", func() {
+				So(errors.Is(err, ErrPubSubNotConnected), ShouldBeTrue)
+			})
+		})
+
+		Convey("When I publish a nil publication", func() {
+			err := ps.Publish(nil)
+
+			Convey("Then err should be ErrPubSubEncode", func() {
+				So(errors.Is(err, ErrPubSubEncode), ShouldBeTrue)
+			})
+		})
 	})
 }
 
@@ -69,11 +69,11 @@ func NewNATSPubSubClient(natsURL string, options ...NATSOption) PubSubClient {
 func (p *natsPubSub) Publish(publication *Publication, opts ...PubSubOptPublish) error {
 
 	if p.client == nil {
-		return errors.New("not connected to nats. messages dropped")
+		return newPubSubError("publish", "", ErrPubSubNotConnected, nil)
 	}
 
 	if publication == nil {
-		return errors.New("publication cannot be nil")
+		return newPubSubError("publish", "", ErrPubSubEncode, errors.New("publication cannot be nil"))
 	}
 
 	config := natsPublishConfig{}
@@ -84,7 +84,7 @@ func (p *natsPubSub) Publish(publication *Publication, opts ...PubSubOptPublish)
 	publication.ResponseMode = config.desiredResponse
 	data, err := elemental.Encode(elemental.EncodingTypeMSGPACK, publication)
 	if err != nil {
-		return fmt.Errorf("unable to encode publication. message dropped: %s", err)
+		return newPubSubError("publish", publication.Topic, ErrPubSubEncode, err)
 	}
 
 	switch config.desiredResponse {
@@ -92,23 +92,19 @@ func (p *natsPubSub) Publish(publication *Publication, opts ...PubSubOptPublish)
 
 		msg, err := p.client.RequestWithContext(config.ctx, publication.Topic, data)
 		if err != nil {
-			// TODO: should return a custom error type here to let the client know
-			// that the request failed so client code has sufficient context if
-			// it needs to build some kind of retry strategy based on the returned
-			// error type.
-			return err
+			return newPubSubError("request", publication.Topic, natsErrorKind(err), err)
 		}
 
 		if config.desiredResponse == ResponseModeACK {
 			if !bytes.Equal(msg.Data, ackMessage) {
-				return fmt.Errorf("invalid ack: %s", string(msg.Data))
+				return newPubSubError("request", publication.Topic, ErrPubSubInvalidAck, fmt.Errorf("received '%s'", string(msg.Data)))
 			}
 		}
 
 		if config.desiredResponse == ResponseModePublication {
 			responsePub := NewPublication("")
 			if err := elemental.Decode(elemental.EncodingTypeMSGPACK, msg.Data, responsePub); err != nil {
-				return err
+				return newPubSubError("request", publication.Topic, ErrPubSubDecode, err)
 			}
 
 			config.responseCh <- responsePub
@@ -117,7 +113,11 @@ func (p *natsPubSub) Publish(publication *Publication, opts ...PubSubOptPublish)
 		return nil
 
 	default:
-		return p.client.Publish(publication.Topic, data)
+		if err := p.client.Publish(publication.Topic, data); err != nil {
+			return newPubSubError("publish", publication.Topic, natsErrorKind(err), err)
+		}
+
+		return nil
 	}
 }
 
@@ -147,7 +147,7 @@ func (p *natsPubSub) Subscribe(pubs chan *Publication, errors chan error, topic
 		// about the reply because you took too long to respond).
 		case <-time.After(config.replyTimeout):
 			pub.setExpired()
-			errors <- fmt.Errorf("timed out waiting for response to send to subscriber on NATS subject: %s", replyAddr)
+			errors <- newPubSubError("reply", replyAddr, ErrPubSubReplyExpired, nil)
 		}
 	}
 
@@ -166,7 +166,7 @@ func (p *natsPubSub) Subscribe(pubs chan *Publication, errors chan error, topic
 			// to respond to the publisher with an ACK.
 			case ResponseModeACK:
 				if err := p.client.Publish(m.Reply, ackMessage); err != nil {
-					errors <- err
+					errors <- newPubSubError("ack", m.Reply, natsErrorKind(err), err)
 					return
 				}
 			// `ResponseModePublication` mode is used in cases when the subscriber needs to do processing on the
@@ -190,7 +190,7 @@ func (p *natsPubSub) Subscribe(pubs chan *Publication, errors chan error, topic
 	}
 
 	if err != nil {
-		errors <- err
+		errors <- newPubSubError("subscribe", topic, natsErrorKind(err), err)
 		return func() {}
 	}
 
@@ -221,7 +221,7 @@ func (p *natsPubSub) Connect(ctx context.Context) error {
 
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("unable to connect to nats on time. last error: %s", err)
+			return newPubSubError("connect", "", ErrPubSubNotConnected, err)
 		default:
 			time.Sleep(p.retryInterval)
 		}
@@ -260,3 +260,23 @@ func (p *natsPubSub) Ping(timeout time.Duration) error {
 		return err
 	}
 }
+
+// natsErrorKind returns the sentinel error matching
+// the given error returned by the nats client.
+func natsErrorKind(err error) error {
+
+	switch {
+	case errors.Is(err, nats.ErrNoResponders):
+		return ErrPubSubNoResponders
+	case errors.Is(err, nats.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ErrPubSubRequestTimeout
+	case errors.Is(err, nats.ErrConnectionClosed),
+		errors.Is(err, nats.ErrConnectionDraining),
+		errors.Is(err, nats.ErrConnectionReconnecting),
+		errors.Is(err, nats.ErrDisconnected),
+		errors.Is(err, nats.ErrInvalidConnection):
+		return ErrPubSubNotConnected
+	default:
+		return ErrPubSubBackend
+	}
+}
@@ -18,7 +18,6 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"reflect"
 	"testing"
 	"time"
 
@@ -83,7 +82,7 @@ func TestPublish(t *testing.T) {
 	natsURL := "nats://localhost:4222"
 
 	tests := []struct {
-		expectedErrType         error
+		expectedErr             error
 		setup                   func(t *testing.T, mockClient *MockNATSClient, pub *Publication)
 		publication             *Publication
 		publishOptionsGenerator func(t *testing.T) ([]PubSubOptPublish, func())
@@ -106,8 +105,8 @@ func TestPublish(t *testing.T) {
 					RequestWithContext(gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(0)
 			},
-			expectedErrType: nil,
-			natsOptions:     []NATSOption{},
+			expectedErr: nil,
+			natsOptions: []NATSOption{},
 		},
 		{
 			description: "should send received publication response to the configured response channel via the NATSOptRespondToChannel option",
@@ -160,8 +159,8 @@ func TestPublish(t *testing.T) {
 					NATSOptRespondToChannel(context.Background(), respCh),
 				}, callback
 			},
-			expectedErrType: nil,
-			natsOptions:     []NATSOption{},
+			expectedErr: nil,
+			natsOptions: []NATSOption{},
 		},
 		{
 			description: "should return an error if the response could not be decoded into a Publication when using the NATSOptRespondToChannel option",
@@ -206,8 +205,8 @@ func TestPublish(t *testing.T) {
 					NATSOptRespondToChannel(context.Background(), respCh),
 				}, callback
 			},
-			expectedErrType: errors.New(""),
-			natsOptions:     []NATSOption{},
+			expectedErr: ErrPubSubDecode,
+			natsOptions: []NATSOption{},
 		},
 		{
 			description: "should return an error if no NATS client had been connected",
@@ -219,7 +218,7 @@ func TestPublish(t *testing.T) {
 					// should never be called in this case!
 					Times(0)
 			},
-			expectedErrType: errors.New(""),
+			expectedErr: ErrPubSubNotConnected,
 			natsOptions: []NATSOption{
 				// notice how we pass a nil client explicitly to simulate the failure scenario
 				// desired by this test
@@ -236,8 +235,8 @@ func TestPublish(t *testing.T) {
 					// should never be called in this case!
 					Times(0)
 			},
-			expectedErrType: errors.New(""),
-			natsOptions:     []NATSOption{},
+			expectedErr: ErrPubSubEncode,
+			natsOptions: []NATSOption{},
 		},
 		{
 			description: "should return an error if Publish returns an error",
@@ -249,8 +248,8 @@ func TestPublish(t *testing.T) {
 					Return(errors.New("failed to publish")).
 					Times(1)
 			},
-			expectedErrType: errors.New(""),
-			natsOptions:     []NATSOption{},
+			expectedErr: ErrPubSubBackend,
+			natsOptions: []NATSOption{},
 		},
 		{
 			description: "should return an error if RequestWithContext returns an error",
@@ -281,8 +280,8 @@ func TestPublish(t *testing.T) {
 					Return(nil, errors.New("darn, failed to get a response")).
 					Times(1)
 			},
-			expectedErrType: errors.New(""),
-			natsOptions:     []NATSOption{},
+			expectedErr: ErrPubSubBackend,
+			natsOptions: []NATSOption{},
 			publishOptionsGenerator: func(t *testing.T) ([]PubSubOptPublish, func()) {
 				return []PubSubOptPublish{
 					NATSOptPublishRequireAck(context.Background()),
@@ -320,8 +319,8 @@ func TestPublish(t *testing.T) {
 					}, nil).
 					Times(1)
 			},
-			expectedErrType: errors.New(""),
-			natsOptions:     []NATSOption{},
+			expectedErr: ErrPubSubInvalidAck,
+			natsOptions: []NATSOption{},
 			publishOptionsGenerator: func(t *testing.T) ([]PubSubOptPublish, func()) {
 				return []PubSubOptPublish{
 					NATSOptPublishRequireAck(context.Background()),
@@ -355,9 +354,8 @@ func TestPublish(t *testing.T) {
 			}
 
 			pubErr := ps.Publish(test.publication, pubOpts...)
-			if actualErrType := reflect.TypeOf(pubErr); actualErrType != reflect.TypeOf(test.expectedErrType) {
-				t.Errorf("Call to publish returned error of type \"%+v\", when an error of type \"%+v\" was expected", actualErrType, reflect.TypeOf(test.expectedErrType))
-				t.Logf("Received error: %v - Expected error: %v", pubErr, test.expectedErrType)
+			if !errors.Is(pubErr, test.expectedErr) {
+				t.Errorf("Call to publish returned error \"%v\", when an error matching \"%v\" was expected", pubErr, test.expectedErr)
 			}
 		})
 	}
@@ -458,7 +456,7 @@ func TestSubscribe(t *testing.T) {
 			},
 			// we expect to get an error because when Subscriber should fail to Publish back its ACK response to the client's request
 			// it will send the error it gets back from its call to Publish to the configured error channel.
-			expectedError:       errors.New(""),
+			expectedError:       ErrPubSubBackend,
 			expectedPublication: nil,
 			subscribeOptions:    nil,
 		},
@@ -534,7 +532,7 @@ func TestSubscribe(t *testing.T) {
 					return
 				}
 			},
-			expectedError: errors.New(""),
+			expectedError: ErrPubSubBackend,
 			expectedPublication: &Publication{
 				Topic: subscribeTopic,
 				Data:  []byte("message"),
@@ -602,7 +600,7 @@ func TestSubscribe(t *testing.T) {
 				}
 			},
 			// we expect to get an error back, because we take longer than the configured timeout to respond to the publication
-			expectedError: errors.New(""),
+			expectedError: ErrPubSubReplyExpired,
 			subscribeOptions: []PubSubOptSubscribe{
 				// we deliberately set a low timeout here!
 				NATSOptSubscribeReplyTimeout(100 * time.Millisecond),
@@ -663,7 +661,7 @@ func TestSubscribe(t *testing.T) {
 		},
 		{
 			description:      "should receive an error in errors channel if subscribing fails for any reason",
-			expectedError:    nats.ErrConnectionClosed,
+			expectedError:    ErrPubSubNotConnected,
 			subscribeOptions: []PubSubOptSubscribe{},
 			setup:            func(t *testing.T, pub *Publication, client PubSubClient) {},
 			natsOptionsGenerator: func() ([]NATSOption, func()) {
@@ -713,7 +711,7 @@ func TestSubscribe(t *testing.T) {
 			)
 			done := make(chan struct{})
 			publications := make(chan *Publication)
-			errors := make(chan error)
+			errCh := make(chan error)
 			go func() {
 
 			Loop:
@@ -744,15 +742,15 @@ func TestSubscribe(t *testing.T) {
 							break Loop
 						}
 
-					case err := <-errors:
+					case err := <-errCh:
 
 						if test.expectedError == nil {
 							t.Errorf("received an unexpected error - err: \"%+v\"", err)
 							return
 						}
 
-						if actualErrType := reflect.TypeOf(test.expectedError); actualErrType != reflect.TypeOf(err) {
-							t.Errorf("received error of type \"%+v\", when an error of type \"%+v\" was expected", actualErrType, reflect.TypeOf(test.expectedError))
+						if !errors.Is(err, test.expectedError) {
+							t.Errorf("received error \"%v\", when an error matching \"%v\" was expected", err, test.expectedError)
 						}
 
 						break Loop
@@ -774,7 +772,7 @@ func TestSubscribe(t *testing.T) {
 				close(done)
 			}()
 
-			unsub := ps.Subscribe(publications, errors, subscribeTopic, test.subscribeOptions...)
+			unsub := ps.Subscribe(publications, errCh, subscribeTopic, test.subscribeOptions...)
 			defer unsub()
 			test.setup(t, test.expectedPublication, ps)
 
@@ -0,0 +1,142 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.aporeto.io/elemental"
+	"go.uber.org/zap"
+)
+
+const outboxRelayBatchSize = 100
+
+// An OutboxEntry is an event stored in a PushOutbox
+// waiting to be published.
+type OutboxEntry struct {
+
+	// ID is the unique identifier of the entry. It is used
+	// as the deduplication ID of the resulting publication.
+	ID string
+
+	// Event is the event to publish.
+	Event *elemental.Event
+}
+
+// A PushOutbox stores events until they are successfully
+// published by the push server.
+//
+// Processors should add their events to the outbox as part of the same
+// transaction as the database write that produced them. Implementations
+// backed by a database can retrieve the current transaction from the
+// given context.Context. Once configured using OptPushOutbox, the push server
+// will drain the outbox into the PubSubClient with at-least-once semantics.
+//
+// When an outbox is configured, the server does not push the output data of
+// create, update, delete and patch operations, as it cannot do so inside
+// the processor's transaction. Processors must add that event to the outbox
+// themselves, and must not also pass it to EnqueueEvents, as it would then be
+// published twice under two different IDs.
+type PushOutbox interface {
+
+	// Add adds the given events to the outbox.
+	Add(ctx context.Context, events ...*elemental.Event) error
+
+	// Pending returns at most max entries that have not been
+	// acknowledged yet, in the order they have been added.
+	Pending(ctx context.Context, max int) ([]*OutboxEntry, error)
+
+	// Ack removes the entries with the given IDs from the outbox.
+	Ack(ctx context.Context, ids ...string) error
+}
+
+// relayOutbox periodically drains the configured outbox until
+// the given context is canceled.
+func (n *pushServer) relayOutbox(ctx context.Context) {
+
+	interval := n.cfg.pushServer.outboxPollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.drainOutbox(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drainOutbox publishes all the pending entries of the outbox.
+// It stops at the first entry that fails to be published for
+// a reason that may be transient so ordering is preserved and
+// the entry is retried during the next drain.
+func (n *pushServer) drainOutbox(ctx context.Context) {
+
+	outbox := n.cfg.pushServer.outbox
+
+	for {
+
+		entries, err := outbox.Pending(ctx, outboxRelayBatchSize)
+		if err != nil {
+			zap.L().Error("Unable to retrieve pending entries from push outbox", zap.Error(err))
+			return
+		}
+
+		if len(entries) == 0 {
+			return
+		}
+
+		acked := make([]string, 0, len(entries))
+
+		for _, entry := range entries {
+
+			if err = n.publishEvent(ctx, entry.ID, entry.Event); err != nil {
+
+				if !errors.Is(err, ErrPubSubEncode) && !errors.Is(err, errShouldPublish) {
+					zap.L().Warn("Unable to publish event from push outbox. Will retry",
+						zap.String("id", entry.ID),
+						zap.Stringer("event", entry.Event),
+						zap.Error(err),
+					)
+					break
+				}
+
+				zap.L().Error("Dropping event from push outbox",
+					zap.String("id", entry.ID),
+					zap.Stringer("event", entry.Event),
+					zap.Error(err),
+				)
+			}
+
+			acked = append(acked, entry.ID)
+		}
+
+		if len(acked) > 0 {
+			if err := outbox.Ack(ctx, acked...); err != nil {
+				zap.L().Error("Unable to ack entries from push outbox", zap.Error(err))
+				return
+			}
+		}
+
+		if len(acked) < len(entries) || len(entries) < outboxRelayBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/gofrs/uuid"
+	"go.aporeto.io/elemental"
+)
+
+// outboxRecord is the on-disk representation
+// of an operation on a localPushOutbox.
+type outboxRecord struct {
+	ID    string `json:"id"`
+	Data  []byte `json:"data,omitempty"`
+	Acked bool   `json:"acked,omitempty"`
+}
+
+// localPushOutbox is a PushOutbox keeping entries in memory,
+// and optionally persisting them in an append-only file.
+type localPushOutbox struct {
+	file    *os.File
+	entries []*OutboxEntry
+	lock    sync.Mutex
+}
+
+// NewMemoryPushOutbox returns a PushOutbox keeping entries in memory.
+// Entries are lost if the process exits before they are published,
+// so it is mostly useful for testing.
+func NewMemoryPushOutbox() PushOutbox {
+
+	return &localPushOutbox{}
+}
+
+// NewFilePushOutbox returns a PushOutbox persisting its entries in
+// the file at the given path. If the file already exists, the entries
+// that have not been acknowledged are loaded back.
+func NewFilePushOutbox(path string) (PushOutbox, error) {
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open push outbox file: %w", err)
+	}
+
+	o := &localPushOutbox{file: file}
+
+	if err := o.load(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (o *localPushOutbox) Add(ctx context.Context, events ...*elemental.Event) error {
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	entries := make([]*OutboxEntry, len(events))
+	records := make([]outboxRecord, len(events))
+
+	for i, event := range events {
+
+		entries[i] = &OutboxEntry{
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			Event: event,
+		}
+
+		if o.file == nil {
+			continue
+		}
+
+		data, err := elemental.Encode(elemental.EncodingTypeMSGPACK, event)
+		if err != nil {
+			return fmt.Errorf("unable to encode event: %w", err)
+		}
+
+		records[i] = outboxRecord{ID: entries[i].ID, Data: data}
+	}
+
+	if err := o.write(records...); err != nil {
+		return err
+	}
+
+	o.entries = append(o.entries, entries...)
+
+	return nil
+}
+
+func (o *localPushOutbox) Pending(ctx context.Context, max int) ([]*OutboxEntry, error) {
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	n := len(o.entries)
+	if max > 0 && max < n {
+		n = max
+	}
+
+	out := make([]*OutboxEntry, n)
+	copy(out, o.entries[:n])
+
+	return out, nil
+}
+
+func (o *localPushOutbox) Ack(ctx context.Context, ids ...string) error {
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	acked := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		acked[id] = struct{}{}
+	}
+
+	records := make([]outboxRecord, 0, len(ids))
+	entries := make([]*OutboxEntry, 0, len(o.entries))
+
+	for _, entry := range o.entries {
+		if _, ok := acked[entry.ID]; ok {
+			records = append(records, outboxRecord{ID: entry.ID, Acked: true})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	// If nothing is pending anymore, we can simply
+	// truncate the file instead of writing the acks.
+	if len(entries) == 0 && o.file != nil {
+		if err := o.truncate(); err != nil {
+			return err
+		}
+	} else if err := o.write(records...); err != nil {
+		return err
+	}
+
+	o.entries = entries
+
+	return nil
+}
+
+// load reads the file and rebuilds the pending entries.
+func (o *localPushOutbox) load() error {
+
+	pending := map[string]*OutboxEntry{}
+	order := []string{}
+
+	scanner := bufio.NewScanner(o.file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+
+		record := outboxRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("unable to decode push outbox record: %w", err)
+		}
+
+		if record.Acked {
+			delete(pending, record.ID)
+			continue
+		}
+
+		event := &elemental.Event{}
+		if err := elemental.Decode(elemental.EncodingTypeMSGPACK, record.Data, event); err != nil {
+			return fmt.Errorf("unable to decode push outbox event: %w", err)
+		}
+
+		pending[record.ID] = &OutboxEntry{ID: record.ID, Event: event}
+		order = append(order, record.ID)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read push outbox file: %w", err)
+	}
+
+	for _, id := range order {
+		if entry, ok := pending[id]; ok {
+			o.entries = append(o.entries, entry)
+		}
+	}
+
+	return nil
+}
+
+// write appends the given records to the file, if any.
+func (o *localPushOutbox) write(records ...outboxRecord) error {
+
+	if o.file == nil || len(records) == 0 {
+		return nil
+	}
+
+	w := bufio.NewWriter(o.file)
+	enc := json.NewEncoder(w)
+
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("unable to encode push outbox record: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("unable to write push outbox file: %w", err)
+	}
+
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("unable to sync push outbox file: %w", err)
+	}
+
+	return nil
+}
+
+// truncate empties the file.
+func (o *localPushOutbox) truncate() error {
+
+	if err := o.file.Truncate(0); err != nil {
+		return fmt.Errorf("unable to truncate push outbox file: %w", err)
+	}
+
+	if _, err := o.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to truncate push outbox file: %w", err)
+	}
+
+	return nil
+}
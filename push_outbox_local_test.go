@@ -0,0 +1,140 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+func TestLocalPushOutbox_Memory(t *testing.T) {
+
+	Convey("Given I have a memory push outbox", t, func() {
+
+		ctx := context.Background()
+		o := NewMemoryPushOutbox()
+
+		Convey("When I add some events", func() {
+
+			err := o.Add(
+				ctx,
+				elemental.NewEvent(elemental.EventCreate, testmodel.NewList()),
+				elemental.NewEvent(elemental.EventUpdate, testmodel.NewList()),
+				elemental.NewEvent(elemental.EventDelete, testmodel.NewList()),
+			)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then Pending should return them in order", func() {
+				entries, err := o.Pending(ctx, 0)
+				So(err, ShouldBeNil)
+				So(len(entries), ShouldEqual, 3)
+				So(entries[0].ID, ShouldNotBeEmpty)
+				So(entries[0].ID, ShouldNotEqual, entries[1].ID)
+				So(entries[0].Event.Type, ShouldEqual, elemental.EventCreate)
+				So(entries[1].Event.Type, ShouldEqual, elemental.EventUpdate)
+				So(entries[2].Event.Type, ShouldEqual, elemental.EventDelete)
+			})
+
+			Convey("Then Pending should honor max", func() {
+				entries, err := o.Pending(ctx, 2)
+				So(err, ShouldBeNil)
+				So(len(entries), ShouldEqual, 2)
+			})
+
+			Convey("When I ack some of them", func() {
+
+				entries, _ := o.Pending(ctx, 0)
+				err := o.Ack(ctx, entries[0].ID, entries[2].ID)
+
+				Convey("Then only the remaining one should be pending", func() {
+					So(err, ShouldBeNil)
+					pending, _ := o.Pending(ctx, 0)
+					So(len(pending), ShouldEqual, 1)
+					So(pending[0].ID, ShouldEqual, entries[1].ID)
+				})
+			})
+		})
+	})
+}
+
+func TestLocalPushOutbox_File(t *testing.T) {
+
+	Convey("Given I have a file push outbox", t, func() {
+
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "outbox")
+
+		o, err := NewFilePushOutbox(path)
+		So(err, ShouldBeNil)
+
+		Convey("When I add some events, ack one and reload the outbox", func() {
+
+			_ = o.Add(
+				ctx,
+				elemental.NewEvent(elemental.EventCreate, testmodel.NewList()),
+				elemental.NewEvent(elemental.EventUpdate, testmodel.NewList()),
+			)
+
+			entries, _ := o.Pending(ctx, 0)
+			So(o.Ack(ctx, entries[0].ID), ShouldBeNil)
+
+			o2, err := NewFilePushOutbox(path)
+
+			Convey("Then only the unacked event should be pending", func() {
+				So(err, ShouldBeNil)
+				pending, err := o2.Pending(ctx, 0)
+				So(err, ShouldBeNil)
+				So(len(pending), ShouldEqual, 1)
+				So(pending[0].ID, ShouldEqual, entries[1].ID)
+				So(pending[0].Event.Type, ShouldEqual, elemental.EventUpdate)
+			})
+		})
+
+		Convey("When I add some events and ack all of them", func() {
+
+			_ = o.Add(ctx, elemental.NewEvent(elemental.EventCreate, testmodel.NewList()))
+			entries, _ := o.Pending(ctx, 0)
+			So(o.Ack(ctx, entries[0].ID), ShouldBeNil)
+
+			Convey("Then the file should be empty", func() {
+				info, err := os.Stat(path)
+				So(err, ShouldBeNil)
+				So(info.Size(), ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given I have a file push outbox with corrupted data", t, func() {
+
+		path := filepath.Join(t.TempDir(), "outbox")
+		_ = os.WriteFile(path, []byte("not json\n"), 0600)
+
+		Convey("When I create the outbox", func() {
+
+			_, err := NewFilePushOutbox(path)
+
+			Convey("Then err should not be nil", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
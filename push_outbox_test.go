@@ -0,0 +1,140 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-zoo/bone"
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+func TestPushOutbox_pushEvents(t *testing.T) {
+
+	Convey("Given I have a push server with an outbox", t, func() {
+
+		pf := func(identity elemental.Identity) (Processor, error) {
+			return struct{}{}, nil
+		}
+
+		srv := &mockPubSubServer{}
+		outbox := NewMemoryPushOutbox()
+
+		cfg := config{}
+		cfg.pushServer.service = srv
+		cfg.pushServer.enabled = true
+		cfg.pushServer.publishEnabled = true
+		cfg.pushServer.outbox = outbox
+
+		wss := newPushServer(cfg, bone.New(), pf)
+
+		Convey("When I call pushEvents", func() {
+
+			wss.pushEvents(
+				elemental.NewEvent(elemental.EventCreate, testmodel.NewList()),
+				elemental.NewEvent(elemental.EventUpdate, testmodel.NewList()),
+			)
+
+			Convey("Then nothing should be published", func() {
+				So(len(srv.publications), ShouldEqual, 0)
+			})
+
+			Convey("Then the events should be in the outbox", func() {
+				entries, err := outbox.Pending(context.Background(), 0)
+				So(err, ShouldBeNil)
+				So(len(entries), ShouldEqual, 2)
+				So(entries[0].Event.Type, ShouldEqual, elemental.EventCreate)
+				So(entries[1].Event.Type, ShouldEqual, elemental.EventUpdate)
+			})
+
+			Convey("When I drain the outbox", func() {
+
+				entries, _ := outbox.Pending(context.Background(), 0)
+				wss.drainOutbox(context.Background())
+
+				Convey("Then the events should be published with their deduplication ID", func() {
+					So(len(srv.publications), ShouldEqual, 2)
+					So(srv.publications[0].ID, ShouldEqual, entries[0].ID)
+					So(srv.publications[1].ID, ShouldEqual, entries[1].ID)
+				})
+
+				Convey("Then the outbox should be empty", func() {
+					entries, err := outbox.Pending(context.Background(), 0)
+					So(err, ShouldBeNil)
+					So(len(entries), ShouldEqual, 0)
+				})
+			})
+		})
+
+		Convey("When I drain the outbox while the pubsub is not connected", func() {
+
+			srv.PublishErr = newPubSubError("publish", "", ErrPubSubNotConnected, nil)
+
+			_ = outbox.Add(context.Background(), elemental.NewEvent(elemental.EventCreate, testmodel.NewList()))
+			wss.drainOutbox(context.Background())
+
+			Convey("Then the event should still be in the outbox", func() {
+				entries, err := outbox.Pending(context.Background(), 0)
+				So(err, ShouldBeNil)
+				So(len(entries), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I drain the outbox with a publish handler refusing the event", func() {
+
+			h := &mockSessionHandler{}
+			wss.cfg.pushServer.publishHandler = h
+
+			_ = outbox.Add(context.Background(), elemental.NewEvent(elemental.EventCreate, testmodel.NewList()))
+			wss.drainOutbox(context.Background())
+
+			Convey("Then nothing should be published", func() {
+				So(len(srv.publications), ShouldEqual, 0)
+			})
+
+			Convey("Then the event should be removed from the outbox", func() {
+				entries, err := outbox.Pending(context.Background(), 0)
+				So(err, ShouldBeNil)
+				So(len(entries), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestPushOutbox_isDuplicate(t *testing.T) {
+
+	Convey("Given I have a push server", t, func() {
+
+		wss := newPushServer(config{}, bone.New(), nil)
+
+		Convey("Then publications without ID should never be duplicates", func() {
+			So(wss.isDuplicate(NewPublication("topic")), ShouldBeFalse)
+			So(wss.isDuplicate(NewPublication("topic")), ShouldBeFalse)
+		})
+
+		Convey("Then publications with the same ID should be deduplicated", func() {
+			p1 := NewPublication("topic")
+			p1.ID = "a"
+			p2 := NewPublication("topic")
+			p2.ID = "b"
+
+			So(wss.isDuplicate(p1), ShouldBeFalse)
+			So(wss.isDuplicate(p2), ShouldBeFalse)
+			So(wss.isDuplicate(p1), ShouldBeTrue)
+			So(wss.isDuplicate(p1.Duplicate()), ShouldBeTrue)
+		})
+	})
+}
@@ -15,10 +15,12 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +30,8 @@ import (
 	"github.com/valyala/tcplisten"
 	"go.aporeto.io/elemental"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var (
@@ -44,6 +48,7 @@ var (
 type restServer struct {
 	multiplexer     *bone.Mux
 	server          *http.Server
+	http3Server     HTTP3Server
 	processorFinder processorFinderFunc
 	pusher          eventPusherFunc
 	customHandlers  retrieveHandlersFunc
@@ -89,6 +94,10 @@ func (a *restServer) createSecureHTTPServer(address string) *http.Server {
 		tlsConfig.Certificates = a.cfg.tls.serverCertificates
 	}
 
+	if a.cfg.tls.reloader != nil {
+		tlsConfig = a.cfg.tls.reloader.TLSConfig(tlsConfig)
+	}
+
 	server := &http.Server{
 		Addr:         address,
 		TLSConfig:    tlsConfig,
@@ -103,6 +112,15 @@ func (a *restServer) createSecureHTTPServer(address string) *http.Server {
 	return server
 }
 
+// isTLSEnabled returns true if the server
+// has been configured with certificates.
+func (a *restServer) isTLSEnabled() bool {
+
+	return a.cfg.tls.serverCertificates != nil ||
+		a.cfg.tls.serverCertificatesRetrieverFunc != nil ||
+		a.cfg.tls.reloader != nil
+}
+
 // createUnsecureHTTPServer returns a insecure HTTP Server.
 //
 // It will return an error if any.
@@ -118,7 +136,7 @@ func (a *restServer) createUnsecureHTTPServer(address string) *http.Server {
 }
 
 // installRoutes installs all the routes declared in the APIServerConfig.
-func (a *restServer) installRoutes(routesInfo map[int][]RouteInfo) {
+func (a *restServer) installRoutes(routesInfo map[int][]RouteInfo, openAPI map[int]*OpenAPIDocument) {
 
 	a.multiplexer.NotFound(http.HandlerFunc(makeNotFoundHandler(a.cfg.security.corsController)))
 
@@ -150,6 +168,30 @@ func (a *restServer) installRoutes(routesInfo map[int][]RouteInfo) {
 			w.WriteHeader(200)
 			_, _ = w.Write(encodedRoutesInfo) // nolint: errcheck
 		}))
+
+		encodedOpenAPI := make(map[string][]byte, len(openAPI))
+		for version, doc := range openAPI {
+			data, err := json.Marshal(doc)
+			if err != nil {
+				panic(fmt.Sprintf("Unable to build openapi document: %s", err))
+			}
+			encodedOpenAPI[strconv.Itoa(version)] = data
+		}
+
+		notFound := makeNotFoundHandler(a.cfg.security.corsController)
+
+		a.multiplexer.Get("/_meta/openapi/v/:version", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			data, ok := encodedOpenAPI[bone.GetValue(r, "version")]
+			if !ok {
+				notFound(w, r)
+				return
+			}
+
+			setCommonHeader(w, elemental.EncodingTypeJSON)
+			w.WriteHeader(200)
+			_, _ = w.Write(data) // nolint: errcheck
+		}))
 	}
 
 	if a.cfg.meta.version != nil {
@@ -205,12 +247,12 @@ func (a *restServer) installRoutes(routesInfo map[int][]RouteInfo) {
 	}
 }
 
-func (a *restServer) start(ctx context.Context, routesInfo map[int][]RouteInfo) {
+func (a *restServer) start(ctx context.Context, routesInfo map[int][]RouteInfo, openAPI map[int]*OpenAPIDocument) {
 
-	a.installRoutes(routesInfo)
+	a.installRoutes(routesInfo, openAPI)
 
 	var err error
-	if a.cfg.tls.serverCertificates != nil || a.cfg.tls.serverCertificatesRetrieverFunc != nil {
+	if a.isTLSEnabled() {
 		a.server = a.createSecureHTTPServer(a.cfg.restServer.listenAddress)
 	} else {
 		a.server = a.createUnsecureHTTPServer(a.cfg.restServer.listenAddress)
@@ -219,6 +261,41 @@ func (a *restServer) start(ctx context.Context, routesInfo map[int][]RouteInfo)
 	// This is just noise.
 	a.server.Handler = a.multiplexer
 
+	if a.cfg.restServer.h2cEnabled && a.server.TLSConfig == nil {
+		a.server.Handler = h2c.NewHandler(a.multiplexer, &http2.Server{IdleTimeout: a.cfg.restServer.idleTimeout})
+	}
+
+	if a.cfg.restServer.http3ServerMaker != nil {
+
+		if a.server.TLSConfig == nil {
+			zap.L().Fatal("Unable to start HTTP/3 server: TLS must be configured")
+		}
+
+		a.http3Server, err = a.cfg.restServer.http3ServerMaker(
+			a.cfg.restServer.http3ListenAddress,
+			a.server.TLSConfig.Clone(),
+			a.multiplexer,
+		)
+		if err != nil {
+			zap.L().Fatal("Unable to create HTTP/3 server", zap.Error(err))
+		}
+
+		var altSvc string
+		if altSvc, err = makeAltSvcHeader(a.cfg.restServer.http3ListenAddress); err != nil {
+			zap.L().Fatal("Unable to create HTTP/3 server", zap.Error(err))
+		}
+
+		a.server.Handler = makeAltSvcHandler(a.server.Handler, altSvc)
+
+		go func() {
+			if err := a.http3Server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				zap.L().Error("HTTP/3 server stopped", zap.Error(err))
+			}
+		}()
+
+		zap.L().Info("HTTP/3 server started", zap.String("address", a.cfg.restServer.http3ListenAddress))
+	}
+
 	if metricManager := a.cfg.healthServer.metricsManager; metricManager != nil {
 		a.server.ConnState = func(conn net.Conn, state http.ConnState) {
 			switch state {
@@ -246,7 +323,7 @@ func (a *restServer) start(ctx context.Context, routesInfo map[int][]RouteInfo)
 
 		listener = newListener(listener, a.cfg.restServer.maxConnection)
 
-		if a.cfg.tls.serverCertificates != nil || a.cfg.tls.serverCertificatesRetrieverFunc != nil {
+		if a.isTLSEnabled() {
 			err = a.server.ServeTLS(listener, "", "")
 		} else {
 			err = a.server.Serve(listener)
@@ -278,6 +355,12 @@ func (a *restServer) stop() context.Context {
 		}
 	}()
 
+	if a.http3Server != nil {
+		if err := a.http3Server.Close(); err != nil {
+			zap.L().Error("Could not stop HTTP/3 server", zap.Error(err))
+		}
+	}
+
 	return ctx
 }
 
@@ -344,6 +427,25 @@ func (a *restServer) makeHandler(handler handlerFunc) http.HandlerFunc {
 			return
 		}
 
+		if err := readRequestBody(w, req, a.maxRequestBodySize(req.URL.Path, manager)); err != nil {
+			code := writeHTTPResponse(
+				w,
+				makeErrorResponse(
+					req.Context(),
+					elemental.NewResponse(elemental.NewRequest()),
+					err,
+					nil,
+					nil,
+				),
+				req.Header.Get("origin"),
+				corsPolicy,
+			)
+			if measure != nil {
+				measure(code, nil)
+			}
+			return
+		}
+
 		request, err := elemental.NewRequestFromHTTPRequest(req, manager)
 		if err != nil {
 			code := writeHTTPResponse(
@@ -440,3 +542,16 @@ func (a *restServer) makeHandler(handler handlerFunc) http.HandlerFunc {
 
 	return gziphandler.GzipHandler(h).(http.HandlerFunc)
 }
+
+// maxRequestBodySize returns the maximum size of the body
+// of a request targeting the given path.
+func (a *restServer) maxRequestBodySize(path string, manager elemental.ModelManager) int64 {
+
+	if len(a.cfg.restServer.maxRequestBodySizes) > 0 {
+		if size, ok := a.cfg.restServer.maxRequestBodySizes[manager.IdentityFromCategory(extractCategory(path))]; ok {
+			return size
+		}
+	}
+
+	return a.cfg.restServer.maxRequestBodySize
+}
@@ -12,7 +12,12 @@
 package bahamut
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -25,8 +30,14 @@ import (
 var (
 	ErrNotFound  = elemental.NewError("Not Found", "Unable to find the requested resource", "bahamut", http.StatusNotFound)
 	ErrRateLimit = elemental.NewError("Rate Limit", "You have exceeded your rate limit", "bahamut", http.StatusTooManyRequests)
+
+	ErrRequestEntityTooLarge = elemental.NewError("Request Entity Too Large", "The request body exceeds the maximum allowed size", "bahamut", http.StatusRequestEntityTooLarge)
 )
 
+// defaultMaxDecompressedBodySize is the maximum size of a decompressed
+// request body when no maximum request body size is configured.
+const defaultMaxDecompressedBodySize = 64 << 20
+
 func setCommonHeader(w http.ResponseWriter, encoding elemental.EncodingType) {
 
 	w.Header().Set("Accept", "application/msgpack,application/json")
@@ -121,3 +132,117 @@ func extractAPIVersion(path string) (version int, err error) {
 
 	return version, nil
 }
+
+// extractCategory returns the category targeted by the given path.
+func extractCategory(path string) string {
+
+	components := strings.Split(strings.Trim(path, "/"), "/")
+	if len(components) >= 2 && components[0] == "v" {
+		components = components[2:]
+	}
+
+	switch len(components) {
+	case 0:
+		return ""
+	case 1, 2:
+		return components[0]
+	default:
+		return components[2]
+	}
+}
+
+// readRequestBody reads the body of the given request, making sure it
+// does not exceed the given max size, and replaces it by the data read.
+// If the body is gzip encoded, it is decompressed and the max size
+// applies to the decompressed data as well.
+func readRequestBody(w http.ResponseWriter, req *http.Request, max int64) error {
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	gzipped := strings.EqualFold(req.Header.Get("Content-Encoding"), "gzip")
+
+	if max <= 0 && !gzipped {
+		return nil
+	}
+
+	if max > 0 && req.ContentLength > max {
+		return ErrRequestEntityTooLarge
+	}
+
+	var body io.Reader = req.Body
+	if max > 0 {
+		body = http.MaxBytesReader(w, req.Body, max)
+	}
+
+	limit := max
+	if gzipped {
+
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return makeReadBodyError(err)
+		}
+		defer zr.Close() // nolint: errcheck
+
+		if limit <= 0 {
+			limit = defaultMaxDecompressedBodySize
+		}
+
+		// We read one more byte than allowed to
+		// know if the decompressed data is too large.
+		body = io.LimitReader(zr, limit+1)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return makeReadBodyError(err)
+	}
+
+	if gzipped && int64(len(data)) > limit {
+		return ErrRequestEntityTooLarge
+	}
+
+	_ = req.Body.Close()
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+
+	if gzipped {
+		req.Header.Del("Content-Encoding")
+	}
+
+	return nil
+}
+
+func makeReadBodyError(err error) error {
+
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return ErrRequestEntityTooLarge
+	}
+
+	return elemental.NewError("Bad Request", fmt.Sprintf("Unable to read request body: %s", err), "bahamut", http.StatusBadRequest)
+}
+
+// makeAltSvcHeader returns the value of the Alt-Svc header
+// advertising an HTTP/3 endpoint listening on the given address.
+func makeAltSvcHeader(address string) (string, error) {
+
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid HTTP/3 listen address '%s': %w", address, err)
+	}
+
+	return fmt.Sprintf(`h3=":%s"; ma=86400`, port), nil
+}
+
+// makeAltSvcHandler returns a http.Handler setting the
+// given Alt-Svc header before calling the given handler.
+func makeAltSvcHandler(h http.Handler, altSvc string) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", altSvc)
+		h.ServeHTTP(w, r)
+	})
+}
@@ -12,9 +12,13 @@
 package bahamut
 
 import (
+	"bytes"
+	"compress/gzip"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	// nolint:revive // Allow dot imports for readability in tests
@@ -269,3 +273,135 @@ func Test_extractAPIVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestRestServerHelper_altSvc(t *testing.T) {
+
+	Convey("Given I call makeAltSvcHeader with a valid address", t, func() {
+		h, err := makeAltSvcHeader("0.0.0.0:443")
+		So(err, ShouldBeNil)
+		So(h, ShouldEqual, `h3=":443"; ma=86400`)
+	})
+
+	Convey("Given I call makeAltSvcHeader with an invalid address", t, func() {
+		_, err := makeAltSvcHeader("nope")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given I have a handler wrapped by makeAltSvcHandler", t, func() {
+
+		h := makeAltSvcHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}), `h3=":443"`)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		So(w.Code, ShouldEqual, http.StatusTeapot)
+		So(w.Header().Get("Alt-Svc"), ShouldEqual, `h3=":443"`)
+	})
+}
+
+func Test_extractCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"empty", "", ""},
+		{"category", "/objects", "objects"},
+		{"object", "/objects/xxx", "objects"},
+		{"children", "/objects/xxx/children", "children"},
+		{"versioned category", "/v/1/objects", "objects"},
+		{"versioned object", "/v/1/objects/xxx", "objects"},
+		{"versioned children", "/v/1/objects/xxx/children", "children"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractCategory(tt.path); got != tt.want {
+				t.Errorf("extractCategory() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestServerHelper_readRequestBody(t *testing.T) {
+
+	gzipData := func(data string) []byte {
+		buf := &bytes.Buffer{}
+		zw := gzip.NewWriter(buf)
+		_, _ = zw.Write([]byte(data))
+		_ = zw.Close()
+		return buf.Bytes()
+	}
+
+	Convey("Given I have a request with a body smaller than the limit", t, func() {
+
+		req := httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader("hello"))
+		err := readRequestBody(httptest.NewRecorder(), req, 10)
+
+		So(err, ShouldBeNil)
+		data, _ := io.ReadAll(req.Body)
+		So(string(data), ShouldEqual, "hello")
+	})
+
+	Convey("Given I have a request with a content length larger than the limit", t, func() {
+
+		req := httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader("hello world"))
+		err := readRequestBody(httptest.NewRecorder(), req, 10)
+
+		So(err, ShouldResemble, ErrRequestEntityTooLarge)
+	})
+
+	Convey("Given I have a request with an unknown content length larger than the limit", t, func() {
+
+		req := httptest.NewRequest(http.MethodPost, "/objects", io.NopCloser(strings.NewReader("hello world")))
+		req.ContentLength = -1
+		err := readRequestBody(httptest.NewRecorder(), req, 10)
+
+		So(err, ShouldResemble, ErrRequestEntityTooLarge)
+	})
+
+	Convey("Given I have a request with no limit", t, func() {
+
+		req := httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader("hello world"))
+		body := req.Body
+		err := readRequestBody(httptest.NewRecorder(), req, 0)
+
+		So(err, ShouldBeNil)
+		So(req.Body, ShouldResemble, body)
+	})
+
+	Convey("Given I have a gzip encoded request", t, func() {
+
+		req := httptest.NewRequest(http.MethodPost, "/objects", bytes.NewReader(gzipData("hello")))
+		req.Header.Set("Content-Encoding", "gzip")
+		err := readRequestBody(httptest.NewRecorder(), req, 100)
+
+		So(err, ShouldBeNil)
+		So(req.Header.Get("Content-Encoding"), ShouldBeEmpty)
+		data, _ := io.ReadAll(req.Body)
+		So(string(data), ShouldEqual, "hello")
+	})
+
+	Convey("Given I have a gzip encoded request decompressing to more than the limit", t, func() {
+
+		compressed := gzipData(strings.Repeat("a", 1000))
+		So(len(compressed), ShouldBeLessThan, 100)
+
+		req := httptest.NewRequest(http.MethodPost, "/objects", bytes.NewReader(compressed))
+		req.Header.Set("Content-Encoding", "gzip")
+		err := readRequestBody(httptest.NewRecorder(), req, 100)
+
+		So(err, ShouldResemble, ErrRequestEntityTooLarge)
+	})
+
+	Convey("Given I have an invalid gzip encoded request", t, func() {
+
+		req := httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader("not gzip"))
+		req.Header.Set("Content-Encoding", "gzip")
+		err := readRequestBody(httptest.NewRecorder(), req, 100)
+
+		So(err, ShouldNotBeNil)
+		So(err.(elemental.Error).Code, ShouldEqual, http.StatusBadRequest)
+	})
+}
@@ -16,6 +16,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -32,6 +33,7 @@ import (
 	. "github.com/smartystreets/goconvey/convey"
 	"go.aporeto.io/elemental"
 	testmodel "go.aporeto.io/elemental/test/model"
+	"golang.org/x/net/http2"
 	"golang.org/x/time/rate"
 )
 
@@ -157,11 +159,11 @@ func TestServer_RouteInstallation(t *testing.T) {
 
 		Convey("When I install the routes", func() {
 
-			c.installRoutes(routes)
+			c.installRoutes(routes, nil)
 
 			Convey("Then the bone Multiplexer should have correct number of handlers", func() {
 				So(len(c.multiplexer.Routes[http.MethodPost]), ShouldEqual, 5)
-				So(len(c.multiplexer.Routes[http.MethodGet]), ShouldEqual, 10)
+				So(len(c.multiplexer.Routes[http.MethodGet]), ShouldEqual, 11)
 				So(len(c.multiplexer.Routes[http.MethodDelete]), ShouldEqual, 3)
 				So(len(c.multiplexer.Routes[http.MethodPatch]), ShouldEqual, 3)
 				So(len(c.multiplexer.Routes[http.MethodHead]), ShouldEqual, 5)
@@ -208,11 +210,11 @@ func TestServer_RouteInstallation(t *testing.T) {
 
 		Convey("When I install the routes", func() {
 
-			c.installRoutes(routes)
+			c.installRoutes(routes, nil)
 
 			Convey("Then the bone Multiplexer should have correct number of handlers", func() {
 				So(len(c.multiplexer.Routes[http.MethodPost]), ShouldEqual, 6)
-				So(len(c.multiplexer.Routes[http.MethodGet]), ShouldEqual, 11)
+				So(len(c.multiplexer.Routes[http.MethodGet]), ShouldEqual, 12)
 				So(len(c.multiplexer.Routes[http.MethodDelete]), ShouldEqual, 4)
 				So(len(c.multiplexer.Routes[http.MethodPatch]), ShouldEqual, 4)
 				So(len(c.multiplexer.Routes[http.MethodHead]), ShouldEqual, 6)
@@ -232,6 +234,58 @@ func TestServer_RouteInstallation(t *testing.T) {
 	})
 }
 
+func TestServer_OpenAPIRoute(t *testing.T) {
+
+	Convey("Given I have a rest server with openapi documents", t, func() {
+
+		cfg := config{}
+		cfg.restServer.customRootHandlerFunc = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+		c := newRestServer(cfg, bone.New(), nil, nil, nil)
+		c.installRoutes(nil, map[int]*OpenAPIDocument{
+			1: {OpenAPI: openAPIVersion, Info: OpenAPIInfo{Title: "hello", Version: "1.0"}},
+		})
+
+		Convey("When I retrieve the document of a known version", func() {
+
+			w := httptest.NewRecorder()
+			c.multiplexer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_meta/openapi/v/1", nil))
+
+			Convey("Then I should get the document", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldStartWith, "application/json")
+				So(w.Body.String(), ShouldContainSubstring, `"openapi":"3.0.3"`)
+				So(w.Body.String(), ShouldContainSubstring, `"title":"hello"`)
+			})
+		})
+
+		Convey("When I retrieve the document of an unknown version", func() {
+
+			w := httptest.NewRecorder()
+			c.multiplexer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_meta/openapi/v/2", nil))
+
+			Convey("Then I should get a 404", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+
+	Convey("Given I have a rest server with meta routes disabled", t, func() {
+
+		cfg := config{}
+		cfg.meta.disableMetaRoute = true
+
+		c := newRestServer(cfg, bone.New(), nil, nil, nil)
+		c.installRoutes(nil, map[int]*OpenAPIDocument{1: {}})
+
+		Convey("Then the openapi route should not be installed", func() {
+			for _, route := range c.multiplexer.Routes[http.MethodGet] {
+				So(route.Path, ShouldNotEqual, "/_meta/openapi/v/:version")
+			}
+		})
+	})
+}
+
 func TestServer_Start(t *testing.T) {
 
 	Convey("Given I create an api without tls server", t, func() {
@@ -246,7 +300,7 @@ func TestServer_Start(t *testing.T) {
 			c := newRestServer(cfg, bone.New(), nil, nil, nil)
 			defer c.stop()
 
-			go c.start(context.TODO(), nil)
+			go c.start(context.TODO(), nil, nil)
 			time.Sleep(30 * time.Millisecond)
 
 			resp, err := http.Get("http://127.0.0.1:" + port1)
@@ -277,7 +331,7 @@ func TestServer_Start(t *testing.T) {
 			c := newRestServer(cfg, bone.New(), nil, nil, nil)
 			defer c.stop()
 
-			go c.start(context.TODO(), nil)
+			go c.start(context.TODO(), nil, nil)
 			time.Sleep(30 * time.Millisecond)
 
 			cert, _ := tls.LoadX509KeyPair("fixtures/certs/client-cert.pem", "fixtures/certs/client-key.pem")
@@ -300,6 +354,121 @@ func TestServer_Start(t *testing.T) {
 			})
 		})
 	})
+
+	Convey("Given I create an api without tls server with h2c enabled", t, func() {
+
+		Convey("When I start the server", func() {
+
+			port1 := strconv.Itoa(rand.Intn(10000) + 30000)
+
+			cfg := config{}
+			cfg.restServer.listenAddress = "127.0.0.1:" + port1
+			cfg.restServer.h2cEnabled = true
+
+			c := newRestServer(cfg, bone.New(), nil, nil, nil)
+			defer c.stop()
+
+			go c.start(context.TODO(), nil, nil)
+			time.Sleep(30 * time.Millisecond)
+
+			client := &http.Client{
+				Transport: &http2.Transport{
+					AllowHTTP: true,
+					DialTLSContext: func(ctx context.Context, network string, addr string, _ *tls.Config) (net.Conn, error) {
+						return (&net.Dialer{}).DialContext(ctx, network, addr)
+					},
+				},
+			}
+
+			resp, err := client.Get("http://127.0.0.1:" + port1)
+
+			Convey("Then the response should use HTTP/2", func() {
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, 200)
+				So(resp.ProtoMajor, ShouldEqual, 2)
+			})
+
+			resp, err = http.Get("http://127.0.0.1:" + port1)
+
+			Convey("Then HTTP/1.1 should still be served", func() {
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, 200)
+				So(resp.ProtoMajor, ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given I create an api with tls server and HTTP/3", t, func() {
+
+		Convey("When I start the server", func() {
+
+			port1 := strconv.Itoa(rand.Intn(10000) + 50000)
+
+			_, _, servercerts := loadFixtureCertificates()
+
+			h3 := &mockHTTP3Server{started: make(chan struct{})}
+			var h3Address string
+			var h3TLSConfig *tls.Config
+
+			cfg := config{}
+			cfg.restServer.listenAddress = "127.0.0.1:" + port1
+			cfg.tls.serverCertificates = servercerts
+			cfg.restServer.http3ListenAddress = "127.0.0.1:4443"
+			cfg.restServer.http3ServerMaker = func(address string, tlsConfig *tls.Config, handler http.Handler) (HTTP3Server, error) {
+				h3Address = address
+				h3TLSConfig = tlsConfig
+				return h3, nil
+			}
+
+			c := newRestServer(cfg, bone.New(), nil, nil, nil)
+
+			go c.start(context.TODO(), nil, nil)
+			time.Sleep(30 * time.Millisecond)
+
+			cacert, _ := os.ReadFile("fixtures/certs/ca-cert.pem")
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(cacert)
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+			resp, err := client.Get("https://localhost:" + port1)
+
+			Convey("Then the HTTP/3 server should be started", func() {
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, 200)
+				So(resp.Header.Get("Alt-Svc"), ShouldEqual, `h3=":4443"; ma=86400`)
+
+				select {
+				case <-h3.started:
+				case <-time.After(time.Second):
+					So("HTTP/3 server not started", ShouldBeNil)
+				}
+
+				So(h3Address, ShouldEqual, "127.0.0.1:4443")
+				So(h3TLSConfig, ShouldNotBeNil)
+			})
+
+			<-c.stop().Done()
+
+			Convey("Then stopping the server should close the HTTP/3 server", func() {
+				So(h3.closed, ShouldBeTrue)
+			})
+		})
+	})
+}
+
+type mockHTTP3Server struct {
+	started chan struct{}
+	closed  bool
+}
+
+func (s *mockHTTP3Server) ListenAndServe() error {
+	close(s.started)
+	return nil
+}
+
+func (s *mockHTTP3Server) Close() error {
+	s.closed = true
+	return nil
 }
 
 type mockMetricsManager struct {
@@ -0,0 +1,245 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.aporeto.io/bahamut/internal/filewatch"
+	"go.aporeto.io/bahamut/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// tlsMaterial is a consistent set of TLS
+// material loaded by a TLSReloader.
+type tlsMaterial struct {
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+// A TLSReloaderOption represents an option to the TLSReloader.
+type TLSReloaderOption func(*TLSReloader)
+
+// TLSReloaderOptClientCAFiles sets the PEM files containing the
+// certificate authorities used to verify the client certificates.
+// If not set, the TLSReloader does not manage the client CA pool.
+func TLSReloaderOptClientCAFiles(files ...string) TLSReloaderOption {
+	return func(r *TLSReloader) {
+		r.caFiles = files
+	}
+}
+
+// TLSReloaderOptCheckInterval sets how often the files are checked
+// for changes. The default is 10s.
+func TLSReloaderOptCheckInterval(interval time.Duration) TLSReloaderOption {
+	return func(r *TLSReloader) {
+		r.interval = interval
+	}
+}
+
+// TLSReloaderOptMetricsRegisterer sets the prometheus.Registerer used to
+// register the reload counter. The default is prometheus.DefaultRegisterer.
+// Passing nil disables the metric.
+func TLSReloaderOptMetricsRegisterer(registerer prometheus.Registerer) TLSReloaderOption {
+	return func(r *TLSReloader) {
+		r.registerer = registerer
+	}
+}
+
+// A TLSReloader keeps a server certificate and an optional client CA
+// pool in sync with PEM files on disk.
+//
+// The files are checked periodically once Watch is called. When they
+// change, the new material is validated before replacing the current one
+// atomically. If it is invalid, the error is logged and the current material
+// is kept. The TLSReloader can be used with OptTLSReloader for the bahamut
+// rest server, or through TLSConfig for any other server, like the gateway.
+type TLSReloader struct {
+	material     atomic.Pointer[tlsMaterial]
+	watcher      *filewatch.Watcher
+	registerer   prometheus.Registerer
+	reloadMetric *prometheus.CounterVec
+	certFile     string
+	keyFile      string
+	caFiles      []string
+	interval     time.Duration
+}
+
+// NewTLSReloader returns a new TLSReloader for the given certificate
+// and key files. The files are loaded immediately and an error is
+// returned if they are not valid.
+func NewTLSReloader(certFile string, keyFile string, options ...TLSReloaderOption) (*TLSReloader, error) {
+
+	r := &TLSReloader{
+		certFile:   certFile,
+		keyFile:    keyFile,
+		interval:   10 * time.Second,
+		registerer: prometheus.DefaultRegisterer,
+	}
+
+	for _, opt := range options {
+		opt(r)
+	}
+
+	r.watcher = filewatch.New(r.load, append([]string{certFile, keyFile}, r.caFiles...)...)
+
+	if r.registerer != nil {
+
+		var err error
+
+		if r.reloadMetric, err = metrics.RegisterCollector(r.registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tls_reloads_total",
+				Help: "The total number of TLS material reloads.",
+			},
+			[]string{"result"},
+		)); err != nil {
+			return nil, fmt.Errorf("unable to register tls reload metric: %w", err)
+		}
+	}
+
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Watch calls Reload at the interval set by TLSReloaderOptCheckInterval
+// until the given context is canceled.
+func (r *TLSReloader) Watch(ctx context.Context) {
+
+	filewatch.Watch(ctx, r.interval, r.Reload, func(err error) {
+		zap.L().Error("Unable to reload TLS material",
+			zap.String("cert", r.certFile),
+			zap.Strings("cas", r.caFiles),
+			zap.Error(err),
+		)
+	})
+}
+
+// Reload replaces the current material if any of the files changed,
+// and returns true if it did. An expired certificate, a key that does
+// not match it or a CA file without any certificate is rejected and
+// the current material is kept.
+func (r *TLSReloader) Reload() (bool, error) {
+
+	changed, err := r.watcher.Reload()
+
+	switch {
+	case err != nil:
+		r.countReload("error")
+	case changed:
+		r.countReload("success")
+	}
+
+	return changed, err
+}
+
+// load is the filewatch.LoadFunc of the TLSReloader. It receives the
+// certificate, the key and the client CA files, in this order.
+func (r *TLSReloader) load(data [][]byte) error {
+
+	certPEM, keyPEM, caPEMs := data[0], data[1], data[2:]
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("invalid certificate or key: %w", err)
+	}
+
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("invalid certificate: %w", err)
+	}
+
+	now := time.Now()
+	if now.Before(cert.Leaf.NotBefore) || now.After(cert.Leaf.NotAfter) {
+		return fmt.Errorf("certificate is not valid between %s and %s", cert.Leaf.NotBefore, cert.Leaf.NotAfter)
+	}
+
+	var clientCAs *x509.CertPool
+	if len(caPEMs) > 0 {
+		clientCAs = x509.NewCertPool()
+		for i, pem := range caPEMs {
+			if !clientCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no valid certificate found in client CA file '%s'", r.caFiles[i])
+			}
+		}
+	}
+
+	r.material.Store(&tlsMaterial{
+		certificate: &cert,
+		clientCAs:   clientCAs,
+	})
+
+	zap.L().Debug("TLS material loaded", zap.String("cert", r.certFile), zap.Strings("cas", r.caFiles))
+
+	return nil
+}
+
+// GetCertificate returns the current certificate. It can be
+// used as the GetCertificate function of a *tls.Config.
+func (r *TLSReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+
+	return r.material.Load().certificate, nil
+}
+
+// ClientCAs returns the current client CA pool, or nil if
+// the TLSReloader has not been configured with CA files.
+func (r *TLSReloader) ClientCAs() *x509.CertPool {
+
+	return r.material.Load().clientCAs
+}
+
+// TLSConfig returns a copy of the given *tls.Config that uses the
+// current material for every new connection, through GetConfigForClient.
+// The given configuration must not be modified afterwards.
+func (r *TLSReloader) TLSConfig(base *tls.Config) *tls.Config {
+
+	if base == nil {
+		base = &tls.Config{}
+	}
+
+	base = base.Clone()
+	base.GetConfigForClient = nil
+
+	out := base.Clone()
+	out.GetCertificate = r.GetCertificate
+	out.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+
+		m := r.material.Load()
+
+		c := base.Clone()
+		c.Certificates = []tls.Certificate{*m.certificate}
+		c.GetCertificate = nil
+
+		if m.clientCAs != nil {
+			c.ClientCAs = m.clientCAs
+		}
+
+		return c, nil
+	}
+
+	return out
+}
+
+func (r *TLSReloader) countReload(result string) {
+
+	if r.reloadMetric != nil {
+		r.reloadMetric.WithLabelValues(result).Inc()
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-zoo/bone"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func copyFixture(t *testing.T, src string, dst string) {
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(dst, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSReloader(t *testing.T) {
+
+	Convey("Given I have certificate files", t, func() {
+
+		dir := t.TempDir()
+		certFile := filepath.Join(dir, "cert.pem")
+		keyFile := filepath.Join(dir, "key.pem")
+		caFile := filepath.Join(dir, "ca.pem")
+
+		copyFixture(t, "fixtures/certs/server-cert.pem", certFile)
+		copyFixture(t, "fixtures/certs/server-key.pem", keyFile)
+		copyFixture(t, "fixtures/certs/ca-cert.pem", caFile)
+
+		serverCert, _ := tls.LoadX509KeyPair("fixtures/certs/server-cert.pem", "fixtures/certs/server-key.pem")
+		clientCert, _ := tls.LoadX509KeyPair("fixtures/certs/client-cert.pem", "fixtures/certs/client-key.pem")
+
+		registry := prometheus.NewRegistry()
+
+		r, err := NewTLSReloader(
+			certFile,
+			keyFile,
+			TLSReloaderOptClientCAFiles(caFile),
+			TLSReloaderOptCheckInterval(10*time.Millisecond),
+			TLSReloaderOptMetricsRegisterer(registry),
+		)
+
+		Convey("Then the reloader should be correctly initialized", func() {
+			So(err, ShouldBeNil)
+			So(r.ClientCAs(), ShouldNotBeNil)
+
+			cert, err := r.GetCertificate(nil)
+			So(err, ShouldBeNil)
+			So(cert.Certificate, ShouldResemble, serverCert.Certificate)
+			So(cert.Leaf, ShouldNotBeNil)
+			So(testutil.ToFloat64(r.reloadMetric.WithLabelValues("success")), ShouldEqual, 1)
+		})
+
+		Convey("When I reload without changing the files", func() {
+
+			changed, err := r.Reload()
+
+			Convey("Then nothing should change", func() {
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeFalse)
+				So(testutil.ToFloat64(r.reloadMetric.WithLabelValues("success")), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I replace the certificate and reload", func() {
+
+			copyFixture(t, "fixtures/certs/client-cert.pem", certFile)
+			copyFixture(t, "fixtures/certs/client-key.pem", keyFile)
+
+			changed, err := r.Reload()
+
+			Convey("Then the certificate should be replaced", func() {
+				So(err, ShouldBeNil)
+				So(changed, ShouldBeTrue)
+
+				cert, _ := r.GetCertificate(nil)
+				So(cert.Certificate, ShouldResemble, clientCert.Certificate)
+				So(testutil.ToFloat64(r.reloadMetric.WithLabelValues("success")), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I replace the certificate with a mismatching key and reload", func() {
+
+			copyFixture(t, "fixtures/certs/client-cert.pem", certFile)
+
+			changed, err := r.Reload()
+
+			Convey("Then the current certificate should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(changed, ShouldBeFalse)
+
+				cert, _ := r.GetCertificate(nil)
+				So(cert.Certificate, ShouldResemble, serverCert.Certificate)
+				So(testutil.ToFloat64(r.reloadMetric.WithLabelValues("error")), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When I replace the CA with garbage and reload", func() {
+
+			pool := r.ClientCAs()
+			_ = os.WriteFile(caFile, []byte("not a pem"), 0600)
+
+			_, err := r.Reload()
+
+			Convey("Then the current pool should be kept", func() {
+				So(err, ShouldNotBeNil)
+				So(r.ClientCAs(), ShouldEqual, pool)
+			})
+		})
+
+		Convey("When I watch the files and replace the certificate", func() {
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go r.Watch(ctx)
+
+			copyFixture(t, "fixtures/certs/client-cert.pem", certFile)
+			copyFixture(t, "fixtures/certs/client-key.pem", keyFile)
+
+			Convey("Then the certificate should eventually be replaced", func() {
+
+				var cert *tls.Certificate
+				for i := 0; i < 100; i++ {
+					cert, _ = r.GetCertificate(nil)
+					if bytes.Equal(cert.Certificate[0], clientCert.Certificate[0]) {
+						break
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+
+				So(cert.Certificate, ShouldResemble, clientCert.Certificate)
+			})
+		})
+
+		Convey("When I get a tls.Config from the reloader", func() {
+
+			base := &tls.Config{MinVersion: tls.VersionTLS12, ClientAuth: tls.RequireAndVerifyClientCert}
+			cfg := r.TLSConfig(base)
+
+			Convey("Then it should use the current material", func() {
+
+				So(cfg.GetConfigForClient, ShouldNotBeNil)
+				So(base.GetConfigForClient, ShouldBeNil)
+
+				c, err := cfg.GetConfigForClient(nil)
+				So(err, ShouldBeNil)
+				So(c.MinVersion, ShouldEqual, tls.VersionTLS12)
+				So(c.ClientAuth, ShouldEqual, tls.RequireAndVerifyClientCert)
+				So(c.ClientCAs, ShouldEqual, r.ClientCAs())
+				So(c.Certificates[0].Certificate, ShouldResemble, serverCert.Certificate)
+
+				copyFixture(t, "fixtures/certs/client-cert.pem", certFile)
+				copyFixture(t, "fixtures/certs/client-key.pem", keyFile)
+				_, _ = r.Reload()
+
+				c, err = cfg.GetConfigForClient(nil)
+				So(err, ShouldBeNil)
+				So(c.Certificates[0].Certificate, ShouldResemble, clientCert.Certificate)
+			})
+		})
+	})
+
+	Convey("Given I have invalid certificate files", t, func() {
+
+		_, err := NewTLSReloader("not-here.pem", "not-here-key.pem", TLSReloaderOptMetricsRegisterer(nil))
+
+		Convey("Then creating the reloader should fail", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given I create two reloaders with the same registerer", t, func() {
+
+		registry := prometheus.NewRegistry()
+
+		r1, err1 := NewTLSReloader("fixtures/certs/server-cert.pem", "fixtures/certs/server-key.pem", TLSReloaderOptMetricsRegisterer(registry))
+		r2, err2 := NewTLSReloader("fixtures/certs/server-cert.pem", "fixtures/certs/server-key.pem", TLSReloaderOptMetricsRegisterer(registry))
+
+		Convey("Then they should share the same metric", func() {
+			So(err1, ShouldBeNil)
+			So(err2, ShouldBeNil)
+			So(r1.reloadMetric, ShouldEqual, r2.reloadMetric)
+			So(r1.ClientCAs(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given I have a rest server configured with a reloader", t, func() {
+
+		r, _ := NewTLSReloader(
+			"fixtures/certs/server-cert.pem",
+			"fixtures/certs/server-key.pem",
+			TLSReloaderOptClientCAFiles("fixtures/certs/ca-cert.pem"),
+			TLSReloaderOptMetricsRegisterer(nil),
+		)
+
+		cfg := config{}
+		cfg.tls.reloader = r
+
+		c := newRestServer(cfg, bone.New(), nil, nil, nil)
+
+		Convey("Then TLS should be enabled", func() {
+			So(c.isTLSEnabled(), ShouldBeTrue)
+		})
+
+		Convey("Then the secure server should use the reloader", func() {
+			srv := c.createSecureHTTPServer(":443")
+			So(srv.TLSConfig.GetConfigForClient, ShouldNotBeNil)
+
+			tc, err := srv.TLSConfig.GetConfigForClient(nil)
+			So(err, ShouldBeNil)
+			So(tc.ClientCAs, ShouldEqual, r.ClientCAs())
+		})
+	})
+}
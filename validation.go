@@ -0,0 +1,333 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.aporeto.io/elemental"
+)
+
+// CustomValidator is the type of function you can use to add
+// additional validation to the identifiables of a given identity.
+// It is called after the identifiable has been decoded and
+// validated by its own Validate method.
+type CustomValidator func(*elemental.Request, elemental.Identifiable) error
+
+// maxValidationDepth is the maximum depth of nested
+// attributes that the schema validation will inspect.
+const maxValidationDepth = 8
+
+// decodeRequestData decodes the data of the given request into dest.
+// If strict is true, attributes that are not part of the model of the
+// request identity are rejected. If decoding fails, the data is checked
+// against the model to return a validation error per faulty attribute.
+func decodeRequestData(req *elemental.Request, dest any, modelManager elemental.ModelManager, strict bool) error {
+
+	if strict {
+		if errs := validateRequestSchema(req, modelManager, true); len(errs) > 0 {
+			return errs
+		}
+	}
+
+	if err := req.Decode(dest); err != nil {
+
+		if errs := validateRequestSchema(req, modelManager, false); len(errs) > 0 {
+			return errs
+		}
+
+		return elemental.NewError("Bad Request", err.Error(), "bahamut", http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// validateIdentifiable runs the Validate method of the given identifiable,
+// if any, then all the given custom validators. All validation errors are
+// returned at once.
+func validateIdentifiable(req *elemental.Request, obj elemental.Identifiable, validators []CustomValidator) error {
+
+	var errs []error
+
+	if v, ok := obj.(elemental.Validatable); ok {
+		if err := v.Validate(); err != nil {
+			if len(validators) == 0 {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	for _, validator := range validators {
+		if err := validator(req, obj); err != nil {
+			errs = append(errs, asValidationError(err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return elemental.NewErrors(errs...)
+}
+
+// validateRequestSchema decodes the request data without a target type
+// and checks every attribute against the model of the request identity.
+func validateRequestSchema(req *elemental.Request, modelManager elemental.ModelManager, strict bool) elemental.Errors {
+
+	if modelManager == nil || len(req.Data) == 0 {
+		return nil
+	}
+
+	spec, ok := modelManager.Identifiable(req.Identity).(elemental.AttributeSpecifiable)
+	if !ok {
+		return nil
+	}
+
+	// If the data cannot be decoded at all, there is
+	// nothing we can say about individual attributes.
+	var data map[string]any
+	if err := elemental.Decode(req.ContentType, req.Data, &data); err != nil {
+		return nil
+	}
+
+	return validateAttributes("", data, spec, modelManager, strict, 0)
+}
+
+// validateAttributes checks the given attribute values against the given specifications.
+func validateAttributes(
+	path string,
+	data map[string]any,
+	spec elemental.AttributeSpecifiable,
+	modelManager elemental.ModelManager,
+	strict bool,
+	depth int,
+) elemental.Errors {
+
+	exposed := map[string]elemental.AttributeSpecification{}
+	for _, s := range spec.AttributeSpecifications() {
+		if s.Exposed {
+			exposed[s.Name] = s
+		}
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs elemental.Errors
+
+	for _, key := range keys {
+
+		attrPath := joinAttributePath(path, key)
+
+		s, ok := exposed[key]
+		if !ok {
+			if strict {
+				errs = append(errs, makeAttributeError(attrPath, fmt.Sprintf("Unknown attribute '%s'", attrPath)))
+			}
+			continue
+		}
+
+		errs = append(errs, validateAttributeValue(attrPath, data[key], s, modelManager, strict, depth)...)
+	}
+
+	return errs
+}
+
+// validateAttributeValue checks the given value against the given specification.
+func validateAttributeValue(
+	path string,
+	value any,
+	spec elemental.AttributeSpecification,
+	modelManager elemental.ModelManager,
+	strict bool,
+	depth int,
+) elemental.Errors {
+
+	if value == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(value)
+
+	switch spec.Type {
+
+	case "string", "enum":
+		if rv.Kind() != reflect.String {
+			return elemental.Errors{makeAttributeTypeError(path, "string", value)}
+		}
+
+	case "time":
+		if _, ok := value.(time.Time); !ok && rv.Kind() != reflect.String {
+			return elemental.Errors{makeAttributeTypeError(path, "time", value)}
+		}
+
+	case "boolean":
+		if rv.Kind() != reflect.Bool {
+			return elemental.Errors{makeAttributeTypeError(path, "boolean", value)}
+		}
+
+	case "integer":
+		if !isIntegerValue(rv) {
+			return elemental.Errors{makeAttributeTypeError(path, "integer", value)}
+		}
+
+	case "float":
+		if !isIntegerValue(rv) && rv.Kind() != reflect.Float32 && rv.Kind() != reflect.Float64 {
+			return elemental.Errors{makeAttributeTypeError(path, "float", value)}
+		}
+
+	case "list", "refList":
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return elemental.Errors{makeAttributeTypeError(path, "list", value)}
+		}
+
+		var errs elemental.Errors
+		for i := 0; i < rv.Len(); i++ {
+			errs = append(errs, validateSubValue(fmt.Sprintf("%s[%d]", path, i), rv.Index(i).Interface(), spec, modelManager, strict, depth)...)
+		}
+
+		return errs
+
+	case "object", "refMap":
+		if rv.Kind() != reflect.Map {
+			return elemental.Errors{makeAttributeTypeError(path, "object", value)}
+		}
+
+		if spec.Type == "refMap" {
+			var errs elemental.Errors
+			for _, k := range rv.MapKeys() {
+				errs = append(errs, validateSubValue(joinAttributePath(path, fmt.Sprint(k.Interface())), rv.MapIndex(k).Interface(), spec, modelManager, strict, depth)...)
+			}
+			return errs
+		}
+
+	case "ref":
+		return validateSubValue(path, value, spec, modelManager, strict, depth)
+	}
+
+	return nil
+}
+
+// validateSubValue checks an item of a list, or a nested ref, against the
+// subtype of the given specification.
+func validateSubValue(
+	path string,
+	value any,
+	spec elemental.AttributeSpecification,
+	modelManager elemental.ModelManager,
+	strict bool,
+	depth int,
+) elemental.Errors {
+
+	switch spec.SubType {
+
+	case "string", "integer", "float", "boolean", "time":
+		return validateAttributeValue(path, value, elemental.AttributeSpecification{Type: spec.SubType}, modelManager, strict, depth)
+
+	case "":
+		return nil
+	}
+
+	if depth >= maxValidationDepth {
+		return nil
+	}
+
+	// The subtype may be the name of another model. If so,
+	// we can validate the nested attributes as well.
+	nested, ok := modelManager.IdentifiableFromString(spec.SubType).(elemental.AttributeSpecifiable)
+	if !ok {
+		return nil
+	}
+
+	m, ok := toStringMap(value)
+	if !ok {
+		return elemental.Errors{makeAttributeTypeError(path, "object", value)}
+	}
+
+	return validateAttributes(path, m, nested, modelManager, strict, depth+1)
+}
+
+// makeAttributeTypeError returns a validation error for an attribute
+// whose value is not of the expected type.
+func makeAttributeTypeError(path string, expected string, value any) elemental.Error {
+
+	return makeAttributeError(path, fmt.Sprintf("Attribute '%s' must be of type %s, got %T", path, expected, value))
+}
+
+// makeAttributeError returns a validation error for the given attribute.
+func makeAttributeError(path string, description string) elemental.Error {
+
+	err := elemental.NewError("Validation Error", description, "bahamut", http.StatusUnprocessableEntity)
+	err.Data = map[string]any{"attribute": path}
+
+	return err
+}
+
+// asValidationError converts the given error into something that
+// can be returned to the client as a validation error.
+func asValidationError(err error) error {
+
+	switch err.(type) {
+	case elemental.Error, elemental.Errors:
+		return err
+	default:
+		return elemental.NewError("Validation Error", err.Error(), "bahamut", http.StatusUnprocessableEntity)
+	}
+}
+
+func joinAttributePath(path string, key string) string {
+
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func isIntegerValue(rv reflect.Value) bool {
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Float32, reflect.Float64:
+		// JSON numbers may be decoded as floats.
+		f := rv.Float()
+		return f == math.Trunc(f) && !math.IsInf(f, 0)
+	default:
+		return false
+	}
+}
+
+func toStringMap(value any) (map[string]any, bool) {
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Map {
+		return nil, false
+	}
+
+	out := make(map[string]any, rv.Len())
+	for _, k := range rv.MapKeys() {
+		out[fmt.Sprint(k.Interface())] = rv.MapIndex(k).Interface()
+	}
+
+	return out, true
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 Aporeto Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bahamut
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	// nolint:revive // Allow dot imports for readability in tests
+	. "github.com/smartystreets/goconvey/convey"
+	"go.aporeto.io/elemental"
+	testmodel "go.aporeto.io/elemental/test/model"
+)
+
+func TestValidation_decodeRequestData(t *testing.T) {
+
+	Convey("Given I have a request with an unknown attribute", t, func() {
+
+		req := elemental.NewRequest()
+		req.Identity = testmodel.ListIdentity
+		req.ContentType = elemental.EncodingTypeJSON
+		req.Data = []byte(`{"name": "hello", "notAnAttribute": true}`)
+
+		Convey("When I decode it in non strict mode", func() {
+
+			obj := testmodel.NewList()
+			err := decodeRequestData(req, obj, testmodel.Manager(), false)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+				So(obj.Name, ShouldEqual, "hello")
+			})
+		})
+
+		Convey("When I decode it in strict mode", func() {
+
+			obj := testmodel.NewList()
+			err := decodeRequestData(req, obj, testmodel.Manager(), true)
+
+			Convey("Then err should contain the unknown attribute", func() {
+				So(err, ShouldHaveSameTypeAs, elemental.Errors{})
+				errs := err.(elemental.Errors)
+				So(len(errs), ShouldEqual, 1)
+				So(errs[0].Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(errs[0].Data, ShouldResemble, map[string]any{"attribute": "notAnAttribute"})
+			})
+		})
+	})
+
+	Convey("Given I have a request with invalid data", t, func() {
+
+		req := elemental.NewRequest()
+		req.Identity = testmodel.ListIdentity
+		req.ContentType = elemental.EncodingTypeJSON
+		req.Data = []byte(`not json`)
+
+		Convey("When I decode it", func() {
+
+			err := decodeRequestData(req, testmodel.NewList(), testmodel.Manager(), true)
+
+			Convey("Then err should be a bad request", func() {
+				So(err, ShouldHaveSameTypeAs, elemental.Error{})
+				So(err.(elemental.Error).Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestValidation_validateAttributeValue(t *testing.T) {
+
+	Convey("Given I have some attribute specifications", t, func() {
+
+		str := elemental.AttributeSpecification{Type: "string"}
+		integer := elemental.AttributeSpecification{Type: "integer"}
+		float := elemental.AttributeSpecification{Type: "float"}
+		boolean := elemental.AttributeSpecification{Type: "boolean"}
+		list := elemental.AttributeSpecification{Type: "list", SubType: "string"}
+		object := elemental.AttributeSpecification{Type: "object"}
+
+		Convey("Then valid values should pass", func() {
+			So(validateAttributeValue("a", "x", str, nil, false, 0), ShouldBeEmpty)
+			So(validateAttributeValue("a", int64(1), integer, nil, false, 0), ShouldBeEmpty)
+			So(validateAttributeValue("a", float64(1), integer, nil, false, 0), ShouldBeEmpty)
+			So(validateAttributeValue("a", 1.5, float, nil, false, 0), ShouldBeEmpty)
+			So(validateAttributeValue("a", true, boolean, nil, false, 0), ShouldBeEmpty)
+			So(validateAttributeValue("a", []any{"x", "y"}, list, nil, false, 0), ShouldBeEmpty)
+			So(validateAttributeValue("a", map[string]any{}, object, nil, false, 0), ShouldBeEmpty)
+			So(validateAttributeValue("a", nil, str, nil, false, 0), ShouldBeEmpty)
+		})
+
+		Convey("Then invalid values should fail with the attribute path", func() {
+
+			errs := validateAttributeValue("a", 42, str, nil, false, 0)
+			So(len(errs), ShouldEqual, 1)
+			So(errs[0].Data, ShouldResemble, map[string]any{"attribute": "a"})
+
+			errs = validateAttributeValue("a", 1.5, integer, nil, false, 0)
+			So(len(errs), ShouldEqual, 1)
+
+			errs = validateAttributeValue("a", "true", boolean, nil, false, 0)
+			So(len(errs), ShouldEqual, 1)
+
+			errs = validateAttributeValue("a", []any{"x", 2, "z", false}, list, nil, false, 0)
+			So(len(errs), ShouldEqual, 2)
+			So(errs[0].Data, ShouldResemble, map[string]any{"attribute": "a[1]"})
+			So(errs[1].Data, ShouldResemble, map[string]any{"attribute": "a[3]"})
+
+			errs = validateAttributeValue("a", "x", object, nil, false, 0)
+			So(len(errs), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestValidation_validateIdentifiable(t *testing.T) {
+
+	Convey("Given I have a valid identifiable", t, func() {
+
+		req := elemental.NewRequest()
+		obj := testmodel.NewList()
+		obj.Name = "hello"
+
+		Convey("When I validate it without custom validators", func() {
+
+			err := validateIdentifiable(req, obj, nil)
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When I validate it with passing custom validators", func() {
+
+			var called *elemental.Request
+			err := validateIdentifiable(req, obj, []CustomValidator{
+				func(r *elemental.Request, o elemental.Identifiable) error { called = r; return nil },
+			})
+
+			Convey("Then err should be nil", func() {
+				So(err, ShouldBeNil)
+				So(called, ShouldEqual, req)
+			})
+		})
+
+		Convey("When I validate it with failing custom validators", func() {
+
+			err := validateIdentifiable(req, obj, []CustomValidator{
+				func(*elemental.Request, elemental.Identifiable) error { return errors.New("oops") },
+				func(*elemental.Request, elemental.Identifiable) error {
+					return makeAttributeError("name", "bad name")
+				},
+			})
+
+			Convey("Then err should contain all errors", func() {
+				So(err, ShouldHaveSameTypeAs, elemental.Errors{})
+				errs := err.(elemental.Errors)
+				So(len(errs), ShouldEqual, 2)
+				So(errs[0].Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(errs[0].Description, ShouldEqual, "oops")
+				So(errs[1].Data, ShouldResemble, map[string]any{"attribute": "name"})
+			})
+		})
+	})
+}
@@ -13,6 +13,7 @@ package bahamut
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -21,30 +22,37 @@ import (
 
 	"github.com/go-zoo/bone"
 	"github.com/gorilla/websocket"
+	"github.com/karlseguin/ccache/v2"
 	"go.aporeto.io/elemental"
 	"go.aporeto.io/wsc"
 	"go.uber.org/zap"
 )
 
+const publicationDedupTTL = 10 * time.Minute
+
+var errShouldPublish = errors.New("unable to check if event should be published")
+
 type pushServer struct {
-	sessionsLock    sync.RWMutex
-	mainContext     context.Context
-	sessions        map[string]*wsPushSession
-	multiplexer     *bone.Mux
-	processorFinder processorFinderFunc
-	publications    chan *Publication
-	cfg             config
+	sessionsLock     sync.RWMutex
+	mainContext      context.Context
+	sessions         map[string]*wsPushSession
+	multiplexer      *bone.Mux
+	processorFinder  processorFinderFunc
+	publications     chan *Publication
+	seenPublications *ccache.Cache
+	cfg              config
 }
 
 func newPushServer(cfg config, multiplexer *bone.Mux, processorFinder processorFinderFunc) *pushServer {
 
 	srv := &pushServer{
-		sessions:        map[string]*wsPushSession{},
-		multiplexer:     multiplexer,
-		cfg:             cfg,
-		sessionsLock:    sync.RWMutex{},
-		processorFinder: processorFinder,
-		publications:    make(chan *Publication, 24000),
+		sessions:         map[string]*wsPushSession{},
+		multiplexer:      multiplexer,
+		cfg:              cfg,
+		sessionsLock:     sync.RWMutex{},
+		processorFinder:  processorFinder,
+		publications:     make(chan *Publication, 24000),
+		seenPublications: ccache.New(ccache.Configure().MaxSize(65536)),
 	}
 
 	endpoint := cfg.pushServer.endpoint
@@ -155,58 +163,102 @@ func (n *pushServer) pushEvents(events ...*elemental.Event) {
 		return
 	}
 
-	var err error
+	// If we have an outbox, we store the events in it and let
+	// the relay publish them. If that fails, we try to publish
+	// them right away.
+	if outbox := n.cfg.pushServer.outbox; outbox != nil {
+
+		err := outbox.Add(context.Background(), events...)
+		if err == nil {
+			return
+		}
+
+		zap.L().Error("Unable to add events to push outbox. Publishing them directly", zap.Error(err))
+	}
 
 	for _, event := range events {
 
-		if n.cfg.pushServer.publishHandler != nil {
-			var ok bool
-			ok, err = n.cfg.pushServer.publishHandler.ShouldPublish(event)
-			if err != nil {
-				zap.L().Error("Error while calling ShouldPublish", zap.Error(err))
-				continue
-			}
+		err := n.publishEvent(context.Background(), "", event)
 
-			if !ok {
-				continue
-			}
+		switch {
+		case err == nil:
+		case errors.Is(err, errShouldPublish):
+			zap.L().Error("Error while calling ShouldPublish", zap.Error(err))
+		case errors.Is(err, ErrPubSubEncode):
+			zap.L().Error("Unable to encode event", zap.Error(err))
+			return
+		default:
+			zap.L().Warn("Unable to publish event", zap.String("topic", n.topicForEvent(event)), zap.Stringer("event", event), zap.Error(err))
 		}
+	}
+}
 
-		// if subject hierarchies are enabled, for the benefit of subscribers interested in specific identities, we utilize
-		// a subject hierarchy here to publish to a specific subject under the configured topic of the push server.
-		//
-		// for example:
-		//
-		//   if the push server topic has been set to "global-events" and the server is about to push a "create" event w/ an identity
-		//   value of "apples", enabling this option, would cause the push server to target a new publication to the subject
-		//   "global-events.apples.create", INSTEAD OF "global-events".
-		//
-		//   consequently, clients interested in receiving events pertaining to the "apples" resource can then subscribe
-		//   on that specific topic, as opposed to ignoring events they don't care about. For clients interested in receiving
-		//   ALL events published to "global-events", they can utilize NATS wildcards and subscribe to "global-events.>"
-		//   ('>' targets all hierarchies) or "global-events.*" ('*' matching a single token).
-		//
-		// more details: https://docs.nats.io/nats-concepts/subjects#subject-hierarchies
-		topic := n.cfg.pushServer.topic
-		if n.cfg.pushServer.subjectHierarchiesEnabled {
-			topic = fmt.Sprintf("%s.%s.%s", topic, event.Identity, event.Type)
-		}
+// publishEvent publishes the given event using the given deduplication id, if any.
+// It returns nil if the publish handler decided the event should not be published.
+func (n *pushServer) publishEvent(ctx context.Context, id string, event *elemental.Event) error {
 
-		publication := NewPublication(topic)
-		if err = publication.Encode(event); err != nil {
-			zap.L().Error("Unable to encode event", zap.Error(err))
-			break
+	if n.cfg.pushServer.publishHandler != nil {
+		ok, err := n.cfg.pushServer.publishHandler.ShouldPublish(event)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errShouldPublish, err)
 		}
 
-		for i := 0; i < 3; i++ {
-			err = n.cfg.pushServer.service.Publish(publication)
-			if err != nil {
-				zap.L().Warn("Unable to publish event", zap.String("topic", publication.Topic), zap.Stringer("event", event), zap.Error(err))
-				continue
-			}
-			break
+		if !ok {
+			return nil
 		}
 	}
+
+	topic := n.topicForEvent(event)
+
+	publication := NewPublication(topic)
+	publication.ID = id
+	if err := publication.Encode(event); err != nil {
+		return newPubSubError("publish", topic, ErrPubSubEncode, err)
+	}
+
+	return PublishWithRetry(ctx, n.cfg.pushServer.service, publication, DefaultPubSubRetryPolicy())
+}
+
+// topicForEvent returns the topic to use to publish the given event.
+func (n *pushServer) topicForEvent(event *elemental.Event) string {
+
+	// if subject hierarchies are enabled, for the benefit of subscribers interested in specific identities, we utilize
+	// a subject hierarchy here to publish to a specific subject under the configured topic of the push server.
+	//
+	// for example:
+	//
+	//   if the push server topic has been set to "global-events" and the server is about to push a "create" event w/ an identity
+	//   value of "apples", enabling this option, would cause the push server to target a new publication to the subject
+	//   "global-events.apples.create", INSTEAD OF "global-events".
+	//
+	//   consequently, clients interested in receiving events pertaining to the "apples" resource can then subscribe
+	//   on that specific topic, as opposed to ignoring events they don't care about. For clients interested in receiving
+	//   ALL events published to "global-events", they can utilize NATS wildcards and subscribe to "global-events.>"
+	//   ('>' targets all hierarchies) or "global-events.*" ('*' matching a single token).
+	//
+	// more details: https://docs.nats.io/nats-concepts/subjects#subject-hierarchies
+	if n.cfg.pushServer.subjectHierarchiesEnabled {
+		return fmt.Sprintf("%s.%s.%s", n.cfg.pushServer.topic, event.Identity, event.Type)
+	}
+
+	return n.cfg.pushServer.topic
+}
+
+// isDuplicate returns true if a publication with the same
+// deduplication ID has been received recently.
+func (n *pushServer) isDuplicate(publication *Publication) bool {
+
+	if publication.ID == "" {
+		return false
+	}
+
+	if item := n.seenPublications.Get(publication.ID); item != nil && !item.Expired() {
+		return true
+	}
+
+	n.seenPublications.Set(publication.ID, struct{}{}, publicationDedupTTL)
+
+	return false
 }
 
 func (n *pushServer) handleRequest(w http.ResponseWriter, r *http.Request) {
@@ -348,6 +400,10 @@ func (n *pushServer) start(ctx context.Context) {
 		}
 
 		defer n.cfg.pushServer.service.Subscribe(n.publications, errors, subTopic)()
+
+		if n.cfg.pushServer.outbox != nil {
+			go n.relayOutbox(ctx)
+		}
 	}
 
 	zap.L().Debug("Websocket server started",
@@ -361,6 +417,12 @@ func (n *pushServer) start(ctx context.Context) {
 
 		case p := <-n.publications:
 
+			// Publications coming from an outbox may be delivered
+			// more than once. We only dispatch them once.
+			if n.isDuplicate(p) {
+				continue
+			}
+
 			go func(publication *Publication) {
 
 				event := &elemental.Event{}
@@ -396,6 +458,19 @@ func (n *pushServer) start(ctx context.Context) {
 					}
 				}
 
+				// We extract the namespace of the entity if the namespaces are enforced.
+				var namespace string
+				if n.cfg.security.namespaceEnforcer != nil {
+					namespace, err = eventNamespace(event)
+					if err != nil {
+						zap.L().Error("Unable to extract event namespace",
+							zap.Stringer("event", event),
+							zap.Error(err),
+						)
+						return
+					}
+				}
+
 				// Keep a references to all current ready push sessions as it may change at any time, we lost 8h on this one...
 				n.sessionsLock.RLock()
 				sessions := make([]*wsPushSession, len(n.sessions))
@@ -442,6 +517,14 @@ func (n *pushServer) start(ctx context.Context) {
 						}
 					}
 
+					// If the event happened in a namespace the session
+					// is not allowed to see, we don't send it.
+					if enforcer := n.cfg.security.namespaceEnforcer; enforcer != nil && namespace != "" {
+						if !enforcer.matcher(session.Claims()).Match(namespace) {
+							continue
+						}
+					}
+
 					if n.cfg.pushServer.dispatchHandler != nil {
 						dispatch, err := n.cfg.pushServer.dispatchHandler.ShouldDispatch(session, event, eventSummary)
 						if err != nil {